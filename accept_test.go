@@ -0,0 +1,121 @@
+package fastnet
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	stdsync "sync"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+)
+
+// greeter：连接建立后立即发送一个字节
+type greeter struct{}
+
+func (s *greeter) OnConnect(c *connection.Connection) {
+	_ = c.Send([]byte("x"))
+}
+
+func (s *greeter) OnMessage(c *connection.Connection, ctx interface{}, data []byte) (out []byte) {
+	return
+}
+
+func (s *greeter) OnClose(c *connection.Connection) {}
+
+// burst：同时发起 n 个连接，返回每个连接从发起到收到第一个字节的耗时
+func burst(addr string, n int) ([]time.Duration, error) {
+	var (
+		wg   stdsync.WaitGroup
+		mu   stdsync.Mutex
+		errs []error
+	)
+	conns := make([]net.Conn, n)
+	latency := make([]time.Duration, n)
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			start := time.Now()
+			conn, err := net.DialTimeout("tcp", addr, 10*time.Second)
+			if err == nil {
+				conns[i] = conn
+				_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+				_, err = conn.Read(make([]byte, 1))
+			}
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+				return
+			}
+			latency[i] = time.Since(start)
+		}(i)
+	}
+	wg.Wait()
+	for _, conn := range conns {
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return latency, nil
+}
+
+func TestAcceptBatch(t *testing.T) {
+	s, err := NewServer(new(greeter),
+		Address(":1850"),
+		NumLoops(2),
+		AcceptBatch(64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	if _, err := burst("127.0.0.1:1850", 300); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func BenchmarkAcceptBurst(b *testing.B) {
+	for i, batch := range []int{1, 64} {
+		addr := ":" + strconv.Itoa(1851+i)
+		b.Run("batch="+strconv.Itoa(batch), func(b *testing.B) {
+			s, err := NewServer(new(greeter),
+				Address(addr),
+				NumLoops(4),
+				Preallocate(true),
+				MaxConnections(1000),
+				AcceptBatch(batch))
+			if err != nil {
+				b.Fatal(err)
+			}
+			go s.Start()
+			defer s.Stop()
+			time.Sleep(100 * time.Millisecond)
+
+			var all []time.Duration
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				latency, err := burst("127.0.0.1"+addr, 1000)
+				if err != nil {
+					b.Fatal(err)
+				}
+				all = append(all, latency...)
+				// 等待上一轮的连接全部关闭，归还到连接池
+				b.StopTimer()
+				for s.connPool.Active() > 0 {
+					time.Sleep(time.Millisecond)
+				}
+				b.StartTimer()
+			}
+			sort.Slice(all, func(i, j int) bool { return all[i] < all[j] })
+			b.ReportMetric(float64(all[len(all)/2].Microseconds()), "p50-first-byte-µs")
+			b.ReportMetric(float64(all[len(all)*99/100].Microseconds()), "p99-first-byte-µs")
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package fastnet
+
+import (
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/tool/sync/atomic"
+)
+
+// auditQueueSize：审计事件队列长度，队列满时丢弃事件
+const auditQueueSize = 4096
+
+// AuditEventType：审计事件类型
+type AuditEventType int
+
+const (
+	// AuditAccept：接受了新的连接
+	AuditAccept AuditEventType = iota + 1
+	// AuditReject：连接数达到上限，新连接被拒绝
+	AuditReject
+	// AuditConnect：连接加入事件循环并完成 OnConnect
+	AuditConnect
+	// AuditClose：连接关闭
+	AuditClose
+)
+
+// String：事件类型名称
+func (t AuditEventType) String() string {
+	switch t {
+	case AuditAccept:
+		return "accept"
+	case AuditReject:
+		return "reject"
+	case AuditConnect:
+		return "connect"
+	case AuditClose:
+		return "close"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText：以名称形式编码事件类型
+func (t AuditEventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// AuditEvent：一条连接生命周期审计记录
+type AuditEvent struct {
+	Type         AuditEventType `json:"type"`
+	Time         time.Time      `json:"time"`
+	ID           int64          `json:"id,omitempty"`            // 连接 ID，被拒绝的连接为 0
+	PeerAddr     string         `json:"peer"`                    // 对端地址
+	BytesRead    int64          `json:"bytes_read,omitempty"`    // 关闭时累计读取的字节数
+	BytesWritten int64          `json:"bytes_written,omitempty"` // 关闭时累计写出的字节数
+	Duration     time.Duration  `json:"duration,omitempty"`      // 关闭时连接的持续时间
+	Reason       string         `json:"reason,omitempty"`        // 关闭或拒绝的原因
+}
+
+// AuditLogger：审计日志输出，Audit 在独立的 goroutine 中按顺序调用，不会阻塞事件循环
+type AuditLogger interface {
+	Audit(e AuditEvent)
+}
+
+// auditor：异步分发审计事件
+type auditor struct {
+	sink    AuditLogger
+	events  chan AuditEvent
+	done    chan struct{}
+	dropped atomic.Int64
+}
+
+func newAuditor(sink AuditLogger) *auditor {
+	a := &auditor{
+		sink:   sink,
+		events: make(chan AuditEvent, auditQueueSize),
+		done:   make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+func (a *auditor) run() {
+	defer close(a.done)
+	for e := range a.events {
+		a.sink.Audit(e)
+	}
+}
+
+// emit：投递审计事件，队列满时丢弃并计数，不阻塞调用方
+func (a *auditor) emit(e AuditEvent) {
+	e.Time = time.Now()
+	select {
+	case a.events <- e:
+	default:
+		// 丢弃数为 2 的幂时输出日志，避免刷屏
+		if n := a.dropped.Add(1); n&(n-1) == 0 {
+			log.Error("[audit] queue full, dropped events:", n)
+		}
+	}
+}
+
+// connEvent：根据连接生成审计事件
+func (a *auditor) connEvent(t AuditEventType, c *connection.Connection) {
+	e := AuditEvent{Type: t, ID: c.ID(), PeerAddr: c.PeerAddr()}
+	if t == AuditClose {
+		e.BytesRead = c.BytesRead()
+		e.BytesWritten = c.BytesWritten()
+		e.Duration = time.Since(c.CreatedAt())
+		if reason := c.CloseReason(); reason != nil {
+			e.Reason = reason.Error()
+		} else {
+			e.Reason = "closed locally"
+		}
+	}
+	a.emit(e)
+}
+
+// close：所有事件循环退出后调用，等待剩余事件输出完成
+func (a *auditor) close() {
+	close(a.events)
+	<-a.done
+}
@@ -0,0 +1,76 @@
+package fastnet
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+type chanAuditLogger chan AuditEvent
+
+func (l chanAuditLogger) Audit(e AuditEvent) {
+	l <- e
+}
+
+func TestAuditSink(t *testing.T) {
+	events := make(chanAuditLogger, 16)
+	s, err := NewServer(new(example),
+		Network("tcp"),
+		Address(":1847"),
+		NumLoops(2),
+		MaxConnections(1),
+		AuditSink(events))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Start()
+	defer s.Stop()
+
+	next := func(expect AuditEventType) AuditEvent {
+		select {
+		case e := <-events:
+			if e.Type != expect {
+				t.Fatalf("expect %s event, but got %s", expect, e.Type)
+			}
+			return e
+		case <-time.After(time.Second * 3):
+			t.Fatalf("expect %s event, but got none", expect)
+		}
+		return AuditEvent{}
+	}
+
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:1847", time.Second*60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accept := next(AuditAccept)
+	connect := next(AuditConnect)
+	if accept.ID == 0 || accept.ID != connect.ID || accept.PeerAddr != conn.LocalAddr().String() {
+		t.Fatalf("unexpected accept/connect events: %+v %+v", accept, connect)
+	}
+
+	// 超过最大连接数的连接被拒绝
+	rejected, err := net.DialTimeout("tcp", "127.0.0.1:1847", time.Second*60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rejected.Close()
+	if e := next(AuditReject); e.PeerAddr != rejected.LocalAddr().String() {
+		t.Fatalf("unexpected reject event: %+v", e)
+	}
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(conn, make([]byte, 5)); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	e := next(AuditClose)
+	if e.ID != accept.ID || e.BytesRead != 5 || e.BytesWritten != 5 || e.Reason != io.EOF.Error() || e.Duration <= 0 {
+		t.Fatalf("unexpected close event: %+v", e)
+	}
+}
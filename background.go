@@ -0,0 +1,55 @@
+package fastnet
+
+import (
+	"runtime"
+	"strconv"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"github.com/Dongxiem/fastnet/log"
+	"golang.org/x/sys/unix"
+)
+
+// BackgroundNice：后台事件循环所在线程的 nice 值，数值越大调度优先级越低
+const BackgroundNice = 10
+
+// loopFor：为新连接选择事件循环，BackgroundLoops 的分类函数返回 true 时使用后台事件循环，只在主事件循环中调用
+func (s *Server) loopFor(fd int, sa unix.Sockaddr) *eventloop.EventLoop {
+	if loop := s.backgroundFor(fd, sa); loop != nil {
+		return loop
+	}
+	return s.nextLoop()
+}
+
+// backgroundFor：BackgroundLoops 的分类函数返回 true 时返回下一个后台事件循环，否则返回 nil
+func (s *Server) backgroundFor(fd int, sa unix.Sockaddr) *eventloop.EventLoop {
+	if len(s.backgroundLoops) > 0 && s.opts.Background != nil && s.opts.Background(fd, sa) {
+		i := s.nextBackgroundIndex.Add(1) - 1
+		return s.backgroundLoops[i%int64(len(s.backgroundLoops))]
+	}
+	return nil
+}
+
+// connLoops：处理连接的全部事件循环，包括 work 事件循环及后台事件循环
+func (s *Server) connLoops() []*eventloop.EventLoop {
+	if len(s.backgroundLoops) == 0 {
+		return s.workLoops
+	}
+	loops := make([]*eventloop.EventLoop, 0, len(s.workLoops)+len(s.backgroundLoops))
+	loops = append(loops, s.workLoops...)
+	return append(loops, s.backgroundLoops...)
+}
+
+// backgroundLoopLabel：第 i 个后台事件循环的标签值
+func backgroundLoopLabel(i int) string {
+	return "background-" + strconv.Itoa(i)
+}
+
+// runBackground：在独占的线程中以较低的调度优先级运行后台事件循环。
+// 线程退出前不会解除绑定，调低了优先级的线程随 goroutine 结束而销毁，不会回到 Go 运行时的线程池中被其他 goroutine 使用
+func runBackground(loop *eventloop.EventLoop) {
+	runtime.LockOSThread()
+	if err := unix.Setpriority(unix.PRIO_PROCESS, unix.Gettid(), BackgroundNice); err != nil {
+		log.Error("[background loop] setpriority:", err)
+	}
+	loop.RunLoop()
+}
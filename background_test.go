@@ -0,0 +1,81 @@
+package fastnet
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/eventloop"
+	"golang.org/x/sys/unix"
+)
+
+// loopsServing：loops 中处理对端地址为 peer 的连接的事件循环个数
+func loopsServing(loops []*eventloop.EventLoop, peer string) int {
+	n := 0
+	for _, l := range loops {
+		l.RangeSockets(func(fd int, sock eventloop.Socket) bool {
+			if c, ok := sock.(*connection.Connection); ok && c.PeerAddr() == peer {
+				n++
+			}
+			return true
+		})
+	}
+	return n
+}
+
+func TestServer_BackgroundLoops(t *testing.T) {
+	// 用对端的源端口区分 admin 连接与数据连接
+	laddr, err := net.ResolveTCPAddr("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.ListenTCP("tcp", laddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 取得一个空闲端口作为 admin 连接的源端口
+	adminPort := ln.Addr().(*net.TCPAddr).Port
+	_ = ln.Close()
+
+	handler := new(example)
+	s, err := NewServer(handler,
+		Address("127.0.0.1:0"),
+		NumLoops(2),
+		BackgroundLoops(1, func(fd int, sa unix.Sockaddr) bool {
+			return sa.(*unix.SockaddrInet4).Port == adminPort
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	dialer := net.Dialer{Timeout: time.Second, LocalAddr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: adminPort}}
+	admin, err := dialer.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer admin.Close()
+	data, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer data.Close()
+
+	expectEcho(t, admin, "admin")
+	expectEcho(t, data, "data")
+
+	if n := loopsServing(s.backgroundLoops, admin.LocalAddr().String()); n != 1 {
+		t.Fatalf("expect the admin connection on the background loop, but got %d", n)
+	}
+	if n := loopsServing(s.workLoops, admin.LocalAddr().String()); n != 0 {
+		t.Fatal("admin connection should not run on a work loop")
+	}
+	if n := loopsServing(s.workLoops, data.LocalAddr().String()); n != 1 {
+		t.Fatalf("expect the data connection on a work loop, but got %d", n)
+	}
+	if n := s.activeConnections(); n != 2 {
+		t.Fatalf("expect 2 active connections, but got %d", n)
+	}
+}
@@ -0,0 +1,12 @@
+package fastnet
+
+import "github.com/Dongxiem/fastnet/connection"
+
+// Broadcast：将 data 发送给所有满足 filter 的连接，filter 为 nil 时发送给全部连接，等待写出完成后返回各连接的写出结果。
+// 协议实现了 connection.ConnIndependentPacket 时 data 对每个协议只打包一次（DefaultProtocol、websocket 等），
+// 其余协议（如 plugins/secure、plugins/tls）仍对每个连接分别打包，写出方式与 connection.Broadcast 相同。
+// filter 在连接所属的事件循环中调用，已断开及广播过程中被关闭的连接会被跳过，UDP 模式下不发送。
+// Broadcast 会阻塞等待事件循环，不能在事件循环 goroutine（如 OnMessage）中调用，调用后不能再修改 data
+func (s *Server) Broadcast(data []byte, filter func(c *connection.Connection) bool) connection.BroadcastResult {
+	return connection.Fanout(s.connLoops(), data, filter)
+}
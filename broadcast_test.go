@@ -0,0 +1,51 @@
+package fastnet
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+)
+
+func TestServer_Broadcast(t *testing.T) {
+	handler := new(example)
+	s, err := NewServer(handler, Address("127.0.0.1:0"), NumLoops(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	const n = 4
+	conns := make([]net.Conn, n)
+	for i := range conns {
+		conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+	}
+	waitConnections(t, handler, n)
+
+	// 排除第一个连接的对端地址
+	excluded := conns[0].LocalAddr().String()
+	result := s.Broadcast([]byte("news"), func(c *connection.Connection) bool {
+		return c.PeerAddr() != excluded
+	})
+	if result.Immediate != n-1 || result.Total() != n-1 {
+		t.Fatalf("expect %d immediate writes, but got %+v", n-1, result)
+	}
+	for _, conn := range conns[1:] {
+		buf := make([]byte, 4)
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := conn.Read(buf); err != nil || string(buf) != "news" {
+			t.Fatalf("expect broadcast data, but got %q, %v", buf, err)
+		}
+	}
+	_ = conns[0].SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := conns[0].Read(make([]byte, 4)); err == nil {
+		t.Fatal("expect the filtered connection to receive nothing")
+	}
+}
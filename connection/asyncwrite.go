@@ -0,0 +1,93 @@
+package connection
+
+// writeCallback：AsyncWrite 等待的写出位置，累计写出的字节数达到 target 时回调
+type writeCallback struct {
+	target int64
+	cb     func(err error)
+}
+
+// AsyncWrite：与 Send 相同，经过协议打包后发送 data，不同的是 data 全部写入 socket 后在事件循环中回调 cb(nil)，
+// 可以据此得知响应已离开 outBuffer，实现按写出进度推进的请求/响应流水线。
+// outBuffer 有积压时 data 排在积压的数据之后，多次 AsyncWrite 的回调按调用顺序执行。
+// 连接在写出之前关闭时以关闭的原因（主动关闭时为 ErrConnectionClosed）回调，
+// 数据因 outBuffer 达到上限被丢弃时以 ErrWriteBufferFull 回调。
+// 调用时连接已关闭或被 Block 策略拒绝时直接返回错误，不会回调 cb
+func (c *Connection) AsyncWrite(data []byte, cb func(err error)) error {
+	if c.udp {
+		return ErrUDPNotSupported
+	}
+	if !c.connected.Get() {
+		return c.closedError()
+	}
+	if c.sendRefused(len(data)) {
+		return ErrWriteBufferFull
+	}
+
+	generation := c.generation.Get()
+	c.loop.QueueInLoop(func() {
+		if c.generation.Get() != generation || !c.connected.Get() {
+			cb(c.closedError())
+			return
+		}
+		c.asyncWriteInLoop(data, cb)
+	})
+	return nil
+}
+
+func (c *Connection) asyncWriteInLoop(data []byte, cb func(err error)) {
+	switch c.sendInLoop(c.protocol.Packet(c, data)) {
+	case writeDone:
+		cb(nil)
+	case writeBuffered:
+		// 此时 outBuffer 中的数据全部写出即表示 data 写出
+		target := c.bytesWritten.Get() + int64(c.outBuffer.Length())
+		c.writeCallbacks = append(c.writeCallbacks, writeCallback{target: target, cb: cb})
+	case writeDropped:
+		cb(ErrWriteBufferFull)
+	default:
+		// 写出出错，连接已经关闭
+		cb(c.writeError())
+	}
+}
+
+// notifyWritten：handleWrite 写出数据后按顺序回调已经写出的 AsyncWrite
+func (c *Connection) notifyWritten() {
+	written := c.bytesWritten.Get()
+	n := 0
+	for n < len(c.writeCallbacks) && c.writeCallbacks[n].target <= written {
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	done := c.writeCallbacks[:n]
+	c.writeCallbacks = c.writeCallbacks[n:]
+	for i := range done {
+		done[i].cb(nil)
+		done[i] = writeCallback{}
+	}
+	if len(c.writeCallbacks) == 0 {
+		c.writeCallbacks = nil
+	}
+}
+
+// failWriteCallbacks：连接关闭时以关闭的原因回调尚未写出的 AsyncWrite
+func (c *Connection) failWriteCallbacks() {
+	if len(c.writeCallbacks) == 0 {
+		return
+	}
+	pending := c.writeCallbacks
+	c.writeCallbacks = nil
+	err := c.writeError()
+	for i := range pending {
+		pending[i].cb(err)
+	}
+}
+
+// writeError：连接关闭导致数据未能写出时回调的错误
+func (c *Connection) writeError() error {
+	if c.closeReason != nil {
+		return c.closeReason
+	}
+	return c.closedError()
+}
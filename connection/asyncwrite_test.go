@@ -0,0 +1,97 @@
+package connection
+
+import (
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestConnection_AsyncWrite(t *testing.T) {
+	c, peer, loop, _ := newRunningConnection(t, &DefaultProtocol{})
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	// outBuffer 为空时写入 socket 后立即回调
+	done := make(chan error, 3)
+	if err := c.AsyncWrite([]byte("x"), func(err error) { done <- err }); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// 对端不读取，数据积压在 outBuffer 中，回调等到写出之后
+	big := make([]byte, 4<<20)
+	order := make(chan int, 2)
+	if err := c.Send(big); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		i := i
+		if err := c.AsyncWrite([]byte("tail"), func(err error) {
+			order <- i
+			done <- err
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if outBufferLength(c) == 0 {
+		t.Fatal("expect data buffered in outBuffer")
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("callback should wait for the data to be written, but got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	want := int64(1 + len(big) + 2*len("tail"))
+	if err := unix.SetNonblock(peer, false); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := io.CopyN(ioutil.Discard, fdReader(peer), want); err != nil || n != want {
+		t.Fatalf("expect %d bytes, but got %d, %v", want, n, err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := <-order; got != i {
+				t.Fatalf("expect callback %d, but got %d", i, got)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("callback should be called after the data is written")
+		}
+	}
+}
+
+func TestConnection_AsyncWriteClosed(t *testing.T) {
+	c, peer, loop, closed := newRunningConnection(t, &DefaultProtocol{})
+	defer loop.Stop()
+
+	if err := c.Send(make([]byte, 4<<20)); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	if err := c.AsyncWrite([]byte("tail"), func(err error) { done <- err }); err != nil {
+		t.Fatal(err)
+	}
+	_ = c.Close()
+	_ = unix.Close(peer)
+	waitCloseReason(t, closed)
+	select {
+	case err := <-done:
+		if err != ErrConnectionClosed {
+			t.Fatalf("expect ErrConnectionClosed, but got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("callback should be called when the connection closes")
+	}
+	if err := c.AsyncWrite([]byte("late"), func(error) {}); err != ErrConnectionClosed {
+		t.Fatalf("expect ErrConnectionClosed, but got %v", err)
+	}
+}
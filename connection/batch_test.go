@@ -0,0 +1,92 @@
+package connection
+
+import (
+	"bufio"
+	"strconv"
+	"testing"
+
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+	"golang.org/x/sys/unix"
+)
+
+// batchEchoCallBack：批量回显，记录每次 OnMessages 收到的消息数
+type batchEchoCallBack struct {
+	echoCallBack
+	batches []int
+	out     [][]byte
+}
+
+func (e *batchEchoCallBack) OnMessages(c *Connection, msgs []Message) [][]byte {
+	e.batches = append(e.batches, len(msgs))
+	e.out = e.out[:0]
+	for _, msg := range msgs {
+		e.out = append(e.out, msg.Data)
+	}
+	return e.out
+}
+
+func TestConnection_OnMessages(t *testing.T) {
+	fd, peer := newSocketPair(t)
+	defer unix.Close(fd)
+	defer unix.Close(peer)
+
+	cb := &batchEchoCallBack{}
+	c := newTestConnectionWith(t, fd, &lineProtocol{}, cb)
+	c.sendBuffersInLoop(c.handlerProtocol(ringbuffer.NewWithData(pipelinedRequests(100))))
+
+	if len(cb.batches) != 1 || cb.batches[0] != 100 {
+		t.Fatalf("expect one batch of 100 messages, but got %v", cb.batches)
+	}
+	f := bufio.NewReader(fdReader(peer))
+	for i := 0; i < 100; i++ {
+		line, err := f.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != strconv.Itoa(i)+"\n" {
+			t.Fatalf("expect %d, but got %q", i, line)
+		}
+	}
+
+	// 没有完整消息时不调用 OnMessages
+	c.handlerProtocol(ringbuffer.NewWithData([]byte("partial")))
+	if len(cb.batches) != 1 {
+		t.Fatalf("OnMessages should not be called without messages, but got %v", cb.batches)
+	}
+}
+
+func benchmarkPipelined(b *testing.B, callBack CallBack) {
+	fd, peer := newSocketPair(b)
+	defer unix.Close(fd)
+	defer unix.Close(peer)
+
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			if _, err := unix.Read(peer, buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	if err := unix.SetNonblock(fd, false); err != nil {
+		b.Fatal(err)
+	}
+	c := newTestConnectionWith(b, fd, &lineProtocol{}, callBack)
+	requests := pipelinedRequests(1000)
+	data := make([]byte, len(requests))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(data, requests)
+		c.sendBuffersInLoop(c.handlerProtocol(ringbuffer.NewWithData(data)))
+	}
+}
+
+func BenchmarkConnection_OnMessage(b *testing.B) {
+	benchmarkPipelined(b, &echoCallBack{})
+}
+
+func BenchmarkConnection_OnMessages(b *testing.B) {
+	benchmarkPipelined(b, &batchEchoCallBack{})
+}
@@ -0,0 +1,188 @@
+package connection
+
+import (
+	"sync"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+)
+
+// BroadcastResult：一次广播中各连接的写出结果统计
+type BroadcastResult struct {
+	Immediate int // 数据已全部写入 socket 的连接数
+	Buffered  int // 数据部分或全部暂存在 outBuffer 中的连接数，即消费较慢、出现积压的连接
+	Failed    int // 已关闭或写出错的连接数
+}
+
+// Total：参与广播的连接总数
+func (r BroadcastResult) Total() int {
+	return r.Immediate + r.Buffered + r.Failed
+}
+
+func (r *BroadcastResult) merge(o BroadcastResult) {
+	r.Immediate += o.Immediate
+	r.Buffered += o.Buffered
+	r.Failed += o.Failed
+}
+
+func (r *BroadcastResult) add(res writeResult) {
+	switch res {
+	case writeDone:
+		r.Immediate++
+	case writeBuffered:
+		r.Buffered++
+	default:
+		r.Failed++
+	}
+}
+
+// Broadcast：将 data 经过各连接的协议打包后发送给 conns 中的所有连接，
+// 按所属事件循环分组，每个事件循环只投递一次任务，等待所有事件循环写出完成后返回各连接的写出结果。
+// 实现了 ConnIndependentPacket 的协议对 data 只打包一次，其余协议对每个连接分别打包。
+// 所属事件循环已经停止或在写出前停止的连接计为失败。
+// Broadcast 会阻塞等待事件循环，不能在事件循环 goroutine（如 OnMessage）中调用
+func Broadcast(conns []*Connection, data []byte) BroadcastResult {
+	var result BroadcastResult
+	var loops []*eventloop.EventLoop
+	groups := make(map[*eventloop.EventLoop][]broadcastTarget)
+	for _, c := range conns {
+		// 先记录复用次数，之后连接被连接池复用给新的客户端时不会收到这次广播
+		generation := c.generation.Get()
+		if !c.Connected() || c.udp {
+			result.Failed++
+			continue
+		}
+		if _, ok := groups[c.loop]; !ok {
+			loops = append(loops, c.loop)
+		}
+		groups[c.loop] = append(groups[c.loop], broadcastTarget{c: c, generation: generation})
+	}
+
+	packets := &packetCache{data: data}
+	result.merge(inLoops(loops, func(loop *eventloop.EventLoop) BroadcastResult {
+		return packets.send(groups[loop])
+	}, func(loop *eventloop.EventLoop) int {
+		return len(groups[loop])
+	}))
+	return result
+}
+
+// Fanout：将 data 发送给 loops 中满足 filter 的所有连接，filter 为 nil 时发送给全部连接，等待写出完成后返回写出结果。
+// 在各事件循环中遍历连接并调用 filter，跳过已关闭的连接及 UDP 连接，打包方式与 Broadcast 相同。
+// 已经停止的事件循环中的连接不计入结果。与 Broadcast 一样不能在事件循环 goroutine 中调用，调用后不能再修改 data
+func Fanout(loops []*eventloop.EventLoop, data []byte, filter func(c *Connection) bool) BroadcastResult {
+	packets := &packetCache{data: data}
+	return inLoops(loops, func(loop *eventloop.EventLoop) BroadcastResult {
+		var group []broadcastTarget
+		loop.RangeSockets(func(fd int, s eventloop.Socket) bool {
+			c, ok := s.(*Connection)
+			if !ok || c.udp || !c.connected.Get() {
+				return true
+			}
+			if filter != nil && !filter(c) {
+				return true
+			}
+			group = append(group, broadcastTarget{c: c, generation: c.generation.Get()})
+			return true
+		})
+		return packets.send(group)
+	}, func(loop *eventloop.EventLoop) int {
+		return 0
+	})
+}
+
+// broadcastTarget：参与广播的连接及选中时的复用次数
+type broadcastTarget struct {
+	c          *Connection
+	generation int64
+}
+
+// inLoops：在每个事件循环中执行一次 run 并汇总结果。事件循环已经停止或在执行前停止时不再等待，
+// 以 skipped 返回的连接数计为失败
+func inLoops(loops []*eventloop.EventLoop, run func(loop *eventloop.EventLoop) BroadcastResult, skipped func(loop *eventloop.EventLoop) int) BroadcastResult {
+	var result BroadcastResult
+	results := make([]chan BroadcastResult, len(loops))
+	for i, loop := range loops {
+		if loop.Stopped() {
+			result.Failed += skipped(loop)
+			continue
+		}
+		loop := loop
+		ch := make(chan BroadcastResult, 1)
+		results[i] = ch
+		loop.QueueInLoop(func() {
+			ch <- run(loop)
+		})
+	}
+
+	for i, ch := range results {
+		if ch == nil {
+			continue
+		}
+		select {
+		case r := <-ch:
+			result.merge(r)
+		case <-loops[i].Done():
+			// 停止前已经执行完的任务仍然计入其结果
+			select {
+			case r := <-ch:
+				result.merge(r)
+			default:
+				result.Failed += skipped(loops[i])
+			}
+		}
+	}
+	return result
+}
+
+// packetCache：一次广播中按协议缓存的打包结果，只缓存实现了 ConnIndependentPacket 的协议，由多个事件循环并发访问
+type packetCache struct {
+	data []byte
+
+	mu        sync.Mutex
+	protocols []Protocol
+	packets   [][]byte
+}
+
+// send：在事件循环中将打包后的数据写给 group 中的连接，已关闭或已被复用的连接计为失败
+func (p *packetCache) send(group []broadcastTarget) BroadcastResult {
+	var r BroadcastResult
+	// 同一事件循环中的连接通常使用同一协议，缓存上一次的结果避免反复加锁
+	var (
+		last   Protocol
+		cached []byte
+	)
+	for _, t := range group {
+		c := t.c
+		if c.generation.Get() != t.generation || !c.connected.Get() {
+			r.Failed++
+			continue
+		}
+		var packet []byte
+		if _, shared := c.protocol.(ConnIndependentPacket); shared {
+			if cached == nil || c.protocol != last {
+				last, cached = c.protocol, p.get(c)
+			}
+			packet = cached
+		} else {
+			packet = c.protocol.Packet(c, p.data)
+		}
+		// 写出出错时连接在 sendInLoop 中关闭，不影响其余连接
+		r.add(c.sendInLoop(packet))
+	}
+	return r
+}
+
+// get：返回 c 的协议打包后的数据，每个协议只打包一次
+func (p *packetCache) get(c *Connection) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, proto := range p.protocols {
+		if proto == c.protocol {
+			return p.packets[i]
+		}
+	}
+	packet := c.protocol.Packet(c, p.data)
+	p.protocols = append(p.protocols, c.protocol)
+	p.packets = append(p.packets, packet)
+	return packet
+}
@@ -0,0 +1,234 @@
+package connection
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"github.com/Dongxiem/fastnet/tool/sync/atomic"
+	"golang.org/x/sys/unix"
+)
+
+func TestBroadcast(t *testing.T) {
+	var loops []*eventloop.EventLoop
+	for i := 0; i < 2; i++ {
+		loop, err := eventloop.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		go loop.RunLoop()
+		defer loop.Stop()
+		loops = append(loops, loop)
+	}
+
+	// 大于 socket 发送缓冲区的数据，对端不读取时必然积压
+	data := bytes.Repeat([]byte("a"), 1<<20)
+
+	var conns []*Connection
+	var fastPeers []int
+	newConn := func(i int) (*Connection, int) {
+		fd, peer := newSocketPair(t)
+		c := New(fd, loops[i%len(loops)], nil, &DefaultProtocol{}, nil, 0, &emptyCallBack{})
+		if err := loops[i%len(loops)].AddSocketAndEnableRead(fd, c); err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, c)
+		return c, peer
+	}
+
+	// 3 个正常读取的连接
+	for i := 0; i < 3; i++ {
+		_, peer := newConn(i)
+		fastPeers = append(fastPeers, peer)
+		go func(peer int) {
+			buf := make([]byte, 64*1024)
+			for {
+				if n, err := unix.Read(peer, buf); n <= 0 || err != nil {
+					return
+				}
+			}
+		}(peer)
+	}
+	// 2 个不读取的连接
+	for i := 0; i < 2; i++ {
+		_, peer := newConn(i)
+		defer unix.Close(peer)
+	}
+	// 1 个已关闭的连接
+	closed, peer := newConn(0)
+	defer unix.Close(peer)
+	_ = closed.Close()
+	<-closed.Done()
+
+	// 正常读取的连接在 writev 过程中可能因为对端读取不及时而短暂积压，
+	// 使用小数据确认其能立即写出
+	result := Broadcast(conns[:3], []byte("hello"))
+	if result.Immediate != 3 || result.Total() != 3 {
+		t.Fatalf("expect 3 immediate writes, but got %+v", result)
+	}
+
+	result = Broadcast(conns, data)
+	if result.Total() != 6 || result.Failed != 1 || result.Buffered < 2 {
+		t.Fatalf("unexpected broadcast result: %+v", result)
+	}
+	for _, c := range conns[3:5] {
+		// outBuffer 只能在事件循环中访问
+		buffered := make(chan int, 1)
+		c.loop.QueueInLoop(func() {
+			buffered <- c.outBuffer.Length()
+		})
+		if <-buffered == 0 {
+			t.Fatal("stalled connection should buffer the broadcast data")
+		}
+	}
+
+	for _, peer := range fastPeers {
+		_ = unix.Close(peer)
+	}
+}
+
+// countingProtocol：统计 Packet 的调用次数
+type countingProtocol struct {
+	DefaultProtocol
+	packets atomic.Int64
+}
+
+func (p *countingProtocol) Packet(c *Connection, data []byte) []byte {
+	p.packets.Add(1)
+	return append([]byte("> "), data...)
+}
+
+func TestFanout(t *testing.T) {
+	var loops []*eventloop.EventLoop
+	for i := 0; i < 2; i++ {
+		loop, err := eventloop.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		go loop.RunLoop()
+		defer loop.Stop()
+		loops = append(loops, loop)
+	}
+
+	protos := []*countingProtocol{{}, {}}
+	var peers []int
+	var skipped *Connection
+	var skippedPeer int
+	for i := 0; i < 6; i++ {
+		fd, peer := newSocketPair(t)
+		defer unix.Close(peer)
+		c := New(fd, loops[i%len(loops)], nil, protos[i/3], nil, 0, &emptyCallBack{})
+		if err := loops[i%len(loops)].AddSocketAndEnableRead(fd, c); err != nil {
+			t.Fatal(err)
+		}
+		if i == 5 {
+			skipped, skippedPeer = c, peer
+			continue
+		}
+		peers = append(peers, peer)
+	}
+	// 广播前关闭的连接
+	fd, peer := newSocketPair(t)
+	defer unix.Close(peer)
+	closed := New(fd, loops[0], nil, protos[0], nil, 0, &emptyCallBack{})
+	if err := loops[0].AddSocketAndEnableRead(fd, closed); err != nil {
+		t.Fatal(err)
+	}
+	_ = closed.Close()
+	<-closed.Done()
+
+	result := Fanout(loops, []byte("hi"), func(c *Connection) bool { return c != skipped })
+	if result.Immediate != len(peers) || result.Total() != len(peers) {
+		t.Fatalf("expect %d immediate writes, but got %+v", len(peers), result)
+	}
+	for _, peer := range peers {
+		buf := make([]byte, 16)
+		n, err := unix.Read(peer, buf)
+		if err != nil || string(buf[:n]) != "> hi" {
+			t.Fatalf("expect packeted broadcast, but got %q, %v", buf[:n], err)
+		}
+	}
+	// 所有事件循环都已处理广播，被 filter 排除的连接不应收到数据
+	if n, _, err := unix.Recvfrom(skippedPeer, make([]byte, 16), unix.MSG_DONTWAIT); err != unix.EAGAIN {
+		t.Fatalf("expect no data for the filtered connection, but got %d bytes, %v", n, err)
+	}
+	for i, p := range protos {
+		if n := p.packets.Get(); n != 1 {
+			t.Fatalf("expect protocol %d to packet once, but got %d", i, n)
+		}
+	}
+}
+
+func TestBroadcast_LoopStopped(t *testing.T) {
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, peer := newSocketPair(t)
+	defer unix.Close(peer)
+	c := New(fd, loop, nil, &DefaultProtocol{}, nil, 0, &emptyCallBack{})
+	if err := loop.AddSocketAndEnableRead(fd, c); err != nil {
+		t.Fatal(err)
+	}
+
+	// 事件循环没有运行，投递的任务在 Stop 之前不会执行，Stop 之后不能一直等待
+	done := make(chan BroadcastResult, 1)
+	go func() {
+		done <- Broadcast([]*Connection{c}, []byte("hi"))
+	}()
+	time.Sleep(time.Millisecond * 20)
+	_ = loop.Stop()
+	select {
+	case result := <-done:
+		if result.Failed != 1 || result.Total() != 1 {
+			t.Fatalf("expect the connection on the stopped loop to fail, but got %+v", result)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Broadcast should return after the loop stops")
+	}
+
+	// 已经停止的事件循环直接跳过
+	if result := Broadcast([]*Connection{c}, []byte("hi")); result.Failed != 1 {
+		t.Fatalf("expect the connection to fail, but got %+v", result)
+	}
+}
+
+func TestBroadcast_PooledConnection(t *testing.T) {
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loop.Stop()
+	p := NewPool(1, true)
+	fd, peer := newSocketPair(t)
+	defer unix.Close(peer)
+	c := p.Get(fd, loop, nil, &DefaultProtocol{}, nil, 0, &emptyCallBack{})
+
+	done := make(chan BroadcastResult, 1)
+	go func() {
+		done <- Broadcast([]*Connection{c}, []byte("stale"))
+	}()
+	for loop.QueueLength() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 广播执行之前连接关闭并被复用给新的客户端
+	c.connected.Set(false)
+	c.cancel()
+	p.put(c)
+	fd, peer = newSocketPair(t)
+	defer unix.Close(peer)
+	if p.Get(fd, loop, nil, &DefaultProtocol{}, nil, 0, &emptyCallBack{}) != c {
+		t.Fatal("connection should be reused")
+	}
+	go loop.RunLoop()
+
+	if result := <-done; result.Failed != 1 || result.Total() != 1 {
+		t.Fatalf("expect the reused connection to be skipped, but got %+v", result)
+	}
+	_ = unix.SetNonblock(peer, true)
+	if n, err := unix.Read(peer, make([]byte, 8)); err != unix.EAGAIN {
+		t.Fatalf("new client should not receive the broadcast, but read %d bytes", n)
+	}
+}
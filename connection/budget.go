@@ -0,0 +1,67 @@
+package connection
+
+import (
+	"github.com/Dongxiem/fastnet/tool/sync/atomic"
+)
+
+// BufferBudget：所有连接读写缓冲区（inBuffer、outBuffer）容量之和的全局上限，
+// 用于在大量慢连接积压数据时限制整个进程的缓冲区内存
+type BufferBudget struct {
+	max  int64
+	used atomic.Int64
+}
+
+// NewBufferBudget：创建缓冲区预算，max 为所有连接缓冲区容量之和的上限（字节）
+func NewBufferBudget(max int64) *BufferBudget {
+	return &BufferBudget{max: max}
+}
+
+// Used：当前所有连接缓冲区容量之和
+func (b *BufferBudget) Used() int64 {
+	return b.used.Get()
+}
+
+// Max：缓冲区容量之和的上限
+func (b *BufferBudget) Max() int64 {
+	return b.max
+}
+
+// Exceeded：是否已经达到上限，达到上限时 Server 不再接受新连接
+func (b *BufferBudget) Exceeded() bool {
+	return b.used.Get() >= b.max
+}
+
+// reserveBuffers：连接建立时将初始的缓冲区容量计入预算，此时不检查上限，由 Server 在接受连接前检查
+func (c *Connection) reserveBuffers() {
+	if c.budget == nil {
+		return
+	}
+	c.budgetUsed = int64(c.inBuffer.Capacity() + c.outBuffer.Capacity())
+	c.budget.used.Add(c.budgetUsed)
+}
+
+// accountBuffers：缓冲区容量变化后更新预算，扩容超出预算时关闭导致扩容的连接，收缩时归还释放的容量。
+// 缓冲区只在读写时扩容或自动收缩（见 ringbuffer.Shrink），因此在读写操作之后调用即可
+func (c *Connection) accountBuffers() {
+	if c.budget == nil || !c.connected.Get() {
+		return
+	}
+	size := int64(c.inBuffer.Capacity() + c.outBuffer.Capacity())
+	delta := size - c.budgetUsed
+	if delta == 0 {
+		return
+	}
+	c.budgetUsed = size
+	if c.budget.used.Add(delta) > c.budget.max && delta > 0 {
+		c.closeWithReason(c.fd, ErrBufferBudgetExceeded)
+	}
+}
+
+// releaseBuffers：连接关闭时归还占用的预算
+func (c *Connection) releaseBuffers() {
+	if c.budget == nil || c.budgetUsed == 0 {
+		return
+	}
+	c.budget.used.Add(-c.budgetUsed)
+	c.budgetUsed = 0
+}
@@ -0,0 +1,104 @@
+package connection
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"golang.org/x/sys/unix"
+)
+
+func TestBufferBudget(t *testing.T) {
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go loop.RunLoop()
+
+	budget := NewBufferBudget(1 << 62)
+	cb := &closeCallBack{closed: make(chan error, 8)}
+	var conns []*Connection
+	for i := 0; i < 8; i++ {
+		// 对端从不读取，发送的数据全部积压在 outBuffer 中
+		fd, peer := newSocketPair(t)
+		defer unix.Close(peer)
+		c := New(fd, loop, nil, &DefaultProtocol{}, nil, 0, cb, WithBufferBudget(budget))
+		if err := loop.AddSocketAndEnableRead(fd, c); err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, c)
+	}
+	if budget.Used() == 0 {
+		t.Fatal("expect initial buffers to be accounted")
+	}
+	// 复用的缓冲区容量不固定，在初始占用之上再留出 4MB 的余量
+	budget.max = budget.Used() + 4<<20
+
+	// 持续发送直到有缓冲区扩容超出预算
+	data := bytes.Repeat([]byte("a"), 1<<20)
+	for i := 0; i < 64; i++ {
+		if result := Broadcast(conns, data); result.Failed > 0 {
+			break
+		}
+	}
+	if budget.Used() > budget.Max() {
+		t.Fatalf("budget should be enforced, but used %d > %d", budget.Used(), budget.Max())
+	}
+	closed := 0
+	for len(cb.closed) > 0 {
+		if reason := <-cb.closed; !errors.Is(reason, ErrBufferBudgetExceeded) {
+			t.Fatalf("expect ErrBufferBudgetExceeded, but got %v", reason)
+		}
+		closed++
+	}
+	if closed == 0 {
+		t.Fatal("expect some connections closed by the budget")
+	}
+
+	// 关闭所有连接后预算全部归还
+	if err := loop.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if budget.Used() != 0 {
+		t.Fatalf("budget should be released after close, but used %d", budget.Used())
+	}
+}
+
+// TestBufferBudget_Shrink：缓冲区在数据量持续较低后自动收缩，收缩释放的容量归还到预算
+func TestBufferBudget_Shrink(t *testing.T) {
+	budget := NewBufferBudget(1 << 62)
+	_, peer, loop := newRunningConnectionWith(t, &lineProtocol{}, &emptyCallBack{}, WithBufferBudget(budget))
+	defer unix.Close(peer)
+	defer loop.Stop()
+	initial := budget.Used()
+
+	waitUsed := func(cond func(used int64) bool) int64 {
+		deadline := time.Now().Add(time.Second * 3)
+		for !cond(budget.Used()) {
+			if time.Now().After(deadline) {
+				t.Fatalf("unexpected budget usage %d, initial %d", budget.Used(), initial)
+			}
+			time.Sleep(time.Millisecond)
+		}
+		return budget.Used()
+	}
+
+	// 没有换行的大消息使 inBuffer 扩容
+	written := make(chan struct{})
+	go func() {
+		_, _ = unix.Write(peer, bytes.Repeat([]byte("a"), 256*1024))
+		close(written)
+	}()
+	<-written
+	grown := waitUsed(func(used int64) bool { return used >= initial+128*1024 })
+
+	// 消息处理完后持续收到小消息，inBuffer 收缩
+	if _, err := unix.Write(peer, append([]byte("\n"), bytes.Repeat([]byte("x\n"), 128)...)); err != nil {
+		t.Fatal(err)
+	}
+	if used := waitUsed(func(used int64) bool { return used < grown }); used > initial*2 {
+		t.Fatalf("expect the shrunk buffer to be credited back, but used %d, initial %d", used, initial)
+	}
+}
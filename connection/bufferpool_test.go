@@ -0,0 +1,37 @@
+package connection
+
+import (
+	"testing"
+
+	"github.com/Dongxiem/fastnet/tool/ringbuffer/pool"
+	"golang.org/x/sys/unix"
+)
+
+func TestConnection_WithBufferPool(t *testing.T) {
+	large := pool.New(64 * 1024)
+	small := pool.New(256)
+
+	fd, peer := newSocketPair(t)
+	defer unix.Close(peer)
+	c := newTestConnectionWith(t, fd, &DefaultProtocol{}, &emptyCallBack{}, WithBufferPool(large))
+	if c.inBuffer.Capacity() != 64*1024 || c.outBuffer.Capacity() != 64*1024 {
+		t.Fatalf("expect buffers from the large pool, but got %d/%d", c.inBuffer.Capacity(), c.outBuffer.Capacity())
+	}
+
+	fd2, peer2 := newSocketPair(t)
+	defer unix.Close(peer2)
+	c2 := newTestConnectionWith(t, fd2, &DefaultProtocol{}, &emptyCallBack{}, WithBufferPool(small))
+	if c2.inBuffer.Capacity() != 256 || c2.outBuffer.Capacity() != 256 {
+		t.Fatalf("expect buffers from the small pool, but got %d/%d", c2.inBuffer.Capacity(), c2.outBuffer.Capacity())
+	}
+
+	// 关闭后缓冲区归还到各自的连接池
+	in := c.inBuffer
+	c.handleClose(fd)
+	if r := large.Get(); r != in {
+		t.Fatal("expect buffer returned to the assigned pool")
+	}
+	if r := pool.Get(); r == in {
+		t.Fatal("buffer should not be returned to the default pool")
+	}
+}
@@ -1,96 +1,422 @@
 package connection
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"io"
 	"net"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/Dongxiem/fastnet/eventloop"
 	"github.com/Dongxiem/fastnet/log"
 	"github.com/Dongxiem/fastnet/poller"
+	"github.com/Dongxiem/fastnet/tool/clock"
 	"github.com/Dongxiem/fastnet/tool/ringbuffer"
 	"github.com/Dongxiem/fastnet/tool/ringbuffer/pool"
 	"github.com/Dongxiem/fastnet/tool/sync/atomic"
 	"github.com/RussellLuo/timingwheel"
-	"github.com/gobwas/pool/pbytes"
 	"golang.org/x/sys/unix"
 )
 
-// CallBack : 回调接口
+// CallBack : 回调接口，OnConnect 在连接加入所属事件循环之后、处理任何读事件之前回调一次，
+// 与 OnMessage、OnClose 一样在该事件循环的 goroutine 中调用，因此可以直接调用 WriteBack、SendInLoop 等只能在事件循环中调用的方法
 type CallBack interface {
+	OnConnect(c *Connection)
 	OnMessage(c *Connection, ctx interface{}, data []byte) []byte
 	OnClose(c *Connection)
 }
 
-// Connection：TCP 连接结构体
+// Message：一次读事件中拆包得到的一条消息
+type Message struct {
+	Ctx  interface{}
+	Data []byte
+}
+
+// BatchCallBack：可选的回调接口，实现后一次读事件中拆包得到的所有消息通过 OnMessages 一次性交给回调处理，
+// 替代逐条调用 OnMessage，返回的每段数据分别经过协议打包后按序发送。
+// msgs 及其中的 Data 在 OnMessages 返回后可能被复用，需要保留时应自行拷贝
+type BatchCallBack interface {
+	OnMessages(c *Connection, msgs []Message) [][]byte
+}
+
+// WritableCallBack：可选的回调接口，通过 EnableWrite 关注可写事件后，outBuffer 为空且 socket 可写时调用
+type WritableCallBack interface {
+	OnWritable(c *Connection)
+}
+
+// ErrorCallBack：可选的回调接口，连接因事件循环注册失败等内部错误被关闭前调用，err 同时会作为 CloseReason
+type ErrorCallBack interface {
+	OnError(c *Connection, err error)
+}
+
+// BodyChunkCallBack：可选的回调接口，支持流式消息体的协议（通过 Connection.StreamBody）将消息体分片交给 OnBodyChunk，
+// 不必缓冲整个消息体，适用于上传大文件等场景。chunk 在 OnBodyChunk 返回后可能被复用，isLast 表示消息体的最后一个分片
+type BodyChunkCallBack interface {
+	OnBodyChunk(c *Connection, chunk []byte, isLast bool)
+}
+
+// HalfCloseCallBack：可选的回调接口，开启 AllowHalfClose 后对端关闭写端时调用，
+// 此时连接进入只写状态，仍然可以继续发送数据
+type HalfCloseCallBack interface {
+	OnReadClose(c *Connection)
+}
+
+// Connection：TCP 连接结构体。
+// 并发约定：Send、AsyncWrite、WriteClose、Close、QueueInLoop 等发送及关闭操作，
+// Context、SetContext 及 KeyValueContext 的方法，ID、PeerAddr、BytesRead 等只读信息，均可以在任意 goroutine 中调用；
+// 同一个 goroutine 发送的数据按调用顺序写出，多个 goroutine 并发发送时不保证彼此之间的顺序。
+// SendQueued、SendFrom 会阻塞等待事件循环，不能在事件循环的 goroutine 中调用；
+// WriteBack、SendInLoop、SendQueuedInLoop、ReportProtocolError、Fd、ReceiveTime 等只能在事件循环的 goroutine 中（回调及 Protocol 内）调用
 type Connection struct {
 	fd        int
 	connected atomic.Bool
 	outBuffer *ringbuffer.RingBuffer 	// 写 buffer
 	inBuffer  *ringbuffer.RingBuffer 	// 读 buffer
+	readView  ringbuffer.RingBuffer		// inBuffer 为空时就地解析事件循环读缓冲区所用的视图，避免每次读取创建 RingBuffer
 	callBack  CallBack					// 回调方法
+	onMessage MessageHandler			// 通过 SetOnMessage 设置的消息处理函数，优先于 callBack
 	loop      *eventloop.EventLoop		// 循环调度
 	peerAddr  string
-	ctx       interface{}
+	sa        unix.Sockaddr				// 对端地址，UDP 连接发送数据报时使用
+	udp       bool						// 是否为 UDP 数据报连接
+	ctx       interface{}				// 用户自定义上下文，由 ctxMu 保护
 	KeyValueContext
 
-	idleTime    time.Duration
-	activeTime  atomic.Int64
+	ctxMu      sync.Mutex			// 保护 ctx、connCtx 及 cancelFunc
+	connCtx    context.Context		// 连接关闭时取消的 context，第一次使用时才创建
+	cancelFunc context.CancelFunc
+
+	idleTime    atomic.Int64			// 空闲超时时间（纳秒），0 表示不检测，可以通过 SetIdleTimeout 修改
+	idleTimer   atomic.Int64			// 空闲检测定时器的序号，重新调度时递增，使之前的定时器失效
+	activeTime  atomic.Int64			// 最近一次活跃的时间（纳秒）
 	timingWheel *timingwheel.TimingWheel
+	clock       clock.Clock				// 空闲超时等功能使用的时钟，默认由 timingWheel 驱动
 
 	protocol Protocol					// 使用协议
+	outVec   [][]byte					// handlerProtocol 复用的输出切片
+	unpacking bool						// 是否正在 handlerProtocol 中拆包，此时 WriteBack 的数据追加到 outVec
+	msgVec   []Message					// 批量回调时复用的消息切片
+
+	allowHalfClose bool					// 对端关闭写端后是否保持连接继续发送
+	readClosed     bool					// 对端已关闭写端，连接处于只写状态
+	readPaused     bool					// 通过 PauseRead 暂停了读取
+	pipelinePaused bool					// 待写出的响应达到上限，暂停读取新的请求
+	closeAfterWrite bool				// 通过 WriteClose 请求在 outBuffer 写完后关闭
+	writeWanted    bool					// 通过 EnableWrite 显式关注可写事件
+	maxReadBufferSize int				// inBuffer 中未能拆包的数据上限，0 表示不限制
+	maxPendingResponses int				// 待写出的响应个数上限，0 表示不限制
+	maxWriteBufferSize  int				// outBuffer 中积压的数据上限，0 表示不限制
+	bufferFullPolicy    BufferFullPolicy	// outBuffer 达到上限时的处理策略
+	outBuffered         atomic.Int64	// outBuffer 中的数据长度，设置了 maxWriteBufferSize 时维护
+	pendingResponses    int				// outBuffer 中待写出的响应个数
+	pipelineHeld        bool			// 达到上限时 inBuffer 中可能还有未处理的请求
+
+	pool       *Pool					// 所属的连接池，关闭后归还
+	generation atomic.Int64				// 每次从连接池中复用时递增，使上一次使用遗留的定时任务失效
+
+	id           int64					// 连接 ID，单调递增
+	createdAt    time.Time				// 连接建立时间
+	bytesRead    atomic.Int64			// 累计读取的字节数
+	bytesWritten atomic.Int64			// 累计写出的字节数
+	closeReason  error					// 连接关闭的原因，主动关闭时为 nil
+	closeHook    func(c *Connection)	// OnClose 之后调用的钩子
+	rtt          atomic.Int64			// 协议测得的往返时间（纳秒）
+	priority     atomic.Int32			// 事件循环中的处理优先级
+
+	rxTimestamps   bool					// 是否开启了接收时间戳
+	rxHardware     bool					// 是否请求网卡硬件时间戳
+	rxTime         time.Time			// 最近一次读取的数据的内核接收时间
+	oob            []byte				// 读取时间戳的辅助数据缓冲区
+
+	budget     *BufferBudget			// 全局缓冲区预算
+	budgetUsed int64					// 已计入预算的缓冲区容量
+
+	bufferPool *pool.RingBufferPool		// 读写缓冲区的来源，默认为 pool.DefaultPool
+
+	protoErrLimit    int				// 窗口时间内允许的协议错误次数
+	protoErrWindow   time.Duration
+	protoErrTimes    []time.Time		// 最近几次协议错误的时间
+	protoErrExceeded bool				// 协议错误已达到上限，停止拆包
+	protoErr         error				// ErrorProtocol 返回的错误，停止拆包并以此为原因关闭连接
+	noProgress       int				// 本轮拆包中连续没有进展的次数
+
+	deadlineChunks []deadlineChunk		// SendWithDeadline 暂存的数据，outBuffer 写完后发送
+	drainWaiters   []chan struct{}		// 等待 outBuffer 积压的数据写出的 SendFrom
+	writeCallbacks []writeCallback		// 等待写出的 AsyncWrite，按写出位置排列
+	drainWritten   int					// outBuffer 变为非空后 handleWrite 写出的字节数，写空时交给 OnWriteComplete
+	dedup          Deduplicator			// 消息去重，重复的消息不回调 OnMessage
+	stats          *Stats				// 与其他连接共享的计数器，设置了 WithStats 时使用
+	registry       *Registry			// 按 ID 索引连接，设置了 WithRegistry 时使用
+
+	writeBatching bool					// Send 是否合并同一轮事件循环中的多次发送
+	sendMu        sync.Mutex
+	sendQueue     [][]byte				// 开启 writeBatching 时等待合并写出的数据，尚未经过协议打包
+	sendSpare     [][]byte				// 与 sendQueue 交替使用，只在事件循环中访问
+	sendVec       [][]byte				// 合并写出时复用的打包结果切片
+	writeCalls    int					// 写系统调用的次数，只在事件循环中访问
 }
 
-// ErrConnectionClosed：生成新错误连接已关闭
-var ErrConnectionClosed = errors.New("connection closed")
+// nextID：下一个连接 ID
+var nextID atomic.Int64
+
+// maxIovecLen：单次 writev 最多提交的 iovec 数量（UIO_MAXIOV）
+const maxIovecLen = 1024
+
 
 // New：创建 Connection
-func New(fd int, loop *eventloop.EventLoop, sa unix.Sockaddr, protocol Protocol, tw *timingwheel.TimingWheel, idleTime time.Duration, callBack CallBack) *Connection {
-	conn := &Connection{
-		fd:          fd,
-		peerAddr:    sockAddrToString(sa),
-		outBuffer:   pool.Get(),
-		inBuffer:    pool.Get(),
-		callBack:    callBack,
-		loop:        loop,
-		idleTime:    idleTime,
-		timingWheel: tw,
-		protocol:    protocol,
+func New(fd int, loop *eventloop.EventLoop, sa unix.Sockaddr, protocol Protocol, tw *timingwheel.TimingWheel, idleTime time.Duration, callBack CallBack, opts ...Option) *Connection {
+	conn := &Connection{}
+	conn.init(fd, loop, sa, protocol, tw, idleTime, callBack, opts)
+	return conn
+}
+
+// init：初始化连接状态，New 及连接池复用时调用
+func (c *Connection) init(fd int, loop *eventloop.EventLoop, sa unix.Sockaddr, protocol Protocol, tw *timingwheel.TimingWheel, idleTime time.Duration, callBack CallBack, opts []Option) {
+	c.fd = fd
+	c.id = nextID.Add(1)
+	c.peerAddr = SockAddrToString(sa)
+	c.callBack = callBack
+	c.loop = loop
+	_ = c.idleTime.Swap(int64(idleTime))
+	c.timingWheel = tw
+	c.protocol = protocol
+	c.bufferPool = pool.DefaultPool
+	if tw != nil {
+		c.clock = clock.Wheel(tw)
+	} else {
+		c.clock = clock.Real()
+	}
+	for _, o := range opts {
+		o(c)
+	}
+	c.createdAt = c.clock.Now()
+	if c.rxTimestamps {
+		if err := enableRxTimestamps(fd, c.rxHardware); err != nil {
+			log.Error("[timestamp]", err)
+			c.rxTimestamps = false
+		} else if c.oob == nil {
+			c.oob = make([]byte, oobSize)
+		}
+	}
+	// 预分配的连接已经带有读写缓冲区
+	if c.inBuffer == nil {
+		c.inBuffer = c.bufferPool.Get()
+		c.outBuffer = c.bufferPool.Get()
+		// fastnetdebug 构建标签下记录缓冲区的使用者，检测缓冲区被其他连接误用
+		pool.Claim(c.inBuffer, c)
+		pool.Claim(c.outBuffer, c)
+	}
+	c.connected.Set(true)
+	c.loop.AddConnections(1)
+	if c.stats != nil {
+		c.stats.Connections.Add(1)
+	}
+	if c.registry != nil {
+		c.registry.add(c)
 	}
-	conn.connected.Set(true)
+	c.reserveBuffers()
 
-	if conn.idleTime > 0 {
-		_ = conn.activeTime.Swap(time.Now().Unix())
-		conn.timingWheel.AfterFunc(conn.idleTime, conn.closeTimeoutConn())
+	if idleTime > 0 {
+		_ = c.activeTime.Swap(c.clock.Now().UnixNano())
+		c.clock.AfterFunc(idleTime, c.closeTimeoutConn())
 	}
+	c.startHandshakeTimer()
+}
 
-	return conn
+// recycle：清理连接状态以便连接池复用，读写缓冲区保留
+func (c *Connection) recycle() {
+	c.generation.Add(1)
+	c.inBuffer.RetrieveAll()
+	c.outBuffer.RetrieveAll()
+	c.reset()
+	c.ctxMu.Lock()
+	c.ctx = nil
+	c.connCtx, c.cancelFunc = nil, nil
+	c.ctxMu.Unlock()
+	c.allowHalfClose = false
+	c.readClosed = false
+	c.readPaused = false
+	c.pipelinePaused = false
+	c.closeAfterWrite = false
+	c.maxPendingResponses = 0
+	c.maxWriteBufferSize = 0
+	c.bufferFullPolicy = DropNewest
+	_ = c.outBuffered.Swap(0)
+	c.pendingResponses = 0
+	c.pipelineHeld = false
+	c.writeWanted = false
+	c.maxReadBufferSize = 0
+	c.budget = nil
+	c.bufferPool = nil
+	c.clock = nil
+	c.rxTimestamps = false
+	c.rxHardware = false
+	c.rxTime = time.Time{}
+	c.deadlineChunks = nil
+	c.drainWaiters = nil
+	c.writeCallbacks = nil
+	c.drainWritten = 0
+	c.dedup = nil
+	c.stats = nil
+	c.registry = nil
+	c.writeBatching = false
+	c.sendMu.Lock()
+	c.sendQueue = nil
+	c.sendMu.Unlock()
+	c.protoErrLimit = 0
+	c.protoErrWindow = 0
+	c.protoErrTimes = c.protoErrTimes[:0]
+	c.protoErrExceeded = false
+	c.protoErr = nil
+	c.noProgress = 0
+	c.closeReason = nil
+	c.closeHook = nil
+	_ = c.bytesRead.Swap(0)
+	_ = c.bytesWritten.Swap(0)
+	_ = c.rtt.Swap(0)
+	_ = c.priority.Swap(0)
+	c.sa = nil
+	c.callBack = nil
+	c.onMessage = nil
+	c.protocol = nil
 }
 
 // closeTimeoutConn：关闭超时的连接
 func (c *Connection) closeTimeoutConn() func() {
+	generation := c.generation.Get()
+	timer := c.idleTimer.Get()
 	return func() {
-		now := time.Now()
-		intervals := now.Sub(time.Unix(c.activeTime.Get(), 0))
+		// 连接已被连接池复用，定时任务属于上一次使用；或者 SetIdleTimeout 已经重新调度
+		if c.generation.Get() != generation || c.idleTimer.Get() != timer {
+			return
+		}
+		idleTime := c.idleTimeout()
+		if idleTime <= 0 {
+			return
+		}
+		now := c.clock.Now()
+		intervals := now.Sub(time.Unix(0, c.activeTime.Get()))
 		// 判断时间差
-		if intervals >= c.idleTime {
-			_ = c.Close()
+		if intervals >= idleTime {
+			_ = c.closeWith(ErrIdleTimeout)
 		} else {
-			c.timingWheel.AfterFunc(c.idleTime-intervals, c.closeTimeoutConn())
+			c.clock.AfterFunc(idleTime-intervals, c.closeTimeoutConn())
 		}
 	}
 }
 
-// Context：获取 Context
+// idleTimeout：当前的空闲超时时间
+func (c *Connection) idleTimeout() time.Duration {
+	return time.Duration(c.idleTime.Get())
+}
+
+// LastActive：最近一次活跃的时间，即最近一次发生读写事件或调用 ResetIdle 的时间，
+// 仅在设置了空闲超时时间时记录，否则返回零值
+func (c *Connection) LastActive() time.Time {
+	if c.idleTimeout() <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, c.activeTime.Get())
+}
+
+// ResetIdle：重置空闲计时，用于不经过 socket 的应用层活动（如异步操作完成）推迟空闲超时
+func (c *Connection) ResetIdle() {
+	if c.idleTimeout() > 0 {
+		_ = c.activeTime.Swap(c.clock.Now().UnixNano())
+	}
+}
+
+// SetIdleTimeout：修改连接的空闲超时时间，d <= 0 时关闭空闲检测，可以在任意 goroutine 中调用。
+// 修改后从当前时刻重新开始计时并按新的时间调度检测，之前调度的检测随之失效，
+// 因此创建时没有设置空闲超时的连接也可以由此开启
+func (c *Connection) SetIdleTimeout(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	_ = c.idleTime.Swap(int64(d))
+	if d > 0 {
+		_ = c.activeTime.Swap(c.clock.Now().UnixNano())
+	}
+	c.idleTimer.Add(1)
+	if d > 0 && c.connected.Get() {
+		c.clock.AfterFunc(d, c.closeTimeoutConn())
+	}
+}
+
+// Context：获取 Context，可以在任意 goroutine 中调用
 func (c *Connection) Context() interface{} {
-	return c.ctx
+	c.ctxMu.Lock()
+	ctx := c.ctx
+	c.ctxMu.Unlock()
+	return ctx
 }
 
-// SetContext：设置 Context
+// SetContext：设置 Context，可以在任意 goroutine 中调用。ctx 本身的并发安全由使用者保证
 func (c *Connection) SetContext(ctx interface{}) {
+	c.ctxMu.Lock()
 	c.ctx = ctx
+	c.ctxMu.Unlock()
+}
+
+// Done：返回一个在连接关闭时被关闭的 channel，用于通知该连接派生的 goroutine 退出
+func (c *Connection) Done() <-chan struct{} {
+	return c.ConnContext().Done()
+}
+
+// ConnContext：返回一个在连接关闭时被取消的 context.Context，区别于 Context 返回的用户自定义上下文
+func (c *Connection) ConnContext() context.Context {
+	c.ctxMu.Lock()
+	defer c.ctxMu.Unlock()
+	if c.connCtx == nil {
+		c.connCtx, c.cancelFunc = context.WithCancel(context.Background())
+		if !c.connected.Get() {
+			c.cancelFunc()
+		}
+	}
+	return c.connCtx
+}
+
+// cancel：取消连接的 context，通知所有监听 Done 的 goroutine
+func (c *Connection) cancel() {
+	c.ctxMu.Lock()
+	if c.cancelFunc != nil {
+		c.cancelFunc()
+	}
+	c.ctxMu.Unlock()
+}
+
+// ID：获取连接 ID，进程内单调递增且唯一
+func (c *Connection) ID() int64 {
+	return c.id
+}
+
+// CreatedAt：获取连接建立的时间
+func (c *Connection) CreatedAt() time.Time {
+	return c.createdAt
+}
+
+// ReceiveTime：开启 ReceiveTimestamps 时，最近一次读取的数据到达内核的时间，在 OnMessage 中即当前消息所在数据的接收时间。
+// 一次读取包含多个 TCP 分段时为其中最后一个分段的时间，未开启或内核没有提供时间戳时返回零值
+func (c *Connection) ReceiveTime() time.Time {
+	return c.rxTime
+}
+
+// BytesRead：获取累计读取的字节数
+func (c *Connection) BytesRead() int64 {
+	return c.bytesRead.Get()
+}
+
+// BytesWritten：获取累计写出的字节数
+func (c *Connection) BytesWritten() int64 {
+	return c.bytesWritten.Get()
+}
+
+// CloseReason：获取连接关闭的原因，只在 OnClose 及之后有意义。
+// 对端关闭时为 io.EOF，空闲超时为 ErrIdleTimeout，读写出错时为对应的错误，调用 Close 主动关闭时为 nil
+func (c *Connection) CloseReason() error {
+	return c.closeReason
 }
 
 // PeerAddr：获取客户端地址信息
@@ -98,224 +424,687 @@ func (c *Connection) PeerAddr() string {
 	return c.peerAddr
 }
 
+// Fd：连接的 socket fd，用于框架没有封装的 setsockopt/getsockopt 或诊断，是不脱离事件循环的逃生口。
+// fd 仍由事件循环管理：不要直接读写、关闭 fd，也不要修改 O_NONBLOCK 等影响事件循环的设置，
+// 连接关闭后 fd 可能被新连接复用，只应在连接打开期间（如 OnConnect、OnMessage 中）使用
+func (c *Connection) Fd() int {
+	return c.fd
+}
+
 // Connected：测试是否已连接
 func (c *Connection) Connected() bool {
 	return c.connected.Get()
 }
 
-// Send：进行发送数据
+// Send：进行发送数据，事件循环设置了任务数上限（MaxLoopQueue）且已达到上限时返回 eventloop.ErrQueueFull，
+// 数据不会被发送，调用方可以稍后重试
 func (c *Connection) Send(buffer []byte) error {
+	return c.sendGeneration(buffer, c.generation.Get())
+}
+
+// sendGeneration：Send 的实现，generation 为调用者持有连接时的复用次数，
+// 连接已被连接池复用给新的客户端时丢弃数据，供 Responder 等在 OnClose 之后仍可能持有连接的调用者使用
+func (c *Connection) sendGeneration(buffer []byte, generation int64) error {
+	if c.generation.Get() != generation {
+		return ErrConnectionClosed
+	}
 	// 如果未连接或连接已断开
 	if !c.connected.Get() {
-		return ErrConnectionClosed
+		return c.closedError()
+	}
+
+	// UDP 连接直接以数据报形式发送给对端
+	if c.udp {
+		return c.loop.TryQueueInLoop(func() {
+			if c.generation.Get() != generation {
+				return
+			}
+			c.sendTo(c.protocol.Packet(c, buffer))
+		})
+	}
+
+	// outBuffer 已达到上限，按 Block 策略直接拒绝
+	if c.sendRefused(len(buffer)) {
+		return ErrWriteBufferFull
+	}
+
+	// 开启了合并写时加入发送队列，同一轮事件循环中的多次发送一起写出
+	if c.writeBatching {
+		return c.queueSend(buffer, generation)
 	}
 
 	// 循环调用 sendInLoop 方法
-	c.loop.QueueInLoop(func() {
+	return c.loop.TryQueueInLoop(func() {
+		// 连接已关闭并被连接池复用，丢弃发给上一个连接的数据
+		if c.generation.Get() != generation {
+			return
+		}
 		// 进行协议打包封装之后再发送
 		c.sendInLoop(c.protocol.Packet(c, buffer))
 	})
-	return nil
+}
+
+// SendInLoop：不经过协议打包直接发送数据，只能在事件循环 goroutine 中调用，
+// 供 Protocol 在 UnPacket 中回写握手等协议自身的数据
+func (c *Connection) SendInLoop(data []byte) {
+	if !c.connected.Get() {
+		return
+	}
+	if c.udp {
+		c.sendTo(data)
+		return
+	}
+	c.sendInLoop(data)
+}
+
+// WriteBack：不经过协议打包回写数据，只能在事件循环 goroutine 中调用。
+// 在 UnPacket 中调用时（如 TLS 握手），数据追加到本次拆包的输出中，与之后拆出的消息的响应按顺序通过一次 writev 写出，
+// 会计入 MaxPendingResponses 的响应个数；在其他位置调用时等同于 SendInLoop
+func (c *Connection) WriteBack(data []byte) {
+	if c.unpacking && !c.udp {
+		if len(data) > 0 {
+			c.outVec = append(c.outVec, data)
+		}
+		return
+	}
+	c.SendInLoop(data)
 }
 
 // Close：关闭连接
 func (c *Connection) Close() error {
+	if c.loop.Stopped() {
+		return c.closeWith(ErrServerShutdown)
+	}
+	return c.closeWith(nil)
+}
+
+// closeWith：在事件循环中以 reason 为原因关闭连接
+func (c *Connection) closeWith(reason error) error {
 	// 如果不能获取当前连接，则报错
 	if !c.connected.Get() {
-		return ErrConnectionClosed
+		return c.closedError()
 	}
 	// 进去循环 loop中调用关闭函数
+	generation := c.generation.Get()
 	c.loop.QueueInLoop(func() {
-		c.handleClose(c.fd)
+		if c.generation.Get() != generation {
+			return
+		}
+		c.closeWithReason(c.fd, reason)
 	})
 	return nil
 }
 
 // ShutdownWrite：关闭可写端，等待读取完接收缓冲区所有数据
 func (c *Connection) ShutdownWrite() error {
+	if c.udp {
+		return ErrUDPNotSupported
+	}
 	c.connected.Set(false)
 	return unix.Shutdown(c.fd, unix.SHUT_WR)
 }
 
 // HandleEvent：内部使用，event loop 回调
 func (c *Connection) HandleEvent(fd int, events poller.Event) {
-	if c.idleTime > 0 {
-		_ = c.activeTime.Swap(time.Now().Unix())
+	if pool.Debug {
+		pool.CheckOwner(c.inBuffer, c)
+		pool.CheckOwner(c.outBuffer, c)
+	}
+	if c.idleTimeout() > 0 {
+		_ = c.activeTime.Swap(c.clock.Now().UnixNano())
 	}
 
 	if events&poller.EventErr != 0 {
-		c.handleClose(fd)
+		c.closeWithReason(fd, socketError(fd))
 		return
 	}
 
-	// 判断 outBuffer 是否为空
+	// outBuffer 不为空时只关注了可写事件（见 enableWrite），写空之前不处理读事件
 	if c.outBuffer.Length() != 0 {
-		// 再接着判断 events 是为读事件还是写事件
 		if events&poller.EventWrite != 0 {
 			// 处理写事件
 			c.handleWrite(fd)
-		} else if events&poller.EventRead != 0 {
+		}
+	} else {
+		if events&poller.EventRead != 0 && !c.readStopped() {
 			// 处理读事件
 			c.handleRead(fd)
 		}
+		// 显式关注了可写事件，通知回调 socket 可写
+		if events&poller.EventWrite != 0 && c.writeWanted && c.connected.Get() && c.outBuffer.Length() == 0 {
+			if w, ok := c.callBack.(WritableCallBack); ok {
+				w.OnWritable(c)
+			}
+		}
 	}
 }
 
-// handlerProtocol：处理协议相关内容
-func (c *Connection) handlerProtocol(buffer *ringbuffer.RingBuffer) []byte {
-	// 在调用方函数里归还
-	out := pbytes.GetCap(1024)
-	ctx, receivedData := c.protocol.UnPacket(c, buffer)
-	for ctx != nil || len(receivedData) != 0 {
+// EnableWrite：显式关注可写事件，可以在任意 goroutine 中调用。
+// 默认情况下只有 outBuffer 中有待发送的数据时才关注可写事件，写完后自动取消；
+// 调用 EnableWrite 后写完也不会取消，socket 可写时回调 OnWritable（如果实现了 WritableCallBack），直到调用 DisableWrite
+func (c *Connection) EnableWrite() error {
+	if !c.connected.Get() {
+		return c.closedError()
+	}
+	c.loop.QueueInLoop(func() {
+		if !c.connected.Get() {
+			return
+		}
+		c.writeWanted = true
+		c.enableWrite(c.fd)
+	})
+	return nil
+}
+
+// DisableWrite：取消 EnableWrite 显式关注的可写事件，可以在任意 goroutine 中调用。
+// outBuffer 中仍有待发送的数据时，可写事件会保留到数据写完后再自动取消
+func (c *Connection) DisableWrite() error {
+	if !c.connected.Get() {
+		return c.closedError()
+	}
+	c.loop.QueueInLoop(func() {
+		if !c.connected.Get() {
+			return
+		}
+		c.writeWanted = false
+		if c.outBuffer.Length() == 0 {
+			c.disableWrite(c.fd)
+		}
+	})
+	return nil
+}
+
+// handlerProtocol：处理协议相关内容，按顺序返回每条消息打包后的数据，由 sendBuffersInLoop 一次性写出
+func (c *Connection) handlerProtocol(buffer *ringbuffer.RingBuffer) [][]byte {
+	if batch, ok := c.callBack.(BatchCallBack); ok && c.onMessage == nil {
+		return c.handlerProtocolBatch(batch, buffer)
+	}
+
+	// 协议在 UnPacket 中通过 WriteBack 回写的数据同样追加到 c.outVec，与响应按顺序一起写出
+	c.outVec = c.outVec[:0]
+	c.unpacking = true
+	c.noProgress = 0
+	ctx, receivedData := c.unpack(buffer)
+	for (ctx != nil || len(receivedData) != 0) && !c.unpackStopped() {
+		// 重复的消息直接丢弃
+		if c.duplicate(ctx, receivedData) {
+			ctx, receivedData = c.unpack(buffer)
+			continue
+		}
+		c.addMessages(1)
 		// 调用 OnMessage 进行相对应的处理后得到 sendData
-		sendData := c.callBack.OnMessage(c, ctx, receivedData)
-		// 如果 sendData 长度大于 0，则插入 out 当中
+		sendData := c.onMessageHandler()(c, ctx, receivedData)
+		// 如果 sendData 长度大于 0，则打包后追加到 out 当中，避免 append 拷贝数据
 		if len(sendData) > 0 {
-			out = append(out, c.protocol.Packet(c, sendData)...)
+			c.outVec = append(c.outVec, c.protocol.Packet(c, sendData))
+		}
+		// 待写出的响应达到上限，剩余的请求留在 buffer 中
+		if c.pipelineFull(len(c.outVec)) {
+			c.pipelineHeld = true
+			break
+		}
+
+		ctx, receivedData = c.unpack(buffer)
+	}
+	c.unpacking = false
+	return c.outVec
+}
+
+// handlerProtocolBatch：拆出 buffer 中的所有消息后一次性交给 OnMessages 处理
+func (c *Connection) handlerProtocolBatch(batch BatchCallBack, buffer *ringbuffer.RingBuffer) [][]byte {
+	msgs := c.msgVec[:0]
+	c.outVec = c.outVec[:0]
+	c.unpacking = true
+	c.noProgress = 0
+	ctx, receivedData := c.unpack(buffer)
+	for (ctx != nil || len(receivedData) != 0) && !c.unpackStopped() {
+		if c.duplicate(ctx, receivedData) {
+			ctx, receivedData = c.unpack(buffer)
+			continue
+		}
+		msgs = append(msgs, Message{Ctx: ctx, Data: receivedData})
+		if c.pipelineFull(len(msgs)) {
+			c.pipelineHeld = true
+			break
+		}
+		ctx, receivedData = c.unpack(buffer)
+	}
+
+	c.unpacking = false
+
+	out := c.outVec
+	if len(msgs) > 0 {
+		c.addMessages(len(msgs))
+		for _, sendData := range batch.OnMessages(c, msgs) {
+			if len(sendData) > 0 {
+				out = append(out, c.protocol.Packet(c, sendData))
+			}
 		}
+	}
 
-		ctx, receivedData = c.protocol.UnPacket(c, buffer)
+	// 释放对消息的引用
+	for i := range msgs {
+		msgs[i] = Message{}
 	}
+	c.msgVec = msgs
+	c.outVec = out
 	return out
 }
 
 // handleRead：处理读事件
 func (c *Connection) handleRead(fd int) {
-	// TODO 避免这次内存拷贝
 	// 获得当前 buf，并通过读系统调用写入到 buf
 	buf := c.loop.PacketBuf()
-	n, err := unix.Read(c.fd, buf)
+	var n int
+	var err error
+	if c.rxTimestamps {
+		n, err = c.readTimestamped(buf)
+	} else {
+		n, err = unix.Read(c.fd, buf)
+	}
+	// 读到 EOF，对端已关闭写端
+	if n == 0 && err == nil && c.allowHalfClose {
+		c.handleReadClose(fd)
+		return
+	}
 	// 错误处理，非阻塞IO 缓冲区未准备数据可供读则返回错误为 EAGAIN
 	if n == 0 || err != nil {
-		if err != unix.EAGAIN {
-			c.handleClose(fd)
+		if err == nil {
+			c.closeWithReason(fd, io.EOF)
+		} else if err != unix.EAGAIN {
+			c.closeWithReason(fd, opError("read", err))
 		}
 		return
 	}
+	c.addRead(n)
 
 	if c.inBuffer.Length() == 0 {
 		// 1. 如果 inBuffer 为空
-		// 通过 ringbuffer.NewWithData 传入 buf[:n] 创建一个新的 buffer
-		buffer := ringbuffer.NewWithData(buf[:n])
+		// 通过 readView 持有 buf[:n]，协议直接在事件循环的读缓冲区上就地解析，不拷贝也不创建新的 buffer
+		c.readView.ResetWithData(buf[:n])
 		// 使用 handlerProtocol 进行解析得到 out
-		out := c.handlerProtocol(buffer)
-		// 如果此时 buffer 长度不为 0，则获取其内容并写入到 inBuffer 中
-		if buffer.Length() > 0 {
-			first, _ := buffer.PeekAll()
+		out := c.handlerProtocol(&c.readView)
+		// 只有未能拆包的尾部需要拷贝到 inBuffer 中，读缓冲区会被下一次读取覆盖
+		if c.readView.Length() > 0 {
+			first, end := c.readView.PeekAll()
 			_, _ = c.inBuffer.Write(first)
+			_, _ = c.inBuffer.Write(end)
 		}
-		if len(out) != 0 {
-			// 处理 out 写buffer
-			c.sendInLoop(out)
-		}
-
-		pbytes.Put(out)
+		c.readView.ResetWithData(nil)
+		c.sendBuffersInLoop(out)
+		c.trackResponses(len(out))
 	} else {
 		// 2. 如果 inBuffer 不为空，则写入到 inBuffer 中
 		_, _ = c.inBuffer.Write(buf[:n])
 		out := c.handlerProtocol(c.inBuffer)
-		if len(out) != 0 {
-			c.sendInLoop(out)
-		}
+		c.sendBuffersInLoop(out)
+		c.trackResponses(len(out))
+	}
+	c.handleHeldRequests()
 
-		pbytes.Put(out)
+	if c.closeOnProtocolErrors(fd) {
+		return
+	}
+	// 未能拆包的数据超过读缓冲区上限，关闭连接，因待写出的响应过多而暂停时 inBuffer 中是尚未处理的完整请求
+	if c.maxReadBufferSize > 0 && !c.pipelinePaused && c.inBuffer.Length() > c.maxReadBufferSize {
+		c.closeWithReason(fd, ErrReadBufferOverflow)
+		return
 	}
+	c.accountBuffers()
 }
 
 // handleWrite：处理写事件
 func (c *Connection) handleWrite(fd int) {
 	// 从 outBuffer 取出数据
 	first, end := c.outBuffer.PeekAll()
-	n, err := unix.Write(c.fd, first)
+	c.writeCalls++
+	n, err := write(c.fd, first)
 	// 错误处理，非阻塞IO 缓冲区没有空间可供写则返回错误为 EAGAIN
 	if err != nil {
 		// 返回 EAGAIN 并不做其他动作，将数据保存在 outBuffer 中，等待下次写
 		if err == unix.EAGAIN {
 			return
 		}
-		c.handleClose(fd)
+		c.closeWithReason(fd, opError("write", err))
 		return
 	}
+	c.addWritten(n)
+	c.drainWritten += n
 	// 清楚部分数据
 	c.outBuffer.Retrieve(n)
+	c.syncOutBuffered()
 
 	// 再进行判断 end 是否有数据，有则同样处理
 	if n == len(first) && len(end) > 0 {
-		n, err = unix.Write(c.fd, end)
+		c.writeCalls++
+		n, err = write(c.fd, end)
 		// 错误处理，非阻塞IO 缓冲区没有空间可供写则返回错误为 EAGAIN
 		if err != nil {
 			if err == unix.EAGAIN {
 				return
 			}
-			c.handleClose(fd)
+			c.closeWithReason(fd, opError("write", err))
 			return
 		}
+		c.addWritten(n)
+		c.drainWritten += n
 		c.outBuffer.Retrieve(n)
+		c.syncOutBuffered()
 	}
+	// 回调已经写出的 AsyncWrite，需要在 WriteClose 关闭连接之前
+	c.notifyWritten()
 
-	// 处理完了之后，通知 fd 可读
+	// 处理完了之后，发送暂存的实时性数据，没有积压则通知 fd 可读
 	if c.outBuffer.Length() == 0 {
-		if err := c.loop.EnableRead(fd); err != nil {
-			log.Error("[EnableRead]", err)
+		c.flushDeadlineChunks()
+	}
+	// WriteClose 发送的数据已全部写出
+	if c.outBuffer.Length() == 0 && c.closeAfterWrite {
+		c.handleClose(fd)
+		return
+	}
+	c.notifyWriteComplete()
+	c.notifyDrained()
+	if c.outBuffer.Length() == 0 && c.pipelinePaused {
+		c.resumePipeline(fd)
+	}
+	if c.outBuffer.Length() == 0 && c.connected.Get() {
+		c.disableWrite(fd)
+	}
+}
+
+// handleReadClose：处理对端关闭写端，连接进入只写状态并通知 OnReadClose
+func (c *Connection) handleReadClose(fd int) {
+	if c.readClosed {
+		return
+	}
+	c.readClosed = true
+	if c.outBuffer.Length() > 0 || c.writeWanted {
+		c.enableWrite(fd)
+	} else {
+		c.disableWrite(fd)
+	}
+	if !c.connected.Get() {
+		return
+	}
+	if h, ok := c.callBack.(HalfCloseCallBack); ok {
+		h.OnReadClose(c)
+	}
+}
+
+// enableWrite：outBuffer 中有待发送的数据时关注可写事件，只写状态及暂停读取时不再关注可读事件
+func (c *Connection) enableWrite(fd int) {
+	var err error
+	// outBuffer 积压时只关注可写事件：积压期间不处理读事件，水平触发下继续关注可读事件会因未读的数据反复触发，
+	// 写空后由 disableWrite 恢复
+	if c.readClosed || c.readStopped() || c.outBuffer.Length() != 0 {
+		err = c.loop.EnableWrite(fd)
+	} else {
+		err = c.loop.EnableReadWrite(fd)
+	}
+	if err != nil {
+		c.pollerFailed(fd, "enable write", err)
+	}
+}
+
+// disableWrite：outBuffer 写完后取消可写事件，只写状态及暂停读取时不再关注任何读写事件，
+// 通过 EnableWrite 显式关注了可写事件时保留
+func (c *Connection) disableWrite(fd int) {
+	// 显式关注了可写事件时保留，积压期间暂停的读事件由 enableWrite 一并恢复
+	if c.writeWanted {
+		c.enableWrite(fd)
+		return
+	}
+	var err error
+	if c.readClosed || c.readStopped() {
+		err = c.loop.DisableReadWrite(fd)
+	} else {
+		err = c.loop.EnableRead(fd)
+	}
+	if err != nil {
+		c.pollerFailed(fd, "disable write", err)
+	}
+}
+
+// pollerFailed：关注的事件与实际不一致时连接无法继续工作，回调 OnError 后关闭连接
+func (c *Connection) pollerFailed(fd int, op string, err error) {
+	if !c.connected.Get() {
+		return
+	}
+	reason := &pollerError{op: op, err: err}
+	log.Error("[poller]", reason)
+	if h, ok := c.callBack.(ErrorCallBack); ok {
+		h.OnError(c, reason)
+	}
+	c.closeWithReason(fd, reason)
+}
+
+// HandlePollerError：内部使用，连接加入事件循环失败时调用，回调 OnError 后关闭连接
+func (c *Connection) HandlePollerError(op string, err error) {
+	generation := c.generation.Get()
+	c.loop.QueueInLoop(func() {
+		if c.generation.Get() != generation {
+			return
 		}
+		c.pollerFailed(c.fd, op, err)
+	})
+}
+
+// closeWithReason：记录关闭原因后处理关闭事件
+func (c *Connection) closeWithReason(fd int, reason error) {
+	if c.connected.Get() && c.closeReason == nil {
+		c.closeReason = reason
 	}
+	c.handleClose(fd)
+}
+
+// socketError：获取 socket 上挂起的错误，没有错误时说明对端已挂断，返回 io.EOF
+func socketError(fd int) error {
+	errno, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_ERROR)
+	if err != nil {
+		return err
+	}
+	if errno != 0 {
+		return unix.Errno(errno)
+	}
+	return io.EOF
 }
 
 // handleClose：处理关闭事件
 func (c *Connection) handleClose(fd int) {
-	if c.connected.Get() {
-		c.connected.Set(false)
+	// UDP 连接与 UDPSocket 共享 fd，关闭时仅将其标记为已断开
+	if c.udp {
+		if c.connected.CompareAndSwap(true, false) {
+			c.cancel()
+			c.closeManaged()
+		}
+		return
+	}
+
+	// 错误事件与主动 Close 可能同时触发，只有将 connected 置为 false 的一方执行关闭，保证关闭是幂等的
+	if c.connected.CompareAndSwap(true, false) {
 		c.loop.DeleteFdInLoop(fd)
+		c.loop.AddConnections(-1)
+		if c.stats != nil {
+			c.stats.Connections.Add(-1)
+		}
+		if c.registry != nil {
+			c.registry.remove(c)
+		}
+
+		// 通知所有监听 Done 的 goroutine
+		c.cancel()
+		// 尚未写出的 AsyncWrite 以关闭的原因回调
+		c.failWriteCallbacks()
 
 		// 关闭事件会调用 OnClose
 		c.callBack.OnClose(c)
+		if c.closeHook != nil {
+			c.closeHook(c)
+		}
+		c.closeManaged()
 		if err := unix.Close(fd); err != nil {
 			log.Error("[close fd]", err)
 		}
 
-		pool.Put(c.inBuffer)
-		pool.Put(c.outBuffer)
+		c.releaseBuffers()
+		if c.pool == nil || !c.pool.prealloc {
+			// 归还前清空残留的数据，避免被下一个连接读到或写出
+			c.inBuffer.RetrieveAll()
+			c.outBuffer.RetrieveAll()
+			c.bufferPool.Put(c.inBuffer)
+			c.bufferPool.Put(c.outBuffer)
+		}
+		if c.pool != nil {
+			c.pool.put(c)
+		}
 	}
 }
 
+// writeResult：sendInLoop 的写出结果
+type writeResult int
+
+const (
+	writeDone     writeResult = iota // 数据已全部写入 socket
+	writeBuffered                    // 部分或全部数据暂存在 outBuffer 中等待可写
+	writeFailed                      // 连接已关闭或写出错
+	writeDropped                     // outBuffer 达到上限，数据被丢弃
+)
+
 // sendInLoop：送入循环，data 为经过协议处理过后的数据
-func (c *Connection) sendInLoop(data []byte) {
+func (c *Connection) sendInLoop(data []byte) writeResult {
+	if !c.connected.Get() {
+		return writeFailed
+	}
 	if c.outBuffer.Length() > 0 {
+		// 积压的数据已达到上限
+		if c.writeLimited(len(data)) {
+			return c.handleBufferFull()
+		}
 		// 如果 outBuffer 长度不为 0，则直接将 outBuffer 写入到 outBuffer
 		_, _ = c.outBuffer.Write(data)
-	} else {
-		// 否则直接调用写系统调用，将数据写入到 fd 对应的的文件中
-		n, err := unix.Write(c.fd, data)
-		// 错误处理，非阻塞IO 缓冲区无位置可供写则返回错误为 EAGAIN
+		c.syncOutBuffered()
+		c.accountBuffers()
+		if !c.connected.Get() {
+			return writeFailed
+		}
+		return writeBuffered
+	}
+
+	// 否则直接调用写系统调用，将数据写入到 fd 对应的的文件中
+	c.writeCalls++
+	n, err := write(c.fd, data)
+	// 错误处理，非阻塞IO 缓冲区无位置可供写则返回错误为 EAGAIN，此时全部数据保存到 outBuffer
+	if err != nil {
+		if err != unix.EAGAIN {
+			c.closeWithReason(c.fd, opError("write", err))
+			return writeFailed
+		}
+		n = 0
+	}
+	c.addWritten(n)
+	if n == len(data) {
+		return writeDone
+	}
+
+	// 将未写入的剩余部分内容保存起来，并通知可读可写。部分数据已经写出，为保证数据完整不受上限限制
+	_, _ = c.outBuffer.Write(data[n:])
+	c.syncOutBuffered()
+	c.enableWrite(c.fd)
+	c.accountBuffers()
+	if !c.connected.Get() {
+		return writeFailed
+	}
+	return writeBuffered
+}
+
+// sendBuffersInLoop：通过 writev 一次性按序写出多段经过协议处理过后的数据
+func (c *Connection) sendBuffersInLoop(bufs [][]byte) {
+	// 写出后释放对各段数据的引用
+	defer func() {
+		for i := range bufs {
+			bufs[i] = nil
+		}
+	}()
+
+	switch len(bufs) {
+	case 0:
+		return
+	case 1:
+		c.sendInLoop(bufs[0])
+		return
+	}
+
+	// 如果 outBuffer 不为空，为保证顺序，全部写入到 outBuffer 中，达到上限后之后的数据都按策略处理
+	if c.outBuffer.Length() > 0 {
+		for _, b := range bufs {
+			if c.writeLimited(len(b)) {
+				if c.handleBufferFull() == writeFailed {
+					return
+				}
+				break
+			}
+			_, _ = c.outBuffer.Write(b)
+		}
+		c.syncOutBuffered()
+		c.accountBuffers()
+		return
+	}
+
+	remain := bufs
+	for len(remain) > 0 {
+		vec := remain
+		if len(vec) > maxIovecLen {
+			vec = vec[:maxIovecLen]
+		}
+		remain = remain[len(vec):]
+
+		c.writeCalls++
+		n, err := writev(c.fd, vec)
 		if err != nil {
-			if err == unix.EAGAIN {
+			if err != unix.EAGAIN {
+				c.closeWithReason(c.fd, opError("writev", err))
 				return
 			}
-			c.handleClose(c.fd)
-			return
-		}
-		if n == 0 {
-			// 如果写入的大小为 0，则将所有的 data 写入到 outBuffer 中
-			_, _ = c.outBuffer.Write(data)
-		} else if n < len(data) {
-			// 如果写入的大小为 n 且不为0，则将其剩余部分内容保存起来
-			_, _ = c.outBuffer.Write(data[n:])
+			n = 0
 		}
+		c.addWritten(n)
 
-		// 通知可读可写
-		if c.outBuffer.Length() > 0 {
-			_ = c.loop.EnableReadWrite(c.fd)
+		// 跳过已经写入的部分，未写入的部分按序保存到 outBuffer
+		for i, b := range vec {
+			if n >= len(b) {
+				n -= len(b)
+				continue
+			}
+			_, _ = c.outBuffer.Write(b[n:])
+			for _, r := range vec[i+1:] {
+				_, _ = c.outBuffer.Write(r)
+			}
+			for _, r := range remain {
+				_, _ = c.outBuffer.Write(r)
+			}
+			remain = nil
+			break
 		}
 	}
+
+	// 通知可读可写
+	if c.outBuffer.Length() > 0 {
+		c.syncOutBuffered()
+		c.enableWrite(c.fd)
+		c.accountBuffers()
+	}
 }
 
-// sockAddrToString：将 socket 转为字符串格式
-func sockAddrToString(sa unix.Sockaddr) string {
+// SockAddrToString：将 socket 地址转为字符串格式
+func SockAddrToString(sa unix.Sockaddr) string {
 	switch sa := (sa).(type) {
 	case *unix.SockaddrInet4:
 		return net.JoinHostPort(net.IP(sa.Addr[:]).String(), strconv.Itoa(sa.Port))
 	case *unix.SockaddrInet6:
 		return net.JoinHostPort(net.IP(sa.Addr[:]).String(), strconv.Itoa(sa.Port))
+	case *unix.SockaddrUnix:
+		return sa.Name
 	default:
 		return fmt.Sprintf("(unknown - %T)", sa)
 	}
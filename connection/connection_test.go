@@ -0,0 +1,136 @@
+package connection
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+	"golang.org/x/sys/unix"
+)
+
+type emptyCallBack struct{}
+
+func (e *emptyCallBack) OnConnect(c *Connection)                                      {}
+func (e *emptyCallBack) OnMessage(c *Connection, ctx interface{}, data []byte) []byte { return nil }
+func (e *emptyCallBack) OnClose(c *Connection)                                        {}
+
+type echoCallBack struct{}
+
+func (e *echoCallBack) OnConnect(c *Connection)                                      {}
+func (e *echoCallBack) OnMessage(c *Connection, ctx interface{}, data []byte) []byte { return data }
+func (e *echoCallBack) OnClose(c *Connection)                                        {}
+
+// lineProtocol：以 '\n' 分隔的简单协议，仅供测试使用
+type lineProtocol struct{}
+
+func (p *lineProtocol) UnPacket(c *Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	first, end := buffer.PeekAll()
+	if index := bytes.IndexByte(first, '\n'); index != -1 {
+		data := append([]byte{}, first[:index]...)
+		buffer.Retrieve(index + 1)
+		return nil, data
+	}
+	if index := bytes.IndexByte(end, '\n'); index != -1 {
+		data := append(append([]byte{}, first...), end[:index]...)
+		buffer.Retrieve(len(first) + index + 1)
+		return nil, data
+	}
+	return nil, nil
+}
+
+func (p *lineProtocol) Packet(c *Connection, data []byte) []byte {
+	return append(data, '\n')
+}
+
+// newTestConnection：在 fd 上创建一个未加入事件循环的 Connection，仅供测试使用
+func newTestConnection(t testing.TB, fd int) *Connection {
+	return newTestConnectionWith(t, fd, &DefaultProtocol{}, &emptyCallBack{})
+}
+
+func newTestConnectionWith(t testing.TB, fd int, protocol Protocol, callBack CallBack, opts ...Option) *Connection {
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(fd, loop, nil, protocol, nil, 0, callBack, opts...)
+}
+
+// newSocketPair：创建一对非阻塞的 Unix Socket
+func newSocketPair(t testing.TB) (int, int) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.SetNonblock(fds[0], true); err != nil {
+		t.Fatal(err)
+	}
+	return fds[0], fds[1]
+}
+
+// fdReader：基于 fd 的阻塞 io.Reader
+type fdReader int
+
+func (r fdReader) Read(p []byte) (int, error) {
+	return unix.Read(int(r), p)
+}
+
+func pipelinedRequests(n int) []byte {
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		b.WriteString(strconv.Itoa(i))
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+func TestConnection_PipelinedOutputOrder(t *testing.T) {
+	fd, peer := newSocketPair(t)
+	defer unix.Close(fd)
+	defer unix.Close(peer)
+
+	c := newTestConnectionWith(t, fd, &lineProtocol{}, &echoCallBack{})
+	c.sendBuffersInLoop(c.handlerProtocol(ringbuffer.NewWithData(pipelinedRequests(100))))
+
+	f := bufio.NewReader(fdReader(peer))
+	for i := 0; i < 100; i++ {
+		line, err := f.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != strconv.Itoa(i)+"\n" {
+			t.Fatalf("expect %d, but got %q", i, line)
+		}
+	}
+}
+
+func BenchmarkConnection_PipelinedOutput(b *testing.B) {
+	fd, peer := newSocketPair(b)
+	defer unix.Close(fd)
+	defer unix.Close(peer)
+
+	go func() {
+		buf := make([]byte, 64*1024)
+		for {
+			if _, err := unix.Read(peer, buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	// 使用阻塞写，保证每次 writev 都完整写出而不会堆积到 outBuffer
+	if err := unix.SetNonblock(fd, false); err != nil {
+		b.Fatal(err)
+	}
+	c := newTestConnectionWith(b, fd, &lineProtocol{}, &echoCallBack{})
+	requests := pipelinedRequests(100)
+	data := make([]byte, len(requests))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		copy(data, requests)
+		c.sendBuffersInLoop(c.handlerProtocol(ringbuffer.NewWithData(data)))
+	}
+}
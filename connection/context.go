@@ -1,13 +1,19 @@
 package connection
 
-import "sync"
+import (
+	"sync"
 
-// KeyValueContext：键值对上下文
+	"github.com/Dongxiem/fastnet/log"
+)
+
+// KeyValueContext：键值对上下文，所有方法都可以在任意 goroutine 中调用
 type KeyValueContext struct {
 	// 读写锁
 	mu sync.RWMutex
 	// map：键为 string，值为接口 interface{}
 	kv map[string]interface{}
+	// 通过 SetManaged 设置的键，按设置顺序排列
+	managed []string
 }
 
 // Set：进行 KeyValueContext 键值设置
@@ -19,15 +25,70 @@ func (c *KeyValueContext) Set(key string, value interface{}) {
 	}
 	// 进行元素键值赋值
 	c.kv[key] = value
+	c.unmanage(key)
 	// 解锁
 	c.mu.Unlock()
 }
 
+// SetManaged：设置键值，并在连接关闭时（OnClose 之后）自动调用 value 的 Close 方法，
+// 用于管理连接级别的资源（如事务、打开的文件）。多个值按设置的相反顺序关闭，
+// 通过 Delete 删除或被 Set 覆盖的值不再自动关闭
+func (c *KeyValueContext) SetManaged(key string, value interface{}) {
+	c.mu.Lock()
+	if c.kv == nil {
+		c.kv = make(map[string]interface{})
+	}
+	c.kv[key] = value
+	c.unmanage(key)
+	c.managed = append(c.managed, key)
+	c.mu.Unlock()
+}
+
 // Delete： 删除 map 中的映射
 func (c *KeyValueContext) Delete(key string) {
 	c.mu.Lock()
 	delete(c.kv, key)
+	c.unmanage(key)
+	c.mu.Unlock()
+}
+
+// unmanage：取消 key 的自动关闭，需要持有锁
+func (c *KeyValueContext) unmanage(key string) {
+	for i, k := range c.managed {
+		if k == key {
+			c.managed = append(c.managed[:i], c.managed[i+1:]...)
+			return
+		}
+	}
+}
+
+// closeManaged：按设置的相反顺序关闭所有通过 SetManaged 设置的值，并将其删除
+func (c *KeyValueContext) closeManaged() {
+	c.mu.Lock()
+	if len(c.managed) == 0 {
+		c.mu.Unlock()
+		return
+	}
+	values := make([]interface{}, 0, len(c.managed))
+	for i := len(c.managed) - 1; i >= 0; i-- {
+		key := c.managed[i]
+		values = append(values, c.kv[key])
+		delete(c.kv, key)
+	}
+	c.managed = nil
 	c.mu.Unlock()
+
+	// 在锁外关闭，允许 Close 中访问 KeyValueContext
+	for _, v := range values {
+		switch closer := v.(type) {
+		case interface{ Close() error }:
+			if err := closer.Close(); err != nil {
+				log.Error("[close managed value]", err)
+			}
+		case interface{ Close() }:
+			closer.Close()
+		}
+	}
 }
 
 // Get： 根据键 key 得到对应的 value 及是否存在标志 bool
@@ -42,5 +103,6 @@ func (c *KeyValueContext) Get(key string) (value interface{}, exists bool) {
 func (c *KeyValueContext) reset() {
 	c.mu.Lock()
 	c.kv = nil
+	c.managed = nil
 	c.mu.Unlock()
 }
@@ -1,8 +1,12 @@
 package connection
 
 import (
+	"bufio"
 	"fmt"
+	"sync"
 	"testing"
+
+	"golang.org/x/sys/unix"
 )
 
 func TestKeyValueContext(t *testing.T) {
@@ -39,3 +43,132 @@ func TestKeyValueContext(t *testing.T) {
 		t.Fatal(fmt.Sprintf("ok should be false, but %t", ok))
 	}
 }
+
+type managedValue struct {
+	name   string
+	closed *[]string
+}
+
+func (m *managedValue) Close() error {
+	*m.closed = append(*m.closed, m.name)
+	return nil
+}
+
+type managedFunc func()
+
+func (f managedFunc) Close() { f() }
+
+func TestKeyValueContext_SetManaged(t *testing.T) {
+	var closed []string
+	ctx := KeyValueContext{}
+
+	ctx.SetManaged("a", &managedValue{name: "a", closed: &closed})
+	ctx.SetManaged("b", &managedValue{name: "b", closed: &closed})
+	ctx.SetManaged("c", managedFunc(func() { closed = append(closed, "c") }))
+	ctx.SetManaged("deleted", &managedValue{name: "deleted", closed: &closed})
+	ctx.SetManaged("replaced", &managedValue{name: "replaced", closed: &closed})
+	ctx.SetManaged("plain", 1)
+	ctx.Delete("deleted")
+	ctx.Set("replaced", 2)
+
+	ctx.closeManaged()
+	if fmt.Sprint(closed) != "[c b a]" {
+		t.Fatal(fmt.Sprintf("managed values should be closed in reverse order, but %v", closed))
+	}
+	if _, ok := ctx.Get("a"); ok {
+		t.Fatal("managed value should be deleted after close")
+	}
+	if v, ok := ctx.Get("replaced"); !ok || v.(int) != 2 {
+		t.Fatal("value replaced by Set should not be closed")
+	}
+
+	// 重复关闭不会再次调用 Close
+	ctx.closeManaged()
+	if len(closed) != 3 {
+		t.Fatal(fmt.Sprintf("managed values should be closed once, but %v", closed))
+	}
+}
+
+func TestConnection_SetManaged(t *testing.T) {
+	c, peer, loop, closedCh := newRunningConnection(t, &DefaultProtocol{})
+	defer loop.Stop()
+
+	var closed []string
+	c.SetManaged("tx", &managedValue{name: "tx", closed: &closed})
+
+	_ = unix.Close(peer)
+	waitCloseReason(t, closedCh)
+	// OnClose 之后关闭，等待事件循环执行完 handleClose
+	done := make(chan struct{})
+	loop.QueueInLoop(func() { close(done) })
+	<-done
+	if fmt.Sprint(closed) != "[tx]" {
+		t.Fatal(fmt.Sprintf("managed value should be closed on disconnect, but %v", closed))
+	}
+}
+
+// contextCallBack：在事件循环中读写 Context 及 KeyValueContext，并回显消息
+type contextCallBack struct {
+	emptyCallBack
+}
+
+func (cb *contextCallBack) OnMessage(c *Connection, ctx interface{}, data []byte) []byte {
+	_ = c.Context()
+	c.SetContext(string(data))
+	if _, ok := c.Get("sender"); ok {
+		c.Set("loop", string(data))
+	}
+	return data
+}
+
+// TestConnection_ConcurrentContext：其他 goroutine 发送数据、读写上下文的同时事件循环处理消息，用 go test -race 检查数据竞争
+func TestConnection_ConcurrentContext(t *testing.T) {
+	c, peer, loop := newRunningConnectionWith(t, &lineProtocol{}, &contextCallBack{})
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	const senders, rounds, requests = 4, 100, 100
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for j := 0; j < rounds; j++ {
+				if err := c.Send([]byte("push")); err != nil {
+					t.Error(err)
+					return
+				}
+				c.SetContext(id)
+				_ = c.Context()
+				c.Set("sender", j)
+				_, _ = c.Get("loop")
+			}
+		}(i)
+	}
+	go func() {
+		for i := 0; i < requests; i++ {
+			_, _ = unix.Write(peer, []byte("req\n"))
+		}
+	}()
+
+	r := bufio.NewReader(fdReader(peer))
+	pushes, replies := 0, 0
+	for pushes+replies < senders*rounds+requests {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch line {
+		case "push\n":
+			pushes++
+		case "req\n":
+			replies++
+		default:
+			t.Fatalf("unexpected line %q", line)
+		}
+	}
+	wg.Wait()
+	if pushes != senders*rounds || replies != requests {
+		t.Fatalf("expect %d pushes and %d replies, but got %d and %d", senders*rounds, requests, pushes, replies)
+	}
+}
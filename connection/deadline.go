@@ -0,0 +1,88 @@
+package connection
+
+import (
+	"time"
+)
+
+// deadlineChunk：SendWithDeadline 暂存的数据，超过 deadline 仍未开始发送则丢弃
+type deadlineChunk struct {
+	data     []byte
+	deadline time.Time
+}
+
+// SendWithDeadline：发送实时性数据，如果在 deadline 之前 outBuffer 中积压的数据没有写完、
+// 该数据还未开始发送，则直接丢弃，适用于直播、游戏状态等过期即无用的数据流。
+// 数据在开始发送时才经过协议打包，一旦开始发送就会完整发出；
+// 在数据暂存期间调用 Send 发送的数据可能先于其发出
+func (c *Connection) SendWithDeadline(data []byte, deadline time.Time) error {
+	if !c.connected.Get() {
+		return c.closedError()
+	}
+
+	if c.udp {
+		c.loop.QueueInLoop(func() {
+			if c.clock.Now().Before(deadline) {
+				c.sendTo(c.protocol.Packet(c, data))
+			}
+		})
+		return nil
+	}
+
+	generation := c.generation.Get()
+	c.loop.QueueInLoop(func() {
+		if c.generation.Get() != generation {
+			return
+		}
+		c.sendWithDeadlineInLoop(data, deadline)
+	})
+	return nil
+}
+
+func (c *Connection) sendWithDeadlineInLoop(data []byte, deadline time.Time) {
+	if !c.connected.Get() {
+		return
+	}
+	now := c.clock.Now()
+	if !now.Before(deadline) {
+		return
+	}
+	// outBuffer 没有积压则直接发送
+	if c.outBuffer.Length() == 0 && len(c.deadlineChunks) == 0 {
+		c.sendInLoop(c.protocol.Packet(c, data))
+		return
+	}
+
+	c.dropStaleChunks(now)
+	c.deadlineChunks = append(c.deadlineChunks, deadlineChunk{data: data, deadline: deadline})
+}
+
+// dropStaleChunks：丢弃已经过期的暂存数据
+func (c *Connection) dropStaleChunks(now time.Time) {
+	chunks := c.deadlineChunks[:0]
+	for _, chunk := range c.deadlineChunks {
+		if now.Before(chunk.deadline) {
+			chunks = append(chunks, chunk)
+		}
+	}
+	for i := len(chunks); i < len(c.deadlineChunks); i++ {
+		c.deadlineChunks[i] = deadlineChunk{}
+	}
+	c.deadlineChunks = chunks
+}
+
+// flushDeadlineChunks：outBuffer 写完后依次发送未过期的暂存数据，直到再次出现积压
+func (c *Connection) flushDeadlineChunks() {
+	if len(c.deadlineChunks) == 0 {
+		return
+	}
+	c.dropStaleChunks(c.clock.Now())
+	for len(c.deadlineChunks) > 0 && c.outBuffer.Length() == 0 && c.connected.Get() {
+		chunk := c.deadlineChunks[0]
+		c.deadlineChunks[0] = deadlineChunk{}
+		c.deadlineChunks = c.deadlineChunks[1:]
+		c.sendInLoop(c.protocol.Packet(c, chunk.data))
+	}
+	if len(c.deadlineChunks) == 0 {
+		c.deadlineChunks = nil
+	}
+}
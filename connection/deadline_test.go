@@ -0,0 +1,61 @@
+package connection
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestConnection_SendWithDeadline(t *testing.T) {
+	c, peer, loop, _ := newRunningConnection(t, &DefaultProtocol{})
+	defer func() {
+		_ = loop.Stop()
+		_ = unix.Close(peer)
+	}()
+
+	// 对端暂不读取，使 outBuffer 出现积压
+	backlog := bytes.Repeat([]byte("a"), 4<<20)
+	if err := c.Send(backlog); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := c.SendWithDeadline([]byte("stale"), time.Now().Add(20*time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if err := c.SendWithDeadline([]byte("fresh"), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	// 已经过期的数据直接丢弃
+	if err := c.SendWithDeadline([]byte("late"), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	got := make([]byte, len(backlog)+len("fresh"))
+	if _, err := io.ReadFull(fdReader(peer), got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got[:len(backlog)], backlog) {
+		t.Fatal("backlog mismatch")
+	}
+	if tail := string(got[len(backlog):]); tail != "fresh" {
+		t.Fatalf("expect fresh, but got %s", tail)
+	}
+
+	// 没有积压时直接发送
+	if err := c.SendWithDeadline([]byte("now"), time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, err := unix.Read(peer, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "now" {
+		t.Fatalf("expect now, but got %s", buf[:n])
+	}
+}
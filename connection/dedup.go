@@ -0,0 +1,77 @@
+package connection
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Deduplicator：消息去重，用于至少一次投递的协议。客户端重连或重试后重复发送的消息在 OnMessage 之前被丢弃，
+// 实现需要支持多个事件循环并发调用。同一个 Deduplicator 可以被多个连接共享，
+// 以会话为单位而不是以连接为单位记录消息，重连后建立的新连接同样能识别出重复的消息
+type Deduplicator interface {
+	// Duplicate：消息是否已经处理过，未处理过时记录该消息并返回 false
+	Duplicate(c *Connection, ctx interface{}, data []byte) bool
+}
+
+// DedupKeyFunc：从消息中取出幂等键，键应包含会话标识（如客户端 ID 与消息序号），
+// 以便在不同连接之间识别同一条消息，ok 为 false 表示消息不带幂等键，不参与去重
+type DedupKeyFunc func(c *Connection, ctx interface{}, data []byte) (key string, ok bool)
+
+// LRUDeduplicator：以 LRU 淘汰的、容量固定的 Deduplicator，记录最近 size 个幂等键，
+// 早于此的消息重复到达时无法识别，size 应覆盖客户端重连及重试的时间窗口内的消息数
+type LRUDeduplicator struct {
+	mu    sync.Mutex
+	size  int
+	key   DedupKeyFunc
+	order *list.List // 最近使用的在前，元素为幂等键
+	keys  map[string]*list.Element
+}
+
+var _ Deduplicator = &LRUDeduplicator{}
+
+// NewLRUDeduplicator：创建最多记录 size 个幂等键的 LRUDeduplicator，size 小于等于 0 时为 1
+func NewLRUDeduplicator(size int, key DedupKeyFunc) *LRUDeduplicator {
+	if size <= 0 {
+		size = 1
+	}
+	return &LRUDeduplicator{
+		size:  size,
+		key:   key,
+		order: list.New(),
+		keys:  make(map[string]*list.Element, size),
+	}
+}
+
+// Duplicate：实现 Deduplicator
+func (d *LRUDeduplicator) Duplicate(c *Connection, ctx interface{}, data []byte) bool {
+	key, ok := d.key(c, ctx, data)
+	if !ok {
+		return false
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if e, ok := d.keys[key]; ok {
+		d.order.MoveToFront(e)
+		return true
+	}
+	d.keys[key] = d.order.PushFront(key)
+	// 超过容量时淘汰最久没有出现的键
+	if d.order.Len() > d.size {
+		oldest := d.order.Back()
+		d.order.Remove(oldest)
+		delete(d.keys, oldest.Value.(string))
+	}
+	return false
+}
+
+// Len：当前记录的幂等键个数
+func (d *LRUDeduplicator) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.order.Len()
+}
+
+// duplicate：消息是否应在 OnMessage 之前丢弃
+func (c *Connection) duplicate(ctx interface{}, data []byte) bool {
+	return c.dedup != nil && c.dedup.Duplicate(c, ctx, data)
+}
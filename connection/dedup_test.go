@@ -0,0 +1,89 @@
+package connection
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// sessionKey：消息格式为 “会话:序号:内容”，幂等键为 “会话:序号”
+func sessionKey(c *Connection, ctx interface{}, data []byte) (string, bool) {
+	i := bytes.IndexByte(data, ':')
+	if i < 0 {
+		return "", false
+	}
+	j := bytes.IndexByte(data[i+1:], ':')
+	if j < 0 {
+		return "", false
+	}
+	return string(data[:i+1+j]), true
+}
+
+// messageRecorder：记录收到的消息
+type messageRecorder struct {
+	emptyCallBack
+	messages chan string
+}
+
+func (r *messageRecorder) OnMessage(c *Connection, ctx interface{}, data []byte) []byte {
+	r.messages <- string(data)
+	return nil
+}
+
+func TestConnection_DeduplicateAfterReconnect(t *testing.T) {
+	dedup := NewLRUDeduplicator(16, sessionKey)
+	cb := &messageRecorder{messages: make(chan string, 8)}
+	expect := func(msg string) {
+		select {
+		case got := <-cb.messages:
+			if got != msg {
+				t.Fatalf("expect %q, but got %q", msg, got)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("expect %q", msg)
+		}
+	}
+
+	c, peer, loop := newRunningConnectionWith(t, &lineProtocol{}, cb, WithDeduplicator(dedup))
+	defer loop.Stop()
+	if _, err := unix.Write(peer, []byte("s1:1:hello\ns1:1:hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	expect("s1:1:hello")
+	_ = c.Close()
+	_ = unix.Close(peer)
+
+	// 重连后客户端重发未确认的消息
+	_, peer, loop2 := newRunningConnectionWith(t, &lineProtocol{}, cb, WithDeduplicator(dedup))
+	defer unix.Close(peer)
+	defer loop2.Stop()
+	if _, err := unix.Write(peer, []byte("s1:1:hello\ns1:2:world\nno key\n")); err != nil {
+		t.Fatal(err)
+	}
+	expect("s1:2:world")
+	expect("no key")
+	select {
+	case got := <-cb.messages:
+		t.Fatalf("duplicate message should be dropped, but got %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestLRUDeduplicator_Evict(t *testing.T) {
+	d := NewLRUDeduplicator(2, sessionKey)
+	for _, msg := range []string{"a:1:", "a:2:", "a:1:", "a:3:"} {
+		d.Duplicate(nil, nil, []byte(msg))
+	}
+	if d.Len() != 2 {
+		t.Fatalf("expect 2 keys, but got %d", d.Len())
+	}
+	// a:2 最久没有出现，被淘汰
+	if d.Duplicate(nil, nil, []byte("a:2:")) {
+		t.Fatal("a:2 should have been evicted")
+	}
+	if !d.Duplicate(nil, nil, []byte("a:3:")) {
+		t.Fatal("a:3 should be a duplicate")
+	}
+}
@@ -0,0 +1,65 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 连接相关的错误，均可以通过 errors.Is 判断，Send、Close 返回及 CloseReason 中的错误可能经过包装
+var (
+	// ErrConnectionClosed：连接已关闭
+	ErrConnectionClosed = errors.New("connection closed")
+	// ErrServerShutdown：Server 已停止，连接随之关闭，同时满足 errors.Is(err, ErrConnectionClosed)
+	ErrServerShutdown = fmt.Errorf("%w: server shutdown", ErrConnectionClosed)
+	// ErrConnectionMigrated：连接已通过 Freeze 迁移到后继进程，同时满足 errors.Is(err, ErrConnectionClosed)
+	ErrConnectionMigrated = fmt.Errorf("%w: migrated", ErrConnectionClosed)
+	// ErrIdleTimeout：连接空闲超时被关闭
+	ErrIdleTimeout = errors.New("connection idle timeout")
+	// ErrWriteBufferFull：待发送的数据超过写缓冲区上限
+	ErrWriteBufferFull = errors.New("connection write buffer full")
+	// ErrReadBufferOverflow：未能拆包的数据超过读缓冲区上限，连接被关闭
+	ErrReadBufferOverflow = errors.New("connection read buffer overflow")
+	// ErrBufferBudgetExceeded：所有连接的缓冲区超过全局预算，连接被关闭
+	ErrBufferBudgetExceeded = errors.New("connection buffer budget exceeded")
+	// ErrHandshakeTimeout：协议握手（如 TLS）未能在 HandshakeProtocol 限定的时间内完成，连接被关闭
+	ErrHandshakeTimeout = errors.New("connection handshake timeout")
+	// ErrTooManyProtocolErrors：窗口时间内协议错误的次数达到 ProtocolErrorLimit 设置的上限，连接被关闭
+	ErrTooManyProtocolErrors = errors.New("connection too many protocol errors")
+	// ErrConnectionReset：连接通过 Reset 以 RST 中止
+	ErrConnectionReset = errors.New("connection reset")
+	// ErrPollerFailure：在事件循环中注册或修改关注的事件失败（epoll_ctl 出错），连接被关闭
+	ErrPollerFailure = errors.New("connection poller failure")
+	// ErrProtocolNoProgress：UnPacket 连续多次返回了 ctx 但数据为空，且没有消耗 buffer 中的数据，继续拆包会陷入死循环
+	ErrProtocolNoProgress = errors.New("connection protocol made no progress")
+)
+
+// closedError：连接已关闭时 Send、Close 等返回的错误，Server 停止后为 ErrServerShutdown
+func (c *Connection) closedError() error {
+	if c.loop != nil && c.loop.Stopped() {
+		return ErrServerShutdown
+	}
+	return ErrConnectionClosed
+}
+
+// pollerError：事件循环操作失败的错误，同时满足 errors.Is(err, ErrPollerFailure) 及 errors.Is(err, 原始错误)
+type pollerError struct {
+	op  string
+	err error
+}
+
+func (e *pollerError) Error() string {
+	return ErrPollerFailure.Error() + ": " + e.op + ": " + e.err.Error()
+}
+
+func (e *pollerError) Unwrap() error {
+	return e.err
+}
+
+func (e *pollerError) Is(target error) bool {
+	return target == ErrPollerFailure
+}
+
+// opError：包装读写系统调用的错误，保留原始错误以便 errors.Is 判断
+func opError(op string, err error) error {
+	return fmt.Errorf("%s: %w", op, err)
+}
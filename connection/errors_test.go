@@ -0,0 +1,166 @@
+package connection
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"golang.org/x/sys/unix"
+)
+
+// closeCallBack：连接关闭时通知
+type closeCallBack struct {
+	emptyCallBack
+	closed chan error
+}
+
+func (e *closeCallBack) OnClose(c *Connection) {
+	e.closed <- c.CloseReason()
+}
+
+func newRunningConnection(t *testing.T, protocol Protocol, opts ...Option) (*Connection, int, *eventloop.EventLoop, chan error) {
+	cb := &closeCallBack{closed: make(chan error, 1)}
+	c, peer, loop := newRunningConnectionWith(t, protocol, cb, opts...)
+	return c, peer, loop, cb.closed
+}
+
+// newRunningConnectionWith：创建一个已加入运行中事件循环的 Connection
+func newRunningConnectionWith(t testing.TB, protocol Protocol, cb CallBack, opts ...Option) (*Connection, int, *eventloop.EventLoop) {
+	fd, peer := newSocketPair(t)
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go loop.RunLoop()
+	// 等待事件循环启动
+	started := make(chan struct{})
+	loop.QueueInLoop(func() { close(started) })
+	<-started
+
+	c := New(fd, loop, nil, protocol, nil, 0, cb, opts...)
+	if err := loop.AddSocketAndEnableRead(fd, c); err != nil {
+		t.Fatal(err)
+	}
+	return c, peer, loop
+}
+
+func waitCloseReason(t *testing.T, closed chan error) error {
+	select {
+	case err := <-closed:
+		return err
+	case <-time.After(time.Second * 3):
+		t.Fatal("connection should be closed")
+	}
+	return nil
+}
+
+func TestErrors_Is(t *testing.T) {
+	if !errors.Is(ErrServerShutdown, ErrConnectionClosed) {
+		t.Fatal("ErrServerShutdown should match ErrConnectionClosed")
+	}
+	if errors.Is(ErrConnectionClosed, ErrServerShutdown) {
+		t.Fatal("ErrConnectionClosed should not match ErrServerShutdown")
+	}
+}
+
+func TestErrors_ReadBufferOverflow(t *testing.T) {
+	c, peer, loop, closed := newRunningConnection(t, &lineProtocol{}, MaxReadBufferSize(16))
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	if _, err := unix.Write(peer, make([]byte, 64)); err != nil {
+		t.Fatal(err)
+	}
+	if reason := waitCloseReason(t, closed); !errors.Is(reason, ErrReadBufferOverflow) {
+		t.Fatalf("expect ErrReadBufferOverflow, but got %v", reason)
+	}
+	if err := c.Send([]byte("late")); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("expect ErrConnectionClosed, but got %v", err)
+	}
+}
+
+func TestErrors_WriteError(t *testing.T) {
+	c, peer, loop, closed := newRunningConnection(t, &DefaultProtocol{})
+	defer loop.Stop()
+
+	_ = unix.Close(peer)
+	_ = c.Send([]byte("hello"))
+	reason := waitCloseReason(t, closed)
+	// 对端关闭时可能先读到 EOF，也可能先写出失败
+	if !errors.Is(reason, unix.EPIPE) && reason.Error() != "EOF" {
+		t.Fatalf("expect EPIPE or EOF, but got %v", reason)
+	}
+}
+
+func TestErrors_ServerShutdown(t *testing.T) {
+	c, peer, loop, closed := newRunningConnection(t, &DefaultProtocol{})
+	defer unix.Close(peer)
+
+	if err := loop.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if reason := waitCloseReason(t, closed); reason != ErrServerShutdown {
+		t.Fatalf("expect ErrServerShutdown, but got %v", reason)
+	}
+	err := c.Send([]byte("late"))
+	if !errors.Is(err, ErrServerShutdown) || !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("expect ErrServerShutdown, but got %v", err)
+	}
+}
+
+// errorCallBack：记录 OnError
+type errorCallBack struct {
+	closeCallBack
+	err error
+}
+
+func (e *errorCallBack) OnError(c *Connection, err error) {
+	e.err = err
+}
+
+func TestConnection_PollerFailure(t *testing.T) {
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go loop.RunLoop()
+	defer func() { _ = loop.Stop() }()
+
+	// 连接没有加入 epoll，写缓冲区积压时关注可写事件会失败（ENOENT）
+	fd, peer := newSocketPair(t)
+	defer unix.Close(peer)
+	cb := &errorCallBack{closeCallBack: closeCallBack{closed: make(chan error, 1)}}
+	c := New(fd, loop, nil, &DefaultProtocol{}, nil, 0, cb)
+	if err := c.Send(make([]byte, 4<<20)); err != nil {
+		t.Fatal(err)
+	}
+
+	reason := waitCloseReason(t, cb.closed)
+	if !errors.Is(reason, ErrPollerFailure) || !errors.Is(reason, unix.ENOENT) {
+		t.Fatalf("expect ErrPollerFailure wrapping ENOENT, but got %v", reason)
+	}
+	if cb.err != reason {
+		t.Fatalf("expect OnError called with the close reason, but got %v", cb.err)
+	}
+	if c.Connected() {
+		t.Fatal("connection should be closed")
+	}
+}
+
+// TestErrors_CloseIdempotent：对端关闭与主动 Close 同时发生时 OnClose 只回调一次
+func TestErrors_CloseIdempotent(t *testing.T) {
+	cb := &closeCallBack{closed: make(chan error, 4)}
+	c, peer, loop := newRunningConnectionWith(t, &lineProtocol{}, cb)
+	defer loop.Stop()
+
+	_ = unix.Close(peer)
+	_ = c.Close()
+	_ = c.Close()
+	waitCloseReason(t, cb.closed)
+	select {
+	case err := <-cb.closed:
+		t.Fatalf("expect OnClose once, but got a second call with %v", err)
+	case <-time.After(time.Millisecond * 100):
+	}
+}
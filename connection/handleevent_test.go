@@ -0,0 +1,115 @@
+package connection
+
+import (
+	"bytes"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"github.com/Dongxiem/fastnet/poller"
+	"golang.org/x/sys/unix"
+)
+
+// echoEventCallBack：原样回写收到的数据
+type echoEventCallBack struct{}
+
+func (e *echoEventCallBack) OnConnect(c *Connection) {}
+func (e *echoEventCallBack) OnMessage(c *Connection, ctx interface{}, data []byte) []byte {
+	return data
+}
+func (e *echoEventCallBack) OnClose(c *Connection) {}
+
+// TestConnection_HandleEventRead：outBuffer 为空时读事件同样需要处理
+func TestConnection_HandleEventRead(t *testing.T) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fds[1])
+	if err := unix.SetNonblock(fds[0], true); err != nil {
+		t.Fatal(err)
+	}
+
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go loop.RunLoop()
+	defer loop.Stop()
+	c := New(fds[0], loop, nil, &DefaultProtocol{}, nil, 0, &echoEventCallBack{})
+	if err := loop.AddSocketAndEnableRead(fds[0], c); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := unix.Write(fds[1], []byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	tv := unix.NsecToTimeval(time.Second.Nanoseconds())
+	if err := unix.SetsockoptTimeval(fds[1], unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if n, err := unix.Read(fds[1], buf); err != nil || string(buf[:n]) != "ping" {
+		t.Fatalf("expect the read event to be handled, but got %q, %v", buf[:n], err)
+	}
+}
+
+// countingSocket：统计事件循环回调 HandleEvent 的次数
+type countingSocket struct {
+	*Connection
+	events int64
+}
+
+func (s *countingSocket) HandleEvent(fd int, events poller.Event) {
+	atomic.AddInt64(&s.events, 1)
+	s.Connection.HandleEvent(fd, events)
+}
+
+// TestConnection_BackloggedReadNoSpin：outBuffer 积压期间对端发来的数据不会让水平触发的读事件反复触发，写空后恢复读取
+func TestConnection_BackloggedReadNoSpin(t *testing.T) {
+	fd, peer := newSocketPair(t)
+	defer unix.Close(peer)
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go loop.RunLoop()
+	defer loop.Stop()
+
+	c := New(fd, loop, nil, &DefaultProtocol{}, nil, 0, &echoEventCallBack{})
+	s := &countingSocket{Connection: c}
+	if err := loop.AddSocketAndEnableRead(fd, s); err != nil {
+		t.Fatal(err)
+	}
+
+	// 对端不读取，大于 socket 缓冲区的数据必然积压
+	data := bytes.Repeat([]byte("a"), 4<<20)
+	if err := c.Send(data); err != nil {
+		t.Fatal(err)
+	}
+	buffered := make(chan int, 1)
+	loop.QueueInLoop(func() { buffered <- c.outBuffer.Length() })
+	if <-buffered == 0 {
+		t.Fatal("expect outBuffer to be backlogged")
+	}
+
+	atomic.StoreInt64(&s.events, 0)
+	if _, err := unix.Write(peer, []byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 100)
+	if n := atomic.LoadInt64(&s.events); n > 10 {
+		t.Fatalf("expect the loop to wait for writability, but HandleEvent was called %d times", n)
+	}
+
+	// 读走积压的数据后，之前收到的数据被处理并回显
+	got := make([]byte, len(data)+4)
+	if _, err := io.ReadFull(fdReader(peer), got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got[:len(data)], data) || string(got[len(data):]) != "ping" {
+		t.Fatalf("expect backlog followed by echo, but got tail %q", got[len(data):])
+	}
+}
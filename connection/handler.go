@@ -0,0 +1,19 @@
+package connection
+
+// MessageHandler：处理一条消息，与 CallBack.OnMessage 相同，返回的数据经过协议打包后发送
+type MessageHandler func(c *Connection, ctx interface{}, data []byte) []byte
+
+// SetOnMessage：为该连接单独设置消息处理函数，设置后该连接的消息不再交给 CallBack 的 OnMessage 或 OnMessages，
+// 适用于连接在认证等状态切换后改用另一套处理逻辑的场景，h 为 nil 时恢复使用 CallBack。
+// 只能在连接所属的事件循环中调用（如 OnConnect、OnMessage 中），从下一条消息开始生效
+func (c *Connection) SetOnMessage(h MessageHandler) {
+	c.onMessage = h
+}
+
+// onMessageHandler：返回处理消息使用的函数
+func (c *Connection) onMessageHandler() MessageHandler {
+	if c.onMessage != nil {
+		return c.onMessage
+	}
+	return c.callBack.OnMessage
+}
@@ -0,0 +1,53 @@
+package connection
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// loginCallBack：认证前回显，收到 login 后改用 authenticated 处理该连接的消息
+type loginCallBack struct {
+	emptyCallBack
+}
+
+func (l *loginCallBack) OnMessage(c *Connection, ctx interface{}, data []byte) []byte {
+	if string(data) == "login" {
+		c.SetOnMessage(authenticated)
+		return []byte("ok")
+	}
+	return data
+}
+
+func authenticated(c *Connection, ctx interface{}, data []byte) []byte {
+	if string(data) == "logout" {
+		c.SetOnMessage(nil)
+		return []byte("bye")
+	}
+	return append([]byte("auth:"), data...)
+}
+
+func TestConnection_SetOnMessage(t *testing.T) {
+	_, peer, loop := newRunningConnectionWith(t, &lineProtocol{}, &loginCallBack{})
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	// 同一次读事件中的后续消息立即使用新的处理函数
+	if _, err := unix.Write(peer, []byte("a\nlogin\nb\nlogout\nc\n")); err != nil {
+		t.Fatal(err)
+	}
+	expect := []byte("a\nok\nauth:b\nbye\nc\n")
+	var got []byte
+	buf := make([]byte, 64)
+	for len(got) < len(expect) {
+		n, err := unix.Read(peer, buf)
+		if err != nil || n == 0 {
+			t.Fatalf("read: %d, %v", n, err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if !bytes.Equal(got, expect) {
+		t.Fatalf("expect %q, but got %q", expect, got)
+	}
+}
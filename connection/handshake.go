@@ -0,0 +1,37 @@
+package connection
+
+import "time"
+
+// HandshakeProtocol：可选的协议接口，需要握手的协议（如 TLS）实现。
+// HandshakeTimeout 大于 0 时，连接建立后超过该时间 HandshakeDone 仍返回 false，则以 ErrHandshakeTimeout 关闭连接，
+// 避免只建立 TCP 连接而不发起握手的客户端一直占用连接
+type HandshakeProtocol interface {
+	// HandshakeTimeout：握手需要在连接建立后的多长时间内完成，小于等于 0 表示不限制
+	HandshakeTimeout() time.Duration
+	// HandshakeDone：连接的握手是否已经完成，在事件循环中调用
+	HandshakeDone(c *Connection) bool
+}
+
+// startHandshakeTimer：协议实现了 HandshakeProtocol 时，启动握手超时的定时器
+func (c *Connection) startHandshakeTimer() {
+	p, ok := c.protocol.(HandshakeProtocol)
+	if !ok {
+		return
+	}
+	timeout := p.HandshakeTimeout()
+	if timeout <= 0 {
+		return
+	}
+	generation := c.generation.Get()
+	c.clock.AfterFunc(timeout, func() {
+		// 协议的握手状态只能在事件循环中访问
+		c.loop.QueueInLoop(func() {
+			if c.generation.Get() != generation || !c.connected.Get() {
+				return
+			}
+			if !p.HandshakeDone(c) {
+				c.closeWithReason(c.fd, ErrHandshakeTimeout)
+			}
+		})
+	})
+}
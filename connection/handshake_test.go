@@ -0,0 +1,57 @@
+package connection
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+	"golang.org/x/sys/unix"
+)
+
+// handshakeLineProtocol：收到第一行数据即视为握手完成
+type handshakeLineProtocol struct {
+	lineProtocol
+	done bool
+}
+
+func (p *handshakeLineProtocol) UnPacket(c *Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	ctx, data := p.lineProtocol.UnPacket(c, buffer)
+	if data != nil {
+		p.done = true
+	}
+	return ctx, data
+}
+
+func (p *handshakeLineProtocol) HandshakeTimeout() time.Duration {
+	return time.Millisecond * 50
+}
+
+func (p *handshakeLineProtocol) HandshakeDone(c *Connection) bool {
+	return p.done
+}
+
+func TestConnection_HandshakeTimeout(t *testing.T) {
+	_, peer, loop, closed := newRunningConnection(t, &handshakeLineProtocol{})
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	if reason := waitCloseReason(t, closed); !errors.Is(reason, ErrHandshakeTimeout) {
+		t.Fatalf("expect ErrHandshakeTimeout, but got %v", reason)
+	}
+}
+
+func TestConnection_HandshakeDone(t *testing.T) {
+	_, peer, loop, closed := newRunningConnection(t, &handshakeLineProtocol{})
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	if _, err := unix.Write(peer, []byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case reason := <-closed:
+		t.Fatalf("connection should stay open after the handshake, but closed with %v", reason)
+	case <-time.After(time.Millisecond * 200):
+	}
+}
@@ -0,0 +1,116 @@
+package connection
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"github.com/Dongxiem/fastnet/tool/clock"
+	"golang.org/x/sys/unix"
+)
+
+func TestConnection_ResetIdle(t *testing.T) {
+	fake := clock.NewFake(time.Unix(1000, 0))
+
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go loop.RunLoop()
+	defer func() { _ = loop.Stop() }()
+
+	fd, peer := newSocketPair(t)
+	defer unix.Close(peer)
+	cb := &closeCallBack{closed: make(chan error, 1)}
+	c := New(fd, loop, nil, &DefaultProtocol{}, nil, 200*time.Millisecond, cb, WithClock(fake))
+	if err := loop.AddSocketAndEnableRead(fd, c); err != nil {
+		t.Fatal(err)
+	}
+
+	// 持续重置空闲计时，超过空闲超时时间后连接仍然存活
+	for i := 0; i < 5; i++ {
+		fake.Advance(100 * time.Millisecond)
+		c.ResetIdle()
+		if !c.LastActive().Equal(fake.Now()) {
+			t.Fatalf("expect idle reset at %v, but got %v", fake.Now(), c.LastActive())
+		}
+	}
+	select {
+	case reason := <-cb.closed:
+		t.Fatalf("connection should not be closed after ResetIdle, but closed with %v", reason)
+	default:
+	}
+
+	// 停止重置后，距最近一次活跃不足空闲超时时间时不关闭
+	fake.Advance(199 * time.Millisecond)
+	if !c.Connected() {
+		t.Fatal("connection should not be closed before idle timeout")
+	}
+	// 到达空闲超时时间后关闭
+	fake.Advance(time.Millisecond)
+	reason := waitCloseReason(t, cb.closed)
+	if !errors.Is(reason, ErrIdleTimeout) {
+		t.Fatalf("expect ErrIdleTimeout, but got %v", reason)
+	}
+}
+
+func TestConnection_SetIdleTimeout(t *testing.T) {
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go loop.RunLoop()
+	defer func() { _ = loop.Stop() }()
+
+	newConn := func(fake *clock.Fake, idle time.Duration) (*Connection, chan error) {
+		fd, peer := newSocketPair(t)
+		t.Cleanup(func() { _ = unix.Close(peer) })
+		cb := &closeCallBack{closed: make(chan error, 1)}
+		c := New(fd, loop, nil, &DefaultProtocol{}, nil, idle, cb, WithClock(fake))
+		if err := loop.AddSocketAndEnableRead(fd, c); err != nil {
+			t.Fatal(err)
+		}
+		return c, cb.closed
+	}
+	expectAlive := func(closed chan error) {
+		time.Sleep(20 * time.Millisecond)
+		select {
+		case reason := <-closed:
+			t.Fatalf("connection should not be closed, but closed with %v", reason)
+		default:
+		}
+	}
+
+	// 创建时没有设置空闲超时，之后开启
+	fake := clock.NewFake(time.Unix(1000, 0))
+	c, closed := newConn(fake, 0)
+	fake.Advance(time.Hour)
+	expectAlive(closed)
+	c.SetIdleTimeout(100 * time.Millisecond)
+	fake.Advance(99 * time.Millisecond)
+	expectAlive(closed)
+	fake.Advance(time.Millisecond)
+	if reason := waitCloseReason(t, closed); !errors.Is(reason, ErrIdleTimeout) {
+		t.Fatalf("expect ErrIdleTimeout, but got %v", reason)
+	}
+
+	// 缩短空闲超时时间，之前按 1s 调度的检测失效
+	fake = clock.NewFake(time.Unix(1000, 0))
+	c, closed = newConn(fake, time.Second)
+	c.SetIdleTimeout(100 * time.Millisecond)
+	fake.Advance(100 * time.Millisecond)
+	if reason := waitCloseReason(t, closed); !errors.Is(reason, ErrIdleTimeout) {
+		t.Fatalf("expect ErrIdleTimeout, but got %v", reason)
+	}
+
+	// 关闭空闲检测
+	fake = clock.NewFake(time.Unix(1000, 0))
+	c, closed = newConn(fake, 100*time.Millisecond)
+	c.SetIdleTimeout(0)
+	fake.Advance(time.Hour)
+	expectAlive(closed)
+	if !c.LastActive().IsZero() {
+		t.Fatal("expect zero LastActive with idle detection disabled")
+	}
+}
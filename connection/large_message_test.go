@@ -0,0 +1,131 @@
+package connection
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+	"golang.org/x/sys/unix"
+)
+
+// lengthProtocol：[4 字节大端长度][内容] 格式的协议，仅供测试使用
+type lengthProtocol struct{}
+
+func (p *lengthProtocol) UnPacket(c *Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	if buffer.Length() < 4 {
+		return nil, nil
+	}
+	n := int(buffer.PeekUint32())
+	if buffer.Length() < 4+n {
+		return nil, nil
+	}
+	buffer.Retrieve(4)
+	data := make([]byte, n)
+	_, _ = buffer.Read(data)
+	return nil, data
+}
+
+func (p *lengthProtocol) Packet(c *Connection, data []byte) []byte {
+	ret := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(ret, uint32(len(data)))
+	copy(ret[4:], data)
+	return ret
+}
+
+// messageCallBack：记录收到的消息并回复消息长度
+type messageCallBack struct {
+	closeCallBack
+	messages chan []byte
+}
+
+func (m *messageCallBack) OnMessage(c *Connection, ctx interface{}, data []byte) []byte {
+	m.messages <- data
+	return []byte("ok")
+}
+
+func sendLargeMessage(t *testing.T, opts ...Option) (*messageCallBack, []byte) {
+	cb := &messageCallBack{
+		closeCallBack: closeCallBack{closed: make(chan error, 1)},
+		messages:      make(chan []byte, 1),
+	}
+	_, peer, loop := newRunningConnectionWith(t, &lengthProtocol{}, cb, opts...)
+	t.Cleanup(func() {
+		_ = loop.Stop()
+		_ = unix.Close(peer)
+	})
+
+	// 5MB 的消息远大于事件循环的 PacketBuf，需要多次读取才能拼出完整的消息
+	msg := make([]byte, 5<<20)
+	for i := range msg {
+		msg[i] = byte(i % 251)
+	}
+	packet := (&lengthProtocol{}).Packet(nil, msg)
+	if len(packet) <= len(loop.PacketBuf()) {
+		t.Fatal("message should be larger than PacketBuf")
+	}
+
+	if err := unix.SetNonblock(peer, false); err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		// 分成大小不一的小块写入，模拟消息在多次读事件中陆续到达
+		for off, step := 0, 1000; off < len(packet); off, step = off+step, step*2%65521+1 {
+			end := off + step
+			if end > len(packet) {
+				end = len(packet)
+			}
+			if _, err := unix.Write(peer, packet[off:end]); err != nil {
+				return
+			}
+		}
+	}()
+	return cb, msg
+}
+
+func TestConnection_LargeMessage(t *testing.T) {
+	cb, msg := sendLargeMessage(t)
+
+	select {
+	case got := <-cb.messages:
+		if !bytes.Equal(got, msg) {
+			t.Fatal("large message should be reassembled correctly")
+		}
+	case reason := <-cb.closed:
+		t.Fatalf("connection should not be closed, reason: %v", reason)
+	case <-time.After(time.Second * 10):
+		t.Fatal("large message should be received")
+	}
+}
+
+func TestConnection_LargeMessageWithinReadBufferLimit(t *testing.T) {
+	cb, msg := sendLargeMessage(t, MaxReadBufferSize(8<<20))
+
+	select {
+	case got := <-cb.messages:
+		if !bytes.Equal(got, msg) {
+			t.Fatal("large message should be reassembled correctly")
+		}
+	case reason := <-cb.closed:
+		t.Fatalf("connection should not be closed, reason: %v", reason)
+	case <-time.After(time.Second * 10):
+		t.Fatal("large message should be received")
+	}
+}
+
+func TestConnection_LargeMessageOverReadBufferLimit(t *testing.T) {
+	cb, _ := sendLargeMessage(t, MaxReadBufferSize(1<<20))
+
+	select {
+	case <-cb.messages:
+		t.Fatal("message over MaxReadBufferSize should not be delivered")
+	case reason := <-cb.closed:
+		if !errors.Is(reason, ErrReadBufferOverflow) {
+			t.Fatalf("expect ErrReadBufferOverflow, but got %v", reason)
+		}
+	case <-time.After(time.Second * 10):
+		t.Fatal("connection should be closed")
+	}
+}
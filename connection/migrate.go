@@ -0,0 +1,166 @@
+package connection
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// Snapshot：冻结的连接状态，与 fd 一起交给后继进程以实现不停机升级。
+// In 为尚未拆包的数据，Out 为尚未写出的数据，State 为应用通过序列化函数保存的状态
+type Snapshot struct {
+	PeerAddr string
+	In       []byte
+	Out      []byte
+	State    []byte
+}
+
+// Freeze：冻结连接，返回复制出的 fd 及连接状态，用于将连接迁移到后继进程。
+// serialize 在事件循环中调用，用于保存应用自身的协议状态（可以读取 KeyValueContext），为 nil 时不保存。
+// 冻结后原连接以 ErrConnectionMigrated 为原因关闭（会回调 OnClose），但 TCP 连接由返回的 fd 保持，
+// 调用方通过 SendSnapshot 交给后继进程后应关闭该 fd。SendWithDeadline 暂存的数据不会被保存。
+// Freeze 会等待事件循环执行完成，不能在事件循环 goroutine（如 OnMessage）中调用
+func (c *Connection) Freeze(serialize func(c *Connection) ([]byte, error)) (int, *Snapshot, error) {
+	if !c.connected.Get() || c.loop.Stopped() {
+		return -1, nil, c.closedError()
+	}
+	if c.udp {
+		return -1, nil, ErrUDPNotSupported
+	}
+
+	var (
+		fd   = -1
+		snap *Snapshot
+		err  error
+		done = make(chan struct{})
+	)
+	generation := c.generation.Get()
+	c.loop.QueueInLoop(func() {
+		defer close(done)
+		if c.generation.Get() != generation {
+			err = ErrConnectionClosed
+			return
+		}
+		fd, snap, err = c.freezeInLoop(serialize)
+	})
+	<-done
+	return fd, snap, err
+}
+
+func (c *Connection) freezeInLoop(serialize func(c *Connection) ([]byte, error)) (int, *Snapshot, error) {
+	if !c.connected.Get() {
+		return -1, nil, c.closedError()
+	}
+	snap := &Snapshot{PeerAddr: c.peerAddr}
+	if serialize != nil {
+		state, err := serialize(c)
+		if err != nil {
+			return -1, nil, err
+		}
+		snap.State = state
+	}
+
+	fd, err := unix.FcntlInt(uintptr(c.fd), unix.F_DUPFD_CLOEXEC, 0)
+	if err != nil {
+		return -1, nil, err
+	}
+	snap.In = peekAll(c.inBuffer.PeekAll())
+	snap.Out = peekAll(c.outBuffer.PeekAll())
+	c.inBuffer.RetrieveAll()
+	c.outBuffer.RetrieveAll()
+	// 关闭的是原 fd，复制出的 fd 仍然保持着 TCP 连接
+	c.closeWithReason(c.fd, ErrConnectionMigrated)
+	return fd, snap, nil
+}
+
+func peekAll(first, end []byte) []byte {
+	data := make([]byte, 0, len(first)+len(end))
+	data = append(data, first...)
+	return append(data, end...)
+}
+
+// LoadSnapshot：内部使用，后继进程恢复连接时将冻结的读写缓冲区数据载入新连接，需要在加入事件循环之前调用
+func (c *Connection) LoadSnapshot(snap *Snapshot) {
+	_, _ = c.inBuffer.Write(snap.In)
+	_, _ = c.outBuffer.Write(snap.Out)
+}
+
+// Resume：内部使用，恢复的连接加入事件循环后在事件循环中调用，处理载入的未拆包数据并继续写出未发送的数据
+func (c *Connection) Resume() {
+	if !c.connected.Get() {
+		return
+	}
+	if c.inBuffer.Length() > 0 {
+		c.sendBuffersInLoop(c.handlerProtocol(c.inBuffer))
+		if c.closeOnProtocolErrors(c.fd) {
+			return
+		}
+	}
+	if c.connected.Get() && c.outBuffer.Length() > 0 {
+		c.enableWrite(c.fd)
+	}
+	c.accountBuffers()
+}
+
+// SendSnapshot：通过 Unix Socket 将 fd（SCM_RIGHTS）及连接状态发送给后继进程
+func SendSnapshot(conn *net.UnixConn, fd int, snap *Snapshot) error {
+	payload, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(len(payload)))
+	if _, _, err := conn.WriteMsgUnix(header, unix.UnixRights(fd), nil); err != nil {
+		return err
+	}
+	_, err = conn.Write(payload)
+	return err
+}
+
+// errNoRights：接收到的消息中没有 fd
+var errNoRights = errors.New("snapshot: no file descriptor received")
+
+// ReceiveSnapshot：接收 SendSnapshot 发送的 fd 及连接状态
+func ReceiveSnapshot(conn *net.UnixConn) (int, *Snapshot, error) {
+	header := make([]byte, 4)
+	oob := make([]byte, unix.CmsgSpace(4))
+	n, oobn, _, _, err := conn.ReadMsgUnix(header, oob)
+	if err != nil {
+		return -1, nil, err
+	}
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return -1, nil, err
+	}
+	if len(msgs) == 0 {
+		return -1, nil, errNoRights
+	}
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	if err != nil {
+		return -1, nil, err
+	}
+	if len(fds) == 0 {
+		return -1, nil, errNoRights
+	}
+	fd := fds[0]
+
+	if _, err := io.ReadFull(conn, header[n:]); err != nil {
+		_ = unix.Close(fd)
+		return -1, nil, err
+	}
+	payload := make([]byte, binary.BigEndian.Uint32(header))
+	if _, err := io.ReadFull(conn, payload); err != nil {
+		_ = unix.Close(fd)
+		return -1, nil, err
+	}
+	snap := new(Snapshot)
+	if err := json.Unmarshal(payload, snap); err != nil {
+		_ = unix.Close(fd)
+		return -1, nil, err
+	}
+	return fd, snap, nil
+}
@@ -0,0 +1,116 @@
+package connection
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"golang.org/x/sys/unix"
+)
+
+// newUnixConnPair：创建一对 *net.UnixConn
+func newUnixConnPair(t *testing.T) (*net.UnixConn, *net.UnixConn) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := make([]*net.UnixConn, 2)
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "")
+		conn, err := net.FileConn(f)
+		_ = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns[i] = conn.(*net.UnixConn)
+	}
+	return conns[0], conns[1]
+}
+
+func TestConnection_Freeze(t *testing.T) {
+	c, peer, loop, closed := newRunningConnection(t, &lineProtocol{})
+	defer func() {
+		_ = loop.Stop()
+		_ = unix.Close(peer)
+	}()
+
+	// outBuffer 中积压待发送的数据，inBuffer 中有未拆包的数据
+	if _, err := unix.Write(peer, []byte("partial")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	backlog := bytes.Repeat([]byte("a"), 4<<20)
+	if err := c.Send(backlog); err != nil {
+		t.Fatal(err)
+	}
+
+	fd, snap, err := c.Freeze(func(c *Connection) ([]byte, error) {
+		return []byte("state"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(snap.In) != "partial" || len(snap.Out) == 0 || string(snap.State) != "state" {
+		t.Fatalf("unexpected snapshot: in %q, out %d bytes, state %q", snap.In, len(snap.Out), snap.State)
+	}
+	if reason := waitCloseReason(t, closed); !errors.Is(reason, ErrConnectionMigrated) {
+		t.Fatalf("expect ErrConnectionMigrated, but got %v", reason)
+	}
+	if _, _, err := c.Freeze(nil); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("expect ErrConnectionClosed, but got %v", err)
+	}
+
+	// 通过 Unix Socket 交给另一个事件循环中的连接
+	src, dst := newUnixConnPair(t)
+	defer src.Close()
+	defer dst.Close()
+	go func() {
+		if err := SendSnapshot(src, fd, snap); err != nil {
+			t.Error(err)
+		}
+		_ = unix.Close(fd)
+	}()
+	fd2, snap2, err := ReceiveSnapshot(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(snap2.Out, snap.Out) || string(snap2.In) != "partial" {
+		t.Fatal("snapshot mismatch after transfer")
+	}
+
+	loop2, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go loop2.RunLoop()
+	defer func() { _ = loop2.Stop() }()
+	if err := unix.SetNonblock(fd2, true); err != nil {
+		t.Fatal(err)
+	}
+	c2 := New(fd2, loop2, nil, &lineProtocol{}, nil, 0, &echoCallBack{})
+	c2.LoadSnapshot(snap2)
+	loop2.QueueInLoop(func() {
+		if err := loop2.AddSocketAndEnableRead(fd2, c2); err != nil {
+			t.Error(err)
+		}
+		c2.Resume()
+	})
+
+	// 剩余的数据继续写出，未拆包的数据与新数据拼接成完整的消息
+	if _, err := unix.Write(peer, []byte(" data\n")); err != nil {
+		t.Fatal(err)
+	}
+	expect := append(append(backlog, '\n'), "partial data\n"...)
+	got := make([]byte, len(expect))
+	if _, err := io.ReadFull(fdReader(peer), got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expect) {
+		t.Fatalf("unexpected data after migration: %q", got[len(backlog):])
+	}
+}
@@ -0,0 +1,117 @@
+package connection
+
+import (
+	"time"
+
+	"github.com/Dongxiem/fastnet/tool/clock"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer/pool"
+)
+
+// Option：Connection 的可选配置
+type Option func(*Connection)
+
+// AllowHalfClose：对端关闭写端（读到 EOF）时不关闭连接，而是进入只写状态并调用 OnReadClose，
+// 之后仍可以继续发送剩余的响应，由应用调用 Close 或 ShutdownWrite 结束连接
+func AllowHalfClose(allow bool) Option {
+	return func(c *Connection) {
+		c.allowHalfClose = allow
+	}
+}
+
+// CloseHook：OnClose 之后调用的钩子，独立于用户回调，供框架内部（如审计日志）使用
+func CloseHook(f func(c *Connection)) Option {
+	return func(c *Connection) {
+		c.closeHook = f
+	}
+}
+
+// MaxReadBufferSize：inBuffer 中未能拆包的数据上限，超过时以 ErrReadBufferOverflow 为原因关闭连接，0 表示不限制
+func MaxReadBufferSize(n int) Option {
+	return func(c *Connection) {
+		c.maxReadBufferSize = n
+	}
+}
+
+// WithBufferBudget：连接的读写缓冲区计入全局预算 b，缓冲区扩容导致超出预算时以 ErrBufferBudgetExceeded 为原因关闭连接
+func WithBufferBudget(b *BufferBudget) Option {
+	return func(c *Connection) {
+		c.budget = b
+	}
+}
+
+// WithBufferPool：从 p 获取读写缓冲区，连接关闭后归还到 p，用于为不同类型的连接使用不同初始大小的缓冲区。
+// 连接池预分配的连接使用自带的缓冲区，不受该选项影响
+func WithBufferPool(p *pool.RingBufferPool) Option {
+	return func(c *Connection) {
+		c.bufferPool = p
+	}
+}
+
+// WriteBatching：合并同一轮事件循环中的多次 Send，经过协议打包后通过一次 writev 写出，
+// 适用于在循环中连续调用 Send 发送大量小消息的场景，可以大幅减少写系统调用的次数。
+// 只对 Send 生效，SendInLoop、OnMessage 的返回值等在事件循环中发送的数据不受影响
+func WriteBatching(enable bool) Option {
+	return func(c *Connection) {
+		c.writeBatching = enable
+	}
+}
+
+// ProtocolErrorLimit：window 时间内通过 ReportProtocolError 报告的协议错误达到 n 次时，
+// 以 ErrTooManyProtocolErrors 为原因关闭连接，window 为 0 时不限时间，n 为 0 表示不限制
+func ProtocolErrorLimit(n int, window time.Duration) Option {
+	return func(c *Connection) {
+		c.protoErrLimit = n
+		c.protoErrWindow = window
+	}
+}
+
+// WithDeduplicator：拆出的消息先交给 d 判断，重复的消息不回调 OnMessage（也不计入 OnMessages 的消息），
+// 多个连接共享同一个 d 时可以识别客户端重连后重发的消息
+func WithDeduplicator(d Deduplicator) Option {
+	return func(c *Connection) {
+		c.dedup = d
+	}
+}
+
+// ReadPaused：连接创建时即处于 PauseRead 暂停读取的状态，加入事件循环后需要由调用方取消可读事件，
+// 之后通过 ResumeRead 恢复
+func ReadPaused() Option {
+	return func(c *Connection) {
+		c.readPaused = true
+	}
+}
+
+// WithClock：使用 clk 代替时间轮作为连接的时钟，空闲超时、SendWithDeadline 等功能都基于该时钟计时，
+// 测试时可以传入 clock.Fake 推进虚拟时间
+func WithClock(clk clock.Clock) Option {
+	return func(c *Connection) {
+		c.clock = clk
+	}
+}
+
+// ReceiveTimestamps：通过 SO_TIMESTAMPING 开启内核接收时间戳，读取数据时一并获取，可以在 OnMessage 中通过 ReceiveTime 得到，
+// 用于精确测量不受 Handler 调度延迟影响的端到端延迟。hardware 为 true 时优先使用网卡硬件时间戳（需要网卡及驱动支持），
+// 否则使用内核软件时间戳。开启后读取改用 recvmsg，仅适用于 TCP 连接
+func ReceiveTimestamps(hardware bool) Option {
+	return func(c *Connection) {
+		c.rxTimestamps = true
+		c.rxHardware = hardware
+	}
+}
+
+// MaxPendingResponses：outBuffer 中待写出的响应达到 n 个时暂停读取新的请求，响应全部写出后恢复，
+// 避免流水线发送大量请求却很少读取响应的客户端使响应无限堆积，0 表示不限制
+func MaxPendingResponses(n int) Option {
+	return func(c *Connection) {
+		c.maxPendingResponses = n
+	}
+}
+
+// MaxWriteBufferSize：outBuffer 中积压的数据上限，已有积压时再追加数据会超过上限则回调 OnBufferFull 并按 policy 处理，
+// 没有积压时写不完的数据总是完整保存，0 表示不限制
+func MaxWriteBufferSize(n int, policy BufferFullPolicy) Option {
+	return func(c *Connection) {
+		c.maxWriteBufferSize = n
+		c.bufferFullPolicy = policy
+	}
+}
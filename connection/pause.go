@@ -0,0 +1,37 @@
+package connection
+
+// PauseRead：暂停读取，连接保持打开，对端发送的数据留在内核接收缓冲区中，
+// 缓冲区满后对端的发送会被 TCP 流量控制阻塞。待发送的数据照常写出。可以在任意 goroutine 中调用
+func (c *Connection) PauseRead() error {
+	if !c.connected.Get() {
+		return c.closedError()
+	}
+	c.loop.QueueInLoop(func() {
+		c.setReadPaused(true)
+	})
+	return nil
+}
+
+// ResumeRead：恢复 PauseRead 暂停的读取，可以在任意 goroutine 中调用
+func (c *Connection) ResumeRead() error {
+	if !c.connected.Get() {
+		return c.closedError()
+	}
+	c.loop.QueueInLoop(func() {
+		c.setReadPaused(false)
+	})
+	return nil
+}
+
+// setReadPaused：在事件循环中更新关注的事件
+func (c *Connection) setReadPaused(paused bool) {
+	if !c.connected.Get() || c.readPaused == paused {
+		return
+	}
+	c.readPaused = paused
+	if c.outBuffer.Length() > 0 || c.writeWanted {
+		c.enableWrite(c.fd)
+	} else {
+		c.disableWrite(c.fd)
+	}
+}
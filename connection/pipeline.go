@@ -0,0 +1,49 @@
+package connection
+
+// readStopped：是否因 PauseRead、待写出的响应过多或等待 WriteClose 写完而停止读取
+func (c *Connection) readStopped() bool {
+	return c.readPaused || c.pipelinePaused || c.closeAfterWrite
+}
+
+// pipelineFull：已在 outBuffer 中的响应加上本次生成的 produced 个响应是否达到上限
+func (c *Connection) pipelineFull(produced int) bool {
+	return c.maxPendingResponses > 0 && c.pendingResponses+produced >= c.maxPendingResponses
+}
+
+// trackResponses：写出本次生成的 n 个响应后更新待写出的响应个数，达到上限时暂停读取新的请求，
+// outBuffer 写完后由 handleWrite 恢复
+func (c *Connection) trackResponses(n int) {
+	if c.maxPendingResponses <= 0 {
+		return
+	}
+	// 响应已全部交给内核
+	if c.outBuffer.Length() == 0 {
+		c.pendingResponses = 0
+		return
+	}
+	c.pendingResponses += n
+	if c.pendingResponses >= c.maxPendingResponses && !c.pipelinePaused && c.connected.Get() {
+		c.pipelinePaused = true
+		c.enableWrite(c.fd)
+	}
+}
+
+// handleHeldRequests：因达到上限而留在 inBuffer 中的请求，在响应已全部写出、没有暂停时继续处理，
+// 它们已经读入 inBuffer，不会再有可读事件触发处理
+func (c *Connection) handleHeldRequests() {
+	for c.pipelineHeld && !c.pipelinePaused && !c.unpackStopped() && c.connected.Get() {
+		c.pipelineHeld = false
+		out := c.handlerProtocol(c.inBuffer)
+		c.sendBuffersInLoop(out)
+		c.trackResponses(len(out))
+	}
+}
+
+// resumePipeline：待写出的响应全部写出后恢复读取，先处理 inBuffer 中剩余的请求
+func (c *Connection) resumePipeline(fd int) {
+	c.pipelinePaused = false
+	c.pendingResponses = 0
+	// 剩余请求的响应未能全部写出时，sendBuffersInLoop 会重新关注可读可写事件，再次达到上限时保持暂停
+	c.handleHeldRequests()
+	c.closeOnProtocolErrors(fd)
+}
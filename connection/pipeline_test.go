@@ -0,0 +1,63 @@
+package connection
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/tool/sync/atomic"
+	"golang.org/x/sys/unix"
+)
+
+// bulkCallBack：对每个请求回复一个较大的响应，记录处理过的请求数
+type bulkCallBack struct {
+	handled atomic.Int64
+}
+
+func (b *bulkCallBack) OnMessage(c *Connection, ctx interface{}, data []byte) []byte {
+	b.handled.Add(1)
+	resp := bytes.Repeat([]byte{'x'}, 32*1024)
+	return append(resp, data...)
+}
+func (b *bulkCallBack) OnConnect(c *Connection) {}
+
+func (b *bulkCallBack) OnClose(c *Connection) {}
+
+func TestConnection_MaxPendingResponses(t *testing.T) {
+	const requests, limit = 200, 4
+	cb := &bulkCallBack{}
+	_, peer, loop := newRunningConnectionWith(t, &lineProtocol{}, cb, MaxPendingResponses(limit))
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	// 客户端一次性发送全部请求，但暂时不读取响应
+	if _, err := unix.Write(peer, pipelinedRequests(requests)); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 100)
+	// 除内核缓冲区容纳的响应外，待写出的响应不超过上限
+	handled := cb.handled.Get()
+	if handled >= requests/2 {
+		t.Fatalf("reading should be paused, but %d requests were handled", handled)
+	}
+
+	// 缓慢读取全部响应，所有请求最终都被处理且顺序不变
+	r := bufio.NewReader(fdReader(peer))
+	for i := 0; i < requests; i++ {
+		if i%20 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := line[32*1024 : len(line)-1]; got != strconv.Itoa(i) {
+			t.Fatalf("expect response %d, but got %q", i, got)
+		}
+	}
+	if handled := cb.handled.Get(); handled != requests {
+		t.Fatalf("expect %d requests handled, but got %d", requests, handled)
+	}
+}
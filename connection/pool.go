@@ -0,0 +1,84 @@
+package connection
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+	"github.com/RussellLuo/timingwheel"
+	"golang.org/x/sys/unix"
+)
+
+// poolBufferSize：连接池预分配的读写缓冲区初始大小，与 ringbuffer/pool 默认大小一致
+const poolBufferSize = 1024
+
+// Pool：连接池，限制同时存在的连接数。
+// 开启预分配时，创建时即分配好全部 Connection 及其读写缓冲区，连接关闭后归还复用，运行期间不再分配连接状态
+type Pool struct {
+	mu       sync.Mutex
+	free     []*Connection // 空闲的预分配连接
+	max      int
+	active   int
+	prealloc bool
+}
+
+// NewPool：创建连接池，max 为最大连接数，preallocate 为是否预分配全部连接
+func NewPool(max int, preallocate bool) *Pool {
+	p := &Pool{max: max, prealloc: preallocate}
+	if preallocate {
+		p.free = make([]*Connection, max)
+		for i := range p.free {
+			p.free[i] = &Connection{
+				outBuffer: ringbuffer.New(poolBufferSize),
+				inBuffer:  ringbuffer.New(poolBufferSize),
+				pool:      p,
+			}
+		}
+	}
+	return p
+}
+
+// Get：从连接池获取并初始化一个 Connection，参数同 New，连接数达到上限时返回 nil
+func (p *Pool) Get(fd int, loop *eventloop.EventLoop, sa unix.Sockaddr, protocol Protocol, tw *timingwheel.TimingWheel, idleTime time.Duration, callBack CallBack, opts ...Option) *Connection {
+	p.mu.Lock()
+	if p.active >= p.max {
+		p.mu.Unlock()
+		return nil
+	}
+	p.active++
+	var c *Connection
+	if p.prealloc {
+		c = p.free[len(p.free)-1]
+		p.free = p.free[:len(p.free)-1]
+	}
+	p.mu.Unlock()
+
+	if c == nil {
+		c = New(fd, loop, sa, protocol, tw, idleTime, callBack, opts...)
+		c.pool = p
+		return c
+	}
+	c.init(fd, loop, sa, protocol, tw, idleTime, callBack, opts)
+	return c
+}
+
+// Active：当前正在使用的连接数
+func (p *Pool) Active() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.active
+}
+
+// put：连接关闭后归还到连接池
+func (p *Pool) put(c *Connection) {
+	if p.prealloc {
+		c.recycle()
+	}
+	p.mu.Lock()
+	p.active--
+	if p.prealloc {
+		p.free = append(p.free, c)
+	}
+	p.mu.Unlock()
+}
@@ -0,0 +1,149 @@
+package connection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"github.com/Dongxiem/fastnet/poller"
+	"github.com/Dongxiem/fastnet/tool/clock"
+	"github.com/RussellLuo/timingwheel"
+	"golang.org/x/sys/unix"
+)
+
+func TestPool_Exhausted(t *testing.T) {
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prealloc := range []bool{false, true} {
+		p := NewPool(2, prealloc)
+		c1 := p.Get(1, loop, nil, &DefaultProtocol{}, nil, 0, &emptyCallBack{})
+		c2 := p.Get(2, loop, nil, &DefaultProtocol{}, nil, 0, &emptyCallBack{})
+		if c1 == nil || c2 == nil {
+			t.Fatal("pool should not be exhausted")
+		}
+		if c := p.Get(3, loop, nil, &DefaultProtocol{}, nil, 0, &emptyCallBack{}); c != nil {
+			t.Fatal("pool should be exhausted")
+		}
+		p.put(c1)
+		if p.Active() != 1 {
+			t.Fatalf("expect 1 active connection, but %d", p.Active())
+		}
+		if c := p.Get(3, loop, nil, &DefaultProtocol{}, nil, 0, &emptyCallBack{}); c == nil {
+			t.Fatal("pool should accept a connection after put")
+		}
+	}
+}
+
+func TestPool_Recycle(t *testing.T) {
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPool(1, true)
+	sa := &unix.SockaddrUnix{Name: "first"}
+
+	c := p.Get(1, loop, sa, &DefaultProtocol{}, nil, 0, &emptyCallBack{}, AllowHalfClose(true))
+	c.Set("k", 1)
+	c.SetContext(1)
+	_, _ = c.inBuffer.Write([]byte("left"))
+	done := c.Done()
+	c.connected.Set(false)
+	c.cancel()
+	p.put(c)
+
+	select {
+	case <-done:
+	default:
+		t.Fatal("Done of the previous connection should be closed")
+	}
+
+	r := p.Get(2, loop, &unix.SockaddrUnix{Name: "second"}, &DefaultProtocol{}, nil, 0, &emptyCallBack{})
+	if r != c {
+		t.Fatal("connection should be reused")
+	}
+	if _, ok := r.Get("k"); ok || r.Context() != nil || r.inBuffer.Length() != 0 || r.allowHalfClose {
+		t.Fatal("recycled connection should be reset")
+	}
+	if r.PeerAddr() != "second" || !r.Connected() {
+		t.Fatal("recycled connection should be initialized")
+	}
+	select {
+	case <-r.Done():
+		t.Fatal("Done of the new connection should not be closed")
+	default:
+	}
+}
+
+// poolCallBack：统计 OnClose 的调用次数
+type poolCallBack struct {
+	emptyCallBack
+	closes int
+}
+
+func (cb *poolCallBack) OnClose(c *Connection) {
+	cb.closes++
+}
+
+// dummySocket：只用于注册到事件循环
+type dummySocket struct{}
+
+func (dummySocket) HandleEvent(fd int, events poller.Event) {}
+func (dummySocket) Close() error                            { return nil }
+
+// TestPool_NoAllocs：完整地建立并关闭连接（注册到事件循环、调度空闲定时器、handleClose 及 OnClose），
+// 除了时间轮调度定时器和事件循环记录 fd 本身的分配之外，连接状态不再分配内存
+func TestPool_NoAllocs(t *testing.T) {
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loop.Stop()
+	tw := timingwheel.NewTimingWheel(time.Millisecond, 20)
+	tw.Start()
+	defer tw.Stop()
+	wheel := clock.Wheel(tw)
+
+	p := NewPool(16, true)
+	sa := &unix.SockaddrUnix{Name: "pool"}
+	protocol := &DefaultProtocol{}
+	callBack := &poolCallBack{}
+	socketPair := func() (int, int) {
+		fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return fds[0], fds[1]
+	}
+
+	// 基准：只注册 fd 并调度一个空闲定时器
+	baseline := testing.AllocsPerRun(1000, func() {
+		fd, peer := socketPair()
+		if err := loop.AddSocketAndEnableRead(fd, dummySocket{}); err != nil {
+			t.Fatal(err)
+		}
+		wheel.AfterFunc(time.Minute, func() { _ = fd })
+		loop.DeleteFdInLoop(fd)
+		_ = unix.Close(fd)
+		_ = unix.Close(peer)
+	})
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		fd, peer := socketPair()
+		c := p.Get(fd, loop, sa, protocol, tw, time.Minute, callBack)
+		if err := loop.AddSocketAndEnableRead(fd, c); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = c.inBuffer.Write([]byte("data"))
+		c.handleClose(fd)
+		_ = unix.Close(peer)
+	})
+	if allocs > baseline {
+		t.Fatalf("expect no allocations beyond the timer and poller registration (%v) per connect/disconnect cycle, but %v", baseline, allocs)
+	}
+	// AllocsPerRun 额外执行一次预热
+	if callBack.closes != 1001 || p.Active() != 0 {
+		t.Fatalf("expect every connection to be closed through handleClose, but %d closes, %d active", callBack.closes, p.Active())
+	}
+}
@@ -0,0 +1,12 @@
+package connection
+
+// SetPriority：设置连接的优先级，开启了优先级调度的事件循环中，同一批就绪事件里优先级高的连接先处理，
+// 默认为 0，可以在任意 goroutine 中调用。适用于让控制、管理类连接在事件循环繁忙时仍能及时响应
+func (c *Connection) SetPriority(p int) {
+	_ = c.priority.Swap(int32(p))
+}
+
+// Priority：连接的优先级
+func (c *Connection) Priority() int {
+	return int(c.priority.Get())
+}
@@ -4,16 +4,33 @@ import (
 	"github.com/Dongxiem/fastnet/tool/ringbuffer"
 )
 
-var _ Protocol = &DefaultProtocol{}
+var _ ConnIndependentPacket = &DefaultProtocol{}
 
 // Protocol：自定义协议编解码接口
 type Protocol interface {
-	// 拆包
+	// 拆包，buffer 可能直接持有事件循环的读缓冲区，返回的数据引用 buffer 时只在本次 OnMessage 中有效
 	UnPacket(c *Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte)
 	// 装包
 	Packet(c *Connection, data []byte) []byte
 }
 
+// ErrorProtocol：可选的协议接口，用于报告无法跳过的协议错误（如超长或非法的帧，之后无法再找到下一帧的边界）。
+// 实现该接口时拆包调用 UnPacketErr 而不是 UnPacket，返回错误时停止拆包，outBuffer 中的数据写出后
+// 以该错误为原因关闭连接（会回调 OnClose）。可以跳过的错误使用 ReportProtocolError
+type ErrorProtocol interface {
+	Protocol
+	UnPacketErr(c *Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte, error)
+}
+
+// ConnIndependentPacket：可选的协议接口，声明 Packet 的结果只取决于 data、与具体的连接无关。
+// Broadcast 及 Fanout 只对实现该接口的协议共享打包结果，其余协议（如按连接加密、压缩的协议）对每个连接分别打包。
+// 实现该接口的协议需要是可比较的类型（通常为指针）；嵌入 DefaultProtocol 并重写 Packet 的协议会继承该接口，
+// Packet 依赖连接时需要改为嵌入其他类型
+type ConnIndependentPacket interface {
+	Protocol
+	ConnIndependentPacket()
+}
+
 // DefaultProtocol：默认 Protocol
 type DefaultProtocol struct{}
 
@@ -28,3 +45,6 @@ func (d *DefaultProtocol) UnPacket(c *Connection, buffer *ringbuffer.RingBuffer)
 func (d *DefaultProtocol) Packet(c *Connection, data []byte) []byte {
 	return data
 }
+
+// ConnIndependentPacket：Packet 原样返回 data，广播时所有连接共享同一份数据
+func (d *DefaultProtocol) ConnIndependentPacket() {}
@@ -0,0 +1,91 @@
+package connection
+
+import (
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// ProtocolErrorCallBack：可选的回调接口，协议通过 ReportProtocolError 报告错误时调用
+type ProtocolErrorCallBack interface {
+	OnProtocolError(c *Connection, err error)
+}
+
+// ReportProtocolError：供协议在 UnPacket 中调用，报告一次可以跳过的协议错误（如格式错误的帧、解析失败），
+// 回调 Handler 的 OnProtocolError（如果实现了该方法）。设置了 ProtocolErrorLimit 时，
+// 窗口时间内的错误次数达到上限后停止拆包，并以 ErrTooManyProtocolErrors 为原因关闭连接
+func (c *Connection) ReportProtocolError(err error) {
+	if h, ok := c.callBack.(ProtocolErrorCallBack); ok {
+		h.OnProtocolError(c, err)
+	}
+	if c.protoErrLimit <= 0 {
+		return
+	}
+
+	now := c.clock.Now()
+	// 只保留最近 protoErrLimit 次错误的时间
+	if len(c.protoErrTimes) == c.protoErrLimit {
+		copy(c.protoErrTimes, c.protoErrTimes[1:])
+		c.protoErrTimes = c.protoErrTimes[:len(c.protoErrTimes)-1]
+	}
+	c.protoErrTimes = append(c.protoErrTimes, now)
+	if len(c.protoErrTimes) == c.protoErrLimit &&
+		(c.protoErrWindow <= 0 || now.Sub(c.protoErrTimes[0]) <= c.protoErrWindow) {
+		c.protoErrExceeded = true
+	}
+}
+
+// maxNoProgress：一轮拆包中允许连续没有进展的次数。自己缓冲数据的协议（如 TLS 中流水线的请求）
+// 可能连续返回多个不消耗 buffer 的消息，超过该次数时才视为死循环
+const maxNoProgress = 1024
+
+// unpack：调用协议拆包，协议实现了 ErrorProtocol 时记录其返回的错误。
+// 协议返回了 ctx 但数据为空，且没有消耗 buffer 中的数据时记为一次没有进展，一轮拆包中连续超过 maxNoProgress 次时
+// 以 ErrProtocolNoProgress 报告协议错误并停止本次拆包，避免事件循环空转
+func (c *Connection) unpack(buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	before := buffer.Length()
+	var ctx interface{}
+	var data []byte
+	if p, ok := c.protocol.(ErrorProtocol); ok {
+		var err error
+		if ctx, data, err = p.UnPacketErr(c, buffer); err != nil {
+			c.protoErr = err
+			return nil, nil
+		}
+	} else {
+		ctx, data = c.protocol.UnPacket(c, buffer)
+	}
+	if ctx == nil || len(data) != 0 || buffer.Length() != before {
+		c.noProgress = 0
+		return ctx, data
+	}
+	c.noProgress++
+	if c.noProgress > maxNoProgress {
+		c.noProgress = 0
+		log.Error("[connection]", ErrProtocolNoProgress, ":", c.PeerAddr())
+		c.ReportProtocolError(ErrProtocolNoProgress)
+		return nil, nil
+	}
+	return ctx, data
+}
+
+// unpackStopped：协议错误达到上限或协议返回了错误，不再拆包
+func (c *Connection) unpackStopped() bool {
+	return c.protoErrExceeded || c.protoErr != nil
+}
+
+// closeOnProtocolErrors：协议错误达到上限或协议返回了错误时关闭连接，返回连接是否被关闭。
+// 协议返回错误时先写出已拆出消息的响应（如 websocket 的 close 帧），写完后再关闭
+func (c *Connection) closeOnProtocolErrors(fd int) bool {
+	switch {
+	case c.protoErr != nil:
+		if c.connected.Get() && c.closeReason == nil {
+			c.closeReason = c.protoErr
+		}
+		c.closeWhenFlushed(fd)
+	case c.protoErrExceeded:
+		c.closeWithReason(fd, ErrTooManyProtocolErrors)
+	default:
+		return false
+	}
+	return true
+}
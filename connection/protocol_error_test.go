@@ -0,0 +1,247 @@
+package connection
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+	"golang.org/x/sys/unix"
+)
+
+var errBadLine = errors.New("bad line")
+
+// strictLineProtocol：跳过内容为 garbage 的行并报告协议错误
+type strictLineProtocol struct {
+	lineProtocol
+}
+
+func (p *strictLineProtocol) UnPacket(c *Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	for {
+		ctx, data := p.lineProtocol.UnPacket(c, buffer)
+		if string(data) != "garbage" {
+			return ctx, data
+		}
+		c.ReportProtocolError(errBadLine)
+	}
+}
+
+// fatalLineProtocol：内容为 garbage 的行是无法跳过的错误
+type fatalLineProtocol struct {
+	lineProtocol
+}
+
+func (p *fatalLineProtocol) UnPacketErr(c *Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte, error) {
+	ctx, data := p.lineProtocol.UnPacket(c, buffer)
+	if string(data) == "garbage" {
+		return nil, nil, errBadLine
+	}
+	return ctx, data, nil
+}
+
+type protocolErrorCallBack struct {
+	closeCallBack
+	errs int32
+}
+
+func (e *protocolErrorCallBack) OnMessage(c *Connection, ctx interface{}, data []byte) []byte {
+	return data
+}
+
+func (e *protocolErrorCallBack) OnProtocolError(c *Connection, err error) {
+	if errors.Is(err, errBadLine) {
+		atomic.AddInt32(&e.errs, 1)
+	}
+}
+
+func expectLine(t *testing.T, r *bufio.Reader, expect string) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != expect {
+		t.Fatalf("expect %q, but got %q", expect, line)
+	}
+}
+
+func TestConnection_ProtocolErrorLimit(t *testing.T) {
+	cb := &protocolErrorCallBack{closeCallBack: closeCallBack{closed: make(chan error, 1)}}
+	_, peer, loop := newRunningConnectionWith(t, &strictLineProtocol{}, cb, ProtocolErrorLimit(3, time.Second))
+	defer unix.Close(peer)
+	defer loop.Stop()
+	r := bufio.NewReader(fdReader(peer))
+
+	// 未达到上限时跳过错误数据，继续处理后续消息
+	if _, err := unix.Write(peer, []byte("garbage\ngarbage\nok\n")); err != nil {
+		t.Fatal(err)
+	}
+	expectLine(t, r, "ok\n")
+	if n := atomic.LoadInt32(&cb.errs); n != 2 {
+		t.Fatalf("expect 2 protocol errors, but got %d", n)
+	}
+
+	// 达到上限后不再处理之后的消息
+	if _, err := unix.Write(peer, []byte("garbage\nlost\n")); err != nil {
+		t.Fatal(err)
+	}
+	if reason := waitCloseReason(t, cb.closed); !errors.Is(reason, ErrTooManyProtocolErrors) {
+		t.Fatalf("expect ErrTooManyProtocolErrors, but got %v", reason)
+	}
+	if line, err := r.ReadString('\n'); err == nil {
+		t.Fatalf("message after the limit should not be handled, but got %q", line)
+	}
+}
+
+func TestConnection_ProtocolErrorWindow(t *testing.T) {
+	cb := &protocolErrorCallBack{closeCallBack: closeCallBack{closed: make(chan error, 1)}}
+	_, peer, loop := newRunningConnectionWith(t, &strictLineProtocol{}, cb, ProtocolErrorLimit(2, time.Millisecond*50))
+	defer unix.Close(peer)
+	defer loop.Stop()
+	r := bufio.NewReader(fdReader(peer))
+
+	// 间隔超过窗口时间的错误不会导致连接关闭
+	for i := 0; i < 3; i++ {
+		if _, err := unix.Write(peer, []byte("garbage\nok\n")); err != nil {
+			t.Fatal(err)
+		}
+		expectLine(t, r, "ok\n")
+		time.Sleep(time.Millisecond * 100)
+	}
+	if n := atomic.LoadInt32(&cb.errs); n != 3 {
+		t.Fatalf("expect 3 protocol errors, but got %d", n)
+	}
+	select {
+	case reason := <-cb.closed:
+		t.Fatalf("connection should be alive, but closed with %v", reason)
+	default:
+	}
+}
+
+func TestConnection_ErrorProtocol(t *testing.T) {
+	cb := &protocolErrorCallBack{closeCallBack: closeCallBack{closed: make(chan error, 1)}}
+	_, peer, loop := newRunningConnectionWith(t, &fatalLineProtocol{}, cb)
+	defer unix.Close(peer)
+	defer loop.Stop()
+	r := bufio.NewReader(fdReader(peer))
+
+	// 错误之前的消息正常响应，之后的消息不再处理，连接以协议返回的错误关闭
+	if _, err := unix.Write(peer, []byte("ok\ngarbage\nlost\n")); err != nil {
+		t.Fatal(err)
+	}
+	expectLine(t, r, "ok\n")
+	if reason := waitCloseReason(t, cb.closed); !errors.Is(reason, errBadLine) {
+		t.Fatalf("expect errBadLine, but got %v", reason)
+	}
+	if line, err := r.ReadString('\n'); err == nil {
+		t.Fatalf("message after the error should not be handled, but got %q", line)
+	}
+}
+
+// stuckProtocol：一直返回新的 ctx 而不消耗 buffer 中的数据
+type stuckProtocol struct {
+	lineProtocol
+	calls int32
+}
+
+type stuckState struct {
+	extra interface{}
+}
+
+func (p *stuckProtocol) UnPacket(c *Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	atomic.AddInt32(&p.calls, 1)
+	return &stuckState{extra: []byte{}}, nil
+}
+
+func TestConnection_ProtocolNoProgress(t *testing.T) {
+	p := &stuckProtocol{}
+	cb := &protocolErrorCallBack{closeCallBack: closeCallBack{closed: make(chan error, 1)}}
+	_, peer, loop := newRunningConnectionWith(t, p, cb, ProtocolErrorLimit(2, time.Second))
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	// 连续 maxNoProgress 次没有进展的 ctx 正常处理，之后报告协议错误并停止本次拆包，第二次读取时达到协议错误的上限
+	if _, err := unix.Write(peer, []byte("stuck")); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second)
+	for atomic.LoadInt32(&p.calls) < maxNoProgress+1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(time.Millisecond * 20)
+	if n := atomic.LoadInt32(&p.calls); n != maxNoProgress+1 {
+		t.Fatalf("expect UnPacket to be called %d times, but got %d", maxNoProgress+1, n)
+	}
+	if _, err := unix.Write(peer, []byte("stuck")); err != nil {
+		t.Fatal(err)
+	}
+	if reason := waitCloseReason(t, cb.closed); !errors.Is(reason, ErrTooManyProtocolErrors) {
+		t.Fatalf("expect ErrTooManyProtocolErrors, but got %v", reason)
+	}
+}
+
+// bufferingProtocol：像 TLS 一样一次取出 buffer 中的全部数据，之后从自己的缓冲中逐个返回没有数据的消息（如流水线的 GET 请求）
+type bufferingProtocol struct {
+	lineProtocol
+	pending []byte
+}
+
+type bufferedRequest struct {
+	line string
+}
+
+func (p *bufferingProtocol) UnPacket(c *Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	p.pending = append(p.pending, buffer.Bytes()...)
+	buffer.RetrieveAll()
+	i := bytes.IndexByte(p.pending, '\n')
+	if i < 0 {
+		return nil, nil
+	}
+	req := &bufferedRequest{line: string(p.pending[:i])}
+	p.pending = p.pending[i+1:]
+	return req, nil
+}
+
+type bufferedCallBack struct {
+	protocolErrorCallBack
+	lines chan string
+}
+
+func (b *bufferedCallBack) OnMessage(c *Connection, ctx interface{}, data []byte) []byte {
+	b.lines <- ctx.(*bufferedRequest).line
+	return nil
+}
+
+func (b *bufferedCallBack) OnProtocolError(c *Connection, err error) {
+	atomic.AddInt32(&b.errs, 1)
+}
+
+func TestConnection_ProtocolBufferedMessages(t *testing.T) {
+	cb := &bufferedCallBack{
+		protocolErrorCallBack: protocolErrorCallBack{closeCallBack: closeCallBack{closed: make(chan error, 1)}},
+		lines:                 make(chan string, 3),
+	}
+	_, peer, loop := newRunningConnectionWith(t, &bufferingProtocol{}, cb, ProtocolErrorLimit(1, time.Second))
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	// 第一次拆包后 buffer 已经为空，之后的消息不消耗 buffer，但每次的 ctx 不同，不能视为没有进展
+	if _, err := unix.Write(peer, []byte("a\nb\nc\n")); err != nil {
+		t.Fatal(err)
+	}
+	for _, expect := range []string{"a", "b", "c"} {
+		select {
+		case line := <-cb.lines:
+			if line != expect {
+				t.Fatalf("expect %q, but got %q", expect, line)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expect buffered message %q", expect)
+		}
+	}
+	if n := atomic.LoadInt32(&cb.errs); n != 0 {
+		t.Fatalf("expect no protocol error, but got %d", n)
+	}
+}
@@ -0,0 +1,89 @@
+package connection
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+	"golang.org/x/sys/unix"
+)
+
+// fixedProtocol：固定长度的消息，直接返回 buffer 中的数据而不拷贝，仅供测试使用
+type fixedProtocol struct {
+	size int
+}
+
+func (p *fixedProtocol) UnPacket(c *Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	if buffer.Length() < p.size {
+		return nil, nil
+	}
+	first, _ := buffer.Peek(p.size)
+	if len(first) < p.size {
+		first = append([]byte{}, first...)
+		_, end := buffer.Peek(p.size)
+		first = append(first, end...)
+	}
+	buffer.Retrieve(p.size)
+	return nil, first
+}
+
+func (p *fixedProtocol) Packet(c *Connection, data []byte) []byte {
+	return data
+}
+
+func TestConnection_HandleReadTail(t *testing.T) {
+	fd, peer := newSocketPair(t)
+	defer unix.Close(fd)
+	defer unix.Close(peer)
+
+	cb := &messageRecorder{messages: make(chan string, 8)}
+	c := newTestConnectionWith(t, fd, &fixedProtocol{size: 4}, cb)
+
+	// 第一次读取的数据被就地解析，只有不完整的尾部留在 inBuffer 中
+	if _, err := unix.Write(peer, []byte("aaaabbbbcc")); err != nil {
+		t.Fatal(err)
+	}
+	c.handleRead(fd)
+	if got := c.inBuffer.Length(); got != 2 {
+		t.Fatalf("expect 2 bytes left in inBuffer, but got %d", got)
+	}
+	// 就地解析结束后不再持有事件循环的读缓冲区
+	if c.readView.Capacity() != 0 {
+		t.Fatal("expect read view to be released")
+	}
+
+	if _, err := unix.Write(peer, []byte("ccdddd")); err != nil {
+		t.Fatal(err)
+	}
+	c.handleRead(fd)
+	if got := c.inBuffer.Length(); got != 0 {
+		t.Fatalf("expect empty inBuffer, but got %d", got)
+	}
+	close(cb.messages)
+	var got []string
+	for msg := range cb.messages {
+		got = append(got, msg)
+	}
+	if want := "aaaa bbbb cccc dddd"; strings.Join(got, " ") != want {
+		t.Fatalf("expect %q, but got %q", want, got)
+	}
+}
+
+// BenchmarkConnection_HandleRead：协议完全消费每次读取的数据时，读路径不产生内存分配
+func BenchmarkConnection_HandleRead(b *testing.B) {
+	fd, peer := newSocketPair(b)
+	defer unix.Close(fd)
+	defer unix.Close(peer)
+
+	c := newTestConnectionWith(b, fd, &fixedProtocol{size: 64}, &emptyCallBack{})
+	data := bytes.Repeat([]byte{'x'}, 64*4)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := unix.Write(peer, data); err != nil {
+			b.Fatal(err)
+		}
+		c.handleRead(fd)
+	}
+}
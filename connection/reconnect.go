@@ -0,0 +1,38 @@
+package connection
+
+import "errors"
+
+// ErrReconnectNotSupported：连接的协议没有实现 ReconnectProtocol
+var ErrReconnectNotSupported = errors.New("protocol does not support reconnect directive")
+
+// ReconnectProtocol：可选的协议接口，支持向客户端发送重连指令的协议实现，由协议决定指令的帧格式
+type ReconnectProtocol interface {
+	// ReconnectFrame：返回通知客户端重连到 target 的完整帧，不再经过 Packet 打包，target 可以为空
+	ReconnectFrame(c *Connection, target string) []byte
+}
+
+// RequestReconnect：通知客户端改为连接 target（如其他节点的地址），发送协议定义的重连指令后，
+// 等 outBuffer 中的数据全部写出再关闭连接，用于下线节点时让客户端平滑迁移而不是被突然断开。
+// 协议没有实现 ReconnectProtocol 时返回 ErrReconnectNotSupported，可以在任意 goroutine 中调用
+func (c *Connection) RequestReconnect(target string) error {
+	if c.udp {
+		return ErrUDPNotSupported
+	}
+	p, ok := c.protocol.(ReconnectProtocol)
+	if !ok {
+		return ErrReconnectNotSupported
+	}
+	if !c.connected.Get() {
+		return c.closedError()
+	}
+
+	generation := c.generation.Get()
+	c.loop.QueueInLoop(func() {
+		if c.generation.Get() != generation || !c.connected.Get() {
+			return
+		}
+		c.sendInLoop(p.ReconnectFrame(c, target))
+		c.closeWhenFlushed(c.fd)
+	})
+	return nil
+}
@@ -0,0 +1,59 @@
+package connection
+
+import (
+	"bufio"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// reconnectLineProtocol：以 "RECONNECT <target>" 行作为重连指令的行协议
+type reconnectLineProtocol struct {
+	lineProtocol
+}
+
+func (p *reconnectLineProtocol) ReconnectFrame(c *Connection, target string) []byte {
+	return []byte("RECONNECT " + target + "\n")
+}
+
+func TestConnection_RequestReconnect(t *testing.T) {
+	c, peer, loop, closed := newRunningConnection(t, &reconnectLineProtocol{})
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	if err := c.Send([]byte("last response")); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RequestReconnect("10.0.0.2:1388"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 之前的数据及指令依次到达，随后连接正常关闭
+	r := bufio.NewReader(fdReader(peer))
+	for _, want := range []string{"last response\n", "RECONNECT 10.0.0.2:1388\n"} {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != want {
+			t.Fatalf("expect %q, but got %q", want, line)
+		}
+	}
+	if reason := waitCloseReason(t, closed); reason != nil {
+		t.Fatalf("expect clean close, but got %v", reason)
+	}
+	var buf [1]byte
+	if n, err := unix.Read(peer, buf[:]); n != 0 || err != nil {
+		t.Fatalf("expect EOF, but got %d %v", n, err)
+	}
+}
+
+func TestConnection_RequestReconnectNotSupported(t *testing.T) {
+	c, peer, loop, _ := newRunningConnection(t, &lineProtocol{})
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	if err := c.RequestReconnect("elsewhere"); err != ErrReconnectNotSupported {
+		t.Fatalf("expect ErrReconnectNotSupported, but got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package connection
+
+import "sync"
+
+// registryShards：Registry 的分片数，按连接 ID 取模分片，避免所有事件循环争用同一把锁
+const registryShards = 64
+
+// Registry：按 ID 索引尚未关闭的连接，通过 WithRegistry 设置后在连接建立时注册、关闭时注销，可以在任意 goroutine 中使用
+type Registry struct {
+	shards [registryShards]registryShard
+}
+
+type registryShard struct {
+	mu    sync.RWMutex
+	conns map[int64]*Connection
+}
+
+// NewRegistry：创建 Registry
+func NewRegistry() *Registry {
+	r := new(Registry)
+	for i := range r.shards {
+		r.shards[i].conns = make(map[int64]*Connection)
+	}
+	return r
+}
+
+// WithRegistry：连接建立时注册到 r，关闭时注销
+func WithRegistry(r *Registry) Option {
+	return func(c *Connection) {
+		c.registry = r
+	}
+}
+
+// Get：返回 ID 为 id 且尚未关闭的连接
+func (r *Registry) Get(id int64) (*Connection, bool) {
+	s := r.shard(id)
+	s.mu.RLock()
+	c, ok := s.conns[id]
+	s.mu.RUnlock()
+	return c, ok
+}
+
+// Len：已注册的连接数
+func (r *Registry) Len() int {
+	n := 0
+	for i := range r.shards {
+		s := &r.shards[i]
+		s.mu.RLock()
+		n += len(s.conns)
+		s.mu.RUnlock()
+	}
+	return n
+}
+
+func (r *Registry) shard(id int64) *registryShard {
+	return &r.shards[uint64(id)%registryShards]
+}
+
+func (r *Registry) add(c *Connection) {
+	s := r.shard(c.id)
+	s.mu.Lock()
+	s.conns[c.id] = c
+	s.mu.Unlock()
+}
+
+func (r *Registry) remove(c *Connection) {
+	s := r.shard(c.id)
+	s.mu.Lock()
+	delete(s.conns, c.id)
+	s.mu.Unlock()
+}
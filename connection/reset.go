@@ -0,0 +1,34 @@
+package connection
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// Reset：中止连接，设置 SO_LINGER 超时为 0 后关闭，内核丢弃尚未发出的数据并向对端发送 RST，
+// 不经过 FIN 挥手，本端也不会进入 TIME_WAIT。适用于发现严重的协议错误或快速断开恶意连接，
+// outBuffer 中尚未发送的数据同样被丢弃。OnClose 中 CloseReason 为 ErrConnectionReset。
+// 与之相对，Close 正常关闭连接，ShutdownWrite 只关闭写端
+func (c *Connection) Reset() error {
+	if c.udp {
+		return ErrUDPNotSupported
+	}
+	if !c.connected.Get() {
+		return c.closedError()
+	}
+
+	generation := c.generation.Get()
+	c.loop.QueueInLoop(func() {
+		if c.generation.Get() != generation || !c.connected.Get() {
+			return
+		}
+		if err := unix.SetsockoptLinger(c.fd, unix.SOL_SOCKET, unix.SO_LINGER, &unix.Linger{Onoff: 1, Linger: 0}); err != nil {
+			// 无法设置 SO_LINGER 时仍然关闭连接，只是退化为正常关闭
+			c.closeWithReason(c.fd, opError("set linger", err))
+			return
+		}
+		c.outBuffer.RetrieveAll()
+		c.deadlineChunks = nil
+		c.closeWithReason(c.fd, ErrConnectionReset)
+	})
+	return nil
+}
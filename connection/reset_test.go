@@ -0,0 +1,76 @@
+package connection
+
+import (
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"golang.org/x/sys/unix"
+)
+
+// newTCPPair：返回一对 TCP 连接，本端为非阻塞的 fd，对端为 net.Conn
+func newTCPPair(t *testing.T) (int, net.Conn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	peer, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	f, err := conn.(*net.TCPConn).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	fd, err := unix.Dup(int(f.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.SetNonblock(fd, true); err != nil {
+		t.Fatal(err)
+	}
+	return fd, peer
+}
+
+func TestConnection_Reset(t *testing.T) {
+	fd, peer := newTCPPair(t)
+	defer peer.Close()
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go loop.RunLoop()
+	defer func() { _ = loop.Stop() }()
+
+	cb := &closeCallBack{closed: make(chan error, 1)}
+	c := New(fd, loop, nil, &DefaultProtocol{}, nil, 0, cb)
+	if err := loop.AddSocketAndEnableRead(fd, c); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if reason := waitCloseReason(t, cb.closed); !errors.Is(reason, ErrConnectionReset) {
+		t.Fatalf("expect ErrConnectionReset, but got %v", reason)
+	}
+
+	// 对端读到的是连接被重置，而不是正常关闭的 EOF
+	_ = peer.SetReadDeadline(time.Now().Add(time.Second * 3))
+	if _, err := peer.Read(make([]byte, 1)); !errors.Is(err, syscall.ECONNRESET) {
+		t.Fatalf("expect ECONNRESET, but got %v", err)
+	}
+	if err := c.Reset(); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("expect ErrConnectionClosed, but got %v", err)
+	}
+}
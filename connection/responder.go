@@ -0,0 +1,40 @@
+package connection
+
+// Responder：异步响应助手，在 OnMessage 中捕获连接及请求 ID，
+// 交给工作协程处理完成后通过 Respond 回写，连接已关闭时不会再发送。
+// 开启连接池预分配时连接关闭后会被复用，Responder 记录创建时连接的复用次数，不会把响应发给复用后的新客户端
+type Responder struct {
+	c          *Connection
+	id         interface{}
+	generation int64
+}
+
+// NewResponder：创建 Responder，id 为可选的请求 ID，用于将响应与请求对应起来
+func NewResponder(c *Connection, id interface{}) *Responder {
+	return &Responder{c: c, id: id, generation: c.generation.Get()}
+}
+
+// Conn：获取请求所属的连接
+func (r *Responder) Conn() *Connection {
+	return r.c
+}
+
+// ID：获取请求 ID
+func (r *Responder) ID() interface{} {
+	return r.id
+}
+
+// Alive：请求所属的连接是否仍然存活
+func (r *Responder) Alive() bool {
+	return r.c.generation.Get() == r.generation && r.c.Connected()
+}
+
+// Respond：将 data 发送回请求所属的连接，可以在任意 goroutine 中调用，连接已关闭或已被复用时返回 ErrConnectionClosed
+func (r *Responder) Respond(data []byte) error {
+	return r.c.sendGeneration(data, r.generation)
+}
+
+// Func：以闭包形式返回 Respond，便于传递给不依赖 connection 包的工作函数
+func (r *Responder) Func() func(data []byte) error {
+	return r.Respond
+}
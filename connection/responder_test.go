@@ -0,0 +1,93 @@
+package connection
+
+import (
+	"testing"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"golang.org/x/sys/unix"
+)
+
+func TestResponder_Respond(t *testing.T) {
+	fd, peer := newSocketPair(t)
+	defer unix.Close(peer)
+
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go loop.RunLoop()
+	defer loop.Stop()
+
+	c := New(fd, loop, nil, &lineProtocol{}, nil, 0, &emptyCallBack{})
+	r := NewResponder(c, 7)
+	if r.ID().(int) != 7 || r.Conn() != c {
+		t.Fatal("responder should keep the connection and request id")
+	}
+
+	respond := r.Func()
+	if err := respond([]byte("done")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, err := unix.Read(peer, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "done\n" {
+		t.Fatalf("expect %q, but got %q", "done\n", buf[:n])
+	}
+
+	_ = c.Close()
+	<-c.Done()
+	if r.Alive() {
+		t.Fatal("responder should not be alive after the connection closed")
+	}
+	if err := r.Respond([]byte("late")); err != ErrConnectionClosed {
+		t.Fatalf("expect ErrConnectionClosed, but got %v", err)
+	}
+}
+
+func TestResponder_PooledConnection(t *testing.T) {
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go loop.RunLoop()
+	defer loop.Stop()
+	p := NewPool(1, true)
+
+	fd, peer := newSocketPair(t)
+	defer unix.Close(peer)
+	c := p.Get(fd, loop, nil, &lineProtocol{}, nil, 0, &emptyCallBack{})
+	if err := loop.AddSocketAndEnableRead(fd, c); err != nil {
+		t.Fatal(err)
+	}
+	r := NewResponder(c, 1)
+	_ = c.Close()
+	<-c.Done()
+
+	// 连接关闭后被复用给新的客户端，之前的 Responder 不能再发送
+	fd, peer = newSocketPair(t)
+	defer unix.Close(peer)
+	reused := p.Get(fd, loop, nil, &lineProtocol{}, nil, 0, &emptyCallBack{})
+	if reused != c {
+		t.Fatal("connection should be reused")
+	}
+	if err := loop.AddSocketAndEnableRead(fd, reused); err != nil {
+		t.Fatal(err)
+	}
+	if r.Alive() {
+		t.Fatal("responder should not be alive after the connection is reused")
+	}
+	if err := r.Respond([]byte("stale")); err != ErrConnectionClosed {
+		t.Fatalf("expect ErrConnectionClosed, but got %v", err)
+	}
+	if err := NewResponder(reused, 2).Respond([]byte("fresh")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, err := unix.Read(peer, buf)
+	if err != nil || string(buf[:n]) != "fresh\n" {
+		t.Fatalf("expect only the new response, but got %q, %v", buf[:n], err)
+	}
+}
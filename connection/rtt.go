@@ -0,0 +1,21 @@
+package connection
+
+import (
+	"time"
+)
+
+// RTT：最近一次测得的往返时间，由协议（如 plugins/keepalive）通过 SetRTT 更新，未测量过时为 0
+func (c *Connection) RTT() time.Duration {
+	return time.Duration(c.rtt.Get())
+}
+
+// SetRTT：供协议在收到探测响应时更新往返时间，可以在任意 goroutine 中调用
+func (c *Connection) SetRTT(d time.Duration) {
+	_ = c.rtt.Swap(int64(d))
+}
+
+// QueueInLoop：在连接所属的事件循环中执行 f，供协议在其他 goroutine 中通过 SendInLoop 发送协议自身的数据。
+// 连接关闭后 f 仍可能被执行，f 中应检查 Connected
+func (c *Connection) QueueInLoop(f func()) {
+	c.loop.QueueInLoop(f)
+}
@@ -0,0 +1,69 @@
+package connection
+
+import "io"
+
+const (
+	sendFromChunkSize = 32 * 1024 // SendFrom 每次从 io.Reader 读取的长度
+	sendFromLowWater  = 64 * 1024 // outBuffer 中积压的数据不超过该值时 SendFrom 才继续读取
+)
+
+// SendFrom：从 r 中读取数据发送给对端直到 io.EOF，每次读到的数据经过协议打包后发送。
+// outBuffer 中积压的数据过多时暂停读取 r，等 socket 可写、数据写出后再继续，内存占用不随 r 的长度增长。
+// 调用会阻塞到 r 读完、读取出错或连接关闭，不能在事件循环 goroutine 中调用
+func (c *Connection) SendFrom(r io.Reader) error {
+	if c.udp {
+		return ErrUDPNotSupported
+	}
+	if !c.connected.Get() {
+		return c.closedError()
+	}
+	done := c.Done()
+	generation := c.generation.Get()
+	for {
+		buf := make([]byte, sendFromChunkSize)
+		n, err := r.Read(buf)
+		if n > 0 {
+			data, drained := buf[:n], make(chan struct{})
+			c.loop.QueueInLoop(func() {
+				// 连接已关闭，done 会被关闭
+				if c.generation.Get() != generation || !c.connected.Get() {
+					return
+				}
+				c.sendInLoop(c.protocol.Packet(c, data))
+				c.waitDrained(drained)
+			})
+			select {
+			case <-drained:
+			case <-done:
+				return c.closedError()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// waitDrained：outBuffer 中积压的数据不超过 sendFromLowWater 时关闭 ch，否则等 handleWrite 写出后再关闭
+func (c *Connection) waitDrained(ch chan struct{}) {
+	if c.outBuffer.Length() <= sendFromLowWater {
+		close(ch)
+		return
+	}
+	c.drainWaiters = append(c.drainWaiters, ch)
+}
+
+// notifyDrained：handleWrite 写出数据后唤醒等待的 SendFrom
+func (c *Connection) notifyDrained() {
+	if len(c.drainWaiters) == 0 || c.outBuffer.Length() > sendFromLowWater {
+		return
+	}
+	for i, ch := range c.drainWaiters {
+		close(ch)
+		c.drainWaiters[i] = nil
+	}
+	c.drainWaiters = c.drainWaiters[:0]
+}
@@ -0,0 +1,94 @@
+package connection
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/tool/sync/atomic"
+	"golang.org/x/sys/unix"
+)
+
+// patternReader：产生 remain 字节的数据，记录已经被读取的长度
+type patternReader struct {
+	remain   int
+	produced atomic.Int64
+}
+
+func (r *patternReader) Read(p []byte) (int, error) {
+	if r.remain == 0 {
+		return 0, io.EOF
+	}
+	if len(p) > r.remain {
+		p = p[:r.remain]
+	}
+	for i := range p {
+		p[i] = byte(i)
+	}
+	r.remain -= len(p)
+	r.produced.Add(int64(len(p)))
+	return len(p), nil
+}
+
+func TestConnection_SendFromSlowConsumer(t *testing.T) {
+	c, peer, loop, _ := newRunningConnection(t, &DefaultProtocol{})
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	const total = 16 << 20
+	r := &patternReader{remain: total}
+	sent := make(chan error, 1)
+	go func() {
+		sent <- c.SendFrom(r)
+	}()
+
+	// 对端缓慢读取，已读取但未被对端收到的数据应当有上限
+	var received, maxPending int64
+	buf := make([]byte, 64*1024)
+	for received < total {
+		time.Sleep(time.Millisecond / 2)
+		n, err := unix.Read(peer, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		received += int64(n)
+		if pending := r.produced.Get() - received; pending > maxPending {
+			maxPending = pending
+		}
+	}
+	if err := <-sent; err != nil {
+		t.Fatal(err)
+	}
+	if maxPending > 2<<20 {
+		t.Fatalf("memory should be bounded, but %d bytes were pending", maxPending)
+	}
+
+	capacity := make(chan int, 1)
+	loop.QueueInLoop(func() { capacity <- c.outBuffer.Capacity() })
+	if n := <-capacity; n > 1<<20 {
+		t.Fatalf("outBuffer should stay small, but grew to %d", n)
+	}
+}
+
+func TestConnection_SendFromClosed(t *testing.T) {
+	c, peer, loop, closed := newRunningConnection(t, &DefaultProtocol{})
+	defer loop.Stop()
+
+	sent := make(chan error, 1)
+	go func() {
+		sent <- c.SendFrom(&patternReader{remain: 1 << 30})
+	}()
+	time.Sleep(time.Millisecond * 50)
+	_ = unix.Close(peer)
+	waitCloseReason(t, closed)
+
+	select {
+	case err := <-sent:
+		if !errors.Is(err, ErrConnectionClosed) {
+			t.Fatalf("expect ErrConnectionClosed, but got %v", err)
+		}
+	case <-time.After(time.Second * 3):
+		t.Fatal("SendFrom should return after the connection is closed")
+	}
+}
@@ -0,0 +1,67 @@
+package connection
+
+// sendQueuedResult：SendQueued 在事件循环中得到的写出结果
+type sendQueuedResult struct {
+	buffered bool
+	err      error
+}
+
+// SendQueued：与 Send 相同，经过协议打包后发送 data，但会等待事件循环实际处理完这次发送再返回，
+// buffered 表示数据（或其中一部分）进入了 outBuffer，即对端的读取跟不上，生产者可以据此立即降低发送速度；
+// 为 false 时数据已全部写入 socket。
+//
+// 并发语义：数据由连接所属的事件循环写出，SendQueued 会阻塞调用方直到事件循环处理到这次发送，
+// 因此只能在事件循环之外的 goroutine 中调用，在 OnMessage 等回调中调用会死锁，回调中使用 SendQueuedInLoop。
+// 多个 goroutine 同时调用时各自的数据按进入事件循环的顺序写出。
+// 连接已关闭时返回关闭的错误，事件循环在处理这次发送之前停止时返回 ErrServerShutdown，outBuffer 达到上限被拒绝或丢弃时返回 ErrWriteBufferFull
+func (c *Connection) SendQueued(data []byte) (buffered bool, err error) {
+	if c.udp {
+		return false, ErrUDPNotSupported
+	}
+	if !c.connected.Get() || c.loop.Stopped() {
+		return false, c.closedError()
+	}
+	if c.sendRefused(len(data)) {
+		return false, ErrWriteBufferFull
+	}
+
+	result := make(chan sendQueuedResult, 1)
+	generation := c.generation.Get()
+	c.loop.QueueInLoop(func() {
+		if c.generation.Get() != generation || !c.connected.Get() {
+			result <- sendQueuedResult{err: c.closedError()}
+			return
+		}
+		buffered, err := c.SendQueuedInLoop(data)
+		result <- sendQueuedResult{buffered: buffered, err: err}
+	})
+	// 事件循环停止后不再执行投递的任务，不能一直等待
+	select {
+	case r := <-result:
+		return r.buffered, r.err
+	case <-c.loop.Done():
+		select {
+		case r := <-result:
+			return r.buffered, r.err
+		default:
+			return false, ErrServerShutdown
+		}
+	}
+}
+
+// SendQueuedInLoop：SendQueued 在事件循环 goroutine 中的版本，供 OnMessage 等回调使用，立即返回写出结果
+func (c *Connection) SendQueuedInLoop(data []byte) (buffered bool, err error) {
+	if c.udp {
+		return false, ErrUDPNotSupported
+	}
+	switch c.sendInLoop(c.protocol.Packet(c, data)) {
+	case writeDone:
+		return false, nil
+	case writeBuffered:
+		return true, nil
+	case writeDropped:
+		return false, ErrWriteBufferFull
+	default:
+		return false, c.writeError()
+	}
+}
@@ -0,0 +1,82 @@
+package connection
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"golang.org/x/sys/unix"
+)
+
+func TestConnection_SendQueued(t *testing.T) {
+	c, peer, loop := newRunningConnectionWith(t, &DefaultProtocol{}, &emptyCallBack{})
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	// 对端及时读取时数据直接写入 socket
+	for i := 0; i < 10; i++ {
+		buffered, err := c.SendQueued([]byte("hello"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buffered {
+			t.Fatal("expect data to be written directly while the reader keeps up")
+		}
+		if _, err := io.ReadFull(fdReader(peer), make([]byte, 5)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 对端停止读取，超出内核缓冲区的数据进入 outBuffer
+	data := bytes.Repeat([]byte{'x'}, 4*1024*1024)
+	buffered, err := c.SendQueued(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !buffered {
+		t.Fatal("expect data to be buffered while the reader is stalled")
+	}
+	// 积压期间后续的数据即使很小也排在 outBuffer 之后
+	if buffered, err = c.SendQueued([]byte("tail")); err != nil || !buffered {
+		t.Fatalf("expect small data to be buffered behind backlog, got %v %v", buffered, err)
+	}
+
+	if _, err := io.ReadFull(fdReader(peer), make([]byte, len(data)+4)); err != nil {
+		t.Fatal(err)
+	}
+
+	_ = c.Close()
+	<-c.Done()
+	if _, err := c.SendQueued([]byte("closed")); err == nil {
+		t.Fatal("expect error after close")
+	}
+}
+
+func TestConnection_SendQueuedLoopStopped(t *testing.T) {
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd, peer := newSocketPair(t)
+	defer unix.Close(peer)
+	c := New(fd, loop, nil, &DefaultProtocol{}, nil, 0, &emptyCallBack{})
+
+	// 事件循环没有运行，Stop 之前投递的发送不会被处理，Stop 之后不能一直等待
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.SendQueued([]byte("hello"))
+		done <- err
+	}()
+	time.Sleep(time.Millisecond * 20)
+	_ = loop.Stop()
+	select {
+	case err := <-done:
+		if err != ErrServerShutdown {
+			t.Fatalf("expect ErrServerShutdown, but got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("SendQueued should return after the loop stops")
+	}
+}
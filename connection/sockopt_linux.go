@@ -0,0 +1,35 @@
+// +build linux
+
+package connection
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrNotUnixSocket：非 Unix Domain Socket 连接错误
+var ErrNotUnixSocket = errors.New("not a unix domain socket connection")
+
+// PeerCred：通过 SO_PEERCRED 获取 Unix Domain Socket 对端进程的 PID/UID/GID，仅适用于 Unix Socket 连接
+func (c *Connection) PeerCred() (*unix.Ucred, error) {
+	// 先确认 socket 的协议族，非 AF_UNIX 的连接不支持 SO_PEERCRED
+	domain, err := unix.GetsockoptInt(c.fd, unix.SOL_SOCKET, unix.SO_DOMAIN)
+	if err != nil {
+		return nil, err
+	}
+	if domain != unix.AF_UNIX {
+		return nil, ErrNotUnixSocket
+	}
+	return unix.GetsockoptUcred(c.fd, unix.SOL_SOCKET, unix.SO_PEERCRED)
+}
+
+// UnackedBytes：通过 ioctl(SIOCOUTQ) 获取内核发送队列中尚未被对端确认的字节数（包括尚未发出的部分），
+// 与 outBuffer 中的数据合起来即为该连接全部在途的数据。对端读取过慢时该值持续增长，可以据此识别慢连接
+func (c *Connection) UnackedBytes() (int, error) {
+	if !c.connected.Get() {
+		return 0, c.closedError()
+	}
+	// SIOCOUTQ 与 TIOCOUTQ 取值相同
+	return unix.IoctlGetInt(c.fd, unix.TIOCOUTQ)
+}
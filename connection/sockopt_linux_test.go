@@ -0,0 +1,136 @@
+// +build linux
+
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestConnection_PeerCred(t *testing.T) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fds[0])
+	defer unix.Close(fds[1])
+
+	c := newTestConnection(t, fds[0])
+	cred, err := c.PeerCred()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(cred.Pid) != os.Getpid() {
+		t.Fatalf("expect pid %d, but got %d", os.Getpid(), cred.Pid)
+	}
+	if int(cred.Uid) != os.Getuid() {
+		t.Fatalf("expect uid %d, but got %d", os.Getuid(), cred.Uid)
+	}
+	if int(cred.Gid) != os.Getgid() {
+		t.Fatalf("expect gid %d, but got %d", os.Getgid(), cred.Gid)
+	}
+}
+
+func TestConnection_PeerCredNotUnix(t *testing.T) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fd)
+
+	c := newTestConnection(t, fd)
+	if _, err := c.PeerCred(); err != ErrNotUnixSocket {
+		t.Fatalf("expect ErrNotUnixSocket, but got %v", err)
+	}
+}
+
+func TestConnection_UnackedBytes(t *testing.T) {
+	fd, peer := newTCPPair(t)
+	defer peer.Close()
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go loop.RunLoop()
+	defer func() { _ = loop.Stop() }()
+
+	cb := &closeCallBack{closed: make(chan error, 1)}
+	c := New(fd, loop, nil, &DefaultProtocol{}, nil, 0, cb)
+	if err := loop.AddSocketAndEnableRead(fd, c); err != nil {
+		t.Fatal(err)
+	}
+	// 限制发送缓冲区，使对端不读取时数据较快地积压在内核发送队列中
+	if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_SNDBUF, 64*1024); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := c.UnackedBytes(); err != nil || n != 0 {
+		t.Fatalf("expect 0 unacked bytes on an idle connection, but got %d, %v", n, err)
+	}
+
+	// 对端一直不读取，接收窗口耗尽后发送的数据停留在发送队列中
+	chunk := make([]byte, 64*1024)
+	last := 0
+	for i := 0; i < 3; i++ {
+		if err := c.Send(chunk); err != nil {
+			t.Fatal(err)
+		}
+		deadline := time.Now().Add(time.Second * 3)
+		n := last
+		for n <= last && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 10)
+			if n, err = c.UnackedBytes(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if i == 0 && n <= last {
+			t.Fatalf("expect unacked bytes to grow, but got %d", n)
+		}
+		last = n
+	}
+
+	// 发送队列与 outBuffer 中的数据合起来超过阈值，即可判定为慢连接
+	if inflight := last + outBufferLength(c); inflight < 64*1024 {
+		t.Fatalf("expect a slow connection with at least 64KB in flight, but got %d", inflight)
+	}
+
+	_ = c.Close()
+	waitCloseReason(t, cb.closed)
+	if _, err := c.UnackedBytes(); !errors.Is(err, ErrConnectionClosed) {
+		t.Fatalf("expect ErrConnectionClosed, but got %v", err)
+	}
+}
+
+func TestConnection_Fd(t *testing.T) {
+	fd, conn := newTCPPair(t)
+	defer conn.Close()
+	c := newTestConnection(t, fd)
+	if c.Fd() != fd {
+		t.Fatalf("expect fd %d, but got %d", fd, c.Fd())
+	}
+	if err := unix.SetsockoptInt(c.Fd(), unix.IPPROTO_TCP, unix.TCP_NODELAY, 1); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := unix.GetsockoptInt(c.Fd(), unix.IPPROTO_TCP, unix.TCP_NODELAY); err != nil || v == 0 {
+		t.Fatalf("expect TCP_NODELAY, but got %d, %v", v, err)
+	}
+}
+
+func ExampleConnection_Fd() {
+	// 在 OnConnect 等回调中通过 Fd 读取框架没有封装的 socket 选项，如 TCP_INFO 中的 RTT（单位为微秒）
+	onConnect := func(c *Connection) {
+		info, err := unix.GetsockoptTCPInfo(c.Fd(), unix.IPPROTO_TCP, unix.TCP_INFO)
+		if err != nil {
+			return
+		}
+		fmt.Println("rtt:", time.Duration(info.Rtt)*time.Microsecond)
+	}
+	_ = onConnect
+}
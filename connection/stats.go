@@ -0,0 +1,41 @@
+package connection
+
+import "github.com/Dongxiem/fastnet/tool/sync/atomic"
+
+// Stats：一组连接共享的计数器，通过 WithStats 设置后在连接建立、关闭及读写时更新，可以在任意 goroutine 中读取
+type Stats struct {
+	Connections  atomic.Int64 // 尚未关闭的连接数
+	BytesRead    atomic.Int64 // 从 socket 读到的字节数
+	BytesWritten atomic.Int64 // 写入 socket 的字节数
+	Messages     atomic.Int64 // 拆包得到并交给 OnMessage（或 OnMessages）处理的消息数
+}
+
+// WithStats：连接的建立、关闭及读写字节数计入 s，多个连接可以共享同一个 Stats
+func WithStats(s *Stats) Option {
+	return func(c *Connection) {
+		c.stats = s
+	}
+}
+
+// addRead：记录从 socket 读到的字节数
+func (c *Connection) addRead(n int) {
+	c.bytesRead.Add(int64(n))
+	if c.stats != nil {
+		c.stats.BytesRead.Add(int64(n))
+	}
+}
+
+// addMessages：记录交给 OnMessage 处理的消息数
+func (c *Connection) addMessages(n int) {
+	if c.stats != nil {
+		c.stats.Messages.Add(int64(n))
+	}
+}
+
+// addWritten：记录写入 socket 的字节数
+func (c *Connection) addWritten(n int) {
+	c.bytesWritten.Add(int64(n))
+	if c.stats != nil {
+		c.stats.BytesWritten.Add(int64(n))
+	}
+}
@@ -0,0 +1,19 @@
+package connection
+
+// CanStreamBody：Handler 是否实现了 BodyChunkCallBack，协议在开始读取消息体前据此选择流式交付还是缓冲整个消息体
+func (c *Connection) CanStreamBody() bool {
+	_, ok := c.callBack.(BodyChunkCallBack)
+	return ok
+}
+
+// StreamBody：供协议在 UnPacket 中调用，将消息体的一个分片交给 Handler 的 OnBodyChunk，
+// Handler 未实现 BodyChunkCallBack 时返回 false，此时协议应缓冲整个消息体后通过 OnMessage 交付。
+// 协议可以在最后一个分片之后返回消息头等 ctx，由 OnMessage 生成响应
+func (c *Connection) StreamBody(chunk []byte, isLast bool) bool {
+	h, ok := c.callBack.(BodyChunkCallBack)
+	if !ok {
+		return false
+	}
+	h.OnBodyChunk(c, chunk, isLast)
+	return true
+}
@@ -0,0 +1,143 @@
+package connection
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"io"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+	"golang.org/x/sys/unix"
+)
+
+// uploadProtocol：4 字节长度 + 消息体，Handler 支持时流式交付消息体，消息体结束后返回 ctx 以便 OnMessage 回复
+type uploadProtocol struct{}
+
+const remainingKey = "upload_remaining"
+
+func (p *uploadProtocol) UnPacket(c *Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	remaining, _ := c.Get(remainingKey)
+	n, _ := remaining.(int)
+	if n == 0 {
+		if buffer.Length() < 4 {
+			return nil, nil
+		}
+		header := make([]byte, 4)
+		_, _ = buffer.Read(header)
+		n = int(binary.BigEndian.Uint32(header))
+	}
+
+	for n > 0 && buffer.Length() > 0 {
+		first, end := buffer.PeekAll()
+		chunk := first
+		if len(chunk) == 0 {
+			chunk = end
+		}
+		if len(chunk) > n {
+			chunk = chunk[:n]
+		}
+		n -= len(chunk)
+		c.StreamBody(chunk, n == 0)
+		buffer.Retrieve(len(chunk))
+	}
+	c.Set(remainingKey, n)
+	if n == 0 {
+		return "done", nil
+	}
+	return nil, nil
+}
+
+func (p *uploadProtocol) Packet(c *Connection, data []byte) []byte {
+	return data
+}
+
+type uploadCallBack struct {
+	hash     hash.Hash
+	chunks   int
+	maxChunk int
+	last     int
+	done     chan struct{}
+}
+
+func (u *uploadCallBack) OnBodyChunk(c *Connection, chunk []byte, isLast bool) {
+	u.hash.Write(chunk)
+	u.chunks++
+	if len(chunk) > u.maxChunk {
+		u.maxChunk = len(chunk)
+	}
+	if isLast {
+		u.last++
+	}
+}
+
+func (u *uploadCallBack) OnMessage(c *Connection, ctx interface{}, data []byte) []byte {
+	close(u.done)
+	return []byte("ok")
+}
+
+func (u *uploadCallBack) OnConnect(c *Connection) {}
+
+func (u *uploadCallBack) OnClose(c *Connection) {}
+
+func TestConnection_StreamBody(t *testing.T) {
+	cb := &uploadCallBack{hash: sha256.New(), done: make(chan struct{})}
+	c, peer, loop := newRunningConnectionWith(t, &uploadProtocol{}, cb)
+	defer func() {
+		_ = loop.Stop()
+		_ = unix.Close(peer)
+	}()
+
+	body := make([]byte, 8<<20)
+	rand.Read(body)
+	go func() {
+		header := make([]byte, 4)
+		binary.BigEndian.PutUint32(header, uint32(len(body)))
+		_, _ = unix.Write(peer, header)
+		for data := body; len(data) > 0; {
+			n, err := unix.Write(peer, data[:minInt(len(data), 64*1024)])
+			if err != nil {
+				return
+			}
+			data = data[n:]
+		}
+	}()
+
+	select {
+	case <-cb.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("upload should be finished")
+	}
+	sum := sha256.Sum256(body)
+	if !bytes.Equal(cb.hash.Sum(nil), sum[:]) {
+		t.Fatal("streamed body mismatch")
+	}
+	if cb.chunks < 2 || cb.last != 1 {
+		t.Fatalf("expect body delivered in chunks with one last chunk, but got %d chunks, %d last", cb.chunks, cb.last)
+	}
+	// 消息体没有被整个缓冲
+	if cb.maxChunk >= len(body) || c.inBuffer.Capacity() >= len(body) {
+		t.Fatalf("body should not be buffered, max chunk %d, inBuffer %d", cb.maxChunk, c.inBuffer.Capacity())
+	}
+
+	resp := make([]byte, 2)
+	if _, err := io.ReadFull(fdReader(peer), resp); err != nil {
+		t.Fatal(err)
+	}
+	if string(resp) != "ok" {
+		t.Fatalf("expect ok, but got %s", resp)
+	}
+	if !c.CanStreamBody() || newTestConnection(t, -1).CanStreamBody() {
+		t.Fatal("CanStreamBody should reflect whether the handler implements OnBodyChunk")
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,103 @@
+// +build linux
+
+package connection
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// sendFlags：写 socket 时使用 MSG_NOSIGNAL，对端已关闭时只返回 EPIPE 而不会产生 SIGPIPE 信号
+const sendFlags = unix.MSG_NOSIGNAL
+
+// write：向 socket 写入数据，等价于 unix.Write，但不会触发 SIGPIPE
+func write(fd int, p []byte) (int, error) {
+	return unix.SendmsgN(fd, p, nil, nil, sendFlags)
+}
+
+// writev：向 socket 写入多段数据，等价于 unix.Writev，但不会触发 SIGPIPE
+func writev(fd int, bufs [][]byte) (int, error) {
+	iovecs := make([]unix.Iovec, 0, len(bufs))
+	for _, b := range bufs {
+		if len(b) == 0 {
+			continue
+		}
+		iov := unix.Iovec{Base: &b[0]}
+		iov.SetLen(len(b))
+		iovecs = append(iovecs, iov)
+	}
+	if len(iovecs) == 0 {
+		return 0, nil
+	}
+
+	var msg unix.Msghdr
+	msg.Iov = &iovecs[0]
+	msg.SetIovlen(len(iovecs))
+	n, _, errno := unix.Syscall(unix.SYS_SENDMSG, uintptr(fd), uintptr(unsafe.Pointer(&msg)), sendFlags)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+// mmsghdr：对应内核 struct mmsghdr，用于 recvmmsg/sendmmsg
+type mmsghdr struct {
+	hdr unix.Msghdr
+	len uint32
+}
+
+// recvmmsg：一次系统调用读取多个数据报，返回读取到的数据报个数
+func recvmmsg(fd int, msgs []mmsghdr) (int, error) {
+	n, _, errno := unix.Syscall6(unix.SYS_RECVMMSG, uintptr(fd), uintptr(unsafe.Pointer(&msgs[0])), uintptr(len(msgs)), 0, 0, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+// rawToSockaddr：将内核返回的 RawSockaddrAny 转换为 unix.Sockaddr，仅支持 IPv4 与 IPv6
+func rawToSockaddr(rsa *unix.RawSockaddrAny) unix.Sockaddr {
+	switch rsa.Addr.Family {
+	case unix.AF_INET:
+		pp := (*unix.RawSockaddrInet4)(unsafe.Pointer(rsa))
+		sa := &unix.SockaddrInet4{Port: int(pp.Port>>8 | pp.Port<<8)}
+		sa.Addr = pp.Addr
+		return sa
+	case unix.AF_INET6:
+		pp := (*unix.RawSockaddrInet6)(unsafe.Pointer(rsa))
+		sa := &unix.SockaddrInet6{Port: int(pp.Port>>8 | pp.Port<<8), ZoneId: pp.Scope_id}
+		sa.Addr = pp.Addr
+		return sa
+	}
+	return nil
+}
+
+// sendmmsg：一次系统调用发送多个数据报，返回成功发送的数据报个数
+func sendmmsg(fd int, msgs []mmsghdr) (int, error) {
+	n, _, errno := unix.Syscall6(unix.SYS_SENDMMSG, uintptr(fd), uintptr(unsafe.Pointer(&msgs[0])), uintptr(len(msgs)), sendFlags, 0, 0)
+	if errno != 0 {
+		return 0, errno
+	}
+	return int(n), nil
+}
+
+// sockaddrToRaw：将 unix.Sockaddr 转换为内核使用的原始地址，仅支持 IPv4 与 IPv6
+func sockaddrToRaw(sa unix.Sockaddr, rsa *unix.RawSockaddrAny) (uint32, bool) {
+	switch sa := sa.(type) {
+	case *unix.SockaddrInet4:
+		pp := (*unix.RawSockaddrInet4)(unsafe.Pointer(rsa))
+		pp.Family = unix.AF_INET
+		pp.Port = uint16(sa.Port>>8) | uint16(sa.Port)<<8
+		pp.Addr = sa.Addr
+		return unix.SizeofSockaddrInet4, true
+	case *unix.SockaddrInet6:
+		pp := (*unix.RawSockaddrInet6)(unsafe.Pointer(rsa))
+		pp.Family = unix.AF_INET6
+		pp.Port = uint16(sa.Port>>8) | uint16(sa.Port)<<8
+		pp.Scope_id = sa.ZoneId
+		pp.Addr = sa.Addr
+		return unix.SizeofSockaddrInet6, true
+	}
+	return 0, false
+}
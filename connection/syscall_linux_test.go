@@ -0,0 +1,43 @@
+// +build linux
+
+package connection
+
+import (
+	"os"
+	"os/signal"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestConnection_WriteClosedPeer(t *testing.T) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, unix.SIGPIPE)
+	defer signal.Stop(sigCh)
+
+	fd, peer := newSocketPair(t)
+	c := newTestConnection(t, fd)
+	if err := unix.Close(peer); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := write(fd, []byte("hello")); err != unix.EPIPE {
+		t.Fatalf("expect EPIPE, but got %v", err)
+	}
+	if _, err := writev(fd, [][]byte{[]byte("hello"), []byte("fastnet")}); err != unix.EPIPE {
+		t.Fatalf("expect EPIPE, but got %v", err)
+	}
+
+	// 写失败后连接应被关闭，且进程不会收到 SIGPIPE
+	c.sendInLoop([]byte("hello"))
+	if c.Connected() {
+		t.Fatal("connection should be closed after writing to a closed peer")
+	}
+
+	select {
+	case <-sigCh:
+		t.Fatal("unexpected SIGPIPE")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,56 @@
+// +build linux
+
+package connection
+
+import (
+	"time"
+	"unsafe"
+
+	"github.com/Dongxiem/fastnet/log"
+	"golang.org/x/sys/unix"
+)
+
+// scmTimestamping：对应内核 struct scm_timestamping，ts[0] 为软件时间戳，ts[2] 为网卡硬件时间戳
+type scmTimestamping struct {
+	ts [3]unix.Timespec
+}
+
+// oobSize：读取时间戳的辅助数据缓冲区大小
+var oobSize = unix.CmsgSpace(int(unsafe.Sizeof(scmTimestamping{})))
+
+// enableRxTimestamps：通过 SO_TIMESTAMPING 开启接收时间戳，hardware 为 true 时同时请求网卡硬件时间戳
+func enableRxTimestamps(fd int, hardware bool) error {
+	flags := unix.SOF_TIMESTAMPING_RX_SOFTWARE | unix.SOF_TIMESTAMPING_SOFTWARE
+	if hardware {
+		flags |= unix.SOF_TIMESTAMPING_RX_HARDWARE | unix.SOF_TIMESTAMPING_RAW_HARDWARE
+	}
+	return unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_TIMESTAMPING, flags)
+}
+
+// readTimestamped：通过 recvmsg 读取数据及其接收时间戳，没有时间戳时 c.rxTime 为零值
+func (c *Connection) readTimestamped(buf []byte) (int, error) {
+	c.rxTime = time.Time{}
+	n, oobn, _, _, err := unix.Recvmsg(c.fd, buf, c.oob, 0)
+	if err != nil || oobn == 0 {
+		return n, err
+	}
+	msgs, err := unix.ParseSocketControlMessage(c.oob[:oobn])
+	if err != nil {
+		log.Error("[timestamp]", err)
+		return n, nil
+	}
+	for _, m := range msgs {
+		if m.Header.Level != unix.SOL_SOCKET || m.Header.Type != unix.SCM_TIMESTAMPING ||
+			len(m.Data) < int(unsafe.Sizeof(scmTimestamping{})) {
+			continue
+		}
+		ts := (*scmTimestamping)(unsafe.Pointer(&m.Data[0])).ts
+		// 优先使用硬件时间戳
+		if t := ts[2]; t.Sec != 0 || t.Nsec != 0 {
+			c.rxTime = time.Unix(t.Unix())
+		} else if t := ts[0]; t.Sec != 0 || t.Nsec != 0 {
+			c.rxTime = time.Unix(t.Unix())
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,67 @@
+// +build linux
+
+package connection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+)
+
+// timestampCallBack：记录每条消息的接收时间及 OnMessage 被调用的时间
+type timestampCallBack struct {
+	emptyCallBack
+	received chan [2]time.Time
+}
+
+func (e *timestampCallBack) OnMessage(c *Connection, ctx interface{}, data []byte) []byte {
+	e.received <- [2]time.Time{c.ReceiveTime(), time.Now()}
+	return nil
+}
+
+func TestConnection_ReceiveTimestamps(t *testing.T) {
+	fd, peer := newTCPPair(t)
+	defer peer.Close()
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go loop.RunLoop()
+	defer func() { _ = loop.Stop() }()
+
+	cb := &timestampCallBack{received: make(chan [2]time.Time, 1)}
+	c := New(fd, loop, nil, &DefaultProtocol{}, nil, 0, cb, ReceiveTimestamps(false))
+	if err := loop.AddSocketAndEnableRead(fd, c); err != nil {
+		t.Fatal(err)
+	}
+
+	// 内核开启时间戳功能是异步生效的，开启后最先到达的数据可能没有时间戳
+	stamped := 0
+	for i := 0; i < 20 && stamped < 3; i++ {
+		sent := time.Now()
+		if _, err := peer.Write([]byte("ping")); err != nil {
+			t.Fatal(err)
+		}
+		var got [2]time.Time
+		select {
+		case got = <-cb.received:
+		case <-time.After(time.Second * 3):
+			t.Fatal("message timeout")
+		}
+		// 内核接收时间应在发送之后、OnMessage 被调用之前
+		rx, handled := got[0], got[1]
+		if rx.IsZero() {
+			time.Sleep(time.Millisecond * 10)
+			continue
+		}
+		stamped++
+		if rx.Before(sent.Add(-time.Millisecond)) || rx.After(handled) {
+			t.Fatalf("implausible receive timestamp %v, sent at %v, handled at %v", rx, sent, handled)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	if stamped < 3 {
+		t.Fatal("receive timestamp should be delivered")
+	}
+}
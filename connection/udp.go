@@ -0,0 +1,224 @@
+package connection
+
+import (
+	"errors"
+	"unsafe"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/poller"
+	"github.com/Dongxiem/fastnet/tool/clock"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+	"golang.org/x/sys/unix"
+)
+
+// maxDatagramSize：单个 UDP 数据报的最大长度
+const maxDatagramSize = 0xFFFF
+
+// DefaultUDPBatchSize：默认每次 recvmmsg 读取的数据报个数
+const DefaultUDPBatchSize = 32
+
+// ErrUDPNotSupported：UDP 连接不支持的操作
+var ErrUDPNotSupported = errors.New("operation not supported on udp connection")
+
+// UDPSocket：UDP 监听 socket，负责读取数据报并分发给回调，
+// 每个数据报都会以一个独立的 Connection 交给 OnMessage，通过该 Connection 可以回复数据报的来源地址
+type UDPSocket struct {
+	fd       int
+	loop     *eventloop.EventLoop
+	protocol Protocol
+	callBack CallBack
+
+	batch int
+	msgs  []mmsghdr
+	bufs  [][]byte
+	addrs []unix.RawSockaddrAny
+	iovs  []unix.Iovec
+}
+
+// NewUDPSocket：创建 UDPSocket，batch 为每次系统调用读取的数据报个数，小于等于 1 时使用 recvfrom 逐个读取
+func NewUDPSocket(fd int, loop *eventloop.EventLoop, protocol Protocol, callBack CallBack, batch int) *UDPSocket {
+	if batch < 1 {
+		batch = 1
+	}
+	s := &UDPSocket{
+		fd:       fd,
+		loop:     loop,
+		protocol: protocol,
+		callBack: callBack,
+		batch:    batch,
+		msgs:     make([]mmsghdr, batch),
+		bufs:     make([][]byte, batch),
+		addrs:    make([]unix.RawSockaddrAny, batch),
+		iovs:     make([]unix.Iovec, batch),
+	}
+	for i := 0; i < batch; i++ {
+		s.bufs[i] = make([]byte, maxDatagramSize)
+		s.iovs[i].Base = &s.bufs[i][0]
+		s.iovs[i].SetLen(maxDatagramSize)
+	}
+	return s
+}
+
+// Fd：返回 socket 的文件描述符
+func (s *UDPSocket) Fd() int {
+	return s.fd
+}
+
+// HandleEvent：内部使用，event loop 回调
+func (s *UDPSocket) HandleEvent(fd int, events poller.Event) {
+	if events&poller.EventRead == 0 {
+		return
+	}
+	if s.batch == 1 {
+		s.readOne()
+	} else {
+		s.readBatch()
+	}
+}
+
+// readOne：使用 recvfrom 读取一个数据报
+func (s *UDPSocket) readOne() {
+	n, sa, err := unix.Recvfrom(s.fd, s.bufs[0], 0)
+	if err != nil {
+		if err != unix.EAGAIN {
+			log.Error("[UDPSocket] recvfrom:", err)
+		}
+		return
+	}
+	s.dispatch(s.bufs[0][:n], sa)
+}
+
+// readBatch：使用 recvmmsg 一次读取多个数据报
+func (s *UDPSocket) readBatch() {
+	for i := range s.msgs {
+		s.msgs[i] = mmsghdr{}
+		s.msgs[i].hdr.Name = (*byte)(unsafe.Pointer(&s.addrs[i]))
+		s.msgs[i].hdr.Namelen = unix.SizeofSockaddrAny
+		s.msgs[i].hdr.Iov = &s.iovs[i]
+		s.msgs[i].hdr.SetIovlen(1)
+	}
+	n, err := recvmmsg(s.fd, s.msgs)
+	if err != nil {
+		if err != unix.EAGAIN {
+			log.Error("[UDPSocket] recvmmsg:", err)
+		}
+		return
+	}
+	for i := 0; i < n; i++ {
+		s.dispatch(s.bufs[i][:s.msgs[i].len], rawToSockaddr(&s.addrs[i]))
+	}
+}
+
+// dispatch：将一个数据报交给协议拆包及 OnMessage 处理，数据报边界即消息边界，拆包剩余的数据会被丢弃
+func (s *UDPSocket) dispatch(data []byte, sa unix.Sockaddr) {
+	if len(data) == 0 {
+		return
+	}
+	c := newUDPConnection(s.fd, s.loop, sa, s.protocol, s.callBack)
+	for _, out := range c.handlerProtocol(ringbuffer.NewWithData(data)) {
+		c.sendTo(out)
+	}
+}
+
+// Close：关闭 socket
+func (s *UDPSocket) Close() error {
+	s.loop.QueueInLoop(func() {
+		s.loop.DeleteFdInLoop(s.fd)
+		if err := unix.Close(s.fd); err != nil {
+			log.Error("[UDPSocket] close error: ", err)
+		}
+	})
+	return nil
+}
+
+// newUDPConnection：创建代表一个数据报来源的 Connection，与 UDPSocket 共享 fd
+func newUDPConnection(fd int, loop *eventloop.EventLoop, sa unix.Sockaddr, protocol Protocol, callBack CallBack) *Connection {
+	conn := &Connection{
+		fd:       fd,
+		udp:      true,
+		sa:       sa,
+		peerAddr: SockAddrToString(sa),
+		callBack: callBack,
+		loop:     loop,
+		protocol: protocol,
+		clock:    clock.Real(),
+	}
+	conn.connected.Set(true)
+	return conn
+}
+
+// sendTo：向数据报来源地址发送一个数据报，发送失败（包括 EAGAIN）时数据报被丢弃
+func (c *Connection) sendTo(data []byte) {
+	if err := unix.Sendto(c.fd, data, sendFlags, c.sa); err != nil {
+		log.Error("[sendTo]", err)
+	}
+}
+
+// Datagram：批量发送的一个数据报
+type Datagram struct {
+	Data []byte
+	Addr unix.Sockaddr // 目标地址，为 nil 时发送给该连接的对端
+}
+
+// SendBatch：批量发送数据报，每个数据报都会经过协议打包，在事件循环中通过 sendmmsg 一次系统调用发出，
+// 仅适用于 UDP 连接
+func (c *Connection) SendBatch(datagrams []Datagram) error {
+	if !c.udp {
+		return ErrUDPNotSupported
+	}
+	if !c.connected.Get() {
+		return c.closedError()
+	}
+
+	c.loop.QueueInLoop(func() {
+		packed := make([]Datagram, len(datagrams))
+		for i := range datagrams {
+			packed[i].Data = c.protocol.Packet(c, datagrams[i].Data)
+			packed[i].Addr = datagrams[i].Addr
+		}
+		c.sendBatchInLoop(packed)
+	})
+	return nil
+}
+
+// sendBatchInLoop：通过 sendmmsg 发送一批数据报，部分发送时继续发送剩余部分，
+// 遇到 EAGAIN 等错误时剩余的数据报被丢弃
+func (c *Connection) sendBatchInLoop(datagrams []Datagram) {
+	msgs := make([]mmsghdr, 0, len(datagrams))
+	iovs := make([]unix.Iovec, len(datagrams))
+	addrs := make([]unix.RawSockaddrAny, len(datagrams))
+	for i := range datagrams {
+		sa := datagrams[i].Addr
+		if sa == nil {
+			sa = c.sa
+		}
+		namelen, ok := sockaddrToRaw(sa, &addrs[i])
+		if !ok {
+			log.Error("[SendBatch] unsupported address", sa)
+			continue
+		}
+		var msg mmsghdr
+		msg.hdr.Name = (*byte)(unsafe.Pointer(&addrs[i]))
+		msg.hdr.Namelen = namelen
+		if data := datagrams[i].Data; len(data) > 0 {
+			iovs[i].Base = &data[0]
+			iovs[i].SetLen(len(data))
+			msg.hdr.Iov = &iovs[i]
+			msg.hdr.SetIovlen(1)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	for len(msgs) > 0 {
+		n, err := sendmmsg(c.fd, msgs)
+		if err != nil {
+			if err == unix.EINTR {
+				continue
+			}
+			log.Error("[SendBatch] dropped", len(msgs), "datagrams:", err)
+			return
+		}
+		msgs = msgs[n:]
+	}
+}
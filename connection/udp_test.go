@@ -0,0 +1,231 @@
+package connection
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"github.com/Dongxiem/fastnet/poller"
+	"golang.org/x/sys/unix"
+)
+
+type udpRecorder struct {
+	data  []string
+	peers []string
+}
+
+func (r *udpRecorder) OnMessage(c *Connection, ctx interface{}, data []byte) []byte {
+	r.data = append(r.data, string(data))
+	r.peers = append(r.peers, c.PeerAddr())
+	return data
+}
+
+func (r *udpRecorder) OnConnect(c *Connection) {}
+
+func (r *udpRecorder) OnClose(c *Connection) {}
+
+// newUDPPair：创建绑定在回环地址上的非阻塞服务端 socket 与客户端 socket
+func newUDPPair(t testing.TB) (server, client int, serverAddr *unix.SockaddrInet4) {
+	newSocket := func() int {
+		fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := unix.Bind(fd, &unix.SockaddrInet4{Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+			t.Fatal(err)
+		}
+		_ = unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_RCVBUF, 4<<20)
+		return fd
+	}
+	server, client = newSocket(), newSocket()
+	if err := unix.SetNonblock(server, true); err != nil {
+		t.Fatal(err)
+	}
+	sa, err := unix.Getsockname(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, client, sa.(*unix.SockaddrInet4)
+}
+
+func newTestUDPSocket(t testing.TB, fd int, callBack CallBack, batch int) *UDPSocket {
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewUDPSocket(fd, loop, &DefaultProtocol{}, callBack, batch)
+}
+
+func TestUDPSocket_Dispatch(t *testing.T) {
+	for _, batch := range []int{1, DefaultUDPBatchSize} {
+		server, client, addr := newUDPPair(t)
+		r := &udpRecorder{}
+		s := newTestUDPSocket(t, server, r, batch)
+
+		for i := 0; i < 10; i++ {
+			if err := unix.Sendto(client, []byte(strconv.Itoa(i)), 0, addr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		deadline := time.Now().Add(time.Second)
+		for len(r.data) < 10 && time.Now().Before(deadline) {
+			s.HandleEvent(server, poller.EventRead)
+		}
+		if len(r.data) != 10 {
+			t.Fatalf("batch %d: expect 10 datagrams, but got %d", batch, len(r.data))
+		}
+
+		clientAddr, _ := unix.Getsockname(client)
+		for i := 0; i < 10; i++ {
+			if r.data[i] != strconv.Itoa(i) {
+				t.Fatalf("batch %d: expect %d, but got %s", batch, i, r.data[i])
+			}
+			if r.peers[i] != SockAddrToString(clientAddr) {
+				t.Fatalf("batch %d: expect peer %s, but got %s", batch, SockAddrToString(clientAddr), r.peers[i])
+			}
+
+			// 回显的数据报应发回到来源地址
+			buf := make([]byte, 16)
+			n, _, err := unix.Recvfrom(client, buf, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(buf[:n]) != strconv.Itoa(i) {
+				t.Fatalf("batch %d: expect echo %d, but got %s", batch, i, buf[:n])
+			}
+		}
+
+		_ = unix.Close(server)
+		_ = unix.Close(client)
+	}
+}
+
+type udpCounter struct {
+	n int
+}
+
+func (u *udpCounter) OnMessage(c *Connection, ctx interface{}, data []byte) []byte {
+	u.n++
+	return nil
+}
+
+func (u *udpCounter) OnConnect(c *Connection) {}
+
+func (u *udpCounter) OnClose(c *Connection) {}
+
+func benchmarkUDPSocket(b *testing.B, batch int) {
+	server, client, addr := newUDPPair(b)
+	defer unix.Close(server)
+	defer unix.Close(client)
+
+	counter := &udpCounter{}
+	s := newTestUDPSocket(b, server, counter, batch)
+	payload := make([]byte, 64)
+	const packets = 64
+
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < packets; j++ {
+			if err := unix.Sendto(client, payload, 0, addr); err != nil {
+				b.Fatal(err)
+			}
+		}
+		want := (i + 1) * packets
+		for counter.n < want {
+			s.HandleEvent(server, poller.EventRead)
+		}
+	}
+	b.ReportMetric(float64(counter.n)/time.Since(start).Seconds(), "packets/s")
+}
+
+func BenchmarkUDPSocket_Recvfrom(b *testing.B) {
+	benchmarkUDPSocket(b, 1)
+}
+
+func BenchmarkUDPSocket_Recvmmsg(b *testing.B) {
+	benchmarkUDPSocket(b, DefaultUDPBatchSize)
+}
+
+func TestConnection_SendBatch(t *testing.T) {
+	server, client, _ := newUDPPair(t)
+	defer unix.Close(server)
+	defer unix.Close(client)
+
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientAddr, _ := unix.Getsockname(client)
+	c := newUDPConnection(server, loop, clientAddr, &DefaultProtocol{}, &emptyCallBack{})
+
+	datagrams := make([]Datagram, 100)
+	for i := range datagrams {
+		datagrams[i].Data = []byte(strconv.Itoa(i))
+	}
+	c.sendBatchInLoop(datagrams)
+
+	buf := make([]byte, 16)
+	for i := range datagrams {
+		n, _, err := unix.Recvfrom(client, buf, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != strconv.Itoa(i) {
+			t.Fatalf("expect %d, but got %s", i, buf[:n])
+		}
+	}
+
+	tcp := newTestConnection(t, -1)
+	if err := tcp.SendBatch(datagrams); err != ErrUDPNotSupported {
+		t.Fatalf("expect ErrUDPNotSupported, but got %v", err)
+	}
+}
+
+func benchmarkUDPSend(b *testing.B, batch bool) {
+	server, client, _ := newUDPPair(b)
+	defer unix.Close(server)
+	defer unix.Close(client)
+
+	go func() {
+		buf := make([]byte, 128)
+		for {
+			if _, _, err := unix.Recvfrom(client, buf, 0); err != nil {
+				return
+			}
+		}
+	}()
+
+	loop, err := eventloop.New()
+	if err != nil {
+		b.Fatal(err)
+	}
+	clientAddr, _ := unix.Getsockname(client)
+	c := newUDPConnection(server, loop, clientAddr, &DefaultProtocol{}, &emptyCallBack{})
+	// 使用阻塞写，避免发送缓冲区满时丢包影响结果
+	_ = unix.SetNonblock(server, false)
+
+	datagrams := make([]Datagram, 64)
+	for i := range datagrams {
+		datagrams[i].Data = make([]byte, 64)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if batch {
+			c.sendBatchInLoop(datagrams)
+		} else {
+			for j := range datagrams {
+				c.sendTo(datagrams[j].Data)
+			}
+		}
+	}
+}
+
+func BenchmarkConnection_SendTo(b *testing.B) {
+	benchmarkUDPSend(b, false)
+}
+
+func BenchmarkConnection_SendBatch(b *testing.B) {
+	benchmarkUDPSend(b, true)
+}
@@ -0,0 +1,84 @@
+package connection
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"github.com/Dongxiem/fastnet/tool/sync/atomic"
+	"golang.org/x/sys/unix"
+)
+
+type writableCallBack struct {
+	emptyCallBack
+	writable atomic.Int64
+}
+
+func (w *writableCallBack) OnWritable(c *Connection) {
+	w.writable.Add(1)
+}
+
+func TestConnection_EnableWrite(t *testing.T) {
+	fd, peer := newSocketPair(t)
+	defer unix.Close(peer)
+
+	loop, err := eventloop.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go loop.RunLoop()
+	defer loop.Stop()
+
+	cb := &writableCallBack{}
+	c := New(fd, loop, nil, &DefaultProtocol{}, nil, 0, cb)
+	if err := loop.AddSocketAndEnableRead(fd, c); err != nil {
+		t.Fatal(err)
+	}
+
+	// 默认不关注可写事件
+	time.Sleep(time.Millisecond * 20)
+	if cb.writable.Get() != 0 {
+		t.Fatal("OnWritable should not be called before EnableWrite")
+	}
+
+	if err := c.EnableWrite(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second * 3)
+	for cb.writable.Get() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("OnWritable should be called after EnableWrite")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 写完数据后仍然保持关注可写事件
+	if err := c.Send([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := unix.Read(peer, buf); err != nil {
+		t.Fatal(err)
+	}
+	n := cb.writable.Get()
+	time.Sleep(time.Millisecond * 20)
+	if cb.writable.Get() == n {
+		t.Fatal("write interest should be kept after Send")
+	}
+
+	if err := c.DisableWrite(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 20)
+	n = cb.writable.Get()
+	time.Sleep(time.Millisecond * 20)
+	if cb.writable.Get() != n {
+		t.Fatal("OnWritable should not be called after DisableWrite")
+	}
+
+	_ = c.Close()
+	<-c.Done()
+	if err := c.EnableWrite(); err != ErrConnectionClosed {
+		t.Fatalf("expect ErrConnectionClosed, but got %v", err)
+	}
+}
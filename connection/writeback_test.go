@@ -0,0 +1,45 @@
+package connection
+
+import (
+	"io"
+	"testing"
+
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+	"golang.org/x/sys/unix"
+)
+
+// handshakeProtocol：第一次拆包时先回写握手数据，之后按行拆包
+type handshakeProtocol struct {
+	lineProtocol
+	done bool
+}
+
+func (p *handshakeProtocol) UnPacket(c *Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	if !p.done {
+		p.done = true
+		c.WriteBack([]byte("hello;"))
+	}
+	return p.lineProtocol.UnPacket(c, buffer)
+}
+
+func TestConnection_WriteBack(t *testing.T) {
+	_, peer, loop := newRunningConnectionWith(t, &handshakeProtocol{}, &echoCallBack{})
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	if _, err := unix.Write(peer, []byte("ping\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := unix.SetNonblock(peer, false); err != nil {
+		t.Fatal(err)
+	}
+	// 回写的数据与响应一起按顺序写出
+	want := "hello;ping\n"
+	got := make([]byte, len(want))
+	if _, err := io.ReadFull(fdReader(peer), got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("expect %q, but got %q", want, got)
+	}
+}
@@ -0,0 +1,47 @@
+package connection
+
+// queueSend：将 buffer 加入发送队列，队列由空变为非空时投递一次 flushSendQueue，
+// 之后在其执行之前的所有 Send 都由这一次投递一起写出。
+// 投递被事件循环的任务数上限拒绝时 buffer 不加入队列，返回 eventloop.ErrQueueFull
+func (c *Connection) queueSend(buffer []byte, generation int64) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+	// 回收时先递增 generation 再持有锁清空队列，在锁内检查即可保证不会把数据留给复用后的连接
+	if c.generation.Get() != generation {
+		return ErrConnectionClosed
+	}
+	// 持有锁投递，flushSendQueue 在 buffer 加入队列之后才能取出队列
+	if len(c.sendQueue) == 0 {
+		err := c.loop.TryQueueInLoop(func() {
+			// 连接已关闭并被连接池复用，队列已在回收时清空
+			if c.generation.Get() != generation {
+				return
+			}
+			c.flushSendQueue()
+		})
+		if err != nil {
+			return err
+		}
+	}
+	c.sendQueue = append(c.sendQueue, buffer)
+	return nil
+}
+
+// flushSendQueue：取出发送队列中的全部数据，逐个经过协议打包后通过一次 writev 写出
+func (c *Connection) flushSendQueue() {
+	c.sendMu.Lock()
+	bufs := c.sendQueue
+	c.sendQueue = c.sendSpare[:0]
+	c.sendMu.Unlock()
+
+	packets := c.sendVec[:0]
+	for i, b := range bufs {
+		if p := c.protocol.Packet(c, b); len(p) > 0 {
+			packets = append(packets, p)
+		}
+		bufs[i] = nil
+	}
+	c.sendBuffersInLoop(packets)
+	c.sendVec = packets[:0]
+	c.sendSpare = bufs[:0]
+}
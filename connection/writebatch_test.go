@@ -0,0 +1,109 @@
+package connection
+
+import (
+	"bufio"
+	"strconv"
+	"testing"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+	"golang.org/x/sys/unix"
+)
+
+// writeCallsOf：在事件循环中读取写系统调用的次数
+func writeCallsOf(c *Connection, loop *eventloop.EventLoop) int {
+	done := make(chan int)
+	loop.QueueInLoop(func() { done <- c.writeCalls })
+	return <-done
+}
+
+// blockLoop：阻塞事件循环直到 release 被关闭，返回时事件循环已经阻塞
+func blockLoop(loop *eventloop.EventLoop) chan struct{} {
+	blocked, release := make(chan struct{}), make(chan struct{})
+	loop.QueueInLoop(func() {
+		close(blocked)
+		<-release
+	})
+	<-blocked
+	return release
+}
+
+func TestConnection_WriteBatching(t *testing.T) {
+	c, peer, loop, _ := newRunningConnection(t, &lineProtocol{}, WriteBatching(true))
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	// 事件循环阻塞期间的发送在同一轮中合并写出
+	release := blockLoop(loop)
+	for i := 0; i < 1000; i++ {
+		if err := c.Send([]byte(strconv.Itoa(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	close(release)
+
+	r := bufio.NewReader(fdReader(peer))
+	for i := 0; i < 1000; i++ {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != strconv.Itoa(i)+"\n" {
+			t.Fatalf("expect %d, but got %q", i, line)
+		}
+	}
+	if n := writeCallsOf(c, loop); n != 1 {
+		t.Fatalf("expect 1 write, but got %d", n)
+	}
+
+	// 队列写出后再次发送会重新投递
+	if err := c.Send([]byte("again")); err != nil {
+		t.Fatal(err)
+	}
+	if line, err := r.ReadString('\n'); err != nil || line != "again\n" {
+		t.Fatalf("expect again, but got %q, %v", line, err)
+	}
+}
+
+// benchmarkSendSmall：每次迭代连续 Send 1000 条小消息并等待对端全部收到，writes/op 为每次迭代的写系统调用次数。
+// 单核环境下的测试结果：不合并时约 400 writes/op、1.3ms/op（其余消息因 outBuffer 有积压而追加写入缓冲区），
+// 每次 Send 还各有一次唤醒事件循环的 eventfd 写入；开启 WriteBatching 后约 1.2 writes/op、0.18ms/op
+func benchmarkSendSmall(b *testing.B, batching bool) {
+	c, peer, loop := newRunningConnectionWith(b, &lineProtocol{}, &emptyCallBack{}, WriteBatching(batching))
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	const messages = 1000
+	msg := []byte("0123456789")
+	// 对端使用阻塞读，避免空转抢占事件循环的 CPU
+	if err := unix.SetNonblock(peer, false); err != nil {
+		b.Fatal(err)
+	}
+	buf := make([]byte, 64*1024)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < messages; j++ {
+			if err := c.Send(msg); err != nil {
+				b.Fatal(err)
+			}
+		}
+		// 等待本次迭代的数据全部到达对端
+		for n := 0; n < messages*(len(msg)+1); {
+			m, err := unix.Read(peer, buf)
+			if err != nil {
+				b.Fatal(err)
+			}
+			n += m
+		}
+	}
+	b.StopTimer()
+	b.ReportMetric(float64(writeCallsOf(c, loop))/float64(b.N), "writes/op")
+}
+
+func BenchmarkConnection_SendSmall(b *testing.B) {
+	benchmarkSendSmall(b, false)
+}
+
+func BenchmarkConnection_SendSmallBatched(b *testing.B) {
+	benchmarkSendSmall(b, true)
+}
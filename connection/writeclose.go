@@ -0,0 +1,38 @@
+package connection
+
+// WriteClose：经过协议打包后发送 buffer，outBuffer 中的数据全部写出后再关闭连接，可以在任意 goroutine 中调用。
+// 与先后调用 Send 和 Close 不同，关闭一定发生在数据写出之后，适用于 HTTP 等发送最后一个响应后挂断的场景。
+// 调用后不再读取新的请求，buffer 为空时只等待已有的数据写出，OnClose 中 CloseReason 为 nil
+func (c *Connection) WriteClose(buffer []byte) error {
+	if c.udp {
+		return ErrUDPNotSupported
+	}
+	if !c.connected.Get() {
+		return c.closedError()
+	}
+
+	generation := c.generation.Get()
+	c.loop.QueueInLoop(func() {
+		if c.generation.Get() != generation || !c.connected.Get() {
+			return
+		}
+		if len(buffer) > 0 {
+			c.sendInLoop(c.protocol.Packet(c, buffer))
+		}
+		c.closeWhenFlushed(c.fd)
+	})
+	return nil
+}
+
+// closeWhenFlushed：outBuffer 已写完时立即关闭，否则停止读取，由 handleWrite 在写完后关闭
+func (c *Connection) closeWhenFlushed(fd int) {
+	if !c.connected.Get() {
+		return
+	}
+	if c.outBuffer.Length() == 0 {
+		c.handleClose(fd)
+		return
+	}
+	c.closeAfterWrite = true
+	c.enableWrite(fd)
+}
@@ -0,0 +1,64 @@
+package connection
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestConnection_WriteClose(t *testing.T) {
+	c, peer, loop, closed := newRunningConnection(t, &DefaultProtocol{})
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	// 远大于 socket 缓冲区的数据，大部分会暂存在 outBuffer 中
+	data := bytes.Repeat([]byte("0123456789abcdef"), 256*1024)
+	if err := c.WriteClose(data); err != nil {
+		t.Fatal(err)
+	}
+
+	// 对端缓慢读取，连接应在全部数据写出后才关闭
+	time.Sleep(time.Millisecond * 50)
+	select {
+	case <-closed:
+		t.Fatal("connection should not be closed before the data is written")
+	default:
+	}
+	var got []byte
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := unix.Read(peer, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 读到 EOF
+		if n == 0 {
+			break
+		}
+		got = append(got, buf[:n]...)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expect %d bytes, but got %d", len(data), len(got))
+	}
+	if reason := waitCloseReason(t, closed); reason != nil {
+		t.Fatalf("expect nil close reason, but got %v", reason)
+	}
+}
+
+func TestConnection_WriteCloseEmpty(t *testing.T) {
+	c, peer, loop, closed := newRunningConnection(t, &DefaultProtocol{})
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	if err := c.WriteClose(nil); err != nil {
+		t.Fatal(err)
+	}
+	if reason := waitCloseReason(t, closed); reason != nil {
+		t.Fatalf("expect nil close reason, but got %v", reason)
+	}
+	if err := c.WriteClose([]byte("late")); err == nil {
+		t.Fatal("WriteClose on a closed connection should fail")
+	}
+}
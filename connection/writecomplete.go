@@ -0,0 +1,26 @@
+package connection
+
+// WriteCompleteCallBack：可选的回调接口，handleWrite 将积压的 outBuffer 写空时调用，
+// writtenBytes 为 outBuffer 从非空到写空期间写出的字节数，可以据此实现应用层流控：积压时停止生产，回调后恢复。
+// 数据直接写入 socket 而没有进入 outBuffer 时不会回调；WriteClose 写完后关闭连接时也不会回调。
+//
+// 与 OnMessage 的顺序：两者都在事件循环中串行调用，不会并发。outBuffer 非空时连接只关注可写事件，
+// 暂停读取对端的数据，因此积压期间不会回调 OnMessage，写空后先回调 OnWriteComplete 并恢复读取，之后读到的数据才会回调 OnMessage
+type WriteCompleteCallBack interface {
+	OnWriteComplete(c *Connection, writtenBytes int)
+}
+
+// notifyWriteComplete：outBuffer 写空时回调 OnWriteComplete，并重新开始统计写出的字节数
+func (c *Connection) notifyWriteComplete() {
+	if c.outBuffer.Length() != 0 || c.drainWritten == 0 {
+		return
+	}
+	n := c.drainWritten
+	c.drainWritten = 0
+	if !c.connected.Get() {
+		return
+	}
+	if h, ok := c.callBack.(WriteCompleteCallBack); ok {
+		h.OnWriteComplete(c, n)
+	}
+}
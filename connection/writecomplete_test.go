@@ -0,0 +1,77 @@
+package connection
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+type writeCompleteCallBack struct {
+	emptyCallBack
+	written chan int
+}
+
+func (w *writeCompleteCallBack) OnWriteComplete(c *Connection, writtenBytes int) {
+	if c.outBuffer.Length() != 0 {
+		writtenBytes = -1
+	}
+	w.written <- writtenBytes
+}
+
+func TestConnection_OnWriteComplete(t *testing.T) {
+	cb := &writeCompleteCallBack{written: make(chan int, 4)}
+	c, peer, loop := newRunningConnectionWith(t, &DefaultProtocol{}, cb)
+	defer unix.Close(peer)
+	defer loop.Stop()
+
+	// 数据直接写入 socket 时不回调
+	if err := c.Send([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(fdReader(peer), make([]byte, 5)); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-cb.written:
+		t.Fatalf("OnWriteComplete should not be called without backlog, got %d", n)
+	case <-time.After(time.Millisecond * 20):
+	}
+
+	// 对端暂不读取，超出内核缓冲区的数据积压在 outBuffer 中
+	data := bytes.Repeat([]byte{'x'}, 4*1024*1024)
+	if err := c.Send(data); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second * 3)
+	for outBufferLength(c) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expect backlog in outBuffer")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case n := <-cb.written:
+		t.Fatalf("OnWriteComplete should not be called before outBuffer drained, got %d", n)
+	default:
+	}
+
+	if _, err := io.ReadFull(fdReader(peer), make([]byte, len(data))); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case n := <-cb.written:
+		if n <= 0 || n > len(data) {
+			t.Fatalf("expect written bytes in (0, %d] with empty outBuffer, but got %d", len(data), n)
+		}
+	case <-time.After(time.Second * 3):
+		t.Fatal("OnWriteComplete should be called after outBuffer drained")
+	}
+	select {
+	case n := <-cb.written:
+		t.Fatalf("OnWriteComplete should be called once, got another %d", n)
+	case <-time.After(time.Millisecond * 20):
+	}
+}
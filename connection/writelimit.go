@@ -0,0 +1,52 @@
+package connection
+
+// BufferFullPolicy：outBuffer 达到 MaxWriteBufferSize 设置的上限时的处理策略
+type BufferFullPolicy int
+
+const (
+	// DropNewest：丢弃新的数据，已在 outBuffer 中的数据照常发送
+	DropNewest BufferFullPolicy = iota
+	// CloseConn：以 ErrWriteBufferFull 为原因关闭连接
+	CloseConn
+	// Block：Send 直接返回 ErrWriteBufferFull，由调用方决定稍后重试或放弃；
+	// OnMessage 的返回值等在事件循环中产生的数据无法拒绝，按 DropNewest 处理
+	Block
+)
+
+// BufferFullCallBack：可选的回调接口，数据因 outBuffer 达到上限被丢弃或连接因此被关闭之前调用
+type BufferFullCallBack interface {
+	OnBufferFull(c *Connection)
+}
+
+// writeLimited：向已有积压的 outBuffer 追加 n 字节是否会超过上限
+func (c *Connection) writeLimited(n int) bool {
+	return c.maxWriteBufferSize > 0 && c.outBuffer.Length()+n > c.maxWriteBufferSize
+}
+
+// handleBufferFull：outBuffer 达到上限时回调 OnBufferFull 并按策略处理
+func (c *Connection) handleBufferFull() writeResult {
+	if h, ok := c.callBack.(BufferFullCallBack); ok {
+		h.OnBufferFull(c)
+	}
+	if c.bufferFullPolicy == CloseConn {
+		c.closeWithReason(c.fd, ErrWriteBufferFull)
+		return writeFailed
+	}
+	return writeDropped
+}
+
+// sendRefused：Block 策略下 Send 是否应直接拒绝 n 字节的数据
+func (c *Connection) sendRefused(n int) bool {
+	if c.maxWriteBufferSize <= 0 || c.bufferFullPolicy != Block {
+		return false
+	}
+	buffered := c.outBuffered.Get()
+	return buffered > 0 && buffered+int64(n) > int64(c.maxWriteBufferSize)
+}
+
+// syncOutBuffered：记录 outBuffer 中的数据长度，供其他 goroutine 中的 Send 判断是否拒绝
+func (c *Connection) syncOutBuffered() {
+	if c.maxWriteBufferSize > 0 {
+		_ = c.outBuffered.Swap(int64(c.outBuffer.Length()))
+	}
+}
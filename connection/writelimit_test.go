@@ -0,0 +1,102 @@
+package connection
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/tool/sync/atomic"
+	"golang.org/x/sys/unix"
+)
+
+// bufferFullCallBack：统计 OnBufferFull 的调用次数
+type bufferFullCallBack struct {
+	closeCallBack
+	full atomic.Int64
+}
+
+func (b *bufferFullCallBack) OnBufferFull(c *Connection) {
+	b.full.Add(1)
+}
+
+// newStalledConnection：对端从不读取的连接
+func newStalledConnection(t *testing.T, policy BufferFullPolicy) (*Connection, int, func(), *bufferFullCallBack) {
+	cb := &bufferFullCallBack{closeCallBack: closeCallBack{closed: make(chan error, 1)}}
+	c, peer, loop := newRunningConnectionWith(t, &DefaultProtocol{}, cb, MaxWriteBufferSize(64*1024, policy))
+	return c, peer, func() {
+		_ = unix.Close(peer)
+		_ = loop.Stop()
+	}, cb
+}
+
+// outBufferLength：在事件循环中获取 outBuffer 的长度
+func outBufferLength(c *Connection) int {
+	ch := make(chan int, 1)
+	c.loop.QueueInLoop(func() { ch <- c.outBuffer.Length() })
+	return <-ch
+}
+
+func TestConnection_WriteBufferDropNewest(t *testing.T) {
+	c, _, cleanup, cb := newStalledConnection(t, DropNewest)
+	defer cleanup()
+
+	chunk := make([]byte, 16*1024)
+	for i := 0; i < 200; i++ {
+		if err := c.Send(chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := outBufferLength(c); n > 64*1024 {
+		t.Fatalf("outBuffer should be bounded, but got %d", n)
+	}
+	if cb.full.Get() == 0 {
+		t.Fatal("OnBufferFull should be called")
+	}
+	if !c.Connected() {
+		t.Fatal("connection should stay open")
+	}
+}
+
+func TestConnection_WriteBufferCloseConn(t *testing.T) {
+	c, _, cleanup, cb := newStalledConnection(t, CloseConn)
+	defer cleanup()
+
+	chunk := make([]byte, 16*1024)
+	for i := 0; i < 200 && c.Connected(); i++ {
+		_ = c.Send(chunk)
+	}
+	if reason := waitCloseReason(t, cb.closed); !errors.Is(reason, ErrWriteBufferFull) {
+		t.Fatalf("expect ErrWriteBufferFull, but got %v", reason)
+	}
+	if cb.full.Get() != 1 {
+		t.Fatalf("expect OnBufferFull once, but got %d", cb.full.Get())
+	}
+}
+
+func TestConnection_WriteBufferBlock(t *testing.T) {
+	c, _, cleanup, _ := newStalledConnection(t, Block)
+	defer cleanup()
+
+	chunk := make([]byte, 16*1024)
+	deadline := time.Now().Add(time.Second * 3)
+	for {
+		err := c.Send(chunk)
+		if errors.Is(err, ErrWriteBufferFull) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Send should be refused once the write buffer is full")
+		}
+		// 等待事件循环处理，使积压的长度对 Send 可见
+		outBufferLength(c)
+	}
+	if n := outBufferLength(c); n > 64*1024 {
+		t.Fatalf("outBuffer should be bounded, but got %d", n)
+	}
+	if !c.Connected() {
+		t.Fatal("connection should stay open")
+	}
+}
@@ -0,0 +1,12 @@
+package fastnet
+
+// Drain：进入排空状态，HealthCheck 设置的健康检查开始返回 503，负载均衡器据此将新流量转移到其他实例。
+// 已有连接及新建立的连接照常处理，等连接数降下来后再调用 Stop 即可平滑下线，可以在任意 goroutine 中调用
+func (s *Server) Drain() {
+	s.draining.Set(true)
+}
+
+// Draining：是否处于排空状态
+func (s *Server) Draining() bool {
+	return s.draining.Get()
+}
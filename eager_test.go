@@ -0,0 +1,119 @@
+package fastnet
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+)
+
+// orderRecorder：回显，并检查每个连接的 OnConnect 先于 OnMessage
+type orderRecorder struct {
+	violations chan string
+}
+
+func (s *orderRecorder) OnConnect(c *connection.Connection) {
+	c.SetContext(true)
+}
+
+func (s *orderRecorder) OnMessage(c *connection.Connection, ctx interface{}, data []byte) (out []byte) {
+	if connected, _ := c.Context().(bool); !connected {
+		s.violations <- c.PeerAddr()
+	}
+	return data
+}
+
+func (s *orderRecorder) OnClose(c *connection.Connection) {}
+
+func TestEagerRead(t *testing.T) {
+	handler := &orderRecorder{violations: make(chan string, 100)}
+	s, err := NewServer(handler,
+		Address("127.0.0.1:0"),
+		NumLoops(2),
+		EagerRead())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	// 建立连接后立即发送数据
+	for i := 0; i < 50; i++ {
+		conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectEcho(t, conn, "first request")
+		_ = conn.Close()
+	}
+	select {
+	case peer := <-handler.violations:
+		t.Fatalf("OnMessage called before OnConnect for %s", peer)
+	default:
+	}
+}
+
+// TestOnConnectBeforeRead：未开启 EagerRead 时 OnConnect 同样在所属事件循环中先于 OnMessage 回调
+func TestOnConnectBeforeRead(t *testing.T) {
+	handler := &orderRecorder{violations: make(chan string, 100)}
+	s, err := NewServer(handler,
+		Address("127.0.0.1:0"),
+		NumLoops(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	for i := 0; i < 50; i++ {
+		conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectEcho(t, conn, "first request")
+		_ = conn.Close()
+	}
+	select {
+	case peer := <-handler.violations:
+		t.Fatalf("OnMessage called before OnConnect for %s", peer)
+	default:
+	}
+}
+
+func benchmarkConnectSend(b *testing.B, opts ...Option) {
+	s, err := NewServer(&orderRecorder{violations: make(chan string, b.N+1)},
+		append([]Option{Address("127.0.0.1:0"), NumLoops(1)}, opts...)...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+	addr := s.Addr()
+
+	buf := make([]byte, 1)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := conn.Write([]byte("x")); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := conn.Read(buf); err != nil {
+			b.Fatal(err)
+		}
+		_ = conn.Close()
+	}
+}
+
+// BenchmarkConnectSend：建立连接后立即发送一个字节并等待回显的延迟
+func BenchmarkConnectSend(b *testing.B) {
+	b.Run("Default", func(b *testing.B) {
+		benchmarkConnectSend(b)
+	})
+	b.Run("EagerRead", func(b *testing.B) {
+		benchmarkConnectSend(b, EagerRead())
+	})
+}
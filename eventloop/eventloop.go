@@ -22,9 +22,19 @@ type EventLoop struct {
 	packet  []byte 					// 临时缓冲区
 
 	eventHandling atomic.Bool 		// eventHandling 表明事件是否正在处理
+	stopped       atomic.Bool 		// 事件循环是否已经停止
+	done          chan struct{}		// Stop 时关闭，之后尚未执行的任务不会再执行
+	stopOnce      sync.Once
+	connections   atomic.Int64		// 属于该事件循环且尚未关闭的连接数
 
 	pendingFunc []func()          	// 添加 EventLoop 待执行函数到 pendingFunc 中，是一个函数切片
 	mu          spinlock.SpinLock 	// 自旋锁
+	queue       queueLimit			// TryQueueInLoop 的任务数上限
+
+	prioritized bool				// 是否按优先级处理就绪事件
+	ready       []readyEvent		// 按优先级处理时暂存的一批就绪事件
+
+	sched *Scheduler				// 接管该事件循环的确定性调度器，仅用于测试
 }
 
 // New：创建一个 EventLoop
@@ -37,6 +47,7 @@ func New() (*EventLoop, error) {
 	return &EventLoop{
 		poll:   p,
 		packet: make([]byte, 0xFFFF),
+		done:   make(chan struct{}),
 	}, nil
 }
 
@@ -71,11 +82,26 @@ func (l *EventLoop) EnableReadWrite(fd int) error {
 	return l.poll.EnableReadWrite(fd)
 }
 
+// EnableWrite：仅使能可写事件
+func (l *EventLoop) EnableWrite(fd int) error {
+	return l.poll.EnableWrite(fd)
+}
+
+// DisableReadWrite：取消可读可写事件
+func (l *EventLoop) DisableReadWrite(fd int) error {
+	return l.poll.DisableReadWrite(fd)
+}
+
 // EnableRead：使能可写事件
 func (l *EventLoop) EnableRead(fd int) error {
 	return l.poll.EnableRead(fd)
 }
 
+// SetSpinBeforeBlock：设置阻塞等待事件前非阻塞轮询的次数，需要在 RunLoop 之前调用
+func (l *EventLoop) SetSpinBeforeBlock(n int) {
+	l.poll.SetSpinBeforeBlock(n)
+}
+
 // RunLoop：启动事件循环
 func (l *EventLoop) RunLoop() {
 	l.poll.Poll(l.handlerEvent)
@@ -83,6 +109,10 @@ func (l *EventLoop) RunLoop() {
 
 // Stop：关闭事件循环
 func (l *EventLoop) Stop() error {
+	l.stopped.Set(true)
+	l.stopOnce.Do(func() { close(l.done) })
+	// 唤醒阻塞在 TryQueueInLoop 中的生产者
+	l.notifyDrained()
 	// sync.map 自身提供了Range方法，通过回调的方式遍历 sync.map
 	l.sockets.Range(func(key, value interface{}) bool {
 		// 这里进行了一次接口类型判断，判断 value 是否为 Socket 接口类型，并得到匹配之后的 s
@@ -97,10 +127,32 @@ func (l *EventLoop) Stop() error {
 		}
 		return true
 	})
+	// 被调度器接管的事件循环没有运行 Poll，直接释放
+	if l.sched != nil {
+		return l.poll.Release()
+	}
 	// 最后并关闭 poll，返回其成功与否标志位
 	return l.poll.Close()
 }
 
+// RangeSockets：遍历事件循环中的所有 Socket，f 返回 false 时停止遍历
+func (l *EventLoop) RangeSockets(f func(fd int, s Socket) bool) {
+	l.sockets.Range(func(key, value interface{}) bool {
+		return f(key.(int), value.(Socket))
+	})
+}
+
+// Done：返回一个在 Stop 时关闭的 channel。Stop 之后尚未执行的任务不会再执行，
+// 投递任务后等待其结果的调用者需要同时等待 Done，避免事件循环停止后永远阻塞
+func (l *EventLoop) Done() <-chan struct{} {
+	return l.done
+}
+
+// Stopped：事件循环是否已经调用过 Stop
+func (l *EventLoop) Stopped() bool {
+	return l.stopped.Get()
+}
+
 // QueueInLoop：添加 func 到事件循环中执行
 func (l *EventLoop) QueueInLoop(f func()) {
 	l.mu.Lock()
@@ -108,7 +160,13 @@ func (l *EventLoop) QueueInLoop(f func()) {
 	l.pendingFunc = append(l.pendingFunc, f)
 	l.mu.Unlock()
 
-	if !l.eventHandling.Get() {
+	l.wakeForPending()
+}
+
+// wakeForPending：加入任务后唤醒事件循环
+func (l *EventLoop) wakeForPending() {
+	// 被调度器接管时由调度器在下一步中发现该任务，无需唤醒
+	if !l.eventHandling.Get() && l.sched == nil {
 		// 进行唤醒，表示有读写事件的到来
 		if err := l.poll.Wake(); err != nil {
 			log.Error("QueueInLoop Wake loop, ", err)
@@ -116,8 +174,31 @@ func (l *EventLoop) QueueInLoop(f func()) {
 	}
 }
 
+// AddConnections：内部使用，由 connection 包在连接建立及关闭时更新连接数
+func (l *EventLoop) AddConnections(delta int64) {
+	l.connections.Add(delta)
+}
+
+// Connections：属于该事件循环且尚未关闭的连接数，可以在任意 goroutine 中调用
+func (l *EventLoop) Connections() int64 {
+	return l.connections.Get()
+}
+
+// QueueLength：等待在事件循环中执行的任务数，可以在任意 goroutine 中调用
+func (l *EventLoop) QueueLength() int {
+	l.mu.Lock()
+	n := len(l.pendingFunc)
+	l.mu.Unlock()
+	return n
+}
+
 // handlerEvent：进行事件处理
 func (l *EventLoop) handlerEvent(fd int, events poller.Event) {
+	// 按优先级处理时先暂存，整批事件到齐后由 handleReady 排序处理
+	if l.prioritized && fd != -1 {
+		l.ready = append(l.ready, readyEvent{fd: fd, events: events})
+		return
+	}
 	// 当前状态设置为处理中
 	l.eventHandling.Set(true)
 
@@ -145,6 +226,7 @@ func (l *EventLoop) doPendingFunc() {
 	l.pendingFunc = nil
 	// 解锁
 	l.mu.Unlock()
+	l.notifyDrained()
 	// 获取待处理方法的长度并一一进行调用
 	length := len(pf)
 	for i := 0; i < length; i++ {
@@ -29,3 +29,22 @@ func TestEventLoop_RunLoop(t *testing.T) {
 
 	el.RunLoop()
 }
+
+func TestEventLoop_Done(t *testing.T) {
+	el, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-el.Done():
+		t.Fatal("Done should not be closed before Stop")
+	default:
+	}
+	_ = el.Stop()
+	_ = el.Stop()
+	select {
+	case <-el.Done():
+	default:
+		t.Fatal("Done should be closed after Stop")
+	}
+}
@@ -0,0 +1,56 @@
+package eventloop
+
+import (
+	"sort"
+
+	"github.com/Dongxiem/fastnet/poller"
+)
+
+// Prioritized：可选接口，实现该接口的 Socket 在开启 EnablePriorities 的事件循环中按 Priority 从高到低处理
+type Prioritized interface {
+	Priority() int
+}
+
+// readyEvent：一个暂存的就绪事件
+type readyEvent struct {
+	fd       int
+	events   poller.Event
+	priority int
+	socket   Socket
+}
+
+// EnablePriorities：按 Socket 的优先级处理每批就绪事件，需要在 RunLoop 之前调用。
+// 每次 epoll_wait 返回的一批事件中，优先级高的 Socket 先处理，优先级相同的保持内核返回的顺序。
+// 排序只发生在同一批事件内部，低优先级的 Socket 在每批中仍然会被处理，不会被饿死，
+// 但高优先级的 Socket 处理耗时越长，同一批中低优先级的 Socket 等待越久
+func (l *EventLoop) EnablePriorities() {
+	l.prioritized = true
+	l.poll.SetBatchDone(l.handleReady)
+}
+
+// handleReady：按优先级处理暂存的一批就绪事件
+func (l *EventLoop) handleReady() {
+	l.eventHandling.Set(true)
+	ready := l.ready
+	for i := range ready {
+		if s, ok := l.sockets.Load(ready[i].fd); ok {
+			ready[i].socket = s.(Socket)
+			if p, ok := s.(Prioritized); ok {
+				ready[i].priority = p.Priority()
+			}
+		}
+	}
+	sort.SliceStable(ready, func(i, j int) bool {
+		return ready[i].priority > ready[j].priority
+	})
+	for i := range ready {
+		// 同一批中前面的事件可能已经关闭了该 Socket
+		if s, ok := l.sockets.Load(ready[i].fd); ok && s == ready[i].socket {
+			ready[i].socket.HandleEvent(ready[i].fd, ready[i].events)
+		}
+		ready[i] = readyEvent{}
+	}
+	l.ready = ready[:0]
+	l.eventHandling.Set(false)
+	l.doPendingFunc()
+}
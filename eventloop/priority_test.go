@@ -0,0 +1,97 @@
+package eventloop
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/poller"
+	"golang.org/x/sys/unix"
+)
+
+// prioritySocket：读出数据并记录处理顺序
+type prioritySocket struct {
+	priority int
+	mu       *sync.Mutex
+	order    *[]int
+	done     *sync.WaitGroup
+}
+
+func (s *prioritySocket) HandleEvent(fd int, events poller.Event) {
+	buf := make([]byte, 16)
+	if n, _ := unix.Read(fd, buf); n > 0 {
+		s.mu.Lock()
+		*s.order = append(*s.order, s.priority)
+		s.mu.Unlock()
+		s.done.Done()
+	}
+}
+
+func (s *prioritySocket) Close() error {
+	return nil
+}
+
+func (s *prioritySocket) Priority() int {
+	return s.priority
+}
+
+func TestEventLoop_Priorities(t *testing.T) {
+	el, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	el.EnablePriorities()
+
+	const n = 40
+	var (
+		mu    sync.Mutex
+		order []int
+		done  sync.WaitGroup
+	)
+	done.Add(n)
+	for i := 0; i < n; i++ {
+		fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer unix.Close(fds[0])
+		defer unix.Close(fds[1])
+		// 每 10 个连接中有 1 个高优先级连接，并且在内核就绪列表中排在后面
+		priority := 0
+		if i%10 == 9 {
+			priority = 10
+		}
+		if err := el.AddSocketAndEnableRead(fds[0], &prioritySocket{priority: priority, mu: &mu, order: &order, done: &done}); err != nil {
+			t.Fatal(err)
+		}
+		// 所有连接在事件循环启动前都已可读，会在同一批事件中返回
+		if _, err := unix.Write(fds[1], []byte("x")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	go el.RunLoop()
+	defer func() { _ = el.Stop() }()
+	waited := make(chan struct{})
+	go func() {
+		done.Wait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(time.Second * 3):
+		t.Fatal("not all sockets were served")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i, p := range order {
+		want := 0
+		if i < n/10 {
+			want = 10
+		}
+		if p != want {
+			t.Fatalf("high priority sockets should be served first, but got order %v", order)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package eventloop
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrQueueFull：待执行的任务数达到 SetMaxQueue 设置的上限，TryQueueInLoop 拒绝了新的任务
+var ErrQueueFull = errors.New("eventloop: task queue full")
+
+// queueLimit：TryQueueInLoop 的任务数上限及阻塞等待所用的条件变量
+type queueLimit struct {
+	max   int  // 待执行的任务数上限，0 表示不限制
+	block bool // 达到上限时阻塞等待而不是返回 ErrQueueFull
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	drains uint64 // 事件循环取走任务的次数，阻塞等待的生产者据此判断是否有了空位
+}
+
+// SetMaxQueue：设置 TryQueueInLoop 的任务数上限，需要在 RunLoop 之前调用，n <= 0 表示不限制（默认）。
+// 达到上限时 TryQueueInLoop 返回 ErrQueueFull；block 为 true 时改为阻塞到事件循环取走任务或事件循环停止，
+// 阻塞模式只适用于在事件循环之外发送的生产者，在事件循环 goroutine 中（如 OnMessage 中 Send）阻塞会导致死锁。
+// QueueInLoop 不受上限限制，框架内部的关闭、唤醒等任务不会被拒绝
+func (l *EventLoop) SetMaxQueue(n int, block bool) {
+	if n < 0 {
+		n = 0
+	}
+	l.queue.max = n
+	l.queue.block = block
+	l.queue.cond = sync.NewCond(&l.queue.mu)
+}
+
+// TryQueueInLoop：与 QueueInLoop 相同，但待执行的任务数达到 SetMaxQueue 设置的上限时按设置返回 ErrQueueFull 或阻塞等待，
+// 生产者可以据此降低速度，避免任务队列无限增长。可以在任意 goroutine 中调用
+func (l *EventLoop) TryQueueInLoop(f func()) error {
+	if l.queue.max <= 0 {
+		l.QueueInLoop(f)
+		return nil
+	}
+	for {
+		l.queue.mu.Lock()
+		drains := l.queue.drains
+		l.queue.mu.Unlock()
+
+		if l.stopped.Get() {
+			l.QueueInLoop(f)
+			return nil
+		}
+		if l.queueIfBelow(l.queue.max, f) {
+			return nil
+		}
+		if !l.queue.block {
+			return ErrQueueFull
+		}
+		// 在检查队列长度之后有取走任务时不再等待，避免错过唤醒
+		l.queue.mu.Lock()
+		for l.queue.drains == drains && !l.stopped.Get() {
+			l.queue.cond.Wait()
+		}
+		l.queue.mu.Unlock()
+	}
+}
+
+// queueIfBelow：待执行的任务数小于 max 时加入任务并返回 true，检查与加入在同一临界区中完成，
+// 并发的生产者不会同时通过检查而超出上限
+func (l *EventLoop) queueIfBelow(max int, f func()) bool {
+	l.mu.Lock()
+	if len(l.pendingFunc) >= max {
+		l.mu.Unlock()
+		return false
+	}
+	l.pendingFunc = append(l.pendingFunc, f)
+	l.mu.Unlock()
+
+	l.wakeForPending()
+	return true
+}
+
+// notifyDrained：事件循环取走任务后唤醒阻塞在 TryQueueInLoop 中的生产者
+func (l *EventLoop) notifyDrained() {
+	if l.queue.max <= 0 || !l.queue.block {
+		return
+	}
+	l.queue.mu.Lock()
+	l.queue.drains++
+	l.queue.cond.Broadcast()
+	l.queue.mu.Unlock()
+}
@@ -0,0 +1,119 @@
+package eventloop
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestEventLoop_MaxQueue(t *testing.T) {
+	el, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer el.Stop()
+	el.SetMaxQueue(3, false)
+
+	// 事件循环没有运行，任务不会被取走
+	for i := 0; i < 3; i++ {
+		if err := el.TryQueueInLoop(func() {}); err != nil {
+			t.Fatalf("expect task %d to be queued, but got %v", i, err)
+		}
+	}
+	if err := el.TryQueueInLoop(func() {}); err != ErrQueueFull {
+		t.Fatalf("expect ErrQueueFull, but got %v", err)
+	}
+	// QueueInLoop 不受上限限制
+	el.QueueInLoop(func() {})
+	if n := el.QueueLength(); n != 4 {
+		t.Fatalf("expect 4 queued tasks, but got %d", n)
+	}
+}
+
+func TestEventLoop_MaxQueueConcurrent(t *testing.T) {
+	el, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer el.Stop()
+	el.SetMaxQueue(10, false)
+
+	// 并发的生产者不能同时通过检查而超出上限
+	var (
+		wg     sync.WaitGroup
+		queued int32
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if el.TryQueueInLoop(func() {}) == nil {
+				atomic.AddInt32(&queued, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := el.QueueLength(); n != 10 || atomic.LoadInt32(&queued) != 10 {
+		t.Fatalf("expect exactly 10 queued tasks, but got %d (%d accepted)", n, queued)
+	}
+}
+
+func TestEventLoop_MaxQueueBlocking(t *testing.T) {
+	el, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	el.SetMaxQueue(1, true)
+	_ = el.TryQueueInLoop(func() {})
+
+	ran := make(chan struct{})
+	queued := make(chan error, 1)
+	go func() {
+		queued <- el.TryQueueInLoop(func() { close(ran) })
+	}()
+	select {
+	case err := <-queued:
+		t.Fatalf("expect TryQueueInLoop to block on a full queue, but got %v", err)
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	// 事件循环取走任务后不再阻塞
+	go el.RunLoop()
+	defer el.Stop()
+	select {
+	case err := <-queued:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second * 3):
+		t.Fatal("expect TryQueueInLoop to return after the loop drains the queue")
+	}
+	select {
+	case <-ran:
+	case <-time.After(time.Second * 3):
+		t.Fatal("expect the task to run")
+	}
+}
+
+func TestEventLoop_MaxQueueStop(t *testing.T) {
+	el, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	el.SetMaxQueue(1, true)
+	_ = el.TryQueueInLoop(func() {})
+
+	queued := make(chan error, 1)
+	go func() {
+		queued <- el.TryQueueInLoop(func() {})
+	}()
+	time.Sleep(time.Millisecond * 20)
+	// 事件循环停止后不再阻塞
+	_ = el.Stop()
+	select {
+	case <-queued:
+	case <-time.After(time.Second * 3):
+		t.Fatal("expect TryQueueInLoop to return after Stop")
+	}
+}
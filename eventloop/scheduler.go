@@ -0,0 +1,123 @@
+package eventloop
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"github.com/Dongxiem/fastnet/poller"
+)
+
+// ErrAttached：事件循环已经被调度器接管
+var ErrAttached = errors.New("eventloop: already attached to a scheduler")
+
+// Scheduler：测试用的确定性调度器。接管的事件循环不再各自运行 RunLoop，而是由调用 Step 的 goroutine
+// 逐步驱动：每一步收集所有事件循环的就绪 fd 与排队的 QueueInLoop 任务，由种子决定的随机数选出其中一个执行。
+// 新连接的 Accept、连接的读写以及通过 QueueInLoop 投递的定时任务都以这种方式串行执行，
+// 因此多个事件循环之间的交错顺序只取决于种子，偶现的跨事件循环竞争可以用同一个种子稳定重现。
+// 同一事件循环的 QueueInLoop 任务仍按投递顺序执行，只有它们与其他事件之间的先后由种子决定
+type Scheduler struct {
+	rnd   *rand.Rand
+	loops []*EventLoop
+	trace []Step
+	ready []Step // 每一步的候选，复用以减少分配
+}
+
+// Step：调度器执行的一步
+type Step struct {
+	Loop   int          // 事件循环在 Attach 顺序中的下标
+	Fd     int          // 处理的 fd，为 -1 时表示执行一个 QueueInLoop 任务
+	Events poller.Event // fd 的就绪事件
+}
+
+// String：步骤的可读形式，如 loop0:task、loop1:fd7
+func (s Step) String() string {
+	if s.Fd == -1 {
+		return fmt.Sprintf("loop%d:task", s.Loop)
+	}
+	return fmt.Sprintf("loop%d:fd%d", s.Loop, s.Fd)
+}
+
+// NewScheduler：创建使用 seed 的确定性调度器
+func NewScheduler(seed int64) *Scheduler {
+	return &Scheduler{rnd: rand.New(rand.NewSource(seed))}
+}
+
+// Attach：由调度器接管事件循环，返回其下标。需要在投递任何任务之前调用，接管后不能再调用 RunLoop
+func (s *Scheduler) Attach(l *EventLoop) (int, error) {
+	if l.sched != nil {
+		return 0, ErrAttached
+	}
+	l.sched = s
+	s.loops = append(s.loops, l)
+	return len(s.loops) - 1, nil
+}
+
+// Step：执行一步，没有任何就绪事件或待执行的任务时返回 false
+func (s *Scheduler) Step() bool {
+	s.ready = s.ready[:0]
+	for i, l := range s.loops {
+		if l.Stopped() {
+			continue
+		}
+		loop := i
+		if err := l.poll.PollNow(func(fd int, events poller.Event) {
+			s.ready = append(s.ready, Step{Loop: loop, Fd: fd, Events: events})
+		}); err != nil {
+			continue
+		}
+		if l.hasPendingFunc() {
+			s.ready = append(s.ready, Step{Loop: loop, Fd: -1})
+		}
+	}
+	if len(s.ready) == 0 {
+		return false
+	}
+
+	step := s.ready[s.rnd.Intn(len(s.ready))]
+	s.trace = append(s.trace, step)
+	l := s.loops[step.Loop]
+	if step.Fd == -1 {
+		l.runPendingFunc()
+		return true
+	}
+	l.eventHandling.Set(true)
+	if sock, ok := l.sockets.Load(step.Fd); ok {
+		sock.(Socket).HandleEvent(step.Fd, step.Events)
+	}
+	l.eventHandling.Set(false)
+	return true
+}
+
+// Run：执行直到没有可执行的步骤或达到 max 步，返回执行的步数
+func (s *Scheduler) Run(max int) int {
+	n := 0
+	for n < max && s.Step() {
+		n++
+	}
+	return n
+}
+
+// Trace：已执行的全部步骤，同一个种子在相同的输入下得到相同的结果
+func (s *Scheduler) Trace() []Step {
+	return s.trace
+}
+
+// hasPendingFunc：是否有待执行的任务
+func (l *EventLoop) hasPendingFunc() bool {
+	l.mu.Lock()
+	n := len(l.pendingFunc)
+	l.mu.Unlock()
+	return n > 0
+}
+
+// runPendingFunc：执行最早投递的一个任务
+func (l *EventLoop) runPendingFunc() {
+	l.mu.Lock()
+	f := l.pendingFunc[0]
+	l.pendingFunc[0] = nil
+	l.pendingFunc = l.pendingFunc[1:]
+	l.mu.Unlock()
+	l.notifyDrained()
+	f()
+}
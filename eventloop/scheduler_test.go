@@ -0,0 +1,116 @@
+package eventloop
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Dongxiem/fastnet/poller"
+	"golang.org/x/sys/unix"
+)
+
+// transfer：在两个事件循环中各自对同一个计数器做“读-改-写”，读与写拆成两个任务，
+// 两个事件循环的读都先于写执行时会丢失一次更新。返回计数器的最终值与调度轨迹
+func transfer(t *testing.T, seed int64) (int, []Step) {
+	s := NewScheduler(seed)
+	balance := 0
+	for i := 0; i < 2; i++ {
+		l, err := New()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = l.Stop() }()
+		if _, err := s.Attach(l); err != nil {
+			t.Fatal(err)
+		}
+		l.QueueInLoop(func() {
+			read := balance
+			l.QueueInLoop(func() {
+				balance = read + 1
+			})
+		})
+	}
+	s.Run(100)
+	return balance, s.Trace()
+}
+
+func TestScheduler_ReplayInterleaving(t *testing.T) {
+	lost, ok := int64(-1), int64(-1)
+	for seed := int64(0); seed < 100 && (lost < 0 || ok < 0); seed++ {
+		if balance, _ := transfer(t, seed); balance == 1 && lost < 0 {
+			lost = seed
+		} else if balance == 2 && ok < 0 {
+			ok = seed
+		}
+	}
+	if lost < 0 || ok < 0 {
+		t.Fatalf("expect seeds to produce both interleavings, but got lost=%d ok=%d", lost, ok)
+	}
+
+	// 同一个种子重现同一种交错
+	balance, trace := transfer(t, lost)
+	for i := 0; i < 10; i++ {
+		b, tr := transfer(t, lost)
+		if b != balance || !reflect.DeepEqual(tr, trace) {
+			t.Fatalf("seed %d: expect %d %v, but got %d %v", lost, balance, trace, b, tr)
+		}
+	}
+	if balance != 1 {
+		t.Fatalf("seed %d should reproduce the lost update, but got %d", lost, balance)
+	}
+}
+
+// pairSocket：读取并记录收到的数据
+type pairSocket struct {
+	received []byte
+}
+
+func (p *pairSocket) HandleEvent(fd int, events poller.Event) {
+	buf := make([]byte, 64)
+	n, _ := unix.Read(fd, buf)
+	if n > 0 {
+		p.received = append(p.received, buf[:n]...)
+	}
+}
+
+func (p *pairSocket) Close() error { return nil }
+
+func TestScheduler_Events(t *testing.T) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fds[0])
+	defer unix.Close(fds[1])
+
+	l, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Stop() }()
+	s := NewScheduler(1)
+	if _, err := s.Attach(l); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Attach(l); err != ErrAttached {
+		t.Fatalf("expect ErrAttached, but got %v", err)
+	}
+
+	sock := &pairSocket{}
+	if err := l.AddSocketAndEnableRead(fds[0], sock); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unix.Write(fds[1], []byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	l.QueueInLoop(func() {})
+
+	if n := s.Run(10); n != 2 {
+		t.Fatalf("expect 2 steps, but got %d: %v", n, s.Trace())
+	}
+	if string(sock.received) != "ping" {
+		t.Fatalf("expect ping, but got %q", sock.received)
+	}
+	if s.Step() {
+		t.Fatal("scheduler should be idle")
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+)
+
+// job：交给工作协程处理的请求
+type job struct {
+	r    *connection.Responder
+	data []byte
+}
+
+// example：OnMessage 不直接返回响应，而是将请求交给工作协程，处理完成后通过 Responder 回写
+type example struct {
+	jobs chan job
+}
+
+func (s *example) OnConnect(c *connection.Connection) {}
+
+func (s *example) OnMessage(c *connection.Connection, ctx interface{}, data []byte) (out []byte) {
+	// data 在 OnMessage 返回后会被复用，需要拷贝一份
+	s.jobs <- job{r: connection.NewResponder(c, nil), data: append([]byte{}, data...)}
+	return
+}
+
+func (s *example) OnClose(c *connection.Connection) {}
+
+// worker：模拟耗时的处理，连接在处理期间关闭时丢弃响应
+func (s *example) worker() {
+	for j := range s.jobs {
+		time.Sleep(10 * time.Millisecond)
+		if err := j.r.Respond([]byte(strings.ToUpper(string(j.data)))); err != nil {
+			log.Info("drop response:", err)
+		}
+	}
+}
+
+func main() {
+	var port int
+	var workers int
+
+	flag.IntVar(&port, "port", 1833, "server port")
+	flag.IntVar(&workers, "workers", 8, "num workers")
+	flag.Parse()
+
+	handler := &example{jobs: make(chan job, 1024)}
+	for i := 0; i < workers; i++ {
+		go handler.worker()
+	}
+
+	s, err := fastnet.NewServer(handler,
+		fastnet.Network("tcp"),
+		fastnet.Address(":"+strconv.Itoa(port)))
+	if err != nil {
+		panic(err)
+	}
+
+	s.Start()
+}
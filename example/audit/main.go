@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"strconv"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+)
+
+// jsonAuditLogger：将审计事件以 JSON 行的形式输出
+type jsonAuditLogger struct {
+	enc *json.Encoder
+}
+
+func (l *jsonAuditLogger) Audit(e fastnet.AuditEvent) {
+	if err := l.enc.Encode(e); err != nil {
+		log.Error("[audit]", err)
+	}
+}
+
+type example struct{}
+
+func (s *example) OnConnect(c *connection.Connection) {}
+func (s *example) OnMessage(c *connection.Connection, ctx interface{}, data []byte) (out []byte) {
+	return data
+}
+func (s *example) OnClose(c *connection.Connection) {}
+
+func main() {
+	var port int
+	var auditFile string
+
+	flag.IntVar(&port, "port", 1833, "server port")
+	flag.StringVar(&auditFile, "audit", "", "audit log file, default stdout")
+	flag.Parse()
+
+	out := os.Stdout
+	if auditFile != "" {
+		f, err := os.OpenFile(auditFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+		if err != nil {
+			panic(err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	s, err := fastnet.NewServer(new(example),
+		fastnet.Network("tcp"),
+		fastnet.Address(":"+strconv.Itoa(port)),
+		fastnet.AuditSink(&jsonAuditLogger{enc: json.NewEncoder(out)}))
+	if err != nil {
+		panic(err)
+	}
+
+	s.Start()
+}
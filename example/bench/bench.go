@@ -0,0 +1,373 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/tool/sync/atomic"
+)
+
+// 预置的负载场景
+const (
+	scenarioEcho      = "echo"      // 每个连接发送消息并等待回显，统计往返延迟
+	scenarioBroadcast = "broadcast" // 第一个连接发布消息，Server 转发给所有连接，统计投递延迟
+	scenarioUpload    = "upload"    // 所有连接持续上传数据，Server 只接收不回复，统计吞吐量
+)
+
+// config：一次压测的负载及 Server 配置
+type config struct {
+	Scenario string
+	Conns    int           // 客户端连接数
+	Size     int           // 每条消息的字节数，echo 及 broadcast 中前 8 字节为发送时间
+	Rate     int           // 每个连接每秒发送的消息数，0 表示不限速
+	Duration time.Duration // 压测时长
+
+	Loops       int  // fastnet.NumLoops
+	Batching    bool // fastnet.WriteBatching
+	Spin        int  // fastnet.SpinBeforeBlock
+	Eager       bool // fastnet.EagerRead
+	AcceptBatch int  // fastnet.AcceptBatch
+}
+
+// options：按配置生成 Server 的选项
+func (c *config) options() []fastnet.Option {
+	opts := []fastnet.Option{
+		fastnet.Network("tcp"),
+		fastnet.Address("127.0.0.1:0"),
+		fastnet.NumLoops(c.Loops),
+		fastnet.WriteBatching(c.Batching),
+	}
+	if c.Spin > 0 {
+		opts = append(opts, fastnet.SpinBeforeBlock(c.Spin))
+	}
+	if c.Eager {
+		opts = append(opts, fastnet.EagerRead())
+	}
+	if c.AcceptBatch > 0 {
+		opts = append(opts, fastnet.AcceptBatch(c.AcceptBatch))
+	}
+	return opts
+}
+
+func (c *config) validate() error {
+	switch c.Scenario {
+	case scenarioEcho, scenarioBroadcast, scenarioUpload:
+	default:
+		return fmt.Errorf("unknown scenario %q", c.Scenario)
+	}
+	if c.Conns <= 0 {
+		return errors.New("conns must be positive")
+	}
+	if c.Size < 8 {
+		return errors.New("size must be at least 8 bytes")
+	}
+	if c.Duration <= 0 {
+		return errors.New("duration must be positive")
+	}
+	return nil
+}
+
+// result：一次压测的结果
+type result struct {
+	Messages  int64         // 完成的消息数：echo 为往返次数，broadcast 为投递次数，upload 为 Server 收到的消息数
+	Bytes     int64         // 完成的消息字节数
+	Elapsed   time.Duration // 实际压测时长
+	Latencies []time.Duration
+	Syscalls  int64  // 进程内读写系统调用次数，包括同一进程中的客户端
+	Mallocs   uint64 // 进程内的内存分配次数，包括同一进程中的客户端
+}
+
+// percentile：延迟的 p 分位数，p 取值 (0, 100]
+func (r *result) percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	i := int(float64(len(r.Latencies))*p/100+0.5) - 1
+	if i < 0 {
+		i = 0
+	} else if i >= len(r.Latencies) {
+		i = len(r.Latencies) - 1
+	}
+	return r.Latencies[i]
+}
+
+// String：输出压测报告
+func (r *result) String() string {
+	secs := r.Elapsed.Seconds()
+	var b strings.Builder
+	fmt.Fprintf(&b, "messages:   %d (%.0f msg/s)\n", r.Messages, float64(r.Messages)/secs)
+	fmt.Fprintf(&b, "throughput: %.2f MiB/s\n", float64(r.Bytes)/secs/(1024*1024))
+	if len(r.Latencies) > 0 {
+		fmt.Fprintf(&b, "latency:    p50 %v  p90 %v  p99 %v  max %v\n",
+			r.percentile(50), r.percentile(90), r.percentile(99), r.Latencies[len(r.Latencies)-1])
+	}
+	if r.Syscalls >= 0 {
+		fmt.Fprintf(&b, "syscalls:   %.0f/s (read+write, whole process)\n", float64(r.Syscalls)/secs)
+	}
+	if r.Messages > 0 {
+		fmt.Fprintf(&b, "allocs:     %.2f/msg (whole process)\n", float64(r.Mallocs)/float64(r.Messages))
+	}
+	return b.String()
+}
+
+// benchHandler：压测使用的 Handler，按场景回显、广播或只接收
+type benchHandler struct {
+	scenario string
+	received atomic.Int64
+
+	mu      sync.Mutex
+	members map[*connection.Connection]struct{}
+}
+
+func (h *benchHandler) OnConnect(c *connection.Connection) {
+	if h.scenario != scenarioBroadcast {
+		return
+	}
+	h.mu.Lock()
+	h.members[c] = struct{}{}
+	h.mu.Unlock()
+}
+
+func (h *benchHandler) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	switch h.scenario {
+	case scenarioEcho:
+		return data
+	case scenarioBroadcast:
+		h.mu.Lock()
+		for member := range h.members {
+			_ = member.Send(data)
+		}
+		h.mu.Unlock()
+	default:
+		h.received.Add(int64(len(data)))
+	}
+	return nil
+}
+
+func (h *benchHandler) OnClose(c *connection.Connection) {
+	h.mu.Lock()
+	delete(h.members, c)
+	h.mu.Unlock()
+}
+
+// run：启动进程内的 Server，由客户端按配置施加负载，返回统计结果
+func run(cfg config) (*result, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	handler := &benchHandler{scenario: cfg.Scenario, members: make(map[*connection.Connection]struct{})}
+	s, err := fastnet.NewServer(handler, cfg.options()...)
+	if err != nil {
+		return nil, err
+	}
+	done := make(chan struct{})
+	go func() {
+		s.Start()
+		close(done)
+	}()
+	defer func() {
+		s.Stop()
+		<-done
+	}()
+
+	conns := make([]net.Conn, cfg.Conns)
+	for i := range conns {
+		conn, err := net.DialTimeout("tcp", s.Addr(), 3*time.Second)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		conns[i] = conn
+	}
+	// 广播场景等待所有连接加入后再开始
+	for start := time.Now(); cfg.Scenario == scenarioBroadcast; {
+		handler.mu.Lock()
+		n := len(handler.members)
+		handler.mu.Unlock()
+		if n == cfg.Conns {
+			break
+		}
+		if time.Since(start) > 3*time.Second {
+			return nil, fmt.Errorf("only %d of %d connections joined", n, cfg.Conns)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	mallocs := ms.Mallocs
+	syscalls := readSyscalls()
+	start := time.Now()
+	end := start.Add(cfg.Duration)
+
+	clients := make([]*client, cfg.Conns)
+	var wg sync.WaitGroup
+	for i, conn := range conns {
+		_ = conn.SetDeadline(end)
+		clients[i] = &client{conn: conn, cfg: &cfg, end: end}
+		wg.Add(1)
+		go func(c *client, publisher bool) {
+			defer wg.Done()
+			switch cfg.Scenario {
+			case scenarioEcho:
+				c.echo()
+			case scenarioBroadcast:
+				c.broadcast(publisher)
+			default:
+				c.upload()
+			}
+		}(clients[i], i == 0)
+	}
+	wg.Wait()
+
+	r := &result{Elapsed: time.Since(start), Syscalls: -1}
+	if syscalls >= 0 {
+		if now := readSyscalls(); now >= 0 {
+			r.Syscalls = now - syscalls
+		}
+	}
+	runtime.ReadMemStats(&ms)
+	r.Mallocs = ms.Mallocs - mallocs
+	if cfg.Scenario == scenarioUpload {
+		r.Bytes = handler.received.Get()
+		r.Messages = r.Bytes / int64(cfg.Size)
+	} else {
+		for _, c := range clients {
+			r.Messages += c.messages
+			r.Latencies = append(r.Latencies, c.latencies...)
+		}
+		r.Bytes = r.Messages * int64(cfg.Size)
+	}
+	sort.Slice(r.Latencies, func(i, j int) bool { return r.Latencies[i] < r.Latencies[j] })
+	return r, nil
+}
+
+// client：一个客户端连接，在 end 之前按场景收发消息
+type client struct {
+	conn      net.Conn
+	cfg       *config
+	end       time.Time
+	messages  int64
+	latencies []time.Duration
+}
+
+// pace：限速时等待下一次发送的时间，返回 false 表示压测已结束
+func (c *client) pace(next *time.Time) bool {
+	if c.cfg.Rate > 0 {
+		if d := time.Until(*next); d > 0 {
+			time.Sleep(d)
+		}
+		*next = next.Add(time.Second / time.Duration(c.cfg.Rate))
+	}
+	return time.Now().Before(c.end)
+}
+
+// stamp：在消息开头写入发送时间
+func stamp(msg []byte) {
+	binary.BigEndian.PutUint64(msg, uint64(time.Now().UnixNano()))
+}
+
+// since：消息开头记录的发送时间至今的时长
+func since(msg []byte) time.Duration {
+	return time.Since(time.Unix(0, int64(binary.BigEndian.Uint64(msg))))
+}
+
+func (c *client) echo() {
+	msg := make([]byte, c.cfg.Size)
+	buf := make([]byte, c.cfg.Size)
+	next := time.Now()
+	for c.pace(&next) {
+		stamp(msg)
+		if _, err := c.conn.Write(msg); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(c.conn, buf); err != nil {
+			return
+		}
+		c.messages++
+		c.latencies = append(c.latencies, since(buf))
+	}
+}
+
+// broadcast：publisher 负责发布消息，所有连接（包括 publisher）接收广播。
+// 不限速时 publisher 收到自己发布的上一条消息后再发布下一条
+func (c *client) broadcast(publisher bool) {
+	received := make(chan struct{}, 1)
+	if publisher {
+		go func() {
+			msg := make([]byte, c.cfg.Size)
+			next := time.Now()
+			for c.pace(&next) {
+				stamp(msg)
+				if _, err := c.conn.Write(msg); err != nil {
+					return
+				}
+				if c.cfg.Rate > 0 {
+					continue
+				}
+				select {
+				case <-received:
+				case <-time.After(time.Until(c.end)):
+					return
+				}
+			}
+		}()
+	}
+	buf := make([]byte, c.cfg.Size)
+	for {
+		if _, err := io.ReadFull(c.conn, buf); err != nil {
+			return
+		}
+		c.messages++
+		c.latencies = append(c.latencies, since(buf))
+		if publisher {
+			select {
+			case received <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (c *client) upload() {
+	msg := make([]byte, c.cfg.Size)
+	next := time.Now()
+	for c.pace(&next) {
+		if _, err := c.conn.Write(msg); err != nil {
+			return
+		}
+	}
+}
+
+// readSyscalls：从 /proc/self/io 读取进程的读写系统调用次数，不支持时返回 -1
+func readSyscalls() int64 {
+	data, err := ioutil.ReadFile("/proc/self/io")
+	if err != nil {
+		return -1
+	}
+	var total int64
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || (fields[0] != "syscr:" && fields[0] != "syscw:") {
+			continue
+		}
+		n, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return -1
+		}
+		total += n
+	}
+	return total
+}
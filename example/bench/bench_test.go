@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRun：以很小的参数运行所有预置场景，保证压测工具本身可用
+func TestRun(t *testing.T) {
+	for _, scenario := range []string{scenarioEcho, scenarioBroadcast, scenarioUpload} {
+		t.Run(scenario, func(t *testing.T) {
+			r, err := run(config{
+				Scenario: scenario,
+				Conns:    3,
+				Size:     64,
+				Duration: 200 * time.Millisecond,
+				Loops:    2,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.Messages == 0 || r.Bytes == 0 {
+				t.Fatalf("expect messages to be exchanged, but got %+v", r)
+			}
+			if scenario != scenarioUpload && len(r.Latencies) != int(r.Messages) {
+				t.Fatalf("expect %d latency samples, but got %d", r.Messages, len(r.Latencies))
+			}
+			if r.String() == "" {
+				t.Fatal("expect a report")
+			}
+		})
+	}
+}
+
+func TestRun_Rate(t *testing.T) {
+	r, err := run(config{
+		Scenario: scenarioEcho,
+		Conns:    1,
+		Size:     64,
+		Rate:     50,
+		Duration: 200 * time.Millisecond,
+		Loops:    1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每秒 50 条，200ms 内最多 11 条
+	if r.Messages == 0 || r.Messages > 11 {
+		t.Fatalf("expect rate limited messages, but got %d", r.Messages)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if _, err := run(config{Scenario: "unknown", Conns: 1, Size: 64, Duration: time.Second}); err == nil {
+		t.Fatal("expect error for unknown scenario")
+	}
+	if _, err := run(config{Scenario: scenarioEcho, Conns: 1, Size: 4, Duration: time.Second}); err == nil {
+		t.Fatal("expect error for message smaller than the timestamp")
+	}
+}
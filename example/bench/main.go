@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// bench：在进程内启动 Server 并由客户端施加负载，输出吞吐量、延迟分位数、系统调用次数及内存分配次数，
+// 用于针对自己的负载调整循环数、合并写等选项，例如：
+//
+//	go run ./example/bench -scenario echo -conns 100 -size 512 -duration 10s -batching
+func main() {
+	var cfg config
+	flag.StringVar(&cfg.Scenario, "scenario", scenarioEcho, "load scenario: echo, broadcast or upload")
+	flag.IntVar(&cfg.Conns, "conns", 50, "client connections")
+	flag.IntVar(&cfg.Size, "size", 512, "message size in bytes")
+	flag.IntVar(&cfg.Rate, "rate", 0, "messages per second per connection, 0 means unlimited")
+	flag.DurationVar(&cfg.Duration, "duration", 5*time.Second, "benchmark duration")
+	flag.IntVar(&cfg.Loops, "loops", -1, "num loops")
+	flag.BoolVar(&cfg.Batching, "batching", false, "coalesce sends in one loop iteration into one writev")
+	flag.IntVar(&cfg.Spin, "spin", 0, "non-blocking polls before blocking in epoll_wait")
+	flag.BoolVar(&cfg.Eager, "eager", false, "read right after accept")
+	flag.IntVar(&cfg.AcceptBatch, "accept-batch", 0, "connections accepted per readable event")
+	flag.Parse()
+
+	r, err := run(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Printf("scenario %s, %d conns, %d bytes, %v\n", cfg.Scenario, cfg.Conns, cfg.Size, cfg.Duration)
+	fmt.Print(r)
+}
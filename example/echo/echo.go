@@ -34,6 +34,7 @@ func (s *example) OnClose(c *connection.Connection) {
 }
 
 func main() {
+	// 事件循环 goroutine 带有 fastnet_loop 标签，go tool pprof -tags 可以按循环统计 CPU 时间，参见 fastnet.LabelLoop
 	go func() {
 		if err := http.ListenAndServe(":6060", nil); err != nil {
 			panic(err)
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"strconv"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+)
+
+// example：每个连接的消息在独占的 goroutine 中处理，可以直接调用阻塞的接口
+type example struct{}
+
+func (s *example) OnConnect(c *connection.Connection) {
+	log.Info("OnConnect ：", c.PeerAddr())
+}
+
+func (s *example) OnMessage(c *connection.Connection, ctx interface{}, data []byte) (out []byte) {
+	var buf bytes.Buffer
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		buf.WriteString(query(sc.Text()))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func (s *example) OnClose(c *connection.Connection) {
+	log.Info("OnClose ：", c.PeerAddr())
+}
+
+// query：模拟一次阻塞的数据库查询
+func query(key string) string {
+	time.Sleep(10 * time.Millisecond)
+	return key + "=" + strconv.Itoa(len(key))
+}
+
+func main() {
+	var port int
+	var loops int
+
+	flag.IntVar(&port, "port", 1833, "server port")
+	flag.IntVar(&loops, "loops", -1, "num loops")
+	flag.Parse()
+
+	s, err := fastnet.NewServer(new(example),
+		fastnet.Network("tcp"),
+		fastnet.Address(":"+strconv.Itoa(port)),
+		fastnet.NumLoops(loops),
+		fastnet.GoroutinePerConnection())
+	if err != nil {
+		panic(err)
+	}
+
+	s.Start()
+}
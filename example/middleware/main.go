@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strconv"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+)
+
+const authKey = "example_middleware_auth"
+
+// logging：记录连接的建立、消息及关闭
+type logging struct {
+	fastnet.Handler
+}
+
+func (l *logging) OnConnect(c *connection.Connection) {
+	log.Info("connect:", c.PeerAddr())
+	l.Handler.OnConnect(c)
+}
+
+func (l *logging) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	log.Info("message:", c.PeerAddr(), len(data))
+	return l.Handler.OnMessage(c, ctx, data)
+}
+
+func (l *logging) OnClose(c *connection.Connection) {
+	log.Info("close:", c.PeerAddr())
+	l.Handler.OnClose(c)
+}
+
+// auth：连接的第一条消息必须是令牌，校验通过后后续消息才交给内层 Handler，否则关闭连接
+type auth struct {
+	fastnet.Handler
+	token []byte
+}
+
+func (a *auth) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	if _, ok := c.Get(authKey); ok {
+		return a.Handler.OnMessage(c, ctx, data)
+	}
+	if !bytes.Equal(bytes.TrimSpace(data), a.token) {
+		log.Info("auth failed:", c.PeerAddr())
+		_ = c.Close()
+		return nil
+	}
+	c.Set(authKey, true)
+	return []byte("ok\n")
+}
+
+func authWith(token string) fastnet.Middleware {
+	return func(next fastnet.Handler) fastnet.Handler {
+		return &auth{Handler: next, token: []byte(token)}
+	}
+}
+
+func withLogging(next fastnet.Handler) fastnet.Handler {
+	return &logging{Handler: next}
+}
+
+type example struct{}
+
+func (s *example) OnConnect(c *connection.Connection) {}
+func (s *example) OnMessage(c *connection.Connection, ctx interface{}, data []byte) (out []byte) {
+	return data
+}
+func (s *example) OnClose(c *connection.Connection) {}
+
+func main() {
+	var port int
+	var token string
+
+	flag.IntVar(&port, "port", 1833, "server port")
+	flag.StringVar(&token, "token", "secret", "auth token")
+	flag.Parse()
+
+	s, err := fastnet.NewServer(new(example),
+		fastnet.Network("tcp"),
+		fastnet.Address(":"+strconv.Itoa(port)),
+		fastnet.Use(withLogging, authWith(token)))
+	if err != nil {
+		panic(err)
+	}
+
+	s.Start()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+)
+
+// example：SCTP 回显服务，每条 SCTP 消息回复为一条消息
+type example struct{}
+
+func (s *example) OnConnect(c *connection.Connection) {}
+
+func (s *example) OnMessage(c *connection.Connection, ctx interface{}, data []byte) (out []byte) {
+	log.Info("OnMessage ：", c.PeerAddr(), len(data))
+	return data
+}
+
+func (s *example) OnClose(c *connection.Connection) {}
+
+func main() {
+	var port int
+
+	flag.IntVar(&port, "port", 1833, "server port")
+	flag.Parse()
+
+	// SCTP 模式下所有关联共用一个 socket，由主事件循环读取
+	s, err := fastnet.NewServer(new(example),
+		fastnet.Network("sctp"),
+		fastnet.Address(":"+strconv.Itoa(port)))
+	if err != nil {
+		panic(err)
+	}
+
+	log.Info("server start, listening on", s.Addr())
+	s.Start()
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+)
+
+// example：UDP 回显服务，每个数据报作为一条消息交给 OnMessage，返回的数据作为一个数据报回复给来源地址
+type example struct{}
+
+// OnConnect：UDP 没有连接，不会回调
+func (s *example) OnConnect(c *connection.Connection) {}
+
+func (s *example) OnMessage(c *connection.Connection, ctx interface{}, data []byte) (out []byte) {
+	log.Info("OnMessage ：", c.PeerAddr(), len(data))
+	return data
+}
+
+func (s *example) OnClose(c *connection.Connection) {}
+
+func main() {
+	var port int
+
+	flag.IntVar(&port, "port", 1833, "server port")
+	flag.Parse()
+
+	// UDP 模式下所有数据报由主事件循环读取，数据报边界即消息边界，可以用 nc -u 127.0.0.1 1833 测试
+	s, err := fastnet.NewServer(new(example),
+		fastnet.Network("udp"),
+		fastnet.Address(":"+strconv.Itoa(port)))
+	if err != nil {
+		panic(err)
+	}
+
+	log.Info("server start, listening on", s.Addr())
+	s.Start()
+}
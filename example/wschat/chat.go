@@ -0,0 +1,103 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/plugins/websocket"
+	"github.com/Dongxiem/fastnet/plugins/websocket/ws"
+	"github.com/Dongxiem/fastnet/plugins/websocket/ws/util"
+)
+
+// chat：聊天室，连接发送的第一条消息作为昵称，之后的每条消息都会广播给聊天室内的所有连接
+type chat struct {
+	mu      sync.Mutex
+	members map[*connection.Connection]string
+	server  *fastnet.Server
+}
+
+// newChat：创建聊天室 Server
+func newChat(opts ...fastnet.Option) (*chat, error) {
+	s := &chat{members: make(map[*connection.Connection]string)}
+
+	u := &ws.Upgrader{}
+	opts = append(opts, fastnet.Protocol(websocket.New(u)))
+	server, err := fastnet.NewServer(websocket.NewHandlerWrap(u, s), opts...)
+	if err != nil {
+		return nil, err
+	}
+	s.server = server
+	return s, nil
+}
+
+func (s *chat) OnConnect(c *connection.Connection) {
+	log.Info("OnConnect ：", c.PeerAddr())
+}
+
+func (s *chat) OnMessage(c *connection.Connection, data []byte) (messageType ws.MessageType, out []byte) {
+	s.mu.Lock()
+	name, ok := s.members[c]
+	if !ok {
+		// 握手完成后的第一条消息作为昵称，此后才加入聊天室接收广播
+		s.members[c] = string(data)
+		s.mu.Unlock()
+		return
+	}
+	conns := make([]*connection.Connection, 0, len(s.members))
+	for member := range s.members {
+		conns = append(conns, member)
+	}
+	s.mu.Unlock()
+
+	// 只封装一次数据帧，再发送给所有连接。
+	// connection.Broadcast 会等待事件循环写出完成，不能在 OnMessage 中调用，这里使用异步的 Send
+	msg, err := util.PackData(ws.MessageText, []byte(name+": "+string(data)))
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for _, member := range conns {
+		if err := member.Send(msg); err != nil {
+			log.Error(err)
+		}
+	}
+	return
+}
+
+func (s *chat) OnClose(c *connection.Connection) {
+	s.mu.Lock()
+	delete(s.members, c)
+	s.mu.Unlock()
+}
+
+// Members：聊天室内的连接数
+func (s *chat) Members() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.members)
+}
+
+// Start：启动 Server
+func (s *chat) Start() {
+	s.server.Start()
+}
+
+// Shutdown：优雅关闭，通知所有连接关闭并等待对端回复 close 帧，超时后直接停止 Server
+func (s *chat) Shutdown(timeout time.Duration) {
+	s.mu.Lock()
+	for c := range s.members {
+		if err := websocket.NewConn(c).WriteClose("server shutdown"); err != nil {
+			log.Error(err)
+		}
+	}
+	s.mu.Unlock()
+
+	deadline := time.Now().Add(timeout)
+	for s.Members() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.server.Stop()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+	"golang.org/x/net/websocket"
+)
+
+func TestChatBroadcast(t *testing.T) {
+	s, err := newChat(
+		fastnet.Address(":1848"),
+		fastnet.NumLoops(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer func() {
+		if t.Failed() {
+			s.server.Stop()
+		}
+	}()
+
+	const n = 5
+	addr := "ws://localhost:1848"
+	clients := make([]*websocket.Conn, n)
+	for i := range clients {
+		var c *websocket.Conn
+		for j := 0; j < 50; j++ {
+			if c, err = websocket.Dial(addr, "", addr); err == nil {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		if err := websocket.Message.Send(c, "user"+strconv.Itoa(i)); err != nil {
+			t.Fatal(err)
+		}
+		clients[i] = c
+	}
+	for start := time.Now(); s.Members() < n; {
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("expect %d members, but got %d", n, s.Members())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var expect []string
+	for i, c := range clients {
+		msg := "hello " + strconv.Itoa(i)
+		if err := websocket.Message.Send(c, msg); err != nil {
+			t.Fatal(err)
+		}
+		expect = append(expect, "user"+strconv.Itoa(i)+": "+msg)
+	}
+	sort.Strings(expect)
+
+	// 每个客户端都应收到所有人的消息
+	for i, c := range clients {
+		_ = c.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var got []string
+		for j := 0; j < n; j++ {
+			var msg string
+			if err := websocket.Message.Receive(c, &msg); err != nil {
+				t.Fatalf("client %d: %v", i, err)
+			}
+			got = append(got, msg)
+		}
+		sort.Strings(got)
+		for j := range expect {
+			if got[j] != expect[j] {
+				t.Fatalf("client %d expect %q, but got %q", i, expect[j], got[j])
+			}
+		}
+	}
+
+	// 优雅关闭后客户端收到 close 帧
+	done := make(chan struct{})
+	go func() {
+		for _, c := range clients {
+			var msg string
+			if err := websocket.Message.Receive(c, &msg); err != io.EOF {
+				t.Errorf("expect io.EOF after shutdown, but got %v", err)
+			}
+			_ = c.Close()
+		}
+		close(done)
+	}()
+	s.Shutdown(3 * time.Second)
+	<-done
+	if s.Members() != 0 {
+		t.Fatalf("expect all members closed, but %d left", s.Members())
+	}
+}
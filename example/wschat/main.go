@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+)
+
+func main() {
+	var port int
+	var loops int
+
+	flag.IntVar(&port, "port", 1833, "server port")
+	flag.IntVar(&loops, "loops", -1, "num loops")
+	flag.Parse()
+
+	s, err := newChat(
+		fastnet.Network("tcp"),
+		fastnet.Address(":"+strconv.Itoa(port)),
+		fastnet.NumLoops(loops))
+	if err != nil {
+		panic(err)
+	}
+
+	// 收到退出信号后通知所有客户端再停止 Server
+	go func() {
+		ch := make(chan os.Signal, 1)
+		signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+		<-ch
+		s.Shutdown(3 * time.Second)
+	}()
+
+	s.Start()
+}
@@ -0,0 +1,113 @@
+package fastnet
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/tool/clock"
+	"golang.org/x/sys/unix"
+)
+
+// flapSweepSize：记录的来源 IP 超过该数量时清理已经过期的记录
+const flapSweepSize = 1024
+
+// flapState：一个来源 IP 的抖动记录
+type flapState struct {
+	flaps        []time.Time // 最近 window 内短连接关闭的时间
+	blockedUntil time.Time   // 拒绝该来源的新连接直到此时
+}
+
+// flapGuard：按来源 IP 检测频繁建立又断开的连接（通常是配置错误的客户端重试循环），
+// 达到阈值后在冷却期内拒绝该来源的新连接，避免反复建立、关闭连接消耗 CPU
+type flapGuard struct {
+	mu        sync.Mutex
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	clock     clock.Clock
+	ips       map[string]*flapState
+}
+
+func newFlapGuard(threshold int, window, cooldown time.Duration, clk clock.Clock) *flapGuard {
+	if clk == nil {
+		clk = clock.Real()
+	}
+	return &flapGuard{
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		clock:     clk,
+		ips:       make(map[string]*flapState),
+	}
+}
+
+// blocked：来源 ip 是否处于冷却期，在主事件循环中 Accept 之后调用
+func (g *flapGuard) blocked(ip string) bool {
+	if ip == "" {
+		return false
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s, ok := g.ips[ip]
+	return ok && g.clock.Now().Before(s.blockedUntil)
+}
+
+// closed：连接关闭时调用，建立后 window 内即关闭的连接记为一次抖动，
+// window 内的抖动达到 threshold 次时开始冷却，在各个 work 事件循环中调用
+func (g *flapGuard) closed(c *connection.Connection) {
+	ip := peerIP(c.PeerAddr())
+	now := g.clock.Now()
+	if ip == "" || now.Sub(c.CreatedAt()) >= g.window {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.ips) >= flapSweepSize {
+		g.sweep(now)
+	}
+	s, ok := g.ips[ip]
+	if !ok {
+		s = &flapState{}
+		g.ips[ip] = s
+	}
+	s.flaps = append(trimFlaps(s.flaps, now.Add(-g.window)), now)
+	if len(s.flaps) >= g.threshold {
+		s.blockedUntil = now.Add(g.cooldown)
+		s.flaps = s.flaps[:0]
+	}
+}
+
+// sweep：删除不在冷却期且 window 内没有抖动的记录
+func (g *flapGuard) sweep(now time.Time) {
+	for ip, s := range g.ips {
+		s.flaps = trimFlaps(s.flaps, now.Add(-g.window))
+		if len(s.flaps) == 0 && !now.Before(s.blockedUntil) {
+			delete(g.ips, ip)
+		}
+	}
+}
+
+// trimFlaps：去掉 since 之前的抖动记录
+func trimFlaps(flaps []time.Time, since time.Time) []time.Time {
+	i := 0
+	for i < len(flaps) && flaps[i].Before(since) {
+		i++
+	}
+	return append(flaps[:0], flaps[i:]...)
+}
+
+// peerIP：从 host:port 形式的对端地址中取出 IP，Unix Socket 等没有 IP 的连接返回空
+func peerIP(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// sockaddrIP：新连接的对端 IP，与 peerIP 的结果一致
+func sockaddrIP(sa unix.Sockaddr) string {
+	return peerIP(connection.SockAddrToString(sa))
+}
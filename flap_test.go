@@ -0,0 +1,70 @@
+package fastnet
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/tool/clock"
+)
+
+// waitConnections：等待服务端的连接数变为 n
+func waitConnections(t *testing.T, handler *example, n int64) {
+	deadline := time.Now().Add(3 * time.Second)
+	for handler.Count.Get() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expect %d connections, but got %d", n, handler.Count.Get())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestServer_FlapGuard(t *testing.T) {
+	fake := clock.NewFake(time.Now())
+	handler := new(example)
+	s, err := NewServer(handler,
+		Address("127.0.0.1:0"),
+		NumLoops(1),
+		Clock(fake),
+		FlapGuard(3, time.Second, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	// 模拟客户端的重试循环：建立后立即断开
+	for i := 0; i < 3; i++ {
+		conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		waitConnections(t, handler, 1)
+		_ = conn.Close()
+		waitConnections(t, handler, 0)
+	}
+
+	// 冷却期内新连接被直接关闭
+	conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expect the flapping source to be refused, but got %v", err)
+	}
+	_ = conn.Close()
+	if handler.Count.Get() != 0 {
+		t.Fatal("refused connection should not call OnConnect")
+	}
+
+	// 冷却期过后恢复
+	fake.Advance(2 * time.Minute)
+	conn, err = net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	expectEcho(t, conn, "back")
+}
@@ -0,0 +1,145 @@
+package fastnet
+
+import (
+	"runtime/pprof"
+	"sync"
+
+	"github.com/Dongxiem/fastnet/connection"
+)
+
+const mailboxKey = "fastnet_mailbox"
+
+// perConnHandler：GoroutinePerConnection 模式下的 Handler 包装，
+// 事件循环只负责读写及拆包，拆出的消息投递到连接独占的 goroutine 中调用 Handler
+type perConnHandler struct {
+	Handler
+	slots chan struct{} // 所有连接共享的 OnMessage 并发名额，nil 表示不限制
+}
+
+// message：投递给连接 goroutine 的消息
+type message struct {
+	ctx  interface{}
+	data []byte
+}
+
+// mailbox：事件循环与连接 goroutine 之间的消息队列，不限长度，投递时不会阻塞事件循环
+type mailbox struct {
+	mu     sync.Mutex
+	queue  []message
+	closed bool
+	notify chan struct{}
+}
+
+func (m *mailbox) push(msg message) {
+	m.mu.Lock()
+	m.queue = append(m.queue, msg)
+	m.mu.Unlock()
+	m.wake()
+}
+
+func (m *mailbox) close() {
+	m.mu.Lock()
+	m.closed = true
+	m.mu.Unlock()
+	m.wake()
+}
+
+func (m *mailbox) wake() {
+	select {
+	case m.notify <- struct{}{}:
+	default:
+	}
+}
+
+// take：取出所有待处理的消息，没有消息时阻塞等待
+func (m *mailbox) take() ([]message, bool) {
+	for {
+		m.mu.Lock()
+		if len(m.queue) > 0 || m.closed {
+			queue, closed := m.queue, m.closed
+			m.queue = nil
+			m.mu.Unlock()
+			return queue, closed
+		}
+		m.mu.Unlock()
+		<-m.notify
+	}
+}
+
+func (h *perConnHandler) OnConnect(c *connection.Connection) {
+	h.start(c, true)
+}
+
+func (h *perConnHandler) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	var m *mailbox
+	if v, ok := c.Get(mailboxKey); ok {
+		m = v.(*mailbox)
+	} else {
+		// 通过 Adopt 接管的连接没有 OnConnect
+		m = h.start(c, false)
+	}
+	// data 可能引用协议复用的缓冲区，投递前拷贝
+	m.push(message{ctx: ctx, data: append([]byte(nil), data...)})
+	return nil
+}
+
+func (h *perConnHandler) OnClose(c *connection.Connection) {
+	if v, ok := c.Get(mailboxKey); ok {
+		v.(*mailbox).close()
+	}
+}
+
+// start：创建连接的消息队列并启动连接 goroutine
+func (h *perConnHandler) start(c *connection.Connection, connect bool) *mailbox {
+	m := &mailbox{notify: make(chan struct{}, 1)}
+	c.Set(mailboxKey, m)
+	go h.serve(c, m, connect)
+	return m
+}
+
+// serve：连接独占的 goroutine，依次调用 OnConnect、OnMessage 及 OnClose，OnMessage 返回的数据通过 Send 发送
+func (h *perConnHandler) serve(c *connection.Connection, m *mailbox, connect bool) {
+	pprof.SetGoroutineLabels(connGoroutineLabels)
+	if connect {
+		h.Handler.OnConnect(c)
+	}
+	for {
+		msgs, closed := m.take()
+		// 连接已关闭，剩余的消息无法再回复，直接丢弃
+		if closed {
+			h.Handler.OnClose(c)
+			return
+		}
+		for _, msg := range msgs {
+			h.acquire(c)
+			out := h.Handler.OnMessage(c, msg.ctx, msg.data)
+			h.release()
+			if len(out) > 0 {
+				_ = c.Send(out)
+			}
+		}
+	}
+}
+
+// acquire：获取一个 OnMessage 并发名额，没有空闲名额时暂停读取该连接，直到获取到名额，
+// 使排队的消息不会无限增长
+func (h *perConnHandler) acquire(c *connection.Connection) {
+	if h.slots == nil {
+		return
+	}
+	select {
+	case h.slots <- struct{}{}:
+		return
+	default:
+	}
+	_ = c.PauseRead()
+	h.slots <- struct{}{}
+	_ = c.ResumeRead()
+}
+
+// release：归还 acquire 获取的名额
+func (h *perConnHandler) release() {
+	if h.slots != nil {
+		<-h.slots
+	}
+}
@@ -0,0 +1,203 @@
+package fastnet
+
+import (
+	"io"
+	"net"
+	"runtime"
+	stdsync "sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+)
+
+// blockingServer：收到 "slow" 时阻塞一段时间后回复，其他消息直接回复
+type blockingServer struct{}
+
+func (s *blockingServer) OnConnect(c *connection.Connection) {}
+
+func (s *blockingServer) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	if string(data) == "slow" {
+		time.Sleep(300 * time.Millisecond)
+	}
+	return data
+}
+
+func (s *blockingServer) OnClose(c *connection.Connection) {}
+
+func TestGoroutinePerConnection(t *testing.T) {
+	s, err := NewServer(new(blockingServer),
+		Address("127.0.0.1:0"),
+		NumLoops(1),
+		GoroutinePerConnection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	slow, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slow.Close()
+	fast, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fast.Close()
+
+	// 同一个事件循环中，阻塞的 OnMessage 不影响其他连接
+	if _, err := slow.Write([]byte("slow")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	start := time.Now()
+	request(t, fast, "fast", "fast")
+	if et := time.Since(start); et > 200*time.Millisecond {
+		t.Fatalf("fast connection blocked for %v", et)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(slow, buf); err != nil || string(buf) != "slow" {
+		t.Fatalf("expect slow, but got %q, %v", buf, err)
+	}
+
+	if _, err := NewServer(new(blockingServer), GoroutinePerConnection(), Preallocate(true), MaxConnections(10)); err != ErrGoroutineWithPreallocate {
+		t.Fatalf("expect ErrGoroutineWithPreallocate, but got %v", err)
+	}
+}
+
+// concurrencyRecorder：记录同时执行的 OnMessage 个数的最大值
+type concurrencyRecorder struct {
+	blockingServer
+	running, peak int32
+}
+
+func (s *concurrencyRecorder) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	n := atomic.AddInt32(&s.running, 1)
+	for {
+		peak := atomic.LoadInt32(&s.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(&s.peak, peak, n) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+	atomic.AddInt32(&s.running, -1)
+	return data
+}
+
+func TestMaxConcurrentMessages(t *testing.T) {
+	const limit = 3
+	handler := new(concurrencyRecorder)
+	s, err := NewServer(handler,
+		Address("127.0.0.1:0"),
+		NumLoops(2),
+		GoroutinePerConnection(),
+		MaxConcurrentMessages(limit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	// 20 个连接同时发送消息
+	const conns = 20
+	var wg stdsync.WaitGroup
+	errs := make(chan error, conns)
+	for i := 0; i < conns; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer conn.Close()
+			_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+			for j := 0; j < 3; j++ {
+				if _, err := conn.Write([]byte("ping")); err != nil {
+					errs <- err
+					return
+				}
+				buf := make([]byte, 4)
+				if _, err := io.ReadFull(conn, buf); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if peak := atomic.LoadInt32(&handler.peak); peak > limit || peak == 0 {
+		t.Fatalf("expect at most %d concurrent OnMessage, but got %d", limit, peak)
+	}
+
+	if _, err := NewServer(new(blockingServer), MaxConcurrentMessages(1)); err != ErrConcurrencyWithoutGoroutine {
+		t.Fatalf("expect ErrConcurrencyWithoutGoroutine, but got %v", err)
+	}
+}
+
+// BenchmarkConnectionMemory：对比默认模式与 GoroutinePerConnection 模式下每个连接占用的内存
+func BenchmarkConnectionMemory(b *testing.B) {
+	const conns = 1000
+	for _, mode := range []struct {
+		name string
+		opts []Option
+	}{
+		{"default", nil},
+		{"goroutine", []Option{GoroutinePerConnection()}},
+	} {
+		b.Run(mode.name, func(b *testing.B) {
+			s, err := NewServer(new(blockingServer), append(mode.opts, Address("127.0.0.1:0"), NumLoops(2))...)
+			if err != nil {
+				b.Fatal(err)
+			}
+			go s.Start()
+			defer s.Stop()
+
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+
+			clients := make([]net.Conn, conns)
+			buf := make([]byte, 4)
+			for i := range clients {
+				conn, err := net.Dial("tcp", s.Addr())
+				if err != nil {
+					b.Fatal(err)
+				}
+				defer conn.Close()
+				clients[i] = conn
+				// 处理一条消息，使连接 goroutine 的栈增长到实际使用的大小
+				if _, err := conn.Write([]byte("ping")); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := io.ReadFull(conn, buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				conn := clients[i%conns]
+				if _, err := conn.Write([]byte("ping")); err != nil {
+					b.Fatal(err)
+				}
+				if _, err := io.ReadFull(conn, buf); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.StopTimer()
+			used := int64(after.HeapInuse+after.StackInuse) - int64(before.HeapInuse+before.StackInuse)
+			b.ReportMetric(float64(used)/conns, "bytes/conn")
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package fastnet
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// DefaultHealthPath：HealthCheck 未指定路径时使用的路径
+const DefaultHealthPath = "/healthz"
+
+const healthCheckedKey = "fastnet_health_checked"
+
+var headerEnd = []byte("\r\n\r\n")
+
+// ErrHealthCheckDatagram：UDP 及 SCTP 模式下健康检查不能与业务共用监听地址
+var ErrHealthCheckDatagram = errors.New("health check requires a separate address for datagram networks")
+
+// healthStatus：健康检查的状态码及内容，排空状态下返回 503
+func (s *Server) healthStatus() (int, string) {
+	if s.Draining() {
+		return http.StatusServiceUnavailable, "draining\n"
+	}
+	return http.StatusOK, "ok\n"
+}
+
+// listenHealth：addr 不为空时在 addr 上监听健康检查，否则在业务监听地址上识别健康检查请求
+func (s *Server) listenHealth() error {
+	path := s.opts.HealthCheckPath
+	if s.opts.HealthCheckAddr == "" {
+		if isDatagram(s.opts.Network) {
+			return ErrHealthCheckDatagram
+		}
+		s.opts.Protocol = &healthProtocol{Protocol: s.opts.Protocol, s: s, prefix: []byte("GET " + path + " ")}
+		return nil
+	}
+
+	ln, err := net.Listen("tcp", s.opts.HealthCheckAddr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		code, body := s.healthStatus()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(body))
+	})
+	s.healthLn = ln
+	s.healthSrv = &http.Server{Handler: mux}
+	return nil
+}
+
+// serveHealth：在独立的监听地址上提供健康检查，Stop 时退出
+func (s *Server) serveHealth() {
+	if err := s.healthSrv.Serve(s.healthLn); err != nil && err != http.ErrServerClosed {
+		log.Error("[health]", err)
+	}
+}
+
+// HealthAddr：健康检查实际监听的地址，健康检查与业务共用监听地址时与 Addr 相同
+func (s *Server) HealthAddr() string {
+	if s.healthLn == nil {
+		return s.Addr()
+	}
+	return s.healthLn.Addr().String()
+}
+
+// healthProtocol：健康检查与业务共用监听地址时包装业务协议，连接的第一个请求是 GET 健康检查路径时
+// 直接回复并关闭连接，否则之后的数据全部交给业务协议
+type healthProtocol struct {
+	connection.Protocol
+	s      *Server
+	prefix []byte // 健康检查请求行的开头，如 "GET /healthz "
+}
+
+// UnPacket：识别健康检查请求，识别完成前不交给业务协议
+func (p *healthProtocol) UnPacket(c *connection.Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	if _, ok := c.Get(healthCheckedKey); !ok {
+		first, end := buffer.PeekAll()
+		head := append(append(make([]byte, 0, len(first)+len(end)), first...), end...)
+		n := len(head)
+		if n > len(p.prefix) {
+			n = len(p.prefix)
+		}
+		switch {
+		case !bytes.Equal(head[:n], p.prefix[:n]):
+			c.Set(healthCheckedKey, true)
+		case n < len(p.prefix) || !bytes.Contains(head, headerEnd):
+			// 请求还不完整，等待更多数据
+			return nil, nil
+		default:
+			buffer.RetrieveAll()
+			code, body := p.s.healthStatus()
+			c.SendInLoop(healthResponse(code, body))
+			_ = c.Close()
+			return nil, nil
+		}
+	}
+	return p.Protocol.UnPacket(c, buffer)
+}
+
+// healthResponse：健康检查的 HTTP 响应
+func healthResponse(code int, body string) []byte {
+	var b bytes.Buffer
+	b.WriteString("HTTP/1.1 ")
+	b.WriteString(strconv.Itoa(code))
+	b.WriteString(" ")
+	b.WriteString(http.StatusText(code))
+	b.WriteString("\r\nContent-Type: text/plain; charset=utf-8\r\nConnection: close\r\nContent-Length: ")
+	b.WriteString(strconv.Itoa(len(body)))
+	b.WriteString("\r\n\r\n")
+	b.WriteString(body)
+	return b.Bytes()
+}
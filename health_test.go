@@ -0,0 +1,88 @@
+package fastnet
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestServer_HealthCheck(t *testing.T) {
+	s, err := NewServer(new(example),
+		Address("127.0.0.1:0"),
+		NumLoops(1),
+		HealthCheck("127.0.0.1:0", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	url := "http://" + s.HealthAddr() + DefaultHealthPath
+	if code := healthGet(t, url); code != http.StatusOK {
+		t.Fatalf("expect 200, but got %d", code)
+	}
+	s.Drain()
+	if code := healthGet(t, url); code != http.StatusServiceUnavailable {
+		t.Fatalf("expect 503 when draining, but got %d", code)
+	}
+}
+
+func TestServer_HealthCheckSharedListener(t *testing.T) {
+	s, err := NewServer(new(example),
+		Address("127.0.0.1:0"),
+		NumLoops(1),
+		HealthCheck("", "/ping"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	if code := healthRaw(t, s.Addr(), "/ping"); code != http.StatusOK {
+		t.Fatalf("expect 200, but got %d", code)
+	}
+
+	// 业务数据不受影响
+	conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	expectEcho(t, conn, "GET /other")
+
+	s.Drain()
+	if code := healthRaw(t, s.Addr(), "/ping"); code != http.StatusServiceUnavailable {
+		t.Fatalf("expect 503 when draining, but got %d", code)
+	}
+}
+
+func healthGet(t *testing.T, url string) int {
+	client := http.Client{Timeout: time.Second * 3}
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
+
+// healthRaw：在业务监听地址上发送健康检查请求
+func healthRaw(t *testing.T, addr, path string) int {
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(time.Second * 3))
+	if _, err := conn.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: lb\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode
+}
@@ -0,0 +1,38 @@
+package fastnet
+
+import (
+	"context"
+	"runtime/pprof"
+	"strconv"
+)
+
+// pprof 标签，Server 的 goroutine 都带有标签，CPU、goroutine 及 block 等 profile 可以据此区分各个事件循环：
+//
+//	go tool pprof -tags http://localhost:6060/debug/pprof/profile
+//
+// 按标签统计 CPU 时间，例如输出中 fastnet_loop 标签下 work-2 占 60%、其余 work 循环各占 10% 左右，
+// 说明连接在各循环间分布不均或 2 号循环上存在热点连接；某个循环的 CPU 占比接近 0 而连接数不为 0 则可能处于阻塞状态，
+// 可以再用 -tagfocus 只看该循环的调用栈：
+//
+//	go tool pprof -tagfocus=fastnet_loop=work-2 http://localhost:6060/debug/pprof/profile
+const (
+	// LabelLoop：事件循环 goroutine 的标签名，值为 main（主事件循环）或 work-N（第 N 个 work 事件循环）
+	LabelLoop = "fastnet_loop"
+	// LabelGoroutine：GoroutinePerConnection 模式下连接 goroutine 的标签名，值为 conn
+	LabelGoroutine = "fastnet_goroutine"
+)
+
+// runLabeled：带上事件循环标签运行 f
+func runLabeled(loop string, f func()) {
+	pprof.Do(context.Background(), pprof.Labels(LabelLoop, loop), func(context.Context) {
+		f()
+	})
+}
+
+// workLoopLabel：第 i 个 work 事件循环的标签值
+func workLoopLabel(i int) string {
+	return "work-" + strconv.Itoa(i)
+}
+
+// connGoroutineLabels：连接 goroutine 的标签，替换从创建它的事件循环继承的标签
+var connGoroutineLabels = pprof.WithLabels(context.Background(), pprof.Labels(LabelGoroutine, "conn"))
@@ -0,0 +1,46 @@
+package fastnet
+
+import (
+	"bytes"
+	"net"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer_PprofLabels(t *testing.T) {
+	s, err := NewServer(new(example),
+		Address("127.0.0.1:0"),
+		NumLoops(2),
+		GoroutinePerConnection())
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	expectEcho(t, conn, "hello")
+
+	// goroutine profile 中每个事件循环及连接 goroutine 都带有各自的标签
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		t.Fatal(err)
+	}
+	profile := buf.String()
+	for _, label := range []string{
+		`"fastnet_loop":"main"`,
+		`"fastnet_loop":"work-0"`,
+		`"fastnet_loop":"work-1"`,
+		`"fastnet_goroutine":"conn"`,
+	} {
+		if !strings.Contains(profile, label) {
+			t.Fatalf("expect label %s in goroutine profile", label)
+		}
+	}
+}
@@ -15,6 +15,20 @@ import (
 // HandleConnFunc：处理新连接回调方法
 type HandleConnFunc func(fd int, sa unix.Sockaddr)
 
+// HandleConnBatchFunc：批量处理新连接回调方法，conns 在回调返回后会被复用
+type HandleConnBatchFunc func(conns []Accepted)
+
+// Accepted：一个已 Accept 的新连接
+type Accepted struct {
+	Fd int
+	Sa unix.Sockaddr
+}
+
+// filer：可以获取底层文件的监听，*net.TCPListener 及 *net.UnixListener 均实现了该接口
+type filer interface {
+	File() (*os.File, error)
+}
+
 // Listener：监听TCP连接
 type Listener struct {
 	file     *os.File				// 文件
@@ -22,6 +36,10 @@ type Listener struct {
 	handleC  HandleConnFunc 		// 处理新连接函数
 	listener net.Listener 			// Listener 监听
 	loop     *eventloop.EventLoop 	// 事件循环
+
+	batch       int					// 每次可读事件最多 Accept 的连接数
+	handleBatch HandleConnBatchFunc // 批量处理新连接函数
+	accepted    []Accepted			// 复用的批量 Accept 结果
 }
 
 // New：创建一个新的 Listener 监听
@@ -38,13 +56,13 @@ func New(network, addr string, reusePort bool, loop *eventloop.EventLoop, handle
 		return nil, err
 	}
 
-	// 得到一个 TCP 监听
-	l, ok := listener.(*net.TCPListener)
+	// 得到一个 TCP 或 Unix 监听
+	l, ok := listener.(filer)
 	if !ok {
 		return nil, errors.New("could not get file descriptor")
 	}
 
-	// 得到该 TCP监听对应的文件
+	// 得到该监听对应的文件
 	file, err := l.File()
 	if err != nil {
 		return nil, err
@@ -64,8 +82,57 @@ func New(network, addr string, reusePort bool, loop *eventloop.EventLoop, handle
 		loop:     loop}, nil
 }
 
+// NewUDP：创建一个非阻塞的 UDP socket，返回其文件描述符
+func NewUDP(network, addr string, reusePort bool) (int, error) {
+	var conn net.PacketConn
+	var err error
+	if reusePort {
+		conn, err = reuseport.ListenPacket(network, addr)
+	} else {
+		conn, err = net.ListenPacket(network, addr)
+	}
+	if err != nil {
+		return -1, err
+	}
+	defer conn.Close()
+
+	l, ok := conn.(filer)
+	if !ok {
+		return -1, errors.New("could not get file descriptor")
+	}
+	// File 返回的是复制出来的 fd，原 conn 可以直接关闭
+	file, err := l.File()
+	if err != nil {
+		return -1, err
+	}
+	fd, err := unix.Dup(int(file.Fd()))
+	_ = file.Close()
+	if err != nil {
+		return -1, err
+	}
+	if err = unix.SetNonblock(fd, true); err != nil {
+		_ = unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
+
+// SetBatch ：开启批量 Accept，每次可读事件最多 Accept n 个连接后一并交给 handle 处理，
+// 在连接风暴时减少事件循环唤醒与系统调用的次数。需要在加入事件循环之前调用
+func (l *Listener) SetBatch(n int, handle HandleConnBatchFunc) {
+	l.batch = n
+	l.handleBatch = handle
+	l.accepted = make([]Accepted, 0, n)
+}
+
 // HandleEvent ：内部使用，供 event loop 回调处理事件
 func (l *Listener) HandleEvent(fd int, events poller.Event) {
+	if l.handleBatch != nil {
+		if events&poller.EventRead != 0 {
+			l.acceptBatch(fd)
+		}
+		return
+	}
 	// 如果 events 有读事件，也即有客户端进行了请求连接
 	if events & poller.EventRead != 0 {
 		// 进行 Accept，并得到 Accept 之后的文件描述符 nfd
@@ -88,6 +155,28 @@ func (l *Listener) HandleEvent(fd int, events poller.Event) {
 	}
 }
 
+// acceptBatch ：一直 Accept 到没有等待的连接或达到批量上限，使用 accept4 直接得到非阻塞的 fd
+func (l *Listener) acceptBatch(fd int) {
+	accepted := l.accepted[:0]
+	for len(accepted) < l.batch {
+		nfd, sa, err := unix.Accept4(fd, unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC)
+		if err != nil {
+			if err != unix.EAGAIN {
+				log.Error("accept:", err)
+			}
+			break
+		}
+		accepted = append(accepted, Accepted{Fd: nfd, Sa: sa})
+	}
+	if len(accepted) > 0 {
+		l.handleBatch(accepted)
+	}
+	for i := range accepted {
+		accepted[i] = Accepted{}
+	}
+	l.accepted = accepted[:0]
+}
+
 // Close ：关闭 listener
 func (l *Listener) Close() error {
 	// 进行一个队列循环，将队列中的所有 Listener 都进行关闭
@@ -96,11 +185,24 @@ func (l *Listener) Close() error {
 		if err := l.listener.Close(); err != nil {
 			log.Error("[Listener] close error: ", err)
 		}
+		// fd 是 File 复制出的描述符，需要单独关闭，否则内核中的监听 socket 仍然存在
+		if err := l.file.Close(); err != nil {
+			log.Error("[Listener] close file error: ", err)
+		}
 	})
 
 	return nil
 }
 
+// Release ：立即关闭尚未加入事件循环的 Listener，用于创建失败时的清理
+func (l *Listener) Release() error {
+	err := l.listener.Close()
+	if e := l.file.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
 // Fd ：返回 listener 的文件句柄
 func (l *Listener) Fd() int {
 	return l.fd
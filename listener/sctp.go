@@ -0,0 +1,60 @@
+package listener
+
+import (
+	"net"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// sctpBacklog：SCTP 等待建立的关联上限
+const sctpBacklog = 128
+
+// IsSCTP：判断是否为 SCTP 网络
+func IsSCTP(network string) bool {
+	return strings.HasPrefix(network, "sctp")
+}
+
+// NewSCTP：创建一个非阻塞的一对多（SOCK_SEQPACKET）SCTP socket 并开始监听，返回其文件描述符。
+// 一对多风格的 socket 上所有关联共用同一个 fd，每次读取得到一条完整的消息及其来源地址，
+// 向来源地址发送即可回复到对应的关联，因此可以与 UDP 一样按数据报处理
+func NewSCTP(network, addr string) (int, error) {
+	tcpAddr, err := net.ResolveTCPAddr(strings.Replace(network, "sctp", "tcp", 1), addr)
+	if err != nil {
+		return -1, err
+	}
+
+	var sa unix.Sockaddr
+	domain := unix.AF_INET
+	if network == "sctp6" || (tcpAddr.IP != nil && tcpAddr.IP.To4() == nil) {
+		domain = unix.AF_INET6
+		sa6 := &unix.SockaddrInet6{Port: tcpAddr.Port}
+		copy(sa6.Addr[:], tcpAddr.IP.To16())
+		sa = sa6
+	} else {
+		sa4 := &unix.SockaddrInet4{Port: tcpAddr.Port}
+		if tcpAddr.IP != nil {
+			copy(sa4.Addr[:], tcpAddr.IP.To4())
+		}
+		sa = sa4
+	}
+
+	fd, err := unix.Socket(domain, unix.SOCK_SEQPACKET|unix.SOCK_NONBLOCK|unix.SOCK_CLOEXEC, unix.IPPROTO_SCTP)
+	if err != nil {
+		return -1, err
+	}
+	if err = unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+		_ = unix.Close(fd)
+		return -1, err
+	}
+	if err = unix.Bind(fd, sa); err != nil {
+		_ = unix.Close(fd)
+		return -1, err
+	}
+	// 一对多风格的 socket 需要 listen 之后才能接受新的关联
+	if err = unix.Listen(fd, sctpBacklog); err != nil {
+		_ = unix.Close(fd)
+		return -1, err
+	}
+	return fd, nil
+}
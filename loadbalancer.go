@@ -0,0 +1,39 @@
+package fastnet
+
+import (
+	"github.com/Dongxiem/fastnet/eventloop"
+	"github.com/Dongxiem/fastnet/tool/sync/atomic"
+)
+
+// LoadBalancer：为新连接从 work 事件循环中选择一个，loops 非空且在 Server 的生命周期内不变。
+// 由主事件循环调用，同一个 LoadBalancer 被多个 Server 共享时需要自行保证并发安全
+type LoadBalancer interface {
+	Pick(loops []*eventloop.EventLoop) *eventloop.EventLoop
+}
+
+// RoundRobin：依次轮流选择事件循环，未设置 LoadBalancer 时的默认方式，零值即可使用
+type RoundRobin struct {
+	next atomic.Int64
+}
+
+// Pick：选择下一个事件循环
+func (r *RoundRobin) Pick(loops []*eventloop.EventLoop) *eventloop.EventLoop {
+	i := r.next.Add(1) - 1
+	return loops[i%int64(len(loops))]
+}
+
+// LeastConnection：选择当前连接数最少的事件循环，连接数相同时选择靠前的一个。
+// 连接的存活时间差异很大时，轮流分配会使部分事件循环积压大量长连接，按连接数选择可以保持均衡
+type LeastConnection struct{}
+
+// Pick：选择连接数最少的事件循环
+func (LeastConnection) Pick(loops []*eventloop.EventLoop) *eventloop.EventLoop {
+	loop := loops[0]
+	least := loop.Connections()
+	for _, l := range loops[1:] {
+		if n := l.Connections(); n < least {
+			loop, least = l, n
+		}
+	}
+	return loop
+}
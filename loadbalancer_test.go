@@ -0,0 +1,80 @@
+package fastnet
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/eventloop"
+)
+
+func newBalancerLoops(t *testing.T, n int) []*eventloop.EventLoop {
+	loops, err := newLoops(n, func(*eventloop.EventLoop) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, l := range loops {
+			_ = l.Stop()
+		}
+	})
+	return loops
+}
+
+func TestRoundRobin(t *testing.T) {
+	loops := newBalancerLoops(t, 3)
+	rr := new(RoundRobin)
+	for i := 0; i < 6; i++ {
+		if l := rr.Pick(loops); l != loops[i%3] {
+			t.Fatalf("expect loop %d at pick %d", i%3, i)
+		}
+	}
+}
+
+func TestLeastConnection(t *testing.T) {
+	loops := newBalancerLoops(t, 3)
+	loops[0].AddConnections(2)
+	loops[1].AddConnections(1)
+	loops[2].AddConnections(1)
+	var lc LeastConnection
+	if l := lc.Pick(loops); l != loops[1] {
+		t.Fatal("expect the first loop with the fewest connections")
+	}
+	loops[1].AddConnections(1)
+	if l := lc.Pick(loops); l != loops[2] {
+		t.Fatal("expect the loop with the fewest connections")
+	}
+}
+
+func TestServer_Balancer(t *testing.T) {
+	handler := new(example)
+	s, err := NewServer(handler, Address("127.0.0.1:0"), NumLoops(2), Balancer(LeastConnection{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	dial := func() net.Conn {
+		n := handler.Count.Get()
+		conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		waitConnections(t, handler, n+1)
+		return conn
+	}
+	// 依次落在 0、1、0 号事件循环，关闭 0 号事件循环的两个连接后，新连接都应落在 0 号事件循环
+	a, _, c := dial(), dial(), dial()
+	_ = a.Close()
+	_ = c.Close()
+	waitConnections(t, handler, 1)
+	dial()
+	dial()
+
+	stats := s.Stats()
+	if stats.LoopConnections[0] != 2 || stats.LoopConnections[1] != 1 {
+		t.Fatalf("expect connections [2 1], but got %v", stats.LoopConnections)
+	}
+}
@@ -0,0 +1,71 @@
+package fastnet
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/eventloop"
+)
+
+// stallHandler：收到消息后阻塞事件循环直到 release 被关闭
+type stallHandler struct {
+	conn    chan *connection.Connection
+	release chan struct{}
+}
+
+func (s *stallHandler) OnConnect(c *connection.Connection) {}
+func (s *stallHandler) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	s.conn <- c
+	<-s.release
+	return nil
+}
+func (s *stallHandler) OnClose(c *connection.Connection) {}
+
+func TestServer_MaxLoopQueue(t *testing.T) {
+	const limit = 64
+	handler := &stallHandler{conn: make(chan *connection.Connection, 1), release: make(chan struct{})}
+	s, err := NewServer(handler, Address("127.0.0.1:0"), NumLoops(1), MaxLoopQueue(limit))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("stall")); err != nil {
+		t.Fatal(err)
+	}
+	c := <-handler.conn
+
+	// 事件循环被阻塞，发送的任务无法被取走，达到上限后 Send 返回错误而不是无限堆积
+	sent := 0
+	for ; sent < limit*10; sent++ {
+		if err := c.Send([]byte("x")); err != nil {
+			if err != eventloop.ErrQueueFull {
+				t.Fatalf("expect ErrQueueFull, but got %v", err)
+			}
+			break
+		}
+	}
+	if sent == 0 || sent > limit {
+		t.Fatalf("expect Send to be refused after at most %d tasks, but sent %d", limit, sent)
+	}
+
+	// 事件循环恢复后已接受的数据全部发出
+	close(handler.release)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second * 3))
+	buf := make([]byte, sent)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Send([]byte("x")); err != nil {
+		t.Fatalf("expect Send to succeed after the loop drains, but got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package fastnet
+
+// Middleware：Handler 中间件，包装 next 并返回新的 Handler，用于实现日志、鉴权、限流等横切逻辑。
+// 通常通过内嵌 next 只重写需要的方法，例如：
+//
+//	type logging struct{ fastnet.Handler }
+//
+//	func (l *logging) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+//		log.Info("message from", c.PeerAddr())
+//		return l.Handler.OnMessage(c, ctx, data)
+//	}
+//
+// 连接对 BatchCallBack、HalfCloseCallBack 等可选接口的检测作用于最外层的 Handler，
+// 中间件需要自行实现并转发这些方法才能使其生效
+type Middleware func(next Handler) Handler
+
+// Use：按顺序添加中间件，先添加的中间件位于外层，最先处理事件
+func Use(m ...Middleware) Option {
+	return func(o *Options) {
+		o.Middlewares = append(o.Middlewares, m...)
+	}
+}
+
+// chain：将中间件依次包装到 handler 上
+func chain(handler Handler, middlewares []Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,68 @@
+package fastnet
+
+import (
+	"testing"
+
+	"github.com/Dongxiem/fastnet/connection"
+)
+
+type recordHandler struct {
+	calls *[]string
+}
+
+func (h *recordHandler) OnConnect(c *connection.Connection) {
+	*h.calls = append(*h.calls, "connect")
+}
+
+func (h *recordHandler) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	*h.calls = append(*h.calls, "message")
+	return data
+}
+
+func (h *recordHandler) OnClose(c *connection.Connection) {
+	*h.calls = append(*h.calls, "close")
+}
+
+type recordMiddleware struct {
+	Handler
+	name  string
+	calls *[]string
+}
+
+func (m *recordMiddleware) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	*m.calls = append(*m.calls, m.name)
+	return m.Handler.OnMessage(c, ctx, append(data, m.name...))
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	record := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return &recordMiddleware{Handler: next, name: name, calls: &calls}
+		}
+	}
+
+	h := chain(&recordHandler{calls: &calls}, []Middleware{record("a"), record("b")})
+	h.OnConnect(nil)
+	out := h.OnMessage(nil, nil, []byte("x"))
+	h.OnClose(nil)
+
+	if string(out) != "xab" {
+		t.Fatalf("expect xab, but got %s", out)
+	}
+	expect := []string{"connect", "a", "b", "message", "close"}
+	if len(calls) != len(expect) {
+		t.Fatalf("expect %v, but got %v", expect, calls)
+	}
+	for i := range expect {
+		if calls[i] != expect[i] {
+			t.Fatalf("expect %v, but got %v", expect, calls)
+		}
+	}
+
+	// 没有中间件时返回原 Handler
+	origin := &recordHandler{calls: &calls}
+	if chain(origin, nil) != Handler(origin) {
+		t.Fatal("chain without middleware should return the handler itself")
+	}
+}
@@ -0,0 +1,76 @@
+package fastnet
+
+import (
+	"errors"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"golang.org/x/sys/unix"
+)
+
+// ErrMaxConnections：连接数已达上限
+var ErrMaxConnections = errors.New("max connections reached")
+
+// Adopt：接管其他进程通过 connection.Freeze 冻结并以 connection.ReceiveSnapshot 交过来的连接，用于不停机升级。
+// restore 用于恢复应用保存在 snap.State 中的协议状态，为 nil 时忽略。
+// 接管的连接不会回调 OnConnect，恢复后继续处理冻结时尚未拆包的数据，并写出尚未发送的数据。
+// Adopt 会等待主事件循环执行完成，需要在 Start 之后调用
+func (s *Server) Adopt(fd int, snap *connection.Snapshot, restore func(c *connection.Connection, state []byte) error) error {
+	if isDatagram(s.opts.Network) {
+		_ = unix.Close(fd)
+		return connection.ErrUDPNotSupported
+	}
+
+	var err error
+	done := make(chan struct{})
+	// loopFor 及连接池只在主事件循环中使用
+	s.loop.QueueInLoop(func() {
+		defer close(done)
+		err = s.adoptInLoop(fd, snap, restore)
+	})
+	<-done
+	return err
+}
+
+func (s *Server) adoptInLoop(fd int, snap *connection.Snapshot, restore func(c *connection.Connection, state []byte) error) error {
+	if err := unix.SetNonblock(fd, true); err != nil {
+		_ = unix.Close(fd)
+		return err
+	}
+	sa, err := unix.Getpeername(fd)
+	if err != nil {
+		_ = unix.Close(fd)
+		return err
+	}
+
+	loop := s.loopFor(fd, sa)
+	opts := s.connOptions(loop, false)
+	var c *connection.Connection
+	if s.connPool != nil {
+		c = s.connPool.Get(fd, loop, sa, s.opts.Protocol, s.timingWheel, s.opts.IdleTime, s.callback, opts...)
+		if c == nil {
+			s.reject(fd, sa, "max connections reached")
+			return ErrMaxConnections
+		}
+	} else {
+		c = connection.New(fd, loop, sa, s.opts.Protocol, s.timingWheel, s.opts.IdleTime, s.callback, opts...)
+	}
+	c.LoadSnapshot(snap)
+	if restore != nil {
+		if err := restore(c, snap.State); err != nil {
+			_ = c.Close()
+			return err
+		}
+	}
+	if s.audit != nil {
+		s.audit.connEvent(AuditAccept, c)
+	}
+
+	loop.QueueInLoop(func() {
+		if err := loop.AddSocketAndEnableRead(fd, c); err != nil {
+			c.HandlePollerError("add", err)
+			return
+		}
+		c.Resume()
+	})
+	return nil
+}
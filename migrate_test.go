@@ -0,0 +1,168 @@
+package fastnet
+
+import (
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"golang.org/x/sys/unix"
+)
+
+// counterServer：回复 "<name><消息序号> <消息>"，消息序号保存在连接的 KeyValueContext 中
+type counterServer struct {
+	name      string
+	connected chan *connection.Connection
+	closed    chan struct{}
+}
+
+func (s *counterServer) OnConnect(c *connection.Connection) {
+	s.connected <- c
+}
+
+func (s *counterServer) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	v, _ := c.Get("count")
+	count, _ := v.(int)
+	count++
+	c.Set("count", count)
+	return []byte(s.name + strconv.Itoa(count) + " " + string(data))
+}
+
+func (s *counterServer) OnClose(c *connection.Connection) {
+	if s.closed != nil {
+		close(s.closed)
+	}
+}
+
+func request(t *testing.T, conn net.Conn, msg, expect string) {
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(expect))
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != expect {
+		t.Fatalf("expect %q, but got %q", expect, got)
+	}
+}
+
+func TestServer_Adopt(t *testing.T) {
+	if os.Getenv("FASTNET_MIGRATE_SOCK") != "" {
+		t.Skip("running as successor")
+	}
+	handler := &counterServer{name: "A", connected: make(chan *connection.Connection, 1)}
+	s, err := NewServer(handler, Address("127.0.0.1:0"), NumLoops(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	// 后继进程：重新执行测试程序，运行 TestServer_AdoptSuccessor
+	sock := filepath.Join(t.TempDir(), "migrate.sock")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: sock, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestServer_AdoptSuccessor$", "-test.v")
+	cmd.Env = append(os.Environ(), "FASTNET_MIGRATE_SOCK="+sock)
+	output := make(chan []byte, 1)
+	go func() {
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			out = append(out, err.Error()...)
+		}
+		output <- out
+	}()
+
+	client, err := net.Dial("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	request(t, client, "hello", "A1 hello")
+
+	// 冻结连接，连同消息序号一起交给后继进程
+	c := <-handler.connected
+	fd, snap, err := c.Freeze(func(c *connection.Connection) ([]byte, error) {
+		v, _ := c.Get("count")
+		return []byte(strconv.Itoa(v.(int))), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = ln.SetDeadline(time.Now().Add(10 * time.Second))
+	successor, err := ln.AcceptUnix()
+	if err != nil {
+		t.Fatal(err, string(<-output))
+	}
+	defer successor.Close()
+	if err := connection.SendSnapshot(successor, fd, snap); err != nil {
+		t.Fatal(err)
+	}
+	_ = unix.Close(fd)
+
+	// 同一个 TCP 连接由后继进程继续处理，消息序号延续
+	request(t, client, "world", "B2 world")
+	_ = client.Close()
+
+	select {
+	case out := <-output:
+		if cmd.ProcessState == nil || !cmd.ProcessState.Success() {
+			t.Fatalf("successor failed:\n%s", out)
+		}
+	case <-time.After(10 * time.Second):
+		_ = cmd.Process.Kill()
+		t.Fatal("successor should exit after the client closed")
+	}
+}
+
+// TestServer_AdoptSuccessor：作为后继进程接管 TestServer_Adopt 迁移过来的连接
+func TestServer_AdoptSuccessor(t *testing.T) {
+	sock := os.Getenv("FASTNET_MIGRATE_SOCK")
+	if sock == "" {
+		t.Skip("only runs as successor of TestServer_Adopt")
+	}
+	handler := &counterServer{name: "B", connected: make(chan *connection.Connection, 1), closed: make(chan struct{})}
+	s, err := NewServer(handler, Address("127.0.0.1:0"), NumLoops(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	conn, err := net.DialUnix("unix", nil, &net.UnixAddr{Name: sock, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fd, snap, err := connection.ReceiveSnapshot(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Adopt(fd, snap, func(c *connection.Connection, state []byte) error {
+		count, err := strconv.Atoi(string(state))
+		c.Set("count", count)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-handler.closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("migrated connection should be closed by the client")
+	}
+	if len(handler.connected) != 0 {
+		t.Fatal("OnConnect should not be called for adopted connections")
+	}
+}
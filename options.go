@@ -4,6 +4,9 @@ import (
 	"time"
 
 	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/tool/clock"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer/pool"
+	"golang.org/x/sys/unix"
 )
 
 // Options：服务配置
@@ -12,11 +15,73 @@ type Options struct {
 	Address   string				// 监听端口地址
 	NumLoops  int					// work 协程个数，负责处理已连接客户端的读写事件
 	ReusePort bool					// 是否开启端口复用
+	ListenerPerLoop bool			// 每个 work 事件循环各自通过 SO_REUSEPORT 监听并 Accept
+	LoadBalancer LoadBalancer		// 为新连接选择 work 事件循环的方式，nil 时轮流选择
 
 	tick      time.Duration			// 事件持续
 	wheelSize int64
 	IdleTime  time.Duration			// 最大空闲时间（秒）
 	Protocol  connection.Protocol	// 连接协议
+
+	UDPBatchSize int				// UDP 模式下每次 recvmmsg 读取的数据报个数
+
+	AllowHalfClose bool				// 对端关闭写端后是否保持连接继续发送
+
+	MaxConnections int				// 最大连接数，超过时新连接直接关闭，0 表示不限制
+	Preallocate    bool				// 是否在启动时按 MaxConnections 预分配全部连接状态
+
+	SpinBeforeBlock int				// work eventloop 阻塞等待事件前非阻塞轮询的次数
+
+	MaxLoopQueue      int			// 每个事件循环等待执行的任务数上限，0 表示不限制
+	LoopQueueBlocking bool			// 达到 MaxLoopQueue 时 Send 阻塞等待而不是返回错误
+
+	AuditSink AuditLogger			// 连接生命周期审计日志输出
+
+	Middlewares []Middleware		// Handler 中间件
+
+	MaxReadBufferSize int			// 每个连接未能拆包的数据上限，0 表示不限制
+
+	MaxPendingResponses int			// 每个连接待写出的响应个数上限，达到时暂停读取新的请求，0 表示不限制
+
+	MaxWriteBufferSize int							// 每个连接写缓冲区中积压的数据上限，0 表示不限制
+	BufferFullPolicy   connection.BufferFullPolicy	// 写缓冲区达到上限时的处理策略，默认 DropNewest
+
+	MaxTotalBufferBytes int64		// 所有连接读写缓冲区容量之和的上限，0 表示不限制
+
+	WriteBatching bool				// 是否合并同一轮事件循环中的多次 Send
+
+	Deduplicator connection.Deduplicator	// 所有连接共享的消息去重，nil 时不去重
+
+	AcceptBatch int					// 每次监听可读事件最多 Accept 的连接数，小于等于 1 时逐个 Accept
+
+	BufferPool *pool.RingBufferPool	// 连接读写缓冲区的来源，nil 时使用 pool.DefaultPool
+
+	GoroutinePerConnection bool		// 是否为每个连接启动独占的 goroutine 调用 Handler
+	MaxConcurrentMessages  int		// GoroutinePerConnection 模式下所有连接同时执行的 OnMessage 个数上限，0 表示不限制
+
+	ProtocolErrorLimit  int				// ProtocolErrorWindow 时间内允许的协议错误次数，0 表示不限制
+	ProtocolErrorWindow time.Duration
+
+	AcceptOverflow func(fd int)		// 连接因过载被拒绝时、关闭 fd 之前调用，nil 时直接关闭
+
+	FlapThreshold int				// FlapWindow 内同一来源的短连接达到该次数时拒绝其新连接，0 表示不检测
+	FlapWindow    time.Duration		// 建立后该时间内即关闭的连接记为一次抖动，同时也是统计抖动次数的窗口
+	FlapCooldown  time.Duration		// 拒绝抖动来源的新连接的时长
+
+	Clock clock.Clock				// 连接使用的时钟，nil 时由时间轮驱动
+
+	ReceiveTimestamps   bool		// 是否开启内核接收时间戳
+	HardwareTimestamps  bool		// 是否优先使用网卡硬件时间戳
+
+	EagerRead bool					// 是否在回调 OnConnect 后立即读取
+
+	Priorities bool					// 是否按连接优先级处理就绪事件
+
+	BackgroundLoops int								// 后台事件循环个数，0 表示不使用
+	Background      func(fd int, sa unix.Sockaddr) bool	// 新连接是否交给后台事件循环处理
+
+	HealthCheckAddr string			// 健康检查的监听地址，为空时与业务共用监听地址
+	HealthCheckPath string			// 健康检查的 HTTP 路径，为空时不提供健康检查
 }
 
 // Option ...
@@ -49,6 +114,10 @@ func newOptions(opt ...Option) *Options {
 	if opts.Protocol == nil {
 		opts.Protocol = &connection.DefaultProtocol{}
 	}
+	// 默认每次读取 32 个数据报
+	if opts.UDPBatchSize == 0 {
+		opts.UDPBatchSize = connection.DefaultUDPBatchSize
+	}
 
 	return &opts
 }
@@ -60,7 +129,9 @@ func ReusePort(reusePort bool) Option {
 	}
 }
 
-// Network：暂时只支持tcp
+// Network：支持 tcp、unix、udp 及 sctp。
+// sctp（sctp4、sctp6）使用一对多风格的 SCTP socket，每条 SCTP 消息与 UDP 数据报一样作为一条消息交给 OnMessage，
+// 不经过读缓冲区拼接，OnMessage 返回的数据作为一条消息回复给该消息所属的关联。单条消息最大 64KB，需要内核支持 SCTP
 func Network(n string) Option {
 	return func(o *Options) {
 		o.Network = n
@@ -94,3 +165,276 @@ func IdleTime(t time.Duration) Option {
 		o.IdleTime = t
 	}
 }
+
+// UDPBatchSize：UDP 模式下每次系统调用（recvmmsg）读取的数据报个数，设置为 1 时使用 recvfrom 逐个读取
+func UDPBatchSize(n int) Option {
+	return func(o *Options) {
+		o.UDPBatchSize = n
+	}
+}
+
+// AllowHalfClose：对端关闭写端时连接进入只写状态而不是直接关闭，并回调 Handler 的 OnReadClose（如果实现了该方法）
+func AllowHalfClose(allow bool) Option {
+	return func(o *Options) {
+		o.AllowHalfClose = allow
+	}
+}
+
+// MaxConnections：最大连接数，连接数达到上限后新建立的连接会被直接关闭
+func MaxConnections(n int) Option {
+	return func(o *Options) {
+		o.MaxConnections = n
+	}
+}
+
+// Preallocate：启动时按 MaxConnections 预分配全部 Connection 及读写缓冲区并循环复用，
+// 避免运行期间分配连接状态（空闲超时的定时器和事件循环登记 fd 仍会分配），需要同时设置 MaxConnections。
+// 开启后 OnClose 返回时 Connection 即被回收并可能分配给新的客户端，在其他 goroutine 中捕获的 *Connection
+// 在 OnClose 之后全部失效：Get、Context、PeerAddr 等可能读到新客户端的状态。
+// 连接关闭后需要继续使用时应通过 connection.Responder 发送，它记录了创建时连接的复用次数，不会发给新的客户端
+func Preallocate(b bool) Option {
+	return func(o *Options) {
+		o.Preallocate = b
+	}
+}
+
+// SpinBeforeBlock：work eventloop 每次阻塞在 epoll_wait 之前先非阻塞轮询 n 次，
+// 以 CPU 换取更低的唤醒延迟。空闲时每个 work eventloop 每次等待都会额外进行 n 次系统调用，
+// 设置较大时会使空闲的 work 协程占满 CPU，仅适用于对延迟极其敏感且 CPU 充足的场景，默认为 0 不轮询
+func SpinBeforeBlock(n int) Option {
+	return func(o *Options) {
+		o.SpinBeforeBlock = n
+	}
+}
+
+// MaxLoopQueue：每个事件循环等待执行的任务数上限，生产者调用 Send 的速度超过事件循环的处理速度时，
+// 任务数达到上限后 Send 返回 eventloop.ErrQueueFull，调用方可以据此降低速度，避免任务队列无限增长，0 表示不限制（默认）
+func MaxLoopQueue(n int) Option {
+	return func(o *Options) {
+		o.MaxLoopQueue = n
+	}
+}
+
+// LoopQueueBlocking：达到 MaxLoopQueue 设置的上限时 Send 阻塞等待事件循环取走任务，而不是返回 eventloop.ErrQueueFull。
+// 只适用于在事件循环之外发送数据的场景，在 OnMessage 等回调中调用 Send 会因等待自身所在的事件循环而死锁
+func LoopQueueBlocking(enable bool) Option {
+	return func(o *Options) {
+		o.LoopQueueBlocking = enable
+	}
+}
+
+// AuditSink：设置连接生命周期审计日志输出，记录每个连接的接受、拒绝、建立及关闭，
+// 事件通过队列异步交给 l，不会阻塞事件循环，队列满时丢弃
+func AuditSink(l AuditLogger) Option {
+	return func(o *Options) {
+		o.AuditSink = l
+	}
+}
+
+// MaxReadBufferSize：每个连接读缓冲区中未能拆包的数据上限，超过时关闭连接，
+// 关闭原因为 connection.ErrReadBufferOverflow，0 表示不限制
+func MaxReadBufferSize(n int) Option {
+	return func(o *Options) {
+		o.MaxReadBufferSize = n
+	}
+}
+
+// MaxPendingResponses：每个连接待写出的响应达到 n 个时暂停读取新的请求，客户端读走响应、全部写出后恢复。
+// 用于流水线协议，防止持续发送请求却很少读取响应的客户端使服务端的响应无限堆积，0 表示不限制
+func MaxPendingResponses(n int) Option {
+	return func(o *Options) {
+		o.MaxPendingResponses = n
+	}
+}
+
+// MaxWriteBufferSize：每个连接写缓冲区中积压的数据上限，对端读取过慢使积压超过上限时，
+// 回调 Handler 的 OnBufferFull（如果实现了 connection.BufferFullCallBack），并按 WriteBufferFullPolicy 设置的策略处理，
+// 防止单个停止读取的对端耗尽内存，0 表示不限制
+func MaxWriteBufferSize(n int) Option {
+	return func(o *Options) {
+		o.MaxWriteBufferSize = n
+	}
+}
+
+// WriteBufferFullPolicy：写缓冲区达到 MaxWriteBufferSize 设置的上限时的处理策略，
+// 可选 connection.DropNewest（默认）、connection.CloseConn 及 connection.Block
+func WriteBufferFullPolicy(p connection.BufferFullPolicy) Option {
+	return func(o *Options) {
+		o.BufferFullPolicy = p
+	}
+}
+
+// MaxTotalBufferBytes：所有连接读写缓冲区容量之和的上限，作为全局的内存保护。
+// 达到上限后不再接受新连接，已有连接的缓冲区扩容导致超出上限时关闭该连接，
+// 关闭原因为 connection.ErrBufferBudgetExceeded，0 表示不限制
+func MaxTotalBufferBytes(n int64) Option {
+	return func(o *Options) {
+		o.MaxTotalBufferBytes = n
+	}
+}
+
+// ListenerPerLoop：每个 work 事件循环各自打开一个设置了 SO_REUSEPORT 的监听 socket 并独立 Accept，
+// 由内核在这些 socket 之间分配新连接，连接留在 Accept 它的事件循环中处理。
+// 事件循环较多时避免单个监听 socket 成为 Accept 的瓶颈，参见 BenchmarkServer_Accept。
+// 内核不支持 SO_REUSEPORT 等原因无法创建时记录日志并退回到由主事件循环 Accept 的单个监听 socket。
+// 只用于流式网络，与 ReusePort 不同，后者只是在单个监听 socket 上设置 SO_REUSEPORT 以便多个进程共享端口
+func ListenerPerLoop(enable bool) Option {
+	return func(o *Options) {
+		o.ListenerPerLoop = enable
+	}
+}
+
+// Balancer：设置为新连接选择 work 事件循环的方式，例如 RoundRobin 或 LeastConnection。
+// ListenerPerLoop 时连接由内核分配到各个事件循环的监听 socket，不使用 LoadBalancer
+func Balancer(lb LoadBalancer) Option {
+	return func(o *Options) {
+		o.LoadBalancer = lb
+	}
+}
+
+// WriteBatching：合并每个连接同一轮事件循环中的多次 Send，经过协议打包后通过一次 writev 写出，
+// 在循环中连续 Send 大量小消息时可以将写系统调用从每条消息一次降为每轮事件循环一次，
+// 参见 connection 包的 BenchmarkConnection_SendSmall
+func WriteBatching(enable bool) Option {
+	return func(o *Options) {
+		o.WriteBatching = enable
+	}
+}
+
+// Deduplicator：所有连接共享 d 对拆出的消息去重，重复的消息在 OnMessage 之前丢弃，
+// 用于至少一次投递的协议在客户端重连、重试后重复发送消息的场景，可以使用 connection.NewLRUDeduplicator
+func Deduplicator(d connection.Deduplicator) Option {
+	return func(o *Options) {
+		o.Deduplicator = d
+	}
+}
+
+// AcceptBatch：开启批量 Accept，每次监听可读事件最多 Accept n 个连接并一起建立，
+// 减少连接风暴时事件循环唤醒与系统调用的次数，可以配合 Preallocate 使用
+func AcceptBatch(n int) Option {
+	return func(o *Options) {
+		o.AcceptBatch = n
+	}
+}
+
+// BufferPool：所有连接从 p 获取读写缓冲区，可以通过 pool.New 指定缓冲区的初始大小，
+// 例如为传输大文件的服务使用较大的缓冲区，减少扩容次数
+func BufferPool(p *pool.RingBufferPool) Option {
+	return func(o *Options) {
+		o.BufferPool = p
+	}
+}
+
+// GoroutinePerConnection：为每个连接启动一个独占的 goroutine 调用 Handler，读写及拆包仍在事件循环中进行，
+// 拆出的消息按顺序投递给该 goroutine，OnMessage 返回的数据通过 Send 发送。
+// 同一连接的 OnConnect、OnMessage、OnClose 均在该 goroutine 中依次调用，因此可以执行阻塞操作而不影响其他连接，
+// OnClose 时尚未处理的消息会被丢弃。Handler 实现的 BatchCallBack 等可选接口在该模式下不生效，且不能与 Preallocate 同时使用。
+//
+// 代价是内存：每个 goroutine 的栈至少 2KB，处理消息时会按需增长到 8KB 甚至更多，
+// 10 万连接约需额外 200MB~1GB 内存，消息处理较慢时排队的消息也会占用内存，连接数很多时应优先使用默认模式
+func GoroutinePerConnection() Option {
+	return func(o *Options) {
+		o.GoroutinePerConnection = true
+	}
+}
+
+// MaxConcurrentMessages：GoroutinePerConnection 模式下限制所有连接同时执行的 OnMessage 个数，
+// 用于保护数据库连接池等共享的下游资源，与连接数无关。达到上限时新消息在连接的队列中等待，
+// 等待名额的连接暂停读取，由 TCP 流量控制将压力传递给客户端，获取到名额后恢复读取（会覆盖 PauseRead 的效果）。
+// 只能与 GoroutinePerConnection 同时使用，n 小于等于 0 时不限制
+func MaxConcurrentMessages(n int) Option {
+	return func(o *Options) {
+		o.MaxConcurrentMessages = n
+	}
+}
+
+// ProtocolErrorLimit：协议在 window 时间内通过 ReportProtocolError 报告的错误达到 n 次时关闭连接，
+// 用于断开持续发送非法数据的客户端，window 为 0 时不限时间
+func ProtocolErrorLimit(n int, window time.Duration) Option {
+	return func(o *Options) {
+		o.ProtocolErrorLimit = n
+		o.ProtocolErrorWindow = window
+	}
+}
+
+// OnAcceptOverflow：服务过载（达到 MaxConnections 或 MaxTotalBufferBytes）或来源 IP 处于 FlapGuard 的冷却期而拒绝新连接时，
+// 在关闭 fd 之前调用 f，用于向客户端发送明确的拒绝信号（如 HTTP 503 或自定义的字节序列），
+// 而不是让客户端只看到连接被关闭。f 在主事件循环中调用，不能阻塞也不能保留 fd，可以使用 RejectWith 构造。
+// 未设置时被拒绝的连接直接关闭
+func OnAcceptOverflow(f func(fd int)) Option {
+	return func(o *Options) {
+		o.AcceptOverflow = f
+	}
+}
+
+// FlapGuard：检测频繁建立又断开的来源 IP（通常是配置错误的客户端重试循环）。建立后 window 内即关闭的连接记为一次抖动，
+// 同一来源 IP 在 window 内抖动 threshold 次后，cooldown 时间内该来源的新连接在 Accept 后立即关闭（同样会调用 OnAcceptOverflow），
+// 不再创建连接、回调 OnConnect，避免反复建立、关闭连接消耗 CPU。NAT 之后的多个客户端共享同一个来源 IP，阈值不宜过低
+func FlapGuard(threshold int, window, cooldown time.Duration) Option {
+	return func(o *Options) {
+		o.FlapThreshold = threshold
+		o.FlapWindow = window
+		o.FlapCooldown = cooldown
+	}
+}
+
+// Clock：连接的空闲超时、SendWithDeadline 等功能使用 c 计时，代替默认的时间轮，
+// 主要用于测试时注入 clock.Fake 推进虚拟时间。RunAfter 及 RunEvery 仍使用时间轮
+func Clock(c clock.Clock) Option {
+	return func(o *Options) {
+		o.Clock = c
+	}
+}
+
+// ReceiveTimestamps：为所有 TCP 连接开启 SO_TIMESTAMPING 接收时间戳，在 OnMessage 中通过 Connection.ReceiveTime 获取，
+// hardware 为 true 时优先使用网卡硬件时间戳，需要网卡及驱动支持
+func ReceiveTimestamps(hardware bool) Option {
+	return func(o *Options) {
+		o.ReceiveTimestamps = true
+		o.HardwareTimestamps = hardware
+	}
+}
+
+// EagerRead：适用于客户端建立连接后立即发送数据的协议（如 TCP Fast Open 或流水线请求）。
+// 开启后在连接所属的 work 事件循环中回调 OnConnect 后立即尝试读取，
+// 如果首个请求已经到达，OnConnect 与首个 OnMessage 在同一次事件循环迭代中依次回调，
+// 省去等待 epoll 通知可读的一次调度，降低建立连接的延迟。
+// OnConnect 仍然先于该连接的任何 OnMessage 回调，没有数据时立即读取只多一次返回 EAGAIN 的系统调用
+func EagerRead() Option {
+	return func(o *Options) {
+		o.EagerRead = true
+	}
+}
+
+// Priorities：开启连接优先级调度，work 事件循环每批就绪事件中，通过 Connection.SetPriority 设置了较高优先级的连接先处理，
+// 使控制、管理类连接在大量数据连接占满事件循环时仍能及时响应。
+// 排序只发生在同一批就绪事件内部，低优先级的连接每批仍会被处理，不会被饿死；代价是每批事件多一次排序
+func Priorities() Option {
+	return func(o *Options) {
+		o.Priorities = true
+	}
+}
+
+// BackgroundLoops：创建 n 个低优先级的后台事件循环，classify 对新连接返回 true 时（如管理端口、已知的保活客户端）
+// 将其交给后台事件循环处理，其余连接照常分配给 work 事件循环，使后台连接的流量与 work 事件循环上的数据连接在物理上隔离。
+// 后台事件循环各自独占一个线程，线程的 nice 值调为 BackgroundNice，CPU 紧张时由内核优先调度 work 事件循环。
+// classify 在主事件循环中调用，不能阻塞，可以通过 sa 判断对端地址或通过 getsockname 判断连接的是哪个端口
+func BackgroundLoops(n int, classify func(fd int, sa unix.Sockaddr) bool) Option {
+	return func(o *Options) {
+		o.BackgroundLoops = n
+		o.Background = classify
+	}
+}
+
+// HealthCheck：提供供负载均衡器探测的健康检查，对 GET path 返回 200，Drain 之后返回 503，path 为空时使用 DefaultHealthPath。
+// addr 不为空时在 addr 上单独监听 HTTP；为空时与业务共用监听地址，连接的第一个请求是 GET path 时直接回复并关闭连接，
+// 其余连接照常交给业务协议，UDP 及 SCTP 模式下 addr 不能为空。只做 TCP 连接探测时无需开启
+func HealthCheck(addr, path string) Option {
+	return func(o *Options) {
+		if path == "" {
+			path = DefaultHealthPath
+		}
+		o.HealthCheckAddr = addr
+		o.HealthCheckPath = path
+	}
+}
@@ -0,0 +1,29 @@
+package fastnet
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// RejectWith：返回一个用于 OnAcceptOverflow 的函数，向被拒绝的连接写出 data（如 HTTP 503 响应）后关闭写端，
+// 并读出客户端已发送的数据，避免关闭时内核因接收缓冲区中有未读数据而发送 RST 导致客户端收不到 data。
+// 写出是非阻塞的，data 应足够小以便一次写入 socket 发送缓冲区
+func RejectWith(data []byte) func(fd int) {
+	return func(fd int) {
+		if _, err := unix.Write(fd, data); err != nil {
+			return
+		}
+		_ = unix.Shutdown(fd, unix.SHUT_WR)
+		drain(fd)
+	}
+}
+
+// drain：读出 fd 中已到达的数据，直到 EAGAIN
+func drain(fd int) {
+	var buf [512]byte
+	for {
+		n, err := unix.Read(fd, buf[:])
+		if n <= 0 || err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package fastnet
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	stdsync "sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const serviceUnavailable = "HTTP/1.1 503 Service Unavailable\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"
+
+func TestOnAcceptOverflow(t *testing.T) {
+	var rejected int32
+	reply := RejectWith([]byte(serviceUnavailable))
+	s, err := NewServer(new(greeter),
+		Address("127.0.0.1:0"),
+		NumLoops(2),
+		MaxConnections(2),
+		OnAcceptOverflow(func(fd int) {
+			atomic.AddInt32(&rejected, 1)
+			reply(fd)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+	addr := s.Addr()
+
+	// 占满连接数
+	for i := 0; i < 2; i++ {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second * 3))
+		if _, err := conn.Read(make([]byte, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 之后的连接都收到拒绝信号而不是被重置
+	const n = 50
+	var wg stdsync.WaitGroup
+	errs := make(chan error, n)
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			conn, err := net.DialTimeout("tcp", addr, time.Second*3)
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer conn.Close()
+			_ = conn.SetDeadline(time.Now().Add(time.Second * 3))
+			if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\n\r\n")); err != nil {
+				errs <- err
+				return
+			}
+			data, err := ioutil.ReadAll(conn)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if string(data) != serviceUnavailable {
+				errs <- fmt.Errorf("unexpected reply %q", data)
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&rejected); got != n {
+		t.Fatalf("expect %d rejected connections, but got %d", n, got)
+	}
+}
@@ -0,0 +1,48 @@
+package fastnet
+
+import (
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/eventloop"
+)
+
+// Pause：暂停所有连接的读取，连接保持打开，之后建立的连接同样不读取，直到调用 Resume。
+// 暂停期间不会回调 OnMessage，待发送的数据照常写出，可以用于维护窗口、一致的状态快照或原子地更新配置。
+// 对端发送的数据会堆积在内核接收缓冲区中，缓冲区满后对端的发送被 TCP 流量控制阻塞，暂停时间过长可能导致对端超时。
+// Pause 等待所有事件循环应用暂停后返回，需要在 Start 之后调用，且不能在 Handler 的回调中调用
+func (s *Server) Pause() {
+	s.setPaused(true)
+}
+
+// Resume：恢复 Pause 暂停的读取，等待所有事件循环应用后返回，调用限制同 Pause
+func (s *Server) Resume() {
+	s.setPaused(false)
+}
+
+// setPaused：在主事件循环中更新暂停状态，再逐个事件循环更新已有连接，保证与新建立的连接之间的顺序
+func (s *Server) setPaused(paused bool) {
+	loops := s.connLoops()
+	done := make(chan struct{}, len(loops))
+	s.loop.QueueInLoop(func() {
+		_ = s.paused.Set(paused)
+		for _, loop := range loops {
+			loop := loop
+			loop.QueueInLoop(func() {
+				loop.RangeSockets(func(fd int, socket eventloop.Socket) bool {
+					if c, ok := socket.(*connection.Connection); ok {
+						if paused {
+							_ = c.PauseRead()
+						} else {
+							_ = c.ResumeRead()
+						}
+					}
+					return true
+				})
+				// PauseRead 及 ResumeRead 排在事件循环的待执行队列中，在它们之后通知完成
+				loop.QueueInLoop(func() { done <- struct{}{} })
+			})
+		}
+	})
+	for range loops {
+		<-done
+	}
+}
@@ -0,0 +1,99 @@
+package fastnet
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+)
+
+// countingEcho：回显并统计 OnMessage 次数
+type countingEcho struct {
+	messages int32
+}
+
+func (s *countingEcho) OnConnect(c *connection.Connection) {}
+
+func (s *countingEcho) OnMessage(c *connection.Connection, ctx interface{}, data []byte) (out []byte) {
+	atomic.AddInt32(&s.messages, 1)
+	return data
+}
+
+func (s *countingEcho) OnClose(c *connection.Connection) {}
+
+// expectEcho：发送 msg 后在 timeout 内读到同样的数据
+func expectEcho(t *testing.T, conn net.Conn, msg string) {
+	if _, err := conn.Write([]byte(msg)); err != nil {
+		t.Fatal(err)
+	}
+	expectRead(t, conn, msg, time.Second*3)
+}
+
+func expectRead(t *testing.T, conn net.Conn, msg string, timeout time.Duration) {
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, len(msg))
+	n := 0
+	for n < len(buf) {
+		m, err := conn.Read(buf[n:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		n += m
+	}
+	if string(buf) != msg {
+		t.Fatalf("expect %q, but got %q", msg, buf)
+	}
+}
+
+func TestServer_PauseResume(t *testing.T) {
+	handler := new(countingEcho)
+	s, err := NewServer(handler,
+		Address("127.0.0.1:0"),
+		NumLoops(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	conns := make([]net.Conn, 2)
+	for i := range conns {
+		if conns[i], err = net.DialTimeout("tcp", s.Addr(), time.Second); err != nil {
+			t.Fatal(err)
+		}
+		defer conns[i].Close()
+		expectEcho(t, conns[i], "before")
+	}
+
+	s.Pause()
+	before := atomic.LoadInt32(&handler.messages)
+	// 暂停期间建立的连接同样不读取
+	late, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer late.Close()
+	conns = append(conns, late)
+	for _, conn := range conns {
+		if _, err := conn.Write([]byte("paused")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(time.Millisecond * 200)
+	if n := atomic.LoadInt32(&handler.messages); n != before {
+		t.Fatalf("OnMessage should not be called while paused, but got %d calls", n-before)
+	}
+	_ = conns[0].SetReadDeadline(time.Now().Add(time.Millisecond * 50))
+	if _, err := conns[0].Read(make([]byte, 1)); err == nil {
+		t.Fatal("nothing should be echoed while paused")
+	}
+
+	// 恢复后处理暂停期间堆积的数据
+	s.Resume()
+	for _, conn := range conns {
+		expectRead(t, conn, "paused", time.Second*3)
+		expectEcho(t, conn, "after")
+	}
+}
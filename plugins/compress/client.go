@@ -0,0 +1,125 @@
+package compress
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+)
+
+// Conn：压缩协议的客户端实现，包装一个 net.Conn
+type Conn struct {
+	net.Conn
+	rd           *bufio.Reader
+	codec        Codec  // 协商选定的算法，不压缩时为 nil
+	pending      []byte // 已收到但未被读取的数据
+	minSize      int
+	maxFrameSize int
+}
+
+// Client：在 conn 上发起压缩协商，codecs 为客户端支持的算法，按偏好排列，协商完成后返回
+func Client(conn net.Conn, codecs ...Codec) (*Conn, error) {
+	c := &Conn{Conn: conn, rd: bufio.NewReader(conn), minSize: 256, maxFrameSize: 1 << 20}
+	names := make([]string, 0, len(codecs))
+	for _, codec := range codecs {
+		names = append(names, codec.Name())
+	}
+	if _, err := conn.Write(appendFrame(nil, frameOffer, []byte(strings.Join(names, ",")))); err != nil {
+		return nil, err
+	}
+
+	// 等待服务端的选择，期间收到的数据帧保留给 Read
+	for {
+		typ, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		if typ != frameSelect {
+			if err := c.deliver(typ, payload); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		for _, codec := range codecs {
+			if codec.Name() == string(payload) {
+				c.codec = codec
+			}
+		}
+		if c.codec == nil && len(payload) > 0 {
+			return nil, ErrBadFrame
+		}
+		return c, nil
+	}
+}
+
+// Algorithm：协商选定的算法名，不压缩时返回空字符串
+func (c *Conn) Algorithm() string {
+	if c.codec == nil {
+		return ""
+	}
+	return c.codec.Name()
+}
+
+// SetMinSize：设置尝试压缩的最小长度，默认 256 字节
+func (c *Conn) SetMinSize(n int) {
+	c.minSize = n
+}
+
+// Read：读取解压后的数据
+func (c *Conn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		typ, payload, err := c.readFrame()
+		if err != nil {
+			return 0, err
+		}
+		if err := c.deliver(typ, payload); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write：将 p 按需压缩后封装为一帧写出
+func (c *Conn) Write(p []byte) (int, error) {
+	if _, err := c.Conn.Write(encode(c.codec, c.minSize, p)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// readFrame：读取一个完整的帧
+func (c *Conn) readFrame() (byte, []byte, error) {
+	var header [headerLen]byte
+	if _, err := io.ReadFull(c.rd, header[:]); err != nil {
+		return 0, nil, err
+	}
+	n := int(binary.BigEndian.Uint32(header[1:]))
+	if n > c.maxFrameSize {
+		return 0, nil, ErrFrameTooLarge
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.rd, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// deliver：将数据帧的内容追加到 pending
+func (c *Conn) deliver(typ byte, payload []byte) error {
+	switch {
+	case typ == frameRaw:
+		c.pending = append(c.pending, payload...)
+	case typ == frameCompressed && c.codec != nil:
+		data, err := c.codec.Decompress(payload, c.maxFrameSize)
+		if err != nil {
+			return err
+		}
+		c.pending = append(c.pending, data...)
+	default:
+		return ErrBadFrame
+	}
+	return nil
+}
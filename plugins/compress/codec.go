@@ -0,0 +1,104 @@
+// Package compress 提供连接级的压缩协商，双方交换各自支持的压缩算法并选定一种，
+// 之后的帧按需透明压缩，可以包装任意 connection.Protocol
+package compress
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// ErrTooLarge：解压后的数据超过限制
+var ErrTooLarge = errors.New("compress: decompressed data exceeds limit")
+
+// Codec：压缩算法，Name 用于协商，需要在双方之间保持一致
+type Codec interface {
+	// Name：算法名，如 gzip
+	Name() string
+	// Compress：压缩 src 并追加到 dst
+	Compress(dst, src []byte) ([]byte, error)
+	// Decompress：解压 src，解压后的数据超过 limit 时返回 ErrTooLarge
+	Decompress(src []byte, limit int) ([]byte, error)
+}
+
+// Gzip：基于 compress/gzip 的 Codec，level 同 gzip.NewWriterLevel
+func Gzip(level int) Codec {
+	return &gzipCodec{level: level}
+}
+
+// Deflate：基于 compress/flate 的 Codec，level 同 flate.NewWriter
+func Deflate(level int) Codec {
+	return &deflateCodec{level: level}
+}
+
+type gzipCodec struct {
+	level int
+}
+
+func (g *gzipCodec) Name() string { return "gzip" }
+
+func (g *gzipCodec) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w, err := gzip.NewWriterLevel(buf, g.level)
+	if err != nil {
+		return dst, err
+	}
+	if _, err := w.Write(src); err != nil {
+		return dst, err
+	}
+	if err := w.Close(); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (g *gzipCodec) Decompress(src []byte, limit int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return readLimited(r, limit)
+}
+
+type deflateCodec struct {
+	level int
+}
+
+func (d *deflateCodec) Name() string { return "deflate" }
+
+func (d *deflateCodec) Compress(dst, src []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(dst)
+	w, err := flate.NewWriter(buf, d.level)
+	if err != nil {
+		return dst, err
+	}
+	if _, err := w.Write(src); err != nil {
+		return dst, err
+	}
+	if err := w.Close(); err != nil {
+		return dst, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (d *deflateCodec) Decompress(src []byte, limit int) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(src))
+	defer r.Close()
+	return readLimited(r, limit)
+}
+
+// readLimited：读取 r 的全部内容，超过 limit 时返回 ErrTooLarge，防止解压炸弹
+func readLimited(r io.Reader, limit int) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
@@ -0,0 +1,132 @@
+package compress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+)
+
+// zstdStub：只用于协商的算法，服务端不支持，不应被选中
+type zstdStub struct{}
+
+func (zstdStub) Name() string { return "zstd" }
+func (zstdStub) Compress(dst, src []byte) ([]byte, error) {
+	return dst, errors.New("zstd should not be negotiated")
+}
+func (zstdStub) Decompress(src []byte, limit int) ([]byte, error) {
+	return nil, errors.New("zstd should not be negotiated")
+}
+
+// countingCodec：统计解压次数的 Codec
+type countingCodec struct {
+	Codec
+	decompressed int32
+}
+
+func (c *countingCodec) Decompress(src []byte, limit int) ([]byte, error) {
+	atomic.AddInt32(&c.decompressed, 1)
+	return c.Codec.Decompress(src, limit)
+}
+
+type echoServer struct {
+	algorithm chan string
+}
+
+func (s *echoServer) OnConnect(c *connection.Connection) {}
+func (s *echoServer) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	select {
+	case s.algorithm <- Algorithm(c):
+	default:
+	}
+	return data
+}
+func (s *echoServer) OnClose(c *connection.Connection) {}
+
+func TestCompress_Negotiate(t *testing.T) {
+	codec := &countingCodec{Codec: Gzip(gzip.DefaultCompression)}
+	handler := &echoServer{algorithm: make(chan string, 1)}
+	s, err := fastnet.NewServer(handler,
+		fastnet.Address("127.0.0.1:0"),
+		fastnet.NumLoops(1),
+		fastnet.Protocol(New(&connection.DefaultProtocol{}, codec)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(time.Second * 3))
+	cc, err := Client(conn, zstdStub{}, Gzip(gzip.BestSpeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cc.Algorithm() != "gzip" {
+		t.Fatalf("expect gzip, but got %q", cc.Algorithm())
+	}
+
+	// 可压缩的数据以压缩帧发送
+	text := bytes.Repeat([]byte("fastnet compression "), 100)
+	if _, err := cc.Write(text); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(text))
+	if _, err := io.ReadFull(cc, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, text) {
+		t.Fatal("echo mismatch")
+	}
+	if name := <-handler.algorithm; name != "gzip" {
+		t.Fatalf("server should negotiate gzip, but got %q", name)
+	}
+	if n := atomic.LoadInt32(&codec.decompressed); n != 1 {
+		t.Fatalf("expect 1 compressed frame, but got %d", n)
+	}
+
+	// 随机数据无法压缩，按原样发送
+	random := make([]byte, 4096)
+	_, _ = rand.Read(random)
+	if _, err := cc.Write(random); err != nil {
+		t.Fatal(err)
+	}
+	got = make([]byte, len(random))
+	if _, err := io.ReadFull(cc, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, random) {
+		t.Fatal("echo mismatch")
+	}
+	if n := atomic.LoadInt32(&codec.decompressed); n != 1 {
+		t.Fatalf("incompressible frame should be sent raw, but got %d compressed frames", n)
+	}
+}
+
+func TestCodec_Limit(t *testing.T) {
+	for _, codec := range []Codec{Gzip(gzip.DefaultCompression), Deflate(gzip.DefaultCompression)} {
+		data, err := codec.Compress(nil, make([]byte, 1<<16))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := codec.Decompress(data, 1<<10); err != ErrTooLarge {
+			t.Fatalf("%s: expect ErrTooLarge, but got %v", codec.Name(), err)
+		}
+		plain, err := codec.Decompress(data, 1<<16)
+		if err != nil || len(plain) != 1<<16 {
+			t.Fatalf("%s: decompress failed, %d %v", codec.Name(), len(plain), err)
+		}
+	}
+}
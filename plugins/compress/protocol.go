@@ -0,0 +1,166 @@
+package compress
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// headerLen：帧头长度，帧格式为 [1 字节类型][4 字节大端长度][内容]
+const headerLen = 5
+
+// 帧类型
+const (
+	frameRaw        byte = iota // 未压缩的数据
+	frameCompressed             // 使用协商的算法压缩的数据
+	frameOffer                  // 客户端支持的算法，按偏好排列，以逗号分隔
+	frameSelect                 // 服务端选定的算法，为空表示不压缩
+)
+
+const sessionKey = "fastnet_compress_session"
+
+// 帧相关错误
+var (
+	ErrBadFrame      = errors.New("compress: malformed frame")
+	ErrFrameTooLarge = errors.New("compress: frame exceeds max frame size")
+)
+
+// session：单个连接的协商状态
+type session struct {
+	codec     Codec                  // 协商选定的算法，未协商或不压缩时为 nil，只在事件循环中访问
+	algorithm atomic.Value           // string，选定的算法名，供其他 goroutine 读取
+	plain     *ringbuffer.RingBuffer // 解压后的数据，交给内层协议拆包
+}
+
+// Protocol：带压缩协商的协议。客户端连接后发送支持的算法列表，服务端按客户端的偏好选出第一个
+// 双方都支持的算法并回复，之后每一帧独立决定是否压缩：短于 SetMinSize 设置的长度或压缩后没有变小
+// （如已经压缩过的数据）的帧按原样发送。协商完成之前的帧总是按原样发送
+type Protocol struct {
+	inner        connection.Protocol
+	codecs       []Codec
+	minSize      int
+	maxFrameSize int
+}
+
+var _ connection.Protocol = &Protocol{}
+
+// New：创建压缩协议，inner 为内层协议，为 nil 时使用 connection.DefaultProtocol，codecs 为服务端支持的算法
+func New(inner connection.Protocol, codecs ...Codec) *Protocol {
+	if inner == nil {
+		inner = &connection.DefaultProtocol{}
+	}
+	return &Protocol{inner: inner, codecs: codecs, minSize: 256, maxFrameSize: 1 << 20}
+}
+
+// SetMinSize：设置尝试压缩的最小长度，默认 256 字节，更短的数据压缩收益很小
+func (p *Protocol) SetMinSize(n int) {
+	p.minSize = n
+}
+
+// Algorithm：连接协商选定的算法名，尚未协商或不压缩时返回空字符串，可以在任意 goroutine 中调用
+func Algorithm(c *connection.Connection) string {
+	v, ok := c.Get(sessionKey)
+	if !ok {
+		return ""
+	}
+	name, _ := v.(*session).algorithm.Load().(string)
+	return name
+}
+
+// session：获取连接的状态，不存在时创建
+func (p *Protocol) session(c *connection.Connection) *session {
+	if v, ok := c.Get(sessionKey); ok {
+		return v.(*session)
+	}
+	s := &session{plain: ringbuffer.New(1024)}
+	c.Set(sessionKey, s)
+	return s
+}
+
+// selectCodec：按客户端的偏好选出第一个服务端也支持的算法
+func (p *Protocol) selectCodec(offer string) Codec {
+	for _, name := range strings.Split(offer, ",") {
+		for _, codec := range p.codecs {
+			if codec.Name() == name {
+				return codec
+			}
+		}
+	}
+	return nil
+}
+
+// UnPacket：拆包，处理协商帧，数据帧解压后交给内层协议
+func (p *Protocol) UnPacket(c *connection.Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	s := p.session(c)
+	for buffer.Length() >= headerLen {
+		first, end := buffer.Peek(headerLen)
+		header := append(append(make([]byte, 0, headerLen), first...), end...)
+		typ, n := header[0], int(binary.BigEndian.Uint32(header[1:]))
+		if n > p.maxFrameSize {
+			log.Error("[compress]", ErrFrameTooLarge)
+			buffer.RetrieveAll()
+			_ = c.Close()
+			return nil, nil
+		}
+		if buffer.Length() < headerLen+n {
+			break
+		}
+		buffer.Retrieve(headerLen)
+		payload := make([]byte, n)
+		_, _ = buffer.Read(payload)
+
+		switch {
+		case typ == frameRaw:
+			_, _ = s.plain.Write(payload)
+		case typ == frameCompressed && s.codec != nil:
+			data, err := s.codec.Decompress(payload, p.maxFrameSize)
+			if err != nil {
+				c.ReportProtocolError(err)
+				continue
+			}
+			_, _ = s.plain.Write(data)
+		case typ == frameOffer:
+			var name string
+			if s.codec = p.selectCodec(string(payload)); s.codec != nil {
+				name = s.codec.Name()
+			}
+			s.algorithm.Store(name)
+			c.SendInLoop(appendFrame(nil, frameSelect, []byte(name)))
+		default:
+			c.ReportProtocolError(ErrBadFrame)
+		}
+	}
+	return p.inner.UnPacket(c, s.plain)
+}
+
+// Packet：装包，内层协议打包后按需压缩并封装为帧
+func (p *Protocol) Packet(c *connection.Connection, data []byte) []byte {
+	return encode(p.session(c).codec, p.minSize, p.inner.Packet(c, data))
+}
+
+// encode：将 data 封装为帧，codec 不为 nil 且压缩后更短时使用压缩帧
+func encode(codec Codec, minSize int, data []byte) []byte {
+	if codec != nil && len(data) >= minSize {
+		frame, err := codec.Compress(make([]byte, headerLen, headerLen+len(data)), data)
+		if err == nil && len(frame) < headerLen+len(data) {
+			frame[0] = frameCompressed
+			binary.BigEndian.PutUint32(frame[1:], uint32(len(frame)-headerLen))
+			return frame
+		}
+	}
+	return appendFrame(nil, frameRaw, data)
+}
+
+// appendFrame：将 data 封装为 typ 类型的帧追加到 dst
+func appendFrame(dst []byte, typ byte, data []byte) []byte {
+	var header [headerLen]byte
+	header[0] = typ
+	binary.BigEndian.PutUint32(header[1:], uint32(len(data)))
+	dst = append(dst, header[:]...)
+	return append(dst, data...)
+}
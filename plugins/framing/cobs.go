@@ -0,0 +1,62 @@
+// Package framing 提供基于分隔符的二进制安全分帧协议，使用 COBS（Consistent Overhead Byte Stuffing）编码，
+// 编码后的数据中不会出现分隔符 0x00，因此内容可以是任意二进制数据，每 254 字节最多增加 1 字节开销。
+// 适用于串口转 TCP、嵌入式设备等使用分隔符分帧的场景
+package framing
+
+import "errors"
+
+// ErrCorrupt：不是合法的 COBS 编码
+var ErrCorrupt = errors.New("framing: corrupt cobs frame")
+
+// MaxEncodedLen：长度为 n 的数据编码后的最大长度（不含分隔符）
+func MaxEncodedLen(n int) int {
+	return n + n/254 + 1
+}
+
+// AppendEncode：将 src 的 COBS 编码追加到 dst，编码结果中不包含 0x00
+func AppendEncode(dst, src []byte) []byte {
+	codeIdx := len(dst)
+	dst = append(dst, 0)
+	code := byte(1)
+	for i, b := range src {
+		if b != 0 {
+			dst = append(dst, b)
+			code++
+			// 连续 254 个非零字节后结束当前块，数据恰好在此结束时不再开始新块
+			if code != 0xFF || i == len(src)-1 {
+				continue
+			}
+		}
+		// 遇到 0x00 或块已满，结束当前块
+		dst[codeIdx] = code
+		codeIdx = len(dst)
+		dst = append(dst, 0)
+		code = 1
+	}
+	dst[codeIdx] = code
+	return dst
+}
+
+// AppendDecode：将 COBS 编码的 src（不含分隔符）解码后追加到 dst
+func AppendDecode(dst, src []byte) ([]byte, error) {
+	for i := 0; i < len(src); {
+		code := int(src[i])
+		if code == 0 || i+code > len(src) {
+			return dst, ErrCorrupt
+		}
+		i++
+		block := src[i : i+code-1]
+		for _, b := range block {
+			if b == 0 {
+				return dst, ErrCorrupt
+			}
+		}
+		dst = append(dst, block...)
+		i += code - 1
+		// 长度不足 254 的块之后原本是一个 0x00，最后一个块除外
+		if code != 0xFF && i < len(src) {
+			dst = append(dst, 0)
+		}
+	}
+	return dst, nil
+}
@@ -0,0 +1,105 @@
+package framing
+
+import (
+	"bytes"
+	"testing"
+	"testing/quick"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+func TestCOBS_Vectors(t *testing.T) {
+	seq := func(from, to int) []byte {
+		var b []byte
+		for i := from; i <= to; i++ {
+			b = append(b, byte(i))
+		}
+		return b
+	}
+	cases := []struct {
+		data, encoded []byte
+	}{
+		{[]byte{}, []byte{0x01}},
+		{[]byte{0x00}, []byte{0x01, 0x01}},
+		{[]byte{0x00, 0x00}, []byte{0x01, 0x01, 0x01}},
+		{[]byte{0x11, 0x22, 0x00, 0x33}, []byte{0x03, 0x11, 0x22, 0x02, 0x33}},
+		{[]byte{0x11, 0x00, 0x00, 0x00}, []byte{0x02, 0x11, 0x01, 0x01, 0x01}},
+		{seq(1, 254), append([]byte{0xFF}, seq(1, 254)...)},
+		{seq(1, 255), append(append([]byte{0xFF}, seq(1, 254)...), 0x02, 0xFF)},
+		{append(seq(1, 254), 0x00), append(append([]byte{0xFF}, seq(1, 254)...), 0x01, 0x01)},
+	}
+	for _, tc := range cases {
+		encoded := AppendEncode(nil, tc.data)
+		if !bytes.Equal(encoded, tc.encoded) {
+			t.Fatalf("encode %x: expect %x, but got %x", tc.data, tc.encoded, encoded)
+		}
+		decoded, err := AppendDecode(nil, encoded)
+		if err != nil || !bytes.Equal(decoded, tc.data) {
+			t.Fatalf("decode %x: expect %x, but got %x, %v", encoded, tc.data, decoded, err)
+		}
+	}
+}
+
+func TestCOBS_RoundTrip(t *testing.T) {
+	f := func(data []byte, zeros []uint16) bool {
+		// 插入一些分隔符字节
+		for _, z := range zeros {
+			if len(data) > 0 {
+				data[int(z)%len(data)] = 0
+			}
+		}
+		encoded := AppendEncode(nil, data)
+		if bytes.IndexByte(encoded, 0) >= 0 || len(encoded) > MaxEncodedLen(len(data)) {
+			return false
+		}
+		decoded, err := AppendDecode(nil, encoded)
+		return err == nil && bytes.Equal(decoded, data)
+	}
+	if err := quick.Check(f, &quick.Config{MaxCount: 2000}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProtocol_Partial(t *testing.T) {
+	p := New(0)
+	payloads := [][]byte{{0x00, 0x01, 0x00}, {}, bytes.Repeat([]byte{0xAB, 0x00}, 300)}
+	var stream []byte
+	for _, payload := range payloads {
+		stream = append(stream, p.Packet(nil, payload)...)
+	}
+
+	// 逐字节到达，帧完整之前不消耗数据
+	buffer := ringbuffer.New(16)
+	var got [][]byte
+	for _, b := range stream {
+		_ = buffer.WriteByte(b)
+		for {
+			ctx, data := p.UnPacket(&connection.Connection{}, buffer)
+			if ctx == nil {
+				break
+			}
+			got = append(got, data)
+		}
+	}
+	if len(got) != len(payloads) {
+		t.Fatalf("expect %d frames, but got %d", len(payloads), len(got))
+	}
+	for i := range payloads {
+		if !bytes.Equal(got[i], payloads[i]) {
+			t.Fatalf("frame %d: expect %x, but got %x", i, payloads[i], got[i])
+		}
+	}
+}
+
+func TestProtocol_Corrupt(t *testing.T) {
+	p := New(0)
+	buffer := ringbuffer.New(16)
+	// 声明的块长度超过帧长度，丢弃后继续处理下一帧
+	_, _ = buffer.Write([]byte{0x05, 0x11, 0x00, 0x00})
+	_, _ = buffer.Write(p.Packet(nil, []byte{0x00, 0x22}))
+	ctx, data := p.UnPacket(&connection.Connection{}, buffer)
+	if ctx == nil || !bytes.Equal(data, []byte{0x00, 0x22}) {
+		t.Fatalf("expect the next frame, but got %v %x", ctx, data)
+	}
+}
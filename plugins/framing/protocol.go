@@ -0,0 +1,92 @@
+package framing
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// ErrFrameTooLarge：编码后的帧超过 MaxFrameLength
+var ErrFrameTooLarge = errors.New("framing: frame exceeds max frame length")
+
+// DefaultMaxFrameLength：默认的最大帧长度
+const DefaultMaxFrameLength = 64 * 1024
+
+// delimiter：帧分隔符
+const delimiter = 0x00
+
+// Protocol：COBS 分帧协议，每帧为 COBS 编码的内容加一个 0x00 分隔符。UnPacket 返回的 ctx 为解码后的长度，
+// 因此空消息同样会回调 OnMessage；连续的分隔符被忽略，可以用来在帧之间重新同步。
+// 解码失败的帧通过 Connection.ReportProtocolError 报告后丢弃，下一个分隔符之后的帧不受影响
+type Protocol struct {
+	maxFrameLength int
+}
+
+var _ connection.Protocol = &Protocol{}
+var _ connection.ConnIndependentPacket = &Protocol{}
+
+// New：创建 COBS 分帧协议，maxFrameLength 为编码后一帧（不含分隔符）的最大长度，
+// 超过时关闭连接，小于等于 0 时使用 DefaultMaxFrameLength
+func New(maxFrameLength int) *Protocol {
+	if maxFrameLength <= 0 {
+		maxFrameLength = DefaultMaxFrameLength
+	}
+	return &Protocol{maxFrameLength: maxFrameLength}
+}
+
+// UnPacket：拆包，找到分隔符后解码其之前的数据，不完整的帧留在 buffer 中
+func (p *Protocol) UnPacket(c *connection.Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	for {
+		first, end := buffer.PeekAll()
+		i := bytes.IndexByte(first, delimiter)
+		if i < 0 {
+			if j := bytes.IndexByte(end, delimiter); j >= 0 {
+				i = len(first) + j
+			}
+		}
+		if i < 0 {
+			if buffer.Length() > p.maxFrameLength {
+				p.fail(c, buffer)
+			}
+			return nil, nil
+		}
+		if i > p.maxFrameLength {
+			p.fail(c, buffer)
+			return nil, nil
+		}
+		// 连续的分隔符
+		if i == 0 {
+			buffer.Retrieve(1)
+			continue
+		}
+
+		frame := make([]byte, i)
+		_, _ = buffer.Read(frame)
+		buffer.Retrieve(1)
+		data, err := AppendDecode(frame[:0], frame)
+		if err != nil {
+			c.ReportProtocolError(err)
+			continue
+		}
+		return len(data), data
+	}
+}
+
+// Packet：装包，COBS 编码后追加分隔符
+func (p *Protocol) Packet(c *connection.Connection, data []byte) []byte {
+	ret := AppendEncode(make([]byte, 0, MaxEncodedLen(len(data))+1), data)
+	return append(ret, delimiter)
+}
+
+// ConnIndependentPacket：编码不依赖连接状态
+func (p *Protocol) ConnIndependentPacket() {}
+
+// fail：一直没有分隔符的数据超过上限，丢弃数据并关闭连接
+func (p *Protocol) fail(c *connection.Connection, buffer *ringbuffer.RingBuffer) {
+	log.Error("[framing]", ErrFrameTooLarge)
+	buffer.RetrieveAll()
+	_ = c.Close()
+}
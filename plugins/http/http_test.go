@@ -0,0 +1,206 @@
+package http
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	nethttp "net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// echoServer：以请求的方法、URI 及请求体作为响应
+type echoServer struct{}
+
+func (s *echoServer) OnConnect(c *connection.Connection) {}
+func (s *echoServer) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	req := ctx.(*Request)
+	if req.URI == "/missing" {
+		WriteResponse(c, &Response{StatusCode: nethttp.StatusNotFound, Header: nethttp.Header{"X-Reason": {"gone"}}})
+		return nil
+	}
+	return []byte(req.Method + " " + req.URI + " " + string(data))
+}
+func (s *echoServer) OnClose(c *connection.Connection) {}
+
+func newServer(t *testing.T, opts Options) string {
+	s, err := fastnet.NewServer(&echoServer{},
+		fastnet.Address("127.0.0.1:0"),
+		fastnet.NumLoops(1),
+		fastnet.Protocol(New(opts)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	t.Cleanup(s.Stop)
+	return s.Addr()
+}
+
+func dial(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.(*net.TCPConn).SetNoDelay(true)
+	_ = conn.SetDeadline(time.Now().Add(3 * time.Second))
+	return conn, bufio.NewReader(conn)
+}
+
+func readResponse(t *testing.T, r *bufio.Reader) (*nethttp.Response, string) {
+	resp, err := nethttp.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestProtocol_KeepAlive(t *testing.T) {
+	addr := newServer(t, Options{})
+	client := &nethttp.Client{Timeout: 3 * time.Second}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Post("http://"+addr+"/echo", "text/plain", strings.NewReader("ping"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != nethttp.StatusOK || string(body) != "POST /echo ping" {
+			t.Fatalf("unexpected response %d %q", resp.StatusCode, body)
+		}
+	}
+}
+
+// TestProtocol_PartialAndPipelined：请求被任意切开时等待后续数据，一次到达的多个请求依次应答
+func TestProtocol_PartialAndPipelined(t *testing.T) {
+	conn, r := dial(t, newServer(t, Options{}))
+
+	reqs := "GET /a HTTP/1.1\r\nHost: x\r\n\r\n" +
+		"POST /b HTTP/1.1\r\nHost: x\r\nContent-Length: 5\r\n\r\nhello" +
+		"POST /c HTTP/1.1\r\nHost: x\r\nTransfer-Encoding: chunked\r\n\r\n3;ext=1\r\nabc\r\n2\r\nde\r\n0\r\nTrailer: v\r\n\r\n" +
+		"GET /missing HTTP/1.1\r\nHost: x\r\n\r\n"
+	for _, part := range []string{reqs[:10], reqs[10:50], reqs[50:90], reqs[90:]} {
+		if _, err := conn.Write([]byte(part)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, expect := range []string{"GET /a ", "POST /b hello", "POST /c abcde"} {
+		if resp, body := readResponse(t, r); resp.StatusCode != nethttp.StatusOK || body != expect {
+			t.Fatalf("expect %q, but got %d %q", expect, resp.StatusCode, body)
+		}
+	}
+	if resp, _ := readResponse(t, r); resp.StatusCode != nethttp.StatusNotFound || resp.Header.Get("X-Reason") != "gone" {
+		t.Fatalf("expect custom response, but got %d %v", resp.StatusCode, resp.Header)
+	}
+}
+
+func TestProtocol_ConnectionClose(t *testing.T) {
+	conn, r := dial(t, newServer(t, Options{}))
+	if _, err := conn.Write([]byte("GET /bye HTTP/1.1\r\nConnection: close\r\n\r\nGET /ignored HTTP/1.1\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	resp, body := readResponse(t, r)
+	if body != "GET /bye " || !resp.Close {
+		t.Fatalf("expect response with Connection: close, but got %q %v", body, resp.Header)
+	}
+	if _, err := r.ReadByte(); err == nil {
+		t.Fatal("expect connection to be closed after the response")
+	}
+}
+
+func TestProtocol_Limits(t *testing.T) {
+	addr := newServer(t, Options{MaxBodyBytes: 4})
+	conn, r := dial(t, addr)
+	if _, err := conn.Write([]byte("POST / HTTP/1.1\r\nContent-Length: 5\r\n\r\nhello")); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := readResponse(t, r); resp.StatusCode != nethttp.StatusRequestEntityTooLarge {
+		t.Fatalf("expect 413, but got %d", resp.StatusCode)
+	}
+
+	conn, r = dial(t, addr)
+	if _, err := conn.Write([]byte("BROKEN\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := readResponse(t, r); resp.StatusCode != nethttp.StatusBadRequest {
+		t.Fatalf("expect 400, but got %d", resp.StatusCode)
+	}
+}
+
+// TestProtocol_ChunkSizeOverflow：超大的 chunk 长度直接按请求体过大拒绝，不能因整数溢出越界
+func TestProtocol_ChunkSizeOverflow(t *testing.T) {
+	p := New(Options{})
+	buffer := ringbuffer.New(0)
+	_, _ = buffer.WriteString("POST / HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n1\r\na\r\n7fffffffffffffff\r\n")
+	c := &connection.Connection{}
+	if ctx, data := p.UnPacket(c, buffer); ctx != nil || data != nil {
+		t.Fatalf("expect the request to be rejected, but got %v %q", ctx, data)
+	}
+	if _, closing := c.Get(closeKey); !closing || buffer.Length() != 0 {
+		t.Fatal("expect the connection to be closed after rejecting the request")
+	}
+}
+
+// TestProtocol_AmbiguousFraming：请求体长度有歧义的请求回复 400，避免请求走私
+func TestProtocol_AmbiguousFraming(t *testing.T) {
+	addr := newServer(t, Options{})
+	for _, req := range []string{
+		"POST / HTTP/1.1\r\nContent-Length: 1\r\nContent-Length: 5\r\n\r\nhello",
+		"POST / HTTP/1.1\r\nContent-Length: 5\r\nTransfer-Encoding: chunked\r\n\r\n0\r\n\r\n",
+		"POST / HTTP/1.1\r\nTransfer-Encoding: gzip, chunked\r\n\r\n0\r\n\r\n",
+	} {
+		conn, r := dial(t, addr)
+		if _, err := conn.Write([]byte(req)); err != nil {
+			t.Fatal(err)
+		}
+		if resp, _ := readResponse(t, r); resp.StatusCode != nethttp.StatusBadRequest {
+			t.Fatalf("expect 400 for %q, but got %d", req, resp.StatusCode)
+		}
+	}
+
+	// 重复但相同的 Content-Length 仍然接受
+	conn, r := dial(t, addr)
+	if _, err := conn.Write([]byte("POST /same HTTP/1.1\r\nContent-Length: 2\r\nContent-Length: 2\r\n\r\nok")); err != nil {
+		t.Fatal(err)
+	}
+	if resp, body := readResponse(t, r); resp.StatusCode != nethttp.StatusOK || body != "POST /same ok" {
+		t.Fatalf("expect request to be accepted, but got %d %q", resp.StatusCode, body)
+	}
+}
+
+// TestProtocol_Incremental：数据逐字节到达时，已解析的部分从 buffer 中取出，不会重新解析
+func TestProtocol_Incremental(t *testing.T) {
+	p := New(Options{})
+	buffer := ringbuffer.New(0)
+	c := &connection.Connection{}
+	req := "POST /inc HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nabc\r\n2\r\nde\r\n0\r\n\r\n" +
+		"POST /next HTTP/1.1\r\nContent-Length: 3\r\n\r\nxyz"
+	var got []string
+	for i := 0; i < len(req); i++ {
+		_ = buffer.WriteByte(req[i])
+		for {
+			ctx, data := p.UnPacket(c, buffer)
+			if ctx == nil {
+				break
+			}
+			got = append(got, ctx.(*Request).URI+" "+string(data))
+		}
+		// 只有尚不完整的请求头会留在 buffer 中，请求体按 chunk 或整体取出
+		if buffer.Length() > len("POST /inc HTTP/1.1\r\nTransfer-Encoding: chunked\r\n\r\n") {
+			t.Fatalf("expect parsed data to be retrieved, but %d bytes are buffered", buffer.Length())
+		}
+	}
+	if len(got) != 2 || got[0] != "/inc abcde" || got[1] != "/next xyz" {
+		t.Fatalf("expect both requests, but got %q", got)
+	}
+}
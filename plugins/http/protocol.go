@@ -0,0 +1,322 @@
+// Package http 提供 HTTP/1.1 协议，UnPacket 从 buffer 中解析请求行、请求头及请求体（Content-Length 或 chunked），
+// 以 *Request 作为 ctx 交给 OnMessage，Packet 将 OnMessage 返回的数据序列化为响应。
+// 连接默认保持打开（keep-alive），buffer 中剩余的数据作为下一个请求继续解析
+package http
+
+import (
+	"bytes"
+	"errors"
+	nethttp "net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// 默认的请求大小限制
+const (
+	DefaultMaxHeaderBytes = 64 * 1024
+	DefaultMaxBodyBytes   = 4 << 20
+)
+
+const (
+	closeKey = "fastnet_http_close"
+	parseKey = "fastnet_http_parse"
+)
+
+// 请求相关错误
+var (
+	ErrMalformedRequest = errors.New("http: malformed request")
+	ErrHeaderTooLarge   = errors.New("http: request header exceeds max header bytes")
+	ErrBodyTooLarge     = errors.New("http: request body exceeds max body bytes")
+)
+
+var crlf = []byte("\r\n")
+
+// Options：协议配置
+type Options struct {
+	MaxHeaderBytes int // 请求行及请求头的最大长度，超过时回复 431 并关闭连接，默认 DefaultMaxHeaderBytes
+	MaxBodyBytes   int // 请求体的最大长度，超过时回复 413 并关闭连接，默认 DefaultMaxBodyBytes
+}
+
+// Request：解析得到的请求，Body 为完整的请求体，chunked 的请求体已经拼接
+type Request struct {
+	Method string
+	URI    string
+	Proto  string
+	Header nethttp.Header
+	Body   []byte
+	Close  bool // 回复后是否关闭连接，由 Connection 请求头及协议版本决定
+}
+
+// Protocol：HTTP/1.1 协议，请求不完整时数据留在 buffer 中等待后续数据，不会被消费
+type Protocol struct {
+	opts Options
+}
+
+var _ connection.Protocol = &Protocol{}
+
+// New：创建 HTTP/1.1 协议
+func New(opts Options) *Protocol {
+	if opts.MaxHeaderBytes <= 0 {
+		opts.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+	return &Protocol{opts: opts}
+}
+
+// parseState：连接上正在解析的请求，请求头及已经到达的请求体从 buffer 中取出后保存在这里，
+// 之后的调用只处理新到达的数据，不会重新解析
+type parseState struct {
+	scanned   int      // 已经查找过请求头结束标记的字节数
+	req       *Request // 请求头已解析、正在读取请求体的请求
+	remaining int      // Content-Length 请求体尚未到达的字节数
+	chunked   bool     // 请求体为 chunked 编码
+	chunkLeft int      // 当前 chunk 尚未读取的数据字节数
+	trailer   bool     // 已读完最后一个 chunk，正在跳过 trailer
+}
+
+// UnPacket：拆包，解析出一个完整的请求，ctx 为 *Request，返回的数据为请求体。
+// 没有请求体的请求同样会回调 OnMessage，此时数据为空，ctx 不为 nil
+func (p *Protocol) UnPacket(c *connection.Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	// 已经决定关闭的连接不再解析新的请求
+	if _, closing := c.Get(closeKey); closing {
+		buffer.RetrieveAll()
+		return nil, nil
+	}
+	var st *parseState
+	if v, ok := c.Get(parseKey); ok {
+		st = v.(*parseState)
+	} else {
+		st = &parseState{}
+		c.Set(parseKey, st)
+	}
+
+	req, err := p.parse(st, buffer)
+	if err != nil {
+		c.Delete(parseKey)
+		p.reject(c, buffer, err)
+		return nil, nil
+	}
+	if req == nil {
+		return nil, nil
+	}
+	*st = parseState{}
+	if req.Close {
+		c.Set(closeKey, true)
+	} else {
+		c.Delete(closeKey)
+	}
+	return req, req.Body
+}
+
+// Packet：装包，将 data 序列化为状态码 200 的响应。应答的请求要求关闭连接时带上 Connection: close，
+// 并在响应写出后关闭连接。只用于 OnMessage 返回的数据，其他状态码及响应头使用 WriteResponse
+func (p *Protocol) Packet(c *connection.Connection, data []byte) []byte {
+	return encodeResponse(c, &Response{StatusCode: nethttp.StatusOK, Body: data})
+}
+
+// parse：从 buffer 中继续解析 st 中的请求，请求完整时返回该请求，数据不完整时返回 nil，已解析的数据从 buffer 中取出
+func (p *Protocol) parse(st *parseState, buffer *ringbuffer.RingBuffer) (*Request, error) {
+	if st.req == nil {
+		req, err := p.parseHeader(st, buffer)
+		if req == nil || err != nil {
+			return nil, err
+		}
+		st.req = req
+		if st.chunked, st.remaining, err = p.framing(req); err != nil {
+			return nil, err
+		}
+	}
+	if st.chunked {
+		return p.parseChunked(st, buffer)
+	}
+	if st.remaining > 0 {
+		if buffer.Length() < st.remaining {
+			return nil, nil
+		}
+		st.req.Body = make([]byte, st.remaining)
+		_, _ = buffer.Read(st.req.Body)
+	}
+	return st.req, nil
+}
+
+// parseHeader：解析请求行及请求头，只在新到达的数据中查找请求头的结束标记，请求头不完整时返回 nil
+func (p *Protocol) parseHeader(st *parseState, buffer *ringbuffer.RingBuffer) (*Request, error) {
+	data := peek(buffer, p.opts.MaxHeaderBytes+4)
+	from := st.scanned - 3
+	if from < 0 {
+		from = 0
+	}
+	i := bytes.Index(data[from:], []byte("\r\n\r\n"))
+	if i < 0 {
+		if len(data) > p.opts.MaxHeaderBytes {
+			return nil, ErrHeaderTooLarge
+		}
+		st.scanned = len(data)
+		return nil, nil
+	}
+	headerEnd := from + i
+	if headerEnd > p.opts.MaxHeaderBytes {
+		return nil, ErrHeaderTooLarge
+	}
+
+	lines := bytes.Split(data[:headerEnd], crlf)
+	req, err := parseRequestLine(string(lines[0]))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = make(nethttp.Header, len(lines)-1)
+	for _, line := range lines[1:] {
+		i := bytes.IndexByte(line, ':')
+		// 不支持已废弃的折叠请求头
+		if i <= 0 || line[0] == ' ' || line[0] == '\t' {
+			return nil, ErrMalformedRequest
+		}
+		key := textproto.CanonicalMIMEHeaderKey(string(bytes.TrimSpace(line[:i])))
+		req.Header.Add(key, string(bytes.TrimSpace(line[i+1:])))
+	}
+	req.Close = shouldClose(req)
+	buffer.Retrieve(headerEnd + 4)
+	return req, nil
+}
+
+// framing：根据 Transfer-Encoding 及 Content-Length 确定请求体的长度。
+// 与 net/http 一样，同时出现两者、多个不同的 Content-Length 或不支持的 Transfer-Encoding 时拒绝请求，避免请求走私
+func (p *Protocol) framing(req *Request) (chunked bool, length int, err error) {
+	te, cl := req.Header["Transfer-Encoding"], req.Header["Content-Length"]
+	if len(te) > 0 {
+		if len(cl) > 0 || len(te) > 1 || !strings.EqualFold(te[0], "chunked") {
+			return false, 0, ErrMalformedRequest
+		}
+		req.Body = make([]byte, 0)
+		return true, 0, nil
+	}
+	if len(cl) == 0 {
+		return false, 0, nil
+	}
+	for _, v := range cl[1:] {
+		if v != cl[0] {
+			return false, 0, ErrMalformedRequest
+		}
+	}
+	length, err = strconv.Atoi(cl[0])
+	if err != nil || length < 0 {
+		return false, 0, ErrMalformedRequest
+	}
+	if length > p.opts.MaxBodyBytes {
+		return false, 0, ErrBodyTooLarge
+	}
+	return false, length, nil
+}
+
+// parseChunked：继续读取 chunked 编码的请求体，每个 chunk 到齐后即追加到请求体并从 buffer 中取出，读完 trailer 时返回请求
+func (p *Protocol) parseChunked(st *parseState, buffer *ringbuffer.RingBuffer) (*Request, error) {
+	for {
+		if st.chunkLeft > 0 {
+			// chunk 数据及其后的 CRLF 到齐后一起取出
+			if buffer.Length() < st.chunkLeft+2 {
+				return nil, nil
+			}
+			start := len(st.req.Body)
+			st.req.Body = append(st.req.Body, make([]byte, st.chunkLeft)...)
+			_, _ = buffer.Read(st.req.Body[start:])
+			var tail [2]byte
+			_, _ = buffer.Read(tail[:])
+			if !bytes.Equal(tail[:], crlf) {
+				return nil, ErrMalformedRequest
+			}
+			st.chunkLeft = 0
+		}
+
+		line, ok, err := p.readLine(buffer)
+		if !ok || err != nil {
+			return nil, err
+		}
+		// 跳过 trailer，以空行结束
+		if st.trailer {
+			if len(line) == 0 {
+				return st.req, nil
+			}
+			continue
+		}
+		// 忽略 chunk 扩展
+		if j := bytes.IndexByte(line, ';'); j >= 0 {
+			line = line[:j]
+		}
+		size, err := strconv.ParseInt(string(bytes.TrimSpace(line)), 16, 64)
+		if err != nil || size < 0 {
+			return nil, ErrMalformedRequest
+		}
+		// 先与剩余的额度比较再做运算，避免超大的 chunk 长度溢出
+		if size > int64(p.opts.MaxBodyBytes-len(st.req.Body)) {
+			return nil, ErrBodyTooLarge
+		}
+		if size == 0 {
+			st.trailer = true
+		}
+		st.chunkLeft = int(size)
+	}
+}
+
+// readLine：取出以 CRLF 结尾的一行（不含 CRLF），数据不完整时返回 false，超过 MaxHeaderBytes 的行视为格式错误
+func (p *Protocol) readLine(buffer *ringbuffer.RingBuffer) ([]byte, bool, error) {
+	data := peek(buffer, p.opts.MaxHeaderBytes+2)
+	i := bytes.Index(data, crlf)
+	if i < 0 {
+		if len(data) > p.opts.MaxHeaderBytes {
+			return nil, false, ErrMalformedRequest
+		}
+		return nil, false, nil
+	}
+	line := append([]byte(nil), data[:i]...)
+	buffer.Retrieve(i + 2)
+	return line, true, nil
+}
+
+// peek：返回 buffer 开头最多 n 字节的数据，跨越缓冲区末尾时拷贝为连续的切片
+func peek(buffer *ringbuffer.RingBuffer, n int) []byte {
+	first, end := buffer.Peek(n)
+	if len(end) == 0 {
+		return first
+	}
+	return append(append(make([]byte, 0, len(first)+len(end)), first...), end...)
+}
+
+// parseRequestLine：解析请求行
+func parseRequestLine(line string) (*Request, error) {
+	parts := strings.Split(line, " ")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || !strings.HasPrefix(parts[2], "HTTP/1.") {
+		return nil, ErrMalformedRequest
+	}
+	return &Request{Method: parts[0], URI: parts[1], Proto: parts[2]}, nil
+}
+
+// shouldClose：HTTP/1.1 默认保持连接，HTTP/1.0 只在请求 keep-alive 时保持连接
+func shouldClose(req *Request) bool {
+	conn := req.Header.Get("Connection")
+	if req.Proto == "HTTP/1.0" {
+		return !strings.EqualFold(conn, "keep-alive")
+	}
+	return strings.EqualFold(conn, "close")
+}
+
+// reject：回复错误的状态码后关闭连接
+func (p *Protocol) reject(c *connection.Connection, buffer *ringbuffer.RingBuffer, err error) {
+	log.Error("[http]", err)
+	buffer.RetrieveAll()
+	status := nethttp.StatusBadRequest
+	switch err {
+	case ErrHeaderTooLarge:
+		status = nethttp.StatusRequestHeaderFieldsTooLarge
+	case ErrBodyTooLarge:
+		status = nethttp.StatusRequestEntityTooLarge
+	}
+	c.Set(closeKey, true)
+	WriteResponse(c, &Response{StatusCode: status})
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"bytes"
+	nethttp "net/http"
+	"strconv"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+)
+
+// Response：响应，未设置 Content-Length 时按 Body 的长度填写
+type Response struct {
+	StatusCode int
+	Header     nethttp.Header
+	Body       []byte
+}
+
+// WriteResponse：不经过 Packet 直接回复 resp，用于自定义状态码及响应头，只能在事件循环 goroutine（如 OnMessage）中调用。
+// 与 OnMessage 返回的响应一样，应答的请求要求关闭连接时在写出后关闭连接
+func WriteResponse(c *connection.Connection, resp *Response) {
+	c.WriteBack(encodeResponse(c, resp))
+}
+
+// encodeResponse：序列化响应，连接即将关闭时带上 Connection: close 并在写出后关闭连接
+func encodeResponse(c *connection.Connection, resp *Response) []byte {
+	_, closing := c.Get(closeKey)
+
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 ")
+	buf.WriteString(strconv.Itoa(resp.StatusCode))
+	buf.WriteByte(' ')
+	buf.WriteString(nethttp.StatusText(resp.StatusCode))
+	buf.WriteString("\r\n")
+	if resp.Header.Get("Content-Length") == "" {
+		buf.WriteString("Content-Length: ")
+		buf.WriteString(strconv.Itoa(len(resp.Body)))
+		buf.WriteString("\r\n")
+	}
+	if closing {
+		buf.WriteString("Connection: close\r\n")
+	}
+	if err := resp.Header.Write(&buf); err != nil {
+		log.Error("[http]", err)
+	}
+	buf.WriteString("\r\n")
+	buf.Write(resp.Body)
+
+	if closing {
+		// 回复之前的数据写出后关闭
+		if err := c.WriteClose(nil); err != nil {
+			log.Error("[http]", err)
+		}
+	}
+	return buf.Bytes()
+}
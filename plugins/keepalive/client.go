@@ -0,0 +1,84 @@
+package keepalive
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Dongxiem/fastnet/tool/sync/atomic"
+)
+
+// Conn：保活协议的客户端实现，包装一个 net.Conn，Read 时自动回应服务端的探测
+type Conn struct {
+	net.Conn
+	rd      *bufio.Reader
+	wmu     sync.Mutex // 保证 Write 与回应探测的帧不会交错
+	pending []byte     // 已收到但未被读取的数据
+	rtt     atomic.Int64
+}
+
+// Client：在 conn 上使用保活协议，需要持续调用 Read 才能回应探测及测量往返时间
+func Client(conn net.Conn) *Conn {
+	return &Conn{Conn: conn, rd: bufio.NewReader(conn)}
+}
+
+// Read：读取数据帧的内容
+func (c *Conn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		var header [headerLen]byte
+		if _, err := io.ReadFull(c.rd, header[:]); err != nil {
+			return 0, err
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(header[1:]))
+		if _, err := io.ReadFull(c.rd, payload); err != nil {
+			return 0, err
+		}
+
+		switch {
+		case header[0] == frameData:
+			c.pending = payload
+		case header[0] == framePing && len(payload) == probeLen:
+			if err := c.writeFrame(framePong, payload); err != nil {
+				return 0, err
+			}
+		case header[0] == framePong && len(payload) == probeLen:
+			sent := time.Duration(binary.BigEndian.Uint64(payload))
+			_ = c.rtt.Swap(int64(time.Since(epoch) - sent))
+		default:
+			return 0, ErrBadFrame
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write：将 p 封装为一个数据帧写出
+func (c *Conn) Write(p []byte) (int, error) {
+	if err := c.writeFrame(frameData, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Ping：向服务端发送一次探测，收到响应后（由 Read 处理）更新 RTT
+func (c *Conn) Ping() error {
+	var payload [probeLen]byte
+	binary.BigEndian.PutUint64(payload[:], uint64(time.Since(epoch)))
+	return c.writeFrame(framePing, payload[:])
+}
+
+// RTT：最近一次测得的往返时间，未测量过时为 0
+func (c *Conn) RTT() time.Duration {
+	return time.Duration(c.rtt.Get())
+}
+
+func (c *Conn) writeFrame(typ byte, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, err := c.Conn.Write(appendFrame(nil, typ, data))
+	return err
+}
@@ -0,0 +1,88 @@
+package keepalive
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+)
+
+type echoServer struct {
+	p     *Protocol
+	conns chan *connection.Connection
+}
+
+func (s *echoServer) OnConnect(c *connection.Connection) {
+	s.p.Start(c)
+	s.conns <- c
+}
+func (s *echoServer) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	return data
+}
+func (s *echoServer) OnClose(c *connection.Connection) {}
+
+func TestKeepalive_RTT(t *testing.T) {
+	handler := &echoServer{
+		p:     New(&connection.DefaultProtocol{}, time.Millisecond*20),
+		conns: make(chan *connection.Connection, 1),
+	}
+	s, err := fastnet.NewServer(handler,
+		fastnet.Address("127.0.0.1:0"),
+		fastnet.NumLoops(1),
+		fastnet.Protocol(handler.p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kc := Client(conn)
+	defer kc.Close()
+	c := <-handler.conns
+
+	// 持续读取以回应服务端的探测
+	echo := make(chan []byte, 1)
+	go func() {
+		buf := make([]byte, 5)
+		if _, err := io.ReadFull(kc, buf); err == nil {
+			echo <- buf
+		}
+		_, _ = io.Copy(ioutil.Discard, kc)
+	}()
+
+	if _, err := kc.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case data := <-echo:
+		if string(data) != "hello" {
+			t.Fatalf("expect hello, but got %q", data)
+		}
+	case <-time.After(time.Second * 3):
+		t.Fatal("echo timeout")
+	}
+
+	if err := kc.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(time.Second * 3)
+	for c.RTT() == 0 || kc.RTT() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("rtt should be measured, server %v, client %v", c.RTT(), kc.RTT())
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	for _, rtt := range []time.Duration{c.RTT(), kc.RTT()} {
+		if rtt < 0 || rtt > time.Second {
+			t.Fatalf("unexpected loopback rtt %v", rtt)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package keepalive
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// headerLen：帧头长度，帧格式为 [1 字节类型][4 字节大端长度][内容]
+const headerLen = 5
+
+// 帧类型
+const (
+	frameData byte = iota // 内层协议的数据
+	framePing             // 探测，内容为 8 字节的发送时间
+	framePong             // 探测响应，原样带回探测的内容
+)
+
+// probeLen：探测及探测响应的内容长度
+const probeLen = 8
+
+const sessionKey = "fastnet_keepalive_session"
+
+// 帧相关错误
+var (
+	ErrBadFrame      = errors.New("keepalive: malformed frame")
+	ErrFrameTooLarge = errors.New("keepalive: frame exceeds max frame size")
+)
+
+// epoch：探测中的时间相对 epoch 计算，使用单调时钟，不受系统时间调整影响
+var epoch = time.Now()
+
+// session：单个连接的状态
+type session struct {
+	plain *ringbuffer.RingBuffer // 数据帧的内容，交给内层协议拆包
+}
+
+// Protocol：应用层保活协议，在内层协议的数据之外定时发送带时间戳的探测，
+// 收到对端的响应后计算往返时间，通过 Connection.RTT 获取。可以包装任意 connection.Protocol
+type Protocol struct {
+	inner        connection.Protocol
+	interval     time.Duration
+	maxFrameSize int
+}
+
+var _ connection.Protocol = &Protocol{}
+
+// New：创建保活协议，inner 为内层协议，interval 为 Start 之后发送探测的间隔
+func New(inner connection.Protocol, interval time.Duration) *Protocol {
+	if inner == nil {
+		inner = &connection.DefaultProtocol{}
+	}
+	return &Protocol{inner: inner, interval: interval, maxFrameSize: 1 << 20}
+}
+
+// Start：开始每隔 interval 向对端发送一次探测，连接关闭后自动停止，一般在 OnConnect 中调用
+func (p *Protocol) Start(c *connection.Connection) {
+	s := p.session(c)
+	var tick func()
+	tick = func() {
+		if !p.ping(c, s) {
+			return
+		}
+		time.AfterFunc(p.interval, tick)
+	}
+	time.AfterFunc(p.interval, tick)
+}
+
+// Ping：立即向对端发送一次探测，可以在任意 goroutine 中调用
+func (p *Protocol) Ping(c *connection.Connection) {
+	p.ping(c, p.session(c))
+}
+
+// ping：在事件循环中发送探测，返回连接是否仍然有效
+func (p *Protocol) ping(c *connection.Connection, s *session) bool {
+	if !c.Connected() {
+		return false
+	}
+	c.QueueInLoop(func() {
+		// 连接已被连接池复用
+		if v, ok := c.Get(sessionKey); !ok || v.(*session) != s {
+			return
+		}
+		c.SendInLoop(appendProbe(nil, framePing, int64(time.Since(epoch))))
+	})
+	return true
+}
+
+// session：获取连接的状态，不存在时创建
+func (p *Protocol) session(c *connection.Connection) *session {
+	if v, ok := c.Get(sessionKey); ok {
+		return v.(*session)
+	}
+	s := &session{plain: ringbuffer.New(1024)}
+	c.Set(sessionKey, s)
+	return s
+}
+
+// UnPacket：拆包，回应探测并根据探测响应更新往返时间，数据帧交给内层协议
+func (p *Protocol) UnPacket(c *connection.Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	s := p.session(c)
+	for buffer.Length() >= headerLen {
+		first, end := buffer.Peek(headerLen)
+		header := append(append(make([]byte, 0, headerLen), first...), end...)
+		typ, n := header[0], int(binary.BigEndian.Uint32(header[1:]))
+		if n > p.maxFrameSize {
+			log.Error("[keepalive]", ErrFrameTooLarge)
+			buffer.RetrieveAll()
+			_ = c.Close()
+			return nil, nil
+		}
+		if buffer.Length() < headerLen+n {
+			break
+		}
+		buffer.Retrieve(headerLen)
+		payload := make([]byte, n)
+		_, _ = buffer.Read(payload)
+
+		switch {
+		case typ == frameData:
+			_, _ = s.plain.Write(payload)
+		case typ == framePing && n == probeLen:
+			c.SendInLoop(appendProbe(nil, framePong, int64(binary.BigEndian.Uint64(payload))))
+		case typ == framePong && n == probeLen:
+			sent := time.Duration(binary.BigEndian.Uint64(payload))
+			c.SetRTT(time.Since(epoch) - sent)
+		default:
+			c.ReportProtocolError(ErrBadFrame)
+		}
+	}
+	return p.inner.UnPacket(c, s.plain)
+}
+
+// Packet：装包，内层协议打包后封装为数据帧
+func (p *Protocol) Packet(c *connection.Connection, data []byte) []byte {
+	return appendFrame(nil, frameData, p.inner.Packet(c, data))
+}
+
+// appendFrame：将 data 封装为 typ 类型的帧追加到 dst
+func appendFrame(dst []byte, typ byte, data []byte) []byte {
+	var header [headerLen]byte
+	header[0] = typ
+	binary.BigEndian.PutUint32(header[1:], uint32(len(data)))
+	dst = append(dst, header[:]...)
+	return append(dst, data...)
+}
+
+// appendProbe：将探测或探测响应追加到 dst
+func appendProbe(dst []byte, typ byte, ts int64) []byte {
+	var payload [probeLen]byte
+	binary.BigEndian.PutUint64(payload[:], uint64(ts))
+	return appendFrame(dst, typ, payload[:])
+}
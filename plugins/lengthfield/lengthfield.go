@@ -0,0 +1,173 @@
+// Package lengthfield 提供基于长度字段的通用拆包协议，帧格式与 Netty 的 LengthFieldBasedFrameDecoder 一致，
+// 通过 Options 描述长度字段在帧中的位置、长度及含义，可以适配大部分“长度 + 内容”格式的二进制协议
+package lengthfield
+
+import (
+	"errors"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/tool/encoding"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// 配置及帧相关错误
+var (
+	ErrFieldLength   = errors.New("lengthfield: length field length must be 1, 2, 4 or 8")
+	ErrStrip         = errors.New("lengthfield: initial bytes to strip must not be negative")
+	ErrFrameTooLarge = errors.New("lengthfield: frame exceeds max frame length")
+	ErrBadLength     = errors.New("lengthfield: negative frame length after adjustment")
+)
+
+// DefaultMaxFrameLength：默认的最大帧长度
+const DefaultMaxFrameLength = 1 << 20
+
+// Options：帧格式，整个帧的长度为 LengthFieldOffset + LengthFieldLength + 长度字段的值 + LengthAdjustment
+type Options struct {
+	LengthFieldOffset   int            // 长度字段之前的字节数
+	LengthFieldLength   int            // 长度字段的字节数，支持 1、2、4、8
+	LengthAdjustment    int            // 长度字段的值加上该值后为长度字段之后的字节数，长度字段的值包含帧头时为负数
+	InitialBytesToStrip int            // 交给 OnMessage 之前从帧首部去掉的字节数，通常等于帧头长度
+	Order               encoding.Order // 长度字段的字节序，默认为大端序
+	MaxFrameLength      int            // 整个帧的最大长度，超过时关闭连接，默认 DefaultMaxFrameLength
+}
+
+// Protocol：基于长度字段的拆包协议，UnPacket 返回的 ctx 为长度字段的值（uint64），
+// 因此长度为 0 的帧同样会回调 OnMessage
+type Protocol struct {
+	opts      Options
+	headerLen int // 长度字段结束的位置
+}
+
+var _ connection.ErrorProtocol = &Protocol{}
+var _ connection.ConnIndependentPacket = &Protocol{}
+
+// New：创建长度字段协议
+func New(opts Options) (*Protocol, error) {
+	switch opts.LengthFieldLength {
+	case 1, 2, 4, 8:
+	default:
+		return nil, ErrFieldLength
+	}
+	if opts.InitialBytesToStrip < 0 || opts.LengthFieldOffset < 0 {
+		return nil, ErrStrip
+	}
+	if opts.MaxFrameLength <= 0 {
+		opts.MaxFrameLength = DefaultMaxFrameLength
+	}
+	return &Protocol{opts: opts, headerLen: opts.LengthFieldOffset + opts.LengthFieldLength}, nil
+}
+
+// UnPacket：拆包，帧格式错误时丢弃数据并关闭连接。连接通过 UnPacketErr 拆包，只在直接调用时使用
+func (p *Protocol) UnPacket(c *connection.Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	ctx, out, err := p.UnPacketErr(c, buffer)
+	if err != nil {
+		log.Error("[lengthfield]", err)
+		buffer.RetrieveAll()
+		_ = c.Close()
+	}
+	return ctx, out
+}
+
+// UnPacketErr：拆包，通过虚读读取帧头，帧不完整时还原读指针，不消耗 buffer 中的数据。
+// 帧超过 MaxFrameLength 或调整后的长度非法时返回 ErrFrameTooLarge 或 ErrBadLength，连接以该错误关闭
+func (p *Protocol) UnPacketErr(c *connection.Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte, error) {
+	if buffer.Length() < p.headerLen {
+		return nil, nil, nil
+	}
+	var scratch [16]byte
+	header := scratch[:]
+	if p.headerLen > len(scratch) {
+		header = make([]byte, p.headerLen)
+	}
+	header = header[:p.headerLen]
+	_, _ = buffer.VirtualRead(header)
+
+	value := p.length(header[p.opts.LengthFieldOffset:])
+	frameLen := int64(p.headerLen) + int64(p.opts.LengthAdjustment)
+	if value > uint64(p.opts.MaxFrameLength) || frameLen+int64(value) > int64(p.opts.MaxFrameLength) {
+		buffer.VirtualRevert()
+		return nil, nil, ErrFrameTooLarge
+	}
+	frameLen += int64(value)
+	if frameLen < int64(p.headerLen) || frameLen < int64(p.opts.InitialBytesToStrip) {
+		buffer.VirtualRevert()
+		return nil, nil, ErrBadLength
+	}
+	if int64(buffer.Length()) < frameLen {
+		buffer.VirtualRevert()
+		return nil, nil, nil
+	}
+
+	strip := p.opts.InitialBytesToStrip
+	out := make([]byte, int(frameLen)-strip)
+	if strip < p.headerLen {
+		n := copy(out, header[strip:])
+		_, _ = buffer.VirtualRead(out[n:])
+	} else {
+		if skip := strip - p.headerLen; skip > 0 {
+			_, _ = buffer.VirtualRead(make([]byte, skip))
+		}
+		_, _ = buffer.VirtualRead(out)
+	}
+	buffer.VirtualFlush()
+	return value, out, nil
+}
+
+// Packet：装包，在 data 的 LengthFieldOffset 处插入长度字段，data 应为去掉长度字段之后的完整帧
+func (p *Protocol) Packet(c *connection.Connection, data []byte) []byte {
+	offset := p.opts.LengthFieldOffset
+	if len(data) < offset {
+		log.Error("[lengthfield] packet shorter than length field offset")
+		return nil
+	}
+	value := int64(len(data)) - int64(offset) - int64(p.opts.LengthAdjustment)
+	if value < 0 || !p.fits(uint64(value)) {
+		log.Error("[lengthfield] packet length does not fit in length field: ", value)
+		return nil
+	}
+
+	ret := make([]byte, 0, len(data)+p.opts.LengthFieldLength)
+	ret = append(ret, data[:offset]...)
+	ret = p.appendLength(ret, uint64(value))
+	return append(ret, data[offset:]...)
+}
+
+// ConnIndependentPacket：长度字段只由 data 决定，打包结果可以在连接间共享
+func (p *Protocol) ConnIndependentPacket() {}
+
+// length：解码长度字段
+func (p *Protocol) length(b []byte) uint64 {
+	switch p.opts.LengthFieldLength {
+	case 1:
+		return uint64(b[0])
+	case 2:
+		return uint64(p.opts.Order.Uint16(b))
+	case 4:
+		return uint64(p.opts.Order.Uint32(b))
+	default:
+		return p.opts.Order.Uint64(b)
+	}
+}
+
+// fits：v 能否用长度字段表示
+func (p *Protocol) fits(v uint64) bool {
+	if p.opts.LengthFieldLength == 8 {
+		return true
+	}
+	return v < 1<<(8*uint(p.opts.LengthFieldLength))
+}
+
+// appendLength：将长度字段追加到 dst
+func (p *Protocol) appendLength(dst []byte, v uint64) []byte {
+	switch p.opts.LengthFieldLength {
+	case 1:
+		return append(dst, byte(v))
+	case 2:
+		return p.opts.Order.AppendUint16(dst, uint16(v))
+	case 4:
+		return p.opts.Order.AppendUint32(dst, uint32(v))
+	default:
+		return p.opts.Order.AppendUint64(dst, v)
+	}
+}
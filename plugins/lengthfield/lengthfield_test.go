@@ -0,0 +1,217 @@
+package lengthfield
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/tool/encoding"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+func mustNew(t *testing.T, opts Options) *Protocol {
+	p, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// TestProtocol_UnPacket：Netty LengthFieldBasedFrameDecoder 文档中的几种典型格式
+func TestProtocol_UnPacket(t *testing.T) {
+	cases := []struct {
+		name   string
+		opts   Options
+		frame  []byte
+		expect []byte
+	}{
+		{
+			name:   "length only",
+			opts:   Options{LengthFieldLength: 2},
+			frame:  []byte{0x00, 0x03, 'a', 'b', 'c'},
+			expect: []byte{0x00, 0x03, 'a', 'b', 'c'},
+		},
+		{
+			name:   "strip header",
+			opts:   Options{LengthFieldLength: 2, InitialBytesToStrip: 2},
+			frame:  []byte{0x00, 0x03, 'a', 'b', 'c'},
+			expect: []byte("abc"),
+		},
+		{
+			name:   "length includes header",
+			opts:   Options{LengthFieldLength: 2, LengthAdjustment: -2, InitialBytesToStrip: 2},
+			frame:  []byte{0x00, 0x05, 'a', 'b', 'c'},
+			expect: []byte("abc"),
+		},
+		{
+			name:   "header before length",
+			opts:   Options{LengthFieldOffset: 2, LengthFieldLength: 4},
+			frame:  []byte{0xca, 0xfe, 0x00, 0x00, 0x00, 0x03, 'a', 'b', 'c'},
+			expect: []byte{0xca, 0xfe, 0x00, 0x00, 0x00, 0x03, 'a', 'b', 'c'},
+		},
+		{
+			name:   "header after length",
+			opts:   Options{LengthFieldLength: 1, LengthAdjustment: 2, InitialBytesToStrip: 1},
+			frame:  []byte{0x03, 0xca, 0xfe, 'a', 'b', 'c'},
+			expect: []byte{0xca, 0xfe, 'a', 'b', 'c'},
+		},
+		{
+			name:   "little endian",
+			opts:   Options{LengthFieldLength: 4, InitialBytesToStrip: 4, Order: encoding.LittleEndian},
+			frame:  []byte{0x03, 0x00, 0x00, 0x00, 'a', 'b', 'c'},
+			expect: []byte("abc"),
+		},
+	}
+	for _, tc := range cases {
+		p := mustNew(t, tc.opts)
+		buffer := ringbuffer.New(8)
+		_, _ = buffer.Write(tc.frame)
+		_, _ = buffer.Write(tc.frame)
+		for i := 0; i < 2; i++ {
+			ctx, out := p.UnPacket(&connection.Connection{}, buffer)
+			if ctx == nil || !bytes.Equal(out, tc.expect) {
+				t.Fatalf("%s: expect %v, but got %v", tc.name, tc.expect, out)
+			}
+		}
+		if buffer.Length() != 0 {
+			t.Fatalf("%s: expect an empty buffer, but got %d", tc.name, buffer.Length())
+		}
+	}
+}
+
+// TestProtocol_Partial：逐字节到达的帧在完整之前不会被消耗
+func TestProtocol_Partial(t *testing.T) {
+	p := mustNew(t, Options{LengthFieldLength: 4, InitialBytesToStrip: 4})
+	frame := p.Packet(nil, []byte("hello"))
+	buffer := ringbuffer.New(4)
+	for i, b := range frame {
+		_ = buffer.WriteByte(b)
+		ctx, out := p.UnPacket(&connection.Connection{}, buffer)
+		if i < len(frame)-1 {
+			if ctx != nil || out != nil {
+				t.Fatalf("expect no frame after %d bytes, but got %q", i+1, out)
+			}
+			if buffer.Length() != i+1 {
+				t.Fatalf("expect %d buffered bytes, but got %d", i+1, buffer.Length())
+			}
+			continue
+		}
+		if string(out) != "hello" || ctx.(uint64) != 5 {
+			t.Fatalf("expect hello, but got %v %q", ctx, out)
+		}
+	}
+}
+
+// TestProtocol_Empty：长度为 0 的帧同样返回非 nil 的 ctx
+func TestProtocol_Empty(t *testing.T) {
+	p := mustNew(t, Options{LengthFieldLength: 2, InitialBytesToStrip: 2})
+	buffer := ringbuffer.New(8)
+	_, _ = buffer.Write(p.Packet(nil, nil))
+	_, _ = buffer.Write(p.Packet(nil, []byte("x")))
+	if ctx, out := p.UnPacket(&connection.Connection{}, buffer); ctx == nil || len(out) != 0 {
+		t.Fatalf("expect an empty frame, but got %v %q", ctx, out)
+	}
+	if _, out := p.UnPacket(&connection.Connection{}, buffer); string(out) != "x" {
+		t.Fatalf("expect x, but got %q", out)
+	}
+}
+
+func TestProtocol_Packet(t *testing.T) {
+	p := mustNew(t, Options{LengthFieldOffset: 1, LengthFieldLength: 2, LengthAdjustment: -3})
+	if got := p.Packet(nil, []byte{0x7f, 'a', 'b'}); !bytes.Equal(got, []byte{0x7f, 0x00, 0x05, 'a', 'b'}) {
+		t.Fatalf("unexpected packet %v", got)
+	}
+	p = mustNew(t, Options{LengthFieldLength: 1})
+	if got := p.Packet(nil, make([]byte, 256)); got != nil {
+		t.Fatalf("expect nil for a packet that does not fit, but got %d bytes", len(got))
+	}
+	if _, err := New(Options{LengthFieldLength: 3}); err != ErrFieldLength {
+		t.Fatalf("expect ErrFieldLength, but got %v", err)
+	}
+}
+
+// TestProtocol_UnPacketErr：格式错误的帧返回错误，不消耗 buffer 中的数据
+func TestProtocol_UnPacketErr(t *testing.T) {
+	p := mustNew(t, Options{LengthFieldLength: 1, LengthAdjustment: -2, MaxFrameLength: 16})
+	cases := []struct {
+		frame []byte
+		err   error
+	}{
+		{[]byte{0x20}, ErrFrameTooLarge},
+		{[]byte{0x00}, ErrBadLength},
+	}
+	for _, c := range cases {
+		buffer := ringbuffer.New(8)
+		_, _ = buffer.Write(c.frame)
+		if _, _, err := p.UnPacketErr(&connection.Connection{}, buffer); err != c.err {
+			t.Fatalf("expect %v, but got %v", c.err, err)
+		}
+		if buffer.Length() != len(c.frame) {
+			t.Fatalf("expect the buffer to be untouched, but got %d bytes", buffer.Length())
+		}
+	}
+}
+
+type echoServer struct {
+	closed chan error
+}
+
+func (s *echoServer) OnConnect(c *connection.Connection) {}
+func (s *echoServer) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	return data
+}
+func (s *echoServer) OnClose(c *connection.Connection) {
+	if s.closed != nil {
+		s.closed <- c.CloseReason()
+	}
+}
+
+func TestProtocol_MaxFrameLength(t *testing.T) {
+	p := mustNew(t, Options{LengthFieldLength: 4, InitialBytesToStrip: 4, MaxFrameLength: 1024})
+	handler := &echoServer{closed: make(chan error, 1)}
+	s, err := fastnet.NewServer(handler,
+		fastnet.Address("127.0.0.1:0"),
+		fastnet.NumLoops(1),
+		fastnet.Protocol(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(time.Second * 3))
+
+	frame := p.Packet(nil, []byte("ping"))
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, len(frame))
+	if _, err := io.ReadFull(conn, buf); err != nil || !bytes.Equal(buf, frame) {
+		t.Fatalf("expect echo %v, but got %v, %v", frame, buf, err)
+	}
+
+	// 声明的长度超过上限，连接以 ErrFrameTooLarge 关闭
+	if _, err := conn.Write([]byte{0x00, 0x10, 0x00, 0x00}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Read(buf); err != io.EOF {
+		t.Fatalf("expect EOF, but got %v", err)
+	}
+	select {
+	case reason := <-handler.closed:
+		if reason != ErrFrameTooLarge {
+			t.Fatalf("expect ErrFrameTooLarge, but got %v", reason)
+		}
+	case <-time.After(time.Second * 3):
+		t.Fatal("timeout waiting for OnClose")
+	}
+}
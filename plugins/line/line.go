@@ -0,0 +1,103 @@
+// Package line 提供以换行符分隔的文本协议，适用于聊天、简单的命令行协议等场景
+package line
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// ErrLineTooLong：一行的长度超过 MaxLineLength
+var ErrLineTooLong = errors.New("line: line exceeds max line length")
+
+// DefaultMaxLineLength：默认的最大行长度
+const DefaultMaxLineLength = 64 * 1024
+
+// Options：协议配置
+type Options struct {
+	CRLF          bool // 为 true 时 Packet 以 \r\n 结尾，UnPacket 同时去掉行尾的 \r；否则只使用 \n
+	MaxLineLength int  // 一行的最大长度（不含分隔符），超过时关闭连接，默认 DefaultMaxLineLength
+}
+
+// Protocol：以 \n 分隔的文本协议，UnPacket 每次返回一行（不含分隔符），ctx 为该行的长度，
+// 因此空行同样会回调 OnMessage。没有找到分隔符时数据留在 buffer 中等待后续数据
+type Protocol struct {
+	opts Options
+}
+
+var _ connection.Protocol = &Protocol{}
+var _ connection.ConnIndependentPacket = &Protocol{}
+
+// New：创建按行分隔的协议
+func New(opts Options) *Protocol {
+	if opts.MaxLineLength <= 0 {
+		opts.MaxLineLength = DefaultMaxLineLength
+	}
+	return &Protocol{opts: opts}
+}
+
+// UnPacket：拆包，在 buffer 中查找 \n，返回第一行
+func (p *Protocol) UnPacket(c *connection.Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	first, end := buffer.PeekAll()
+	i := bytes.IndexByte(first, '\n')
+	if i < 0 {
+		if j := bytes.IndexByte(end, '\n'); j >= 0 {
+			i = len(first) + j
+		}
+	}
+	if i < 0 {
+		// 一直没有分隔符的数据不能无限缓冲，CRLF 模式下行尾的 \r 不计入长度
+		limit := p.opts.MaxLineLength
+		if p.opts.CRLF {
+			limit++
+		}
+		if buffer.Length() > limit {
+			p.fail(c, buffer)
+		}
+		return nil, nil
+	}
+
+	n := i
+	if p.opts.CRLF && n > 0 && byteAt(first, end, n-1) == '\r' {
+		n--
+	}
+	if n > p.opts.MaxLineLength {
+		p.fail(c, buffer)
+		return nil, nil
+	}
+	line := make([]byte, n)
+	_, _ = buffer.Read(line)
+	buffer.Retrieve(i + 1 - n)
+	return n, line
+}
+
+// Packet：装包，在 data 后追加分隔符
+func (p *Protocol) Packet(c *connection.Connection, data []byte) []byte {
+	ret := make([]byte, len(data), len(data)+2)
+	copy(ret, data)
+	if p.opts.CRLF {
+		ret = append(ret, '\r')
+	}
+	return append(ret, '\n')
+}
+
+// ConnIndependentPacket：只在 data 后追加换行，打包结果可以在连接间共享
+func (p *Protocol) ConnIndependentPacket() {}
+
+// fail：行过长，丢弃数据并关闭连接
+func (p *Protocol) fail(c *connection.Connection, buffer *ringbuffer.RingBuffer) {
+	log.Error("[line]", ErrLineTooLong)
+	buffer.RetrieveAll()
+	_ = c.Close()
+}
+
+// byteAt：PeekAll 返回的两段数据中下标为 i 的字节
+func byteAt(first, end []byte, i int) byte {
+	if i < len(first) {
+		return first[i]
+	}
+	return end[i-len(first)]
+}
@@ -0,0 +1,100 @@
+package line
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+type echoServer struct{}
+
+func (s *echoServer) OnConnect(c *connection.Connection) {}
+func (s *echoServer) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	return append([]byte("> "), data...)
+}
+func (s *echoServer) OnClose(c *connection.Connection) {}
+
+func newServer(t *testing.T, opts Options) *fastnet.Server {
+	s, err := fastnet.NewServer(&echoServer{},
+		fastnet.Address("127.0.0.1:0"),
+		fastnet.NumLoops(1),
+		fastnet.Protocol(New(opts)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	return s
+}
+
+// TestProtocol_Fragmented：行在 TCP 读取中被任意切开时不丢失数据
+func TestProtocol_Fragmented(t *testing.T) {
+	s := newServer(t, Options{CRLF: true})
+	defer s.Stop()
+
+	conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.(*net.TCPConn).SetNoDelay(true)
+	_ = conn.SetDeadline(time.Now().Add(time.Second * 3))
+
+	for _, part := range []string{"hel", "lo\r", "\nwor", "ld\r\n\r\nlast", "\r\n"} {
+		if _, err := conn.Write([]byte(part)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+	r := bufio.NewReader(conn)
+	for _, expect := range []string{"> hello\r\n", "> world\r\n", "> \r\n", "> last\r\n"} {
+		got, err := r.ReadString('\n')
+		if err != nil || got != expect {
+			t.Fatalf("expect %q, but got %q, %v", expect, got, err)
+		}
+	}
+}
+
+func TestProtocol_MaxLineLength(t *testing.T) {
+	s := newServer(t, Options{MaxLineLength: 16})
+	defer s.Stop()
+
+	conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(time.Second * 3))
+
+	// 一直不结束的行超过上限后连接被关闭
+	if _, err := conn.Write([]byte(strings.Repeat("x", 32))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expect EOF, but got %v", err)
+	}
+}
+
+func TestProtocol_UnPacket(t *testing.T) {
+	p := New(Options{})
+	buffer := ringbuffer.New(8)
+	_, _ = buffer.Write([]byte("a\r\nbc\n"))
+	if ctx, line := p.UnPacket(&connection.Connection{}, buffer); ctx.(int) != 2 || string(line) != "a\r" {
+		t.Fatalf("expect a\\r without CRLF, but got %q", line)
+	}
+	if _, line := p.UnPacket(&connection.Connection{}, buffer); string(line) != "bc" {
+		t.Fatalf("expect bc, but got %q", line)
+	}
+	if ctx, line := p.UnPacket(&connection.Connection{}, buffer); ctx != nil || line != nil {
+		t.Fatalf("expect no line, but got %q", line)
+	}
+	if got := string(p.Packet(nil, []byte("x"))); got != "x\n" {
+		t.Fatalf("expect x\\n, but got %q", got)
+	}
+}
@@ -0,0 +1,25 @@
+module github.com/Dongxiem/fastnet/plugins/metrics
+
+go 1.21
+
+require (
+	github.com/Dongxiem/fastnet v0.0.0
+	github.com/prometheus/client_golang v1.19.1
+)
+
+require (
+	github.com/RussellLuo/timingwheel v0.0.0-20201029015908-64de9d088c74 // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/libp2p/go-reuseport v0.0.2 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+)
+
+// 使用同一仓库中的 fastnet
+replace github.com/Dongxiem/fastnet => ../..
@@ -0,0 +1,96 @@
+// Package metrics：将 fastnet.Server 的运行统计导出为 Prometheus 指标。
+//
+// 指标在每次抓取时从 Server.Stats 及 Server.BufferStats 的快照生成，不在连接的读写路径上额外更新计数器。
+// 快照中的计数器都是原子读取的，抓取可以与事件循环并发进行。
+// 由于依赖 Prometheus 客户端库，metrics 是单独的 module，不使用时不会引入额外的依赖
+package metrics
+
+import (
+	"github.com/Dongxiem/fastnet"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const namespace = "fastnet"
+
+// loopLabel：按事件循环区分的指标的标签名，值与 fastnet.LabelLoop 的 pprof 标签相同
+const loopLabel = "loop"
+
+var (
+	connectionsDesc = prometheus.NewDesc(namespace+"_connections",
+		"Number of open connections.", nil, nil)
+	loopConnectionsDesc = prometheus.NewDesc(namespace+"_loop_connections",
+		"Number of open connections owned by each event loop.", []string{loopLabel}, nil)
+	loopQueueDesc = prometheus.NewDesc(namespace+"_loop_queue_length",
+		"Number of tasks waiting to run in each event loop.", []string{loopLabel}, nil)
+	acceptedDesc = prometheus.NewDesc(namespace+"_accepted_connections_total",
+		"Number of connections accepted by the listener, including rejected ones.", nil, nil)
+	readBytesDesc = prometheus.NewDesc(namespace+"_read_bytes_total",
+		"Number of bytes read from sockets.", nil, nil)
+	writtenBytesDesc = prometheus.NewDesc(namespace+"_written_bytes_total",
+		"Number of bytes written to sockets.", nil, nil)
+	messagesDesc = prometheus.NewDesc(namespace+"_messages_total",
+		"Number of messages unpacked and handed to OnMessage.", nil, nil)
+	bufferGetsDesc = prometheus.NewDesc(namespace+"_buffer_pool_gets_total",
+		"Number of ring buffers taken from the buffer pool.", nil, nil)
+	bufferPutsDesc = prometheus.NewDesc(namespace+"_buffer_pool_puts_total",
+		"Number of ring buffers returned to the buffer pool.", nil, nil)
+	bufferAllocsDesc = prometheus.NewDesc(namespace+"_buffer_pool_allocs_total",
+		"Number of ring buffers newly allocated because the pool was empty.", nil, nil)
+	bufferGrowsDesc = prometheus.NewDesc(namespace+"_buffer_grows_total",
+		"Number of times a ring buffer reallocated to grow.", nil, nil)
+)
+
+// Collector：从 Server 的统计快照生成指标的 prometheus.Collector
+type Collector struct {
+	server *fastnet.Server
+}
+
+var _ prometheus.Collector = &Collector{}
+
+// NewCollector：创建导出 s 的统计的 Collector，需要自行注册时使用，一般直接使用 Register
+func NewCollector(s *fastnet.Server) *Collector {
+	return &Collector{server: s}
+}
+
+// Register：将 s 的指标注册到 reg，reg 为 nil 时注册到 prometheus.DefaultRegisterer
+func Register(s *fastnet.Server, reg prometheus.Registerer) error {
+	if reg == nil {
+		reg = prometheus.DefaultRegisterer
+	}
+	return reg.Register(NewCollector(s))
+}
+
+// Describe：实现 prometheus.Collector
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- connectionsDesc
+	ch <- loopConnectionsDesc
+	ch <- loopQueueDesc
+	ch <- acceptedDesc
+	ch <- readBytesDesc
+	ch <- writtenBytesDesc
+	ch <- messagesDesc
+	ch <- bufferGetsDesc
+	ch <- bufferPutsDesc
+	ch <- bufferAllocsDesc
+	ch <- bufferGrowsDesc
+}
+
+// Collect：实现 prometheus.Collector，每次抓取时读取一次统计快照
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.server.Stats()
+	ch <- prometheus.MustNewConstMetric(connectionsDesc, prometheus.GaugeValue, float64(stats.Connections))
+	for i, label := range stats.LoopLabels {
+		ch <- prometheus.MustNewConstMetric(loopConnectionsDesc, prometheus.GaugeValue, float64(stats.LoopConnections[i]), label)
+		ch <- prometheus.MustNewConstMetric(loopQueueDesc, prometheus.GaugeValue, float64(stats.LoopQueues[i]), label)
+	}
+	ch <- prometheus.MustNewConstMetric(acceptedDesc, prometheus.CounterValue, float64(stats.Accepted))
+	ch <- prometheus.MustNewConstMetric(readBytesDesc, prometheus.CounterValue, float64(stats.BytesRead))
+	ch <- prometheus.MustNewConstMetric(writtenBytesDesc, prometheus.CounterValue, float64(stats.BytesWritten))
+	ch <- prometheus.MustNewConstMetric(messagesDesc, prometheus.CounterValue, float64(stats.Messages))
+
+	buffers := c.server.BufferStats()
+	ch <- prometheus.MustNewConstMetric(bufferGetsDesc, prometheus.CounterValue, float64(buffers.Gets))
+	ch <- prometheus.MustNewConstMetric(bufferPutsDesc, prometheus.CounterValue, float64(buffers.Puts))
+	ch <- prometheus.MustNewConstMetric(bufferAllocsDesc, prometheus.CounterValue, float64(buffers.Allocs))
+	ch <- prometheus.MustNewConstMetric(bufferGrowsDesc, prometheus.CounterValue, float64(buffers.Grows))
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type echo struct{}
+
+func (e *echo) OnConnect(c *connection.Connection) {}
+func (e *echo) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	return data
+}
+func (e *echo) OnClose(c *connection.Connection) {}
+
+func TestRegister(t *testing.T) {
+	s, err := fastnet.NewServer(&echo{}, fastnet.Address("127.0.0.1:0"), fastnet.NumLoops(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	reg := prometheus.NewRegistry()
+	if err := Register(s, reg); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("hello\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bufio.NewReader(conn).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCollector(s)
+	if got := testutil.ToFloat64(collectorFor(c, connectionsDesc)); got != 1 {
+		t.Fatalf("expect 1 connection, but got %v", got)
+	}
+	if got := testutil.ToFloat64(collectorFor(c, readBytesDesc)); got != 6 {
+		t.Fatalf("expect 6 bytes read, but got %v", got)
+	}
+	if got := testutil.ToFloat64(collectorFor(c, messagesDesc)); got != 1 {
+		t.Fatalf("expect 1 message, but got %v", got)
+	}
+	// 每个事件循环一组按 loop 区分的指标
+	if n, err := testutil.GatherAndCount(reg, "fastnet_loop_connections", "fastnet_loop_queue_length"); err != nil || n != 4 {
+		t.Fatalf("expect 4 per-loop series, but got %d, %v", n, err)
+	}
+	problems, err := testutil.GatherAndLint(reg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(problems) != 0 {
+		t.Fatalf("lint problems: %v", problems)
+	}
+}
+
+// TestCollect_Concurrent：抓取与事件循环并发修改计数器，配合 -race 运行
+func TestCollect_Concurrent(t *testing.T) {
+	s, err := fastnet.NewServer(&echo{}, fastnet.Address("127.0.0.1:0"), fastnet.NumLoops(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+	reg := prometheus.NewRegistry()
+	if err := Register(s, reg); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			for j := 0; j < 50; j++ {
+				if _, err := conn.Write([]byte("ping\n")); err != nil {
+					t.Error(err)
+					return
+				}
+				if _, err := r.ReadString('\n'); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for {
+		if _, err := reg.Gather(); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+	}
+}
+
+// descCollector：只输出一个指标的 Collector，用于 testutil.ToFloat64
+type descCollector struct {
+	c    *Collector
+	desc *prometheus.Desc
+}
+
+func collectorFor(c *Collector, desc *prometheus.Desc) prometheus.Collector {
+	return &descCollector{c: c, desc: desc}
+}
+
+func (d *descCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- d.desc
+}
+
+func (d *descCollector) Collect(ch chan<- prometheus.Metric) {
+	all := make(chan prometheus.Metric, 64)
+	d.c.Collect(all)
+	close(all)
+	for m := range all {
+		if m.Desc() == d.desc {
+			ch <- m
+		}
+	}
+}
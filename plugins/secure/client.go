@@ -0,0 +1,113 @@
+package secure
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+)
+
+// Conn：加密层的客户端实现，包装一个 net.Conn 作为握手发起方
+type Conn struct {
+	net.Conn
+	cfg     Config
+	rd      *bufio.Reader
+	session *session
+	pending []byte // 已解密但未被读取的数据
+}
+
+// Client：在 conn 上以发起方身份完成握手，返回加密后的连接
+func Client(conn net.Conn, cfg *Config) (*Conn, error) {
+	c, err := cfg.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	sc := &Conn{Conn: conn, cfg: c, rd: bufio.NewReader(conn)}
+	if err := sc.handshake(); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+func (c *Conn) handshake() error {
+	hs, err := newHandshakeState(c.cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Conn.Write(appendFrame(nil, hs.message())); err != nil {
+		return err
+	}
+
+	msg, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+	s, err := hs.split(msg, true)
+	if err != nil {
+		return err
+	}
+
+	// 校验响应方发送的确认帧，PSK 不一致时在此处失败
+	confirm, err := c.readFrame()
+	if err != nil {
+		return err
+	}
+	if _, err := s.recv.open(confirm); err != nil {
+		return err
+	}
+	c.session = s
+	return nil
+}
+
+// Read：读取解密后的数据
+func (c *Conn) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		frame, err := c.readFrame()
+		if err != nil {
+			return 0, err
+		}
+		if c.pending, err = c.session.recv.open(frame); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write：加密数据并写出，超过 MaxFrameSize 的数据会被拆分为多个帧
+func (c *Conn) Write(p []byte) (int, error) {
+	maxPlain := c.cfg.MaxFrameSize - c.session.send.aead.Overhead()
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > maxPlain {
+			chunk = chunk[:maxPlain]
+		}
+		frame := make([]byte, frameHeaderLen, frameHeaderLen+len(chunk)+c.session.send.aead.Overhead())
+		frame = c.session.send.seal(frame, chunk)
+		binary.BigEndian.PutUint32(frame, uint32(len(frame)-frameHeaderLen))
+		if _, err := c.Conn.Write(frame); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+		p = p[len(chunk):]
+	}
+	return written, nil
+}
+
+func (c *Conn) readFrame() ([]byte, error) {
+	var header [frameHeaderLen]byte
+	if _, err := io.ReadFull(c.rd, header[:]); err != nil {
+		return nil, err
+	}
+	n := int(binary.BigEndian.Uint32(header[:]))
+	if n > c.cfg.MaxFrameSize {
+		return nil, ErrFrameTooLarge
+	}
+	frame := make([]byte, n)
+	if _, err := io.ReadFull(c.rd, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
@@ -0,0 +1,195 @@
+package secure
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// protocolName：参与密钥派生，区分不同版本的握手
+const protocolName = "fastnet_secure_X25519_AESGCM_SHA256"
+
+// keySize：X25519 公钥长度
+const keySize = 32
+
+// Pattern：握手模式
+type Pattern byte
+
+const (
+	// PatternNN：双方只交换临时密钥，不做身份认证，仅提供机密性和完整性
+	PatternNN Pattern = iota + 1
+	// PatternNNpsk0：在 NN 的基础上混入预共享密钥，只有持有相同 PSK 的对端才能完成握手
+	PatternNNpsk0
+)
+
+// 握手相关错误
+var (
+	ErrBadHandshake  = errors.New("secure: malformed handshake message")
+	ErrPatternDiffer = errors.New("secure: handshake pattern mismatch")
+	ErrMissingPSK    = errors.New("secure: pre-shared key required by pattern")
+	ErrDecrypt       = errors.New("secure: message authentication failed")
+	ErrFrameTooLarge = errors.New("secure: frame exceeds max frame size")
+)
+
+// Config：加密层配置
+type Config struct {
+	Pattern          Pattern       // 握手模式，默认为 PatternNN
+	PSK              []byte        // 预共享密钥，PatternNNpsk0 时必须设置
+	MaxFrameSize     int           // 单个加密帧的最大长度，默认为 1MB
+	HandshakeTimeout time.Duration // 服务端连接建立后超过该时间仍未完成握手时，以 connection.ErrHandshakeTimeout 关闭连接，0 表示不限制
+}
+
+func (cfg *Config) withDefaults() (Config, error) {
+	c := Config{}
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Pattern == 0 {
+		c.Pattern = PatternNN
+	}
+	if c.Pattern == PatternNNpsk0 && len(c.PSK) == 0 {
+		return c, ErrMissingPSK
+	}
+	if c.MaxFrameSize <= 0 {
+		c.MaxFrameSize = 1 << 20
+	}
+	return c, nil
+}
+
+// cipherState：单向的 AEAD 加解密状态，nonce 为递增计数器
+type cipherState struct {
+	aead  cipher.AEAD
+	nonce uint64
+}
+
+func newCipherState(key []byte) (*cipherState, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &cipherState{aead: aead}, nil
+}
+
+func (cs *cipherState) nextNonce() []byte {
+	nonce := make([]byte, cs.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], cs.nonce)
+	cs.nonce++
+	return nonce
+}
+
+// seal：加密 plaintext 并追加到 dst
+func (cs *cipherState) seal(dst, plaintext []byte) []byte {
+	return cs.aead.Seal(dst, cs.nextNonce(), plaintext, nil)
+}
+
+// open：解密并校验 ciphertext
+func (cs *cipherState) open(ciphertext []byte) ([]byte, error) {
+	plaintext, err := cs.aead.Open(nil, cs.nextNonce(), ciphertext, nil)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// session：握手完成后双方的加解密状态
+type session struct {
+	send *cipherState
+	recv *cipherState
+}
+
+// handshakeState：一次握手中本端的临时密钥
+type handshakeState struct {
+	cfg  Config
+	priv *ecdh.PrivateKey
+}
+
+func newHandshakeState(cfg Config) (*handshakeState, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &handshakeState{cfg: cfg, priv: priv}, nil
+}
+
+// message：本端握手消息，格式为 [pattern][公钥]
+func (hs *handshakeState) message() []byte {
+	return append([]byte{byte(hs.cfg.Pattern)}, hs.priv.PublicKey().Bytes()...)
+}
+
+// split：根据对端握手消息计算共享密钥并派生出双向的会话密钥
+func (hs *handshakeState) split(peerMsg []byte, initiator bool) (*session, error) {
+	if len(peerMsg) < 1+keySize {
+		return nil, ErrBadHandshake
+	}
+	if Pattern(peerMsg[0]) != hs.cfg.Pattern {
+		return nil, ErrPatternDiffer
+	}
+	peerPub, err := ecdh.X25519().NewPublicKey(peerMsg[1 : 1+keySize])
+	if err != nil {
+		return nil, ErrBadHandshake
+	}
+	shared, err := hs.priv.ECDH(peerPub)
+	if err != nil {
+		return nil, err
+	}
+
+	localPub := hs.priv.PublicKey().Bytes()
+	initiatorPub, responderPub := localPub, peerPub.Bytes()
+	if !initiator {
+		initiatorPub, responderPub = responderPub, initiatorPub
+	}
+
+	// 握手记录作为 salt，共享密钥（以及 PSK）作为输入密钥材料
+	h := sha256.New()
+	h.Write([]byte(protocolName))
+	h.Write([]byte{byte(hs.cfg.Pattern)})
+	h.Write(initiatorPub)
+	h.Write(responderPub)
+	ikm := shared
+	if hs.cfg.Pattern == PatternNNpsk0 {
+		ikm = append(ikm, hs.cfg.PSK...)
+	}
+	i2r, r2i := hkdf(h.Sum(nil), ikm)
+
+	i2rState, err := newCipherState(i2r)
+	if err != nil {
+		return nil, err
+	}
+	r2iState, err := newCipherState(r2i)
+	if err != nil {
+		return nil, err
+	}
+	if initiator {
+		return &session{send: i2rState, recv: r2iState}, nil
+	}
+	return &session{send: r2iState, recv: i2rState}, nil
+}
+
+// hkdf：HKDF-SHA256，输出两段 32 字节的密钥
+func hkdf(salt, ikm []byte) (k1, k2 []byte) {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(ikm)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	expand.Write([]byte(protocolName))
+	expand.Write([]byte{1})
+	k1 = expand.Sum(nil)
+
+	expand.Reset()
+	expand.Write(k1)
+	expand.Write([]byte(protocolName))
+	expand.Write([]byte{2})
+	k2 = expand.Sum(nil)
+	return
+}
@@ -0,0 +1,192 @@
+package secure
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// frameHeaderLen：帧头长度，帧格式为 [4 字节大端长度][内容]
+const frameHeaderLen = 4
+
+const sessionKey = "fastnet_secure_session"
+
+// serverSession：服务端单个连接的加密状态
+type serverSession struct {
+	*session                        // 握手完成前为 nil
+	plain    *ringbuffer.RingBuffer // 解密后的明文，交给内层协议拆包
+	pending  [][]byte               // 握手完成前待发送的明文，握手完成后随握手消息一起加密发送
+	failed   bool                   // 握手或解密失败，后续数据全部丢弃
+}
+
+// Protocol：加密层协议，握手完成后对内层协议的数据进行透明加解密，
+// 可以包装任意 connection.Protocol，如 &connection.DefaultProtocol{}
+type Protocol struct {
+	inner connection.Protocol
+	cfg   Config
+}
+
+var (
+	_ connection.Protocol          = &Protocol{}
+	_ connection.HandshakeProtocol = &Protocol{}
+)
+
+// New：创建加密层 Protocol，inner 为内层协议
+func New(inner connection.Protocol, cfg *Config) (*Protocol, error) {
+	c, err := cfg.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+	if inner == nil {
+		inner = &connection.DefaultProtocol{}
+	}
+	return &Protocol{inner: inner, cfg: c}, nil
+}
+
+// HandshakeTimeout：实现 connection.HandshakeProtocol，由 Config.HandshakeTimeout 设置
+func (p *Protocol) HandshakeTimeout() time.Duration {
+	return p.cfg.HandshakeTimeout
+}
+
+// HandshakeDone：实现 connection.HandshakeProtocol
+func (p *Protocol) HandshakeDone(c *connection.Connection) bool {
+	v, ok := c.Get(sessionKey)
+	return ok && v.(*serverSession).session != nil
+}
+
+// UnPacket：拆包，首个帧为握手消息，之后的帧解密后交给内层协议
+func (p *Protocol) UnPacket(c *connection.Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	s := p.serverSession(c)
+	if s.session == nil && !s.failed {
+		msg, ok, err := readFrame(buffer, p.cfg.MaxFrameSize)
+		if !ok {
+			if err != nil {
+				p.fail(c, s, err)
+			}
+			return nil, nil
+		}
+		if err := p.respond(c, s, msg); err != nil {
+			p.fail(c, s, err)
+			return nil, nil
+		}
+	}
+
+	if s.failed {
+		buffer.RetrieveAll()
+		return nil, nil
+	}
+
+	// 解密所有完整的帧，不完整的帧留在 buffer 中等待后续数据
+	for {
+		frame, ok, err := readFrame(buffer, p.cfg.MaxFrameSize)
+		if err != nil {
+			p.fail(c, s, err)
+			return nil, nil
+		}
+		if !ok {
+			break
+		}
+		plaintext, err := s.recv.open(frame)
+		if err != nil {
+			p.fail(c, s, err)
+			return nil, nil
+		}
+		_, _ = s.plain.Write(plaintext)
+	}
+
+	return p.inner.UnPacket(c, s.plain)
+}
+
+// Packet：装包，内层协议打包后加密为一个帧，握手完成前的数据会暂存到握手完成后再发送
+func (p *Protocol) Packet(c *connection.Connection, data []byte) []byte {
+	s := p.serverSession(c)
+	if s.failed {
+		log.Error("[secure] packet after handshake failed")
+		return nil
+	}
+	plaintext := p.inner.Packet(c, data)
+	if s.session == nil {
+		s.pending = append(s.pending, append([]byte{}, plaintext...))
+		return nil
+	}
+	return s.seal(make([]byte, 0, frameHeaderLen+len(plaintext)+s.send.aead.Overhead()), plaintext)
+}
+
+// serverSession：返回连接的加密状态，不存在时创建一个等待握手的状态
+func (p *Protocol) serverSession(c *connection.Connection) *serverSession {
+	if v, ok := c.Get(sessionKey); ok {
+		return v.(*serverSession)
+	}
+	s := &serverSession{}
+	c.Set(sessionKey, s)
+	return s
+}
+
+// seal：将 plaintext 加密为一个帧追加到 dst
+func (s *serverSession) seal(dst, plaintext []byte) []byte {
+	start := len(dst)
+	dst = append(dst, make([]byte, frameHeaderLen)...)
+	dst = s.send.seal(dst, plaintext)
+	binary.BigEndian.PutUint32(dst[start:], uint32(len(dst)-start-frameHeaderLen))
+	return dst
+}
+
+// respond：作为响应方处理发起方的握手消息，回写本端握手消息及一个空的加密确认帧
+func (p *Protocol) respond(c *connection.Connection, s *serverSession, msg []byte) error {
+	hs, err := newHandshakeState(p.cfg)
+	if err != nil {
+		return err
+	}
+	if s.session, err = hs.split(msg, false); err != nil {
+		return err
+	}
+
+	s.plain = ringbuffer.New(1024)
+
+	out := appendFrame(nil, hs.message())
+	out = appendFrame(out, s.send.seal(nil, nil))
+	// 握手完成前暂存的数据紧跟在确认帧之后发送
+	for _, data := range s.pending {
+		out = s.seal(out, data)
+	}
+	s.pending = nil
+	c.SendInLoop(out)
+	return nil
+}
+
+// fail：握手或解密失败，关闭连接
+func (p *Protocol) fail(c *connection.Connection, s *serverSession, err error) {
+	log.Error("[secure]", err)
+	s.failed = true
+	s.pending = nil
+	_ = c.Close()
+}
+
+// appendFrame：将 data 封装为帧追加到 dst
+func appendFrame(dst, data []byte) []byte {
+	var header [frameHeaderLen]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(data)))
+	dst = append(dst, header[:]...)
+	return append(dst, data...)
+}
+
+// readFrame：从 buffer 中读取一个完整的帧，数据不足时不消耗 buffer
+func readFrame(buffer *ringbuffer.RingBuffer, maxFrameSize int) (frame []byte, ok bool, err error) {
+	if buffer.Length() < frameHeaderLen {
+		return nil, false, nil
+	}
+	n := int(buffer.PeekUint32())
+	if n > maxFrameSize {
+		return nil, false, ErrFrameTooLarge
+	}
+	if buffer.Length() < frameHeaderLen+n {
+		return nil, false, nil
+	}
+	buffer.Retrieve(frameHeaderLen)
+	frame = make([]byte, n)
+	_, _ = buffer.Read(frame)
+	return frame, true, nil
+}
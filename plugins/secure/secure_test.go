@@ -0,0 +1,226 @@
+package secure
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+)
+
+type echoServer struct{}
+
+func (s *echoServer) OnConnect(c *connection.Connection) {}
+func (s *echoServer) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	return data
+}
+func (s *echoServer) OnClose(c *connection.Connection) {}
+
+func startServer(t *testing.T, addr string, cfg *Config) *fastnet.Server {
+	p, err := New(&connection.DefaultProtocol{}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := fastnet.NewServer(&echoServer{},
+		fastnet.Address(addr),
+		fastnet.NumLoops(2),
+		fastnet.Protocol(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	return s
+}
+
+func TestSecure_RoundTrip(t *testing.T) {
+	for port, cfg := range map[string]*Config{
+		"1841": {Pattern: PatternNN},
+		"1843": {Pattern: PatternNNpsk0, PSK: []byte("fastnet psk")},
+	} {
+		s := startServer(t, ":"+port, cfg)
+
+		conn, err := net.DialTimeout("tcp", "127.0.0.1:"+port, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sc, err := Client(conn, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, size := range []int{1, 100, 64 * 1024} {
+			data := bytes.Repeat([]byte{'a'}, size)
+			if _, err := sc.Write(data); err != nil {
+				t.Fatal(err)
+			}
+			got := make([]byte, size)
+			if _, err := io.ReadFull(sc, got); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, got) {
+				t.Fatalf("pattern %d: echo mismatch for size %d", cfg.Pattern, size)
+			}
+		}
+
+		_ = sc.Close()
+		s.Stop()
+	}
+}
+
+func TestSecure_PSKMismatch(t *testing.T) {
+	s := startServer(t, ":1842", &Config{Pattern: PatternNNpsk0, PSK: []byte("server")})
+	defer s.Stop()
+
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:1842", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := Client(conn, &Config{Pattern: PatternNNpsk0, PSK: []byte("client")}); err != ErrDecrypt {
+		t.Fatalf("expect ErrDecrypt, but got %v", err)
+	}
+}
+
+// TestSecure_Broadcast：每个连接的会话密钥不同，广播的数据需要对每个连接分别加密
+func TestSecure_Broadcast(t *testing.T) {
+	cfg := &Config{Pattern: PatternNN}
+	s := startServer(t, ":1861", cfg)
+	defer s.Stop()
+
+	var clients []net.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := net.DialTimeout("tcp", "127.0.0.1:1861", time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sc, err := Client(conn, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer sc.Close()
+		// 收到回显说明服务端已完成握手
+		if _, err := sc.Write([]byte("ping")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadFull(sc, make([]byte, 4)); err != nil {
+			t.Fatal(err)
+		}
+		clients = append(clients, sc)
+	}
+
+	if result := s.Broadcast([]byte("news"), nil); result.Total() != len(clients) || result.Failed != 0 {
+		t.Fatalf("expect the broadcast to reach every client, but got %+v", result)
+	}
+	for _, sc := range clients {
+		_ = sc.SetReadDeadline(time.Now().Add(time.Second))
+		got := make([]byte, 4)
+		if _, err := io.ReadFull(sc, got); err != nil || string(got) != "news" {
+			t.Fatalf("expect broadcast data, but got %q, %v", got, err)
+		}
+	}
+}
+
+// greetServer：在 OnConnect 中发送数据，此时握手尚未完成
+type greetServer struct {
+	echoServer
+}
+
+func (s *greetServer) OnConnect(c *connection.Connection) {
+	_ = c.Send([]byte("hello"))
+}
+
+func TestSecure_SendBeforeHandshake(t *testing.T) {
+	cfg := &Config{Pattern: PatternNN}
+	p, err := New(&connection.DefaultProtocol{}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := fastnet.NewServer(&greetServer{}, fastnet.Address(":1862"), fastnet.Protocol(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:1862", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := Client(conn, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+
+	// 握手完成前发送的数据在握手完成后加密发出
+	_ = sc.SetReadDeadline(time.Now().Add(time.Second))
+	got := make([]byte, 5)
+	if _, err := io.ReadFull(sc, got); err != nil || string(got) != "hello" {
+		t.Fatalf("expect data sent from OnConnect, but got %q, %v", got, err)
+	}
+}
+
+// closeReasonServer：记录连接关闭的原因
+type closeReasonServer struct {
+	echoServer
+	closed chan error
+}
+
+func (s *closeReasonServer) OnClose(c *connection.Connection) {
+	s.closed <- c.CloseReason()
+}
+
+func TestSecure_HandshakeTimeout(t *testing.T) {
+	cfg := &Config{Pattern: PatternNN, HandshakeTimeout: time.Millisecond * 100}
+	p, err := New(&connection.DefaultProtocol{}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := &closeReasonServer{closed: make(chan error, 2)}
+	s, err := fastnet.NewServer(handler, fastnet.Address("127.0.0.1:0"), fastnet.Protocol(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, err := Client(conn, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sc.Close()
+
+	// 只发送了半个握手帧的客户端被关闭
+	raw, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if _, err := raw.Write([]byte{0, 0}); err != nil {
+		t.Fatal(err)
+	}
+	_ = raw.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if n, err := raw.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expect the server to close the connection, but got %d bytes, %v", n, err)
+	}
+	if reason := <-handler.closed; !errors.Is(reason, connection.ErrHandshakeTimeout) {
+		t.Fatalf("expect ErrHandshakeTimeout, but got %v", reason)
+	}
+
+	// 完成握手的连接超过握手时间后仍然可用
+	if _, err := sc.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	_ = sc.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := io.ReadFull(sc, make([]byte, 4)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,95 @@
+package tls
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// memConn：供 crypto/tls 使用的内存连接。
+// 密文由事件循环通过 feed 写入，crypto/tls 写出的密文暂存在 out 中由事件循环取走；
+// 当 crypto/tls 读完所有输入需要等待更多数据时，通过 idle 通知事件循环，从而将其驱动为同步的状态机
+type memConn struct {
+	mu  sync.Mutex
+	in  []byte
+	out []byte
+
+	feed chan struct{}   // 事件循环 -> tls goroutine：有新的输入
+	idle chan struct{}   // tls goroutine -> 事件循环：输入已读完，等待更多数据
+	done <-chan struct{} // 连接关闭
+
+	laddr, raddr net.Addr
+}
+
+func newMemConn(done <-chan struct{}, laddr, raddr net.Addr) *memConn {
+	return &memConn{
+		feed:  make(chan struct{}),
+		idle:  make(chan struct{}),
+		done:  done,
+		laddr: laddr,
+		raddr: raddr,
+	}
+}
+
+// Read：crypto/tls 读取密文，没有数据时通知事件循环并阻塞等待
+func (m *memConn) Read(p []byte) (int, error) {
+	for {
+		m.mu.Lock()
+		if len(m.in) > 0 {
+			n := copy(p, m.in)
+			m.in = m.in[n:]
+			m.mu.Unlock()
+			return n, nil
+		}
+		m.mu.Unlock()
+
+		select {
+		case m.idle <- struct{}{}:
+		case <-m.done:
+			return 0, io.EOF
+		}
+		select {
+		case <-m.feed:
+		case <-m.done:
+			return 0, io.EOF
+		}
+	}
+}
+
+// Write：crypto/tls 写出密文，暂存等待事件循环取走
+func (m *memConn) Write(p []byte) (int, error) {
+	m.mu.Lock()
+	m.out = append(m.out, p...)
+	m.mu.Unlock()
+	return len(p), nil
+}
+
+// push：事件循环写入密文
+func (m *memConn) push(p []byte) {
+	m.mu.Lock()
+	m.in = append(m.in, p...)
+	m.mu.Unlock()
+}
+
+// take：事件循环取走 crypto/tls 写出的密文
+func (m *memConn) take() []byte {
+	m.mu.Lock()
+	out := m.out
+	m.out = nil
+	m.mu.Unlock()
+	return out
+}
+
+func (m *memConn) Close() error                       { return nil }
+func (m *memConn) LocalAddr() net.Addr                { return m.laddr }
+func (m *memConn) RemoteAddr() net.Addr               { return m.raddr }
+func (m *memConn) SetDeadline(t time.Time) error      { return nil }
+func (m *memConn) SetReadDeadline(t time.Time) error  { return nil }
+func (m *memConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// addr：仅用于 memConn 的地址
+type addr string
+
+func (a addr) Network() string { return "tcp" }
+func (a addr) String() string  { return string(a) }
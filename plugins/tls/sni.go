@@ -0,0 +1,93 @@
+package tls
+
+import (
+	ctls "crypto/tls"
+	"strings"
+	"sync"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+)
+
+const routeKey = "fastnet_tls_route"
+
+// Route：一个服务器名对应的证书与处理器
+type Route struct {
+	Certificate ctls.Certificate
+	Handler     fastnet.Handler
+}
+
+// SNIRouter：根据客户端通过 SNI 请求的服务器名选择证书与处理器，实现在同一端口上托管多个 TLS 域名。
+// SNIRouter 本身实现了 fastnet.Handler，握手完成后将连接交给对应的处理器，未知的服务器名使用默认路由
+type SNIRouter struct {
+	mu     sync.RWMutex
+	routes map[string]*Route
+	def    *Route
+}
+
+var _ fastnet.Handler = &SNIRouter{}
+
+// NewSNIRouter：创建 SNIRouter，def 为默认路由
+func NewSNIRouter(def Route) *SNIRouter {
+	return &SNIRouter{
+		routes: make(map[string]*Route),
+		def:    &def,
+	}
+}
+
+// Handle：注册服务器名对应的路由，服务器名不区分大小写，可以在运行时调用，之后新建立的握手生效
+func (r *SNIRouter) Handle(serverName string, route Route) {
+	r.mu.Lock()
+	r.routes[strings.ToLower(serverName)] = &route
+	r.mu.Unlock()
+}
+
+// Route：获取服务器名对应的路由，未注册时返回默认路由
+func (r *SNIRouter) Route(serverName string) *Route {
+	r.mu.RLock()
+	route, ok := r.routes[strings.ToLower(serverName)]
+	r.mu.RUnlock()
+	if !ok {
+		return r.def
+	}
+	return route
+}
+
+// Protocol：创建使用该路由选择证书的 TLS Protocol，cfg 中的证书配置会被忽略
+func (r *SNIRouter) Protocol(cfg *ctls.Config, inner connection.Protocol, opts ...Option) *Protocol {
+	cfg = cfg.Clone()
+	cfg.GetCertificate = r.getCertificate
+	p := New(cfg, inner, opts...)
+	p.onHandshake = r.onHandshake
+	return p
+}
+
+func (r *SNIRouter) getCertificate(hello *ctls.ClientHelloInfo) (*ctls.Certificate, error) {
+	return &r.Route(hello.ServerName).Certificate, nil
+}
+
+// onHandshake：握手完成后确定连接的路由，并调用对应处理器的 OnConnect
+func (r *SNIRouter) onHandshake(c *connection.Connection) {
+	route := r.Route(ServerName(c))
+	c.Set(routeKey, route)
+	route.Handler.OnConnect(c)
+}
+
+// OnConnect：此时还未握手，无法确定路由，在握手完成后调用对应处理器的 OnConnect
+func (r *SNIRouter) OnConnect(c *connection.Connection) {}
+
+// OnMessage：交给连接对应的处理器处理
+func (r *SNIRouter) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	v, ok := c.Get(routeKey)
+	if !ok {
+		return nil
+	}
+	return v.(*Route).Handler.OnMessage(c, ctx, data)
+}
+
+// OnClose：握手完成的连接交给对应的处理器处理
+func (r *SNIRouter) OnClose(c *connection.Connection) {
+	if v, ok := c.Get(routeKey); ok {
+		v.(*Route).Handler.OnClose(c)
+	}
+}
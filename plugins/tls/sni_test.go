@@ -0,0 +1,64 @@
+package tls
+
+import (
+	ctls "crypto/tls"
+	"io"
+	"testing"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+)
+
+// nameServer：连接建立后先发送自己的名字，之后原样回显
+type nameServer struct {
+	echoServer
+	name string
+}
+
+func (s *nameServer) OnConnect(c *connection.Connection) {
+	_ = c.Send([]byte(s.name))
+}
+
+func TestSNIRouter(t *testing.T) {
+	router := NewSNIRouter(Route{Certificate: newCertificate(t, "default.fastnet"), Handler: &nameServer{name: "default"}})
+	router.Handle("a.fastnet", Route{Certificate: newCertificate(t, "a.fastnet"), Handler: &nameServer{name: "a"}})
+	router.Handle("B.fastnet", Route{Certificate: newCertificate(t, "b.fastnet"), Handler: &nameServer{name: "b"}})
+
+	s, err := fastnet.NewServer(router,
+		fastnet.Address(":1849"),
+		fastnet.NumLoops(2),
+		fastnet.Protocol(router.Protocol(&ctls.Config{}, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	for _, tc := range []struct {
+		serverName string
+		cn         string
+		handler    string
+	}{
+		{"a.fastnet", "a.fastnet", "a"},
+		{"b.fastnet", "b.fastnet", "b"},
+		{"unknown.fastnet", "default.fastnet", "default"},
+		{"", "default.fastnet", "default"},
+	} {
+		conn, err := ctls.Dial("tcp", "127.0.0.1:1849", &ctls.Config{ServerName: tc.serverName, InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cn := conn.ConnectionState().PeerCertificates[0].Subject.CommonName; cn != tc.cn {
+			t.Fatalf("server name %q: expect certificate %s, but got %s", tc.serverName, tc.cn, cn)
+		}
+		name := make([]byte, len(tc.handler))
+		if _, err := io.ReadFull(conn, name); err != nil {
+			t.Fatal(err)
+		}
+		if string(name) != tc.handler {
+			t.Fatalf("server name %q: expect handler %s, but got %s", tc.serverName, tc.handler, name)
+		}
+		echo(t, conn)
+		_ = conn.Close()
+	}
+}
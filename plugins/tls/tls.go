@@ -0,0 +1,247 @@
+// Package tls 提供基于 crypto/tls 的 TLS 协议封装，可以包装任意 connection.Protocol
+package tls
+
+import (
+	ctls "crypto/tls"
+	"errors"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+const sessionKey = "fastnet_tls_session"
+
+// ErrNoCertificate：没有可用的证书
+var ErrNoCertificate = errors.New("tls: no certificate configured")
+
+// session：单个连接的 TLS 状态，crypto/tls 运行在独立的 goroutine 中，由事件循环同步驱动
+type session struct {
+	mem   *memConn
+	conn  *ctls.Conn
+	plain *ringbuffer.RingBuffer // 解密后的明文，交给内层协议拆包
+	buf   []byte
+
+	handshakeDone int32         // 握手是否完成
+	serverName    string        // 客户端通过 SNI 请求的服务器名，握手完成后有效
+	notified      bool          // 是否已经调用过 onHandshake
+	pending       [][]byte      // 握手完成前待发送的明文
+	exited        chan struct{} // tls goroutine 退出
+	err           error         // tls goroutine 退出的原因
+}
+
+// Protocol：TLS 协议，UnPacket 将密文解密后交给内层协议，Packet 将内层协议打包后的数据加密
+type Protocol struct {
+	cfg   *ctls.Config
+	inner connection.Protocol
+	cert  atomic.Value // *ctls.Certificate，通过 SetCertificate 热更新
+
+	onHandshake      func(c *connection.Connection) // 握手完成后在事件循环中调用
+	handshakeTimeout time.Duration                  // 握手需要在连接建立后的多长时间内完成，0 表示不限制
+}
+
+var (
+	_ connection.Protocol          = &Protocol{}
+	_ connection.HandshakeProtocol = &Protocol{}
+)
+
+// Option：TLS Protocol 的可选配置
+type Option func(p *Protocol)
+
+// HandshakeTimeout：连接建立后超过 d 仍未完成握手时，以 connection.ErrHandshakeTimeout 关闭连接
+func HandshakeTimeout(d time.Duration) Option {
+	return func(p *Protocol) {
+		p.handshakeTimeout = d
+	}
+}
+
+// New：创建 TLS Protocol，inner 为内层协议，为 nil 时使用 connection.DefaultProtocol。
+// cfg 为 nil 时使用空的配置，握手前需要通过 SetCertificate 设置证书
+func New(cfg *ctls.Config, inner connection.Protocol, opts ...Option) *Protocol {
+	if inner == nil {
+		inner = &connection.DefaultProtocol{}
+	}
+	if cfg == nil {
+		cfg = &ctls.Config{}
+	}
+	p := &Protocol{inner: inner}
+	for _, o := range opts {
+		o(p)
+	}
+	p.cfg = cfg.Clone()
+	// 未设置 GetCertificate 时，由 Protocol 管理证书以支持运行时更换证书
+	if p.cfg.GetCertificate == nil {
+		if len(p.cfg.Certificates) > 0 {
+			cert := p.cfg.Certificates[0]
+			p.cert.Store(&cert)
+		}
+		// crypto/tls 在没有 SNI 时会直接使用 Certificates[0]，这里清空以保证总是走 GetCertificate
+		p.cfg.Certificates = nil
+		p.cfg.GetCertificate = p.getCertificate
+	}
+	return p
+}
+
+// SetCertificate：运行时更换证书，之后新建立的握手使用新证书，已建立的连接不受影响
+func (p *Protocol) SetCertificate(cert ctls.Certificate) {
+	p.cert.Store(&cert)
+}
+
+func (p *Protocol) getCertificate(*ctls.ClientHelloInfo) (*ctls.Certificate, error) {
+	cert, _ := p.cert.Load().(*ctls.Certificate)
+	if cert == nil {
+		return nil, ErrNoCertificate
+	}
+	return cert, nil
+}
+
+// HandshakeTimeout：实现 connection.HandshakeProtocol
+func (p *Protocol) HandshakeTimeout() time.Duration {
+	return p.handshakeTimeout
+}
+
+// HandshakeDone：实现 connection.HandshakeProtocol，客户端还没有发送任何数据时握手没有开始
+func (p *Protocol) HandshakeDone(c *connection.Connection) bool {
+	v, ok := c.Get(sessionKey)
+	return ok && atomic.LoadInt32(&v.(*session).handshakeDone) == 1
+}
+
+// UnPacket：拆包，将密文交给 crypto/tls 处理，握手数据直接回写给对端，解密后的明文交给内层协议拆包
+func (p *Protocol) UnPacket(c *connection.Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	s := p.session(c)
+
+	if buffer.Length() > 0 {
+		first, end := buffer.PeekAll()
+		s.mem.push(first)
+		s.mem.push(end)
+		buffer.RetrieveAll()
+		s.drive()
+		p.flush(c, s)
+	}
+
+	if s.err != nil && s.plain.Length() == 0 {
+		return nil, nil
+	}
+	return p.inner.UnPacket(c, s.plain)
+}
+
+// Packet：装包，内层协议打包后加密，握手完成前的数据会暂存到握手完成后再发送
+func (p *Protocol) Packet(c *connection.Connection, data []byte) []byte {
+	s := p.session(c)
+	plaintext := p.inner.Packet(c, data)
+	if atomic.LoadInt32(&s.handshakeDone) == 0 {
+		s.pending = append(s.pending, append([]byte{}, plaintext...))
+		return nil
+	}
+	if _, err := s.conn.Write(plaintext); err != nil {
+		log.Error("[tls] write:", err)
+		return nil
+	}
+	return s.mem.take()
+}
+
+// session：获取连接的 TLS 状态，第一次调用时创建并启动 tls goroutine
+func (p *Protocol) session(c *connection.Connection) *session {
+	if v, ok := c.Get(sessionKey); ok {
+		return v.(*session)
+	}
+
+	s := &session{
+		mem:    newMemConn(c.Done(), addr(""), addr(c.PeerAddr())),
+		plain:  ringbuffer.New(1024),
+		buf:    make([]byte, 16*1024),
+		exited: make(chan struct{}),
+	}
+	s.conn = ctls.Server(s.mem, p.cfg)
+	c.Set(sessionKey, s)
+
+	go s.run()
+	s.wait()
+	return s
+}
+
+// ServerName：获取连接通过 SNI 请求的服务器名，握手完成前或客户端未发送 SNI 时返回空字符串
+func ServerName(c *connection.Connection) string {
+	v, ok := c.Get(sessionKey)
+	if !ok {
+		return ""
+	}
+	s := v.(*session)
+	if atomic.LoadInt32(&s.handshakeDone) == 0 {
+		return ""
+	}
+	return s.serverName
+}
+
+// run：tls goroutine，完成握手后持续读取明文
+func (s *session) run() {
+	defer close(s.exited)
+	if err := s.conn.Handshake(); err != nil {
+		s.err = err
+		return
+	}
+	s.serverName = s.conn.ConnectionState().ServerName
+	atomic.StoreInt32(&s.handshakeDone, 1)
+
+	for {
+		n, err := s.conn.Read(s.buf)
+		if n > 0 {
+			_, _ = s.plain.Write(s.buf[:n])
+		}
+		if err != nil {
+			s.err = err
+			return
+		}
+	}
+}
+
+// drive：通知 tls goroutine 有新的密文，并等待其处理完所有输入
+func (s *session) drive() {
+	select {
+	case s.mem.feed <- struct{}{}:
+		s.wait()
+	case <-s.exited:
+	}
+}
+
+// wait：等待 tls goroutine 读完所有输入或退出
+func (s *session) wait() {
+	select {
+	case <-s.mem.idle:
+	case <-s.exited:
+	}
+}
+
+// flush：回写 crypto/tls 产生的握手等数据，握手完成后发送暂存的数据并调用 onHandshake，出错时关闭连接
+func (p *Protocol) flush(c *connection.Connection, s *session) {
+	if atomic.LoadInt32(&s.handshakeDone) == 1 && len(s.pending) > 0 {
+		for _, data := range s.pending {
+			if _, err := s.conn.Write(data); err != nil {
+				log.Error("[tls] write:", err)
+				break
+			}
+		}
+		s.pending = nil
+	}
+	if atomic.LoadInt32(&s.handshakeDone) == 1 && !s.notified {
+		s.notified = true
+		if p.onHandshake != nil {
+			p.onHandshake(c)
+		}
+	}
+
+	// 握手等数据作为本次拆包的输出，与解密出的消息的响应一起写出
+	if out := s.mem.take(); len(out) > 0 {
+		c.WriteBack(out)
+	}
+
+	if s.err != nil {
+		if s.err != io.EOF {
+			log.Error("[tls]", s.err)
+		}
+		_ = c.Close()
+	}
+}
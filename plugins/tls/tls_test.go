@@ -0,0 +1,228 @@
+package tls
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	ctls "crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/plugins/websocket"
+	"github.com/Dongxiem/fastnet/plugins/websocket/ws"
+)
+
+type echoServer struct{}
+
+func (s *echoServer) OnConnect(c *connection.Connection) {}
+func (s *echoServer) OnMessage(c *connection.Connection, ctx interface{}, data []byte) []byte {
+	return data
+}
+func (s *echoServer) OnClose(c *connection.Connection) {}
+
+// newCertificate：生成一个自签名证书
+func newCertificate(t *testing.T, commonName string) ctls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{commonName},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ctls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func dialAndEcho(t *testing.T, addr string) (*ctls.Conn, string) {
+	conn, err := ctls.Dial("tcp", addr, &ctls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo(t, conn)
+	return conn, conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func echo(t *testing.T, conn *ctls.Conn) {
+	data := []byte("hello fastnet")
+	if _, err := conn.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(data))
+	if _, err := io.ReadFull(conn, got); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(data) {
+		t.Fatalf("expect %s, but got %s", data, got)
+	}
+}
+
+func TestProtocol_SetCertificate(t *testing.T) {
+	p := New(&ctls.Config{Certificates: []ctls.Certificate{newCertificate(t, "old.fastnet")}}, nil)
+	s, err := fastnet.NewServer(&echoServer{},
+		fastnet.Address(":1844"),
+		fastnet.NumLoops(2),
+		fastnet.Protocol(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	oldConn, cn := dialAndEcho(t, "127.0.0.1:1844")
+	defer oldConn.Close()
+	if cn != "old.fastnet" {
+		t.Fatalf("expect old.fastnet, but got %s", cn)
+	}
+
+	p.SetCertificate(newCertificate(t, "new.fastnet"))
+
+	newConn, cn := dialAndEcho(t, "127.0.0.1:1844")
+	defer newConn.Close()
+	if cn != "new.fastnet" {
+		t.Fatalf("expect new.fastnet, but got %s", cn)
+	}
+
+	// 已建立的连接继续使用原来的会话
+	echo(t, oldConn)
+}
+
+// TestProtocol_NilConfig：cfg 为 nil 时使用空的配置，证书通过 SetCertificate 设置
+func TestProtocol_NilConfig(t *testing.T) {
+	p := New(nil, nil)
+	p.SetCertificate(newCertificate(t, "nil.fastnet"))
+	s, err := fastnet.NewServer(&echoServer{},
+		fastnet.Address(":1863"),
+		fastnet.Protocol(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	conn, cn := dialAndEcho(t, "127.0.0.1:1863")
+	defer conn.Close()
+	if cn != "nil.fastnet" {
+		t.Fatalf("expect nil.fastnet, but got %s", cn)
+	}
+}
+
+type wsEcho struct{}
+
+func (wsEcho) OnConnect(c *connection.Connection) {}
+func (wsEcho) OnMessage(c *connection.Connection, msg []byte) (ws.MessageType, []byte) {
+	return ws.MessageText, msg
+}
+func (wsEcho) OnClose(c *connection.Connection) {}
+
+// TestProtocol_WebSocket：TLS 位于 websocket 协议之下，握手数据与升级响应都经过 TLS
+func TestProtocol_WebSocket(t *testing.T) {
+	u := &ws.Upgrader{}
+	p := New(&ctls.Config{Certificates: []ctls.Certificate{newCertificate(t, "ws.fastnet")}}, websocket.New(u))
+	s, err := fastnet.NewServer(websocket.NewHandlerWrap(u, wsEcho{}),
+		fastnet.Address("127.0.0.1:0"),
+		fastnet.NumLoops(1),
+		fastnet.Protocol(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	conn, err := ctls.Dial("tcp", s.Addr(), &ctls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(3 * time.Second))
+
+	req := "GET /chat HTTP/1.1\r\nHost: ws.fastnet\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expect 101, but got %d", resp.StatusCode)
+	}
+
+	frame, err := ws.FrameToBytes(ws.NewTextFrame([]byte("hello")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatal(err)
+	}
+	got := make([]byte, len(frame))
+	if _, err := io.ReadFull(r, got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, frame) {
+		t.Fatalf("expect %q, but got %q", frame, got)
+	}
+}
+
+// closeReasonServer：记录连接关闭的原因
+type closeReasonServer struct {
+	echoServer
+	closed chan error
+}
+
+func (s *closeReasonServer) OnClose(c *connection.Connection) {
+	s.closed <- c.CloseReason()
+}
+
+func TestProtocol_HandshakeTimeout(t *testing.T) {
+	p := New(&ctls.Config{Certificates: []ctls.Certificate{newCertificate(t, "timeout.fastnet")}}, nil,
+		HandshakeTimeout(time.Millisecond*100))
+	handler := &closeReasonServer{closed: make(chan error, 2)}
+	s, err := fastnet.NewServer(handler,
+		fastnet.Address("127.0.0.1:0"),
+		fastnet.NumLoops(1),
+		fastnet.Protocol(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	// 完成握手的连接超过握手时间后仍然可用
+	conn, _ := dialAndEcho(t, s.Addr())
+	defer conn.Close()
+
+	// 只建立 TCP 连接而不发起握手的客户端被关闭
+	raw, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	_ = raw.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if n, err := raw.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expect the server to close the connection, but got %d bytes, %v", n, err)
+	}
+	if reason := <-handler.closed; !errors.Is(reason, connection.ErrHandshakeTimeout) {
+		t.Fatalf("expect ErrHandshakeTimeout, but got %v", reason)
+	}
+	echo(t, conn)
+}
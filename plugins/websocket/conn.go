@@ -0,0 +1,49 @@
+package websocket
+
+import (
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/plugins/websocket/ws"
+	"github.com/Dongxiem/fastnet/plugins/websocket/ws/util"
+)
+
+// Conn：对 connection.Connection 的封装，发送时自动封装 websocket 帧
+type Conn struct {
+	*connection.Connection
+}
+
+// NewConn：创建 websocket Conn
+func NewConn(c *connection.Connection) *Conn {
+	return &Conn{Connection: c}
+}
+
+// WriteMessage：将 data 封装为 messageType 类型的数据帧后发送，协商了 permessage-deflate 时压缩后发送
+func (c *Conn) WriteMessage(messageType ws.MessageType, data []byte) error {
+	return send(c.Connection, messageType, data)
+}
+
+// WriteClose：发送 close 帧，对端回复 close 帧后连接将被关闭
+func (c *Conn) WriteClose(reason string) error {
+	msg, err := util.PackCloseData(reason)
+	if err != nil {
+		return err
+	}
+	return c.Send(msg)
+}
+
+// SendText：将 s 封装为文本数据帧后发送，服务端发送的帧不带掩码，协商了 permessage-deflate 时压缩后发送，可以在任意 goroutine 中调用
+func SendText(c *connection.Connection, s string) error {
+	return send(c, ws.MessageText, []byte(s))
+}
+
+// SendBinary：将 b 封装为二进制数据帧后发送，其余同 SendText
+func SendBinary(c *connection.Connection, b []byte) error {
+	return send(c, ws.MessageBinary, b)
+}
+
+func send(c *connection.Connection, messageType ws.MessageType, data []byte) error {
+	msg, err := packFrame(c, messageType, data)
+	if err != nil {
+		return err
+	}
+	return c.Send(msg)
+}
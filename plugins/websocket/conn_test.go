@@ -0,0 +1,76 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/plugins/websocket/ws"
+)
+
+func TestPackFrame_Length(t *testing.T) {
+	for _, tc := range []struct {
+		size   int
+		header int
+	}{
+		{0, 2}, {125, 2}, {126, 4}, {65535, 4}, {65536, 10},
+	} {
+		frame, err := packFrame(&connection.Connection{}, ws.MessageBinary, bytes.Repeat([]byte("x"), tc.size))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(frame) != tc.header+tc.size {
+			t.Fatalf("size %d: expect %d header bytes, but got %d", tc.size, tc.header, len(frame)-tc.size)
+		}
+		// FIN | binary，不带掩码
+		if frame[0] != 0x82 || frame[1]&0x80 != 0 {
+			t.Fatalf("size %d: unexpected header % x", tc.size, frame[:2])
+		}
+		var length int
+		switch tc.header {
+		case 2:
+			length = int(frame[1])
+		case 4:
+			length = int(binary.BigEndian.Uint16(frame[2:4]))
+			if frame[1] != 126 {
+				t.Fatalf("size %d: expect 16-bit length marker, but got %d", tc.size, frame[1])
+			}
+		case 10:
+			length = int(binary.BigEndian.Uint64(frame[2:10]))
+			if frame[1] != 127 {
+				t.Fatalf("size %d: expect 64-bit length marker, but got %d", tc.size, frame[1])
+			}
+		}
+		if length != tc.size {
+			t.Fatalf("size %d: unexpected encoded length %d", tc.size, length)
+		}
+	}
+}
+
+// sendHandler：通过 SendText 及 SendBinary 回复
+type sendHandler struct {
+	messageRecorder
+}
+
+func (h *sendHandler) OnMessage(c *connection.Connection, msg []byte) (ws.MessageType, []byte) {
+	if err := SendText(c, "text:"+string(msg)); err != nil {
+		panic(err)
+	}
+	if err := SendBinary(c, msg); err != nil {
+		panic(err)
+	}
+	return ws.MessageText, nil
+}
+
+func TestSendTextBinary(t *testing.T) {
+	conn, r := dialServer(t, startServer(t, New(&ws.Upgrader{}), &sendHandler{}))
+
+	writeFrame(t, conn, true, ws.OpBinary, []byte("hi"))
+	if h, payload := readFrameFrom(t, r); !h.Fin || h.OpCode != ws.OpText || string(payload) != "text:hi" {
+		t.Fatalf("expect text frame, but got %+v %q", h, payload)
+	}
+	if h, payload := readFrameFrom(t, r); !h.Fin || h.OpCode != ws.OpBinary || string(payload) != "hi" {
+		t.Fatalf("expect binary frame, but got %+v %q", h, payload)
+	}
+}
@@ -0,0 +1,196 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"sync"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/plugins/websocket/ws"
+	"github.com/gobwas/httphead"
+)
+
+const deflateKey = "fastnet_ws_deflate"
+
+// rsv1：RSV1 位在 ws.Header.Rsv 中的取值，permessage-deflate 用其标记压缩过的消息
+const rsv1 byte = 0x04
+
+// deflateTail：同步刷新产生的结尾，发送前去掉，解压前补上（RFC 7692 7.2.1）
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// ErrMessageTooLarge：解压后的消息超过限制
+var ErrMessageTooLarge = errors.New("websocket: decompressed message exceeds limit")
+
+// deflateContext：一组压缩与解压上下文
+type deflateContext struct {
+	w   *flate.Writer
+	r   io.ReadCloser
+	buf bytes.Buffer
+}
+
+// Deflate：permessage-deflate 扩展（RFC 7692），只协商 no_context_takeover 模式，
+// 每条消息独立压缩，上下文在消息之间不保留任何状态。pooled 为 true 时上下文只在处理消息时
+// 从 sync.Pool 中取出，所有连接共享；否则每个连接在协商成功后分配自己的上下文，一直持有到连接关闭
+type Deflate struct {
+	level          int
+	pooled         bool
+	maxMessageSize int
+	contexts       sync.Pool // *deflateContext
+}
+
+// NewDeflate：创建 permessage-deflate 扩展，level 同 flate.NewWriter。连接较多且生命周期较短时
+// 应使用 pooled，flate 的压缩上下文有数百 KB，按连接分配会带来大量的内存占用与 GC 压力
+func NewDeflate(level int, pooled bool) *Deflate {
+	return &Deflate{level: level, pooled: pooled, maxMessageSize: 1 << 20}
+}
+
+// SetMaxMessageSize：设置解压后消息的最大长度，默认 1MB，防止解压炸弹
+func (d *Deflate) SetMaxMessageSize(n int) {
+	d.maxMessageSize = n
+}
+
+// newContext：分配一组新的上下文
+func (d *Deflate) newContext() *deflateContext {
+	w, err := flate.NewWriter(nil, d.level)
+	if err != nil {
+		// level 不合法时退回默认压缩级别
+		w, _ = flate.NewWriter(nil, flate.DefaultCompression)
+	}
+	return &deflateContext{w: w, r: flate.NewReader(nil)}
+}
+
+// deflateSession：连接协商的结果，ctx 只在不使用池时存在
+type deflateSession struct {
+	d   *Deflate
+	ctx *deflateContext
+}
+
+// newSession：创建连接的状态
+func (d *Deflate) newSession() *deflateSession {
+	s := &deflateSession{d: d}
+	if !d.pooled {
+		s.ctx = d.newContext()
+	}
+	return s
+}
+
+// Negotiate：选择客户端请求的 permessage-deflate 扩展，可以直接作为 ws.Upgrader.ExtensionCustom，
+// 要求对端的服务端窗口不小于 flate 固定使用的 32KB 窗口。客户端没有请求该扩展时不压缩。
+// 回复中总是带有 server_no_context_takeover 及 client_no_context_takeover，双方都不在消息之间保留上下文
+func (d *Deflate) Negotiate(c *connection.Connection, header []byte, selected []httphead.Option) ([]httphead.Option, bool) {
+	offers, ok := httphead.ParseOptions(header, nil)
+	if !ok {
+		return selected, false
+	}
+	for _, offer := range offers {
+		if string(offer.Name) != "permessage-deflate" {
+			continue
+		}
+		if bits, ok := offer.Parameters.Get("server_max_window_bits"); ok && len(bits) > 0 && string(bits) != "15" {
+			continue
+		}
+		// 解压不受客户端窗口大小的限制，回复中不设置 client_max_window_bits，但客户端给出的取值必须合法
+		if bits, ok := offer.Parameters.Get("client_max_window_bits"); ok && len(bits) > 0 && !validWindowBits(bits) {
+			continue
+		}
+		c.Set(deflateKey, d.newSession())
+		return append(selected, httphead.NewOption("permessage-deflate", map[string]string{
+			"server_no_context_takeover": "",
+			"client_no_context_takeover": "",
+		})), true
+	}
+	return selected, true
+}
+
+// validWindowBits：窗口大小参数的取值为 8 到 15（RFC 7692 7.1.2）
+func validWindowBits(bits []byte) bool {
+	n, err := strconv.Atoi(string(bits))
+	return err == nil && n >= 8 && n <= 15
+}
+
+// deflateSessionOf：连接协商成功时返回其状态，否则返回 nil
+func deflateSessionOf(c *connection.Connection) *deflateSession {
+	if v, ok := c.Get(deflateKey); ok {
+		return v.(*deflateSession)
+	}
+	return nil
+}
+
+// acquire：获取处理一条消息使用的上下文
+func (s *deflateSession) acquire() *deflateContext {
+	if s.ctx != nil {
+		return s.ctx
+	}
+	if ctx, ok := s.d.contexts.Get().(*deflateContext); ok {
+		return ctx
+	}
+	return s.d.newContext()
+}
+
+// release：归还 acquire 获取的上下文
+func (s *deflateSession) release(ctx *deflateContext) {
+	if s.ctx == nil {
+		s.d.contexts.Put(ctx)
+	}
+}
+
+// compress：压缩一条消息
+func (s *deflateSession) compress(p []byte) ([]byte, error) {
+	ctx := s.acquire()
+	defer s.release(ctx)
+	ctx.buf.Reset()
+	ctx.w.Reset(&ctx.buf)
+	if _, err := ctx.w.Write(p); err != nil {
+		return nil, err
+	}
+	if err := ctx.w.Flush(); err != nil {
+		return nil, err
+	}
+	out := bytes.TrimSuffix(ctx.buf.Bytes(), deflateTail)
+	return append([]byte(nil), out...), nil
+}
+
+// decompress：解压一条消息，解压后超过 maxMessageSize 时返回 ErrMessageTooLarge
+func (s *deflateSession) decompress(p []byte) ([]byte, error) {
+	ctx := s.acquire()
+	defer s.release(ctx)
+	if err := ctx.r.(flate.Resetter).Reset(io.MultiReader(bytes.NewReader(p), bytes.NewReader(deflateTail)), nil); err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(ctx.r, int64(s.d.maxMessageSize)+1))
+	// 补上的结尾只是同步刷新的空块，没有最后一个块的标记，读到结尾时返回 io.ErrUnexpectedEOF
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if len(data) > s.d.maxMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+	return data, nil
+}
+
+// packFrame：将 data 封装为 messageType 类型的数据帧，连接协商了 permessage-deflate 时压缩后设置 RSV1
+func packFrame(c *connection.Connection, messageType ws.MessageType, data []byte) ([]byte, error) {
+	var frame *ws.Frame
+	switch messageType {
+	case ws.MessageBinary:
+		frame = ws.NewBinaryFrame(data)
+	case ws.MessageText:
+		frame = ws.NewTextFrame(data)
+	default:
+		return nil, ws.ErrProtocolOpCodeReserved
+	}
+	if s := deflateSessionOf(c); s != nil {
+		compressed, err := s.compress(data)
+		if err != nil {
+			return nil, err
+		}
+		frame.Payload = compressed
+		frame.Header.Length = int64(len(compressed))
+		frame.Header.Rsv |= rsv1
+	}
+	return ws.FrameToBytes(frame)
+}
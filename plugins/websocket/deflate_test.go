@@ -0,0 +1,191 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/plugins/websocket/ws"
+	gorilla "github.com/gorilla/websocket"
+)
+
+func TestDeflate_Negotiate(t *testing.T) {
+	d := NewDeflate(flate.DefaultCompression, true)
+
+	c := &connection.Connection{}
+	selected, ok := d.Negotiate(c, []byte("permessage-deflate; server_max_window_bits=10, permessage-deflate; client_max_window_bits"), nil)
+	if !ok || len(selected) != 1 || string(selected[0].Name) != "permessage-deflate" {
+		t.Fatalf("permessage-deflate should be selected, but got %v", selected)
+	}
+	if _, ok := selected[0].Parameters.Get("server_no_context_takeover"); !ok {
+		t.Fatal("server_no_context_takeover should be negotiated")
+	}
+	if deflateSessionOf(c) == nil {
+		t.Fatal("session should be created")
+	}
+
+	c = &connection.Connection{}
+	if selected, _ := d.Negotiate(c, []byte("permessage-deflate; server_max_window_bits=10"), nil); len(selected) != 0 {
+		t.Fatalf("smaller window should be rejected, but got %v", selected)
+	}
+	if deflateSessionOf(c) != nil {
+		t.Fatal("session should not be created")
+	}
+}
+
+func TestDeflate_RoundTrip(t *testing.T) {
+	msg := bytes.Repeat([]byte("fastnet websocket "), 64)
+	for _, pooled := range []bool{true, false} {
+		s := NewDeflate(flate.BestSpeed, pooled).newSession()
+		for i := 0; i < 3; i++ {
+			compressed, err := s.compress(msg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(compressed) >= len(msg) || bytes.HasSuffix(compressed, deflateTail) {
+				t.Fatalf("pooled %v: unexpected compressed message % x", pooled, compressed)
+			}
+			plain, err := s.decompress(compressed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(plain, msg) {
+				t.Fatalf("pooled %v: round trip mismatch", pooled)
+			}
+		}
+	}
+}
+
+func TestDeflate_RFCExample(t *testing.T) {
+	// RFC 7692 7.2.3.1：没有上下文接管时 "Hello" 的压缩结果
+	s := NewDeflate(flate.DefaultCompression, true).newSession()
+	plain, err := s.decompress([]byte{0xf2, 0x48, 0xcd, 0xc9, 0xc9, 0x07, 0x00})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plain) != "Hello" {
+		t.Fatalf("expect Hello, but got %q", plain)
+	}
+}
+
+func TestDeflate_MessageTooLarge(t *testing.T) {
+	d := NewDeflate(flate.DefaultCompression, true)
+	d.SetMaxMessageSize(1024)
+	s := d.newSession()
+	compressed, err := s.compress(make([]byte, 4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.decompress(compressed); err != ErrMessageTooLarge {
+		t.Fatalf("expect ErrMessageTooLarge, but got %v", err)
+	}
+}
+
+func TestDeflate_PackFrame(t *testing.T) {
+	c := &connection.Connection{}
+	_, _ = NewDeflate(flate.DefaultCompression, true).Negotiate(c, []byte("permessage-deflate"), nil)
+	frame, err := packFrame(c, ws.MessageText, bytes.Repeat([]byte("a"), 256))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// FIN | RSV1 | text
+	if frame[0] != 0xc1 {
+		t.Fatalf("expect compressed text frame, but got %#x", frame[0])
+	}
+}
+
+// benchmarkDeflateChurn：每次迭代模拟一个短连接，协商后收发一条消息即关闭
+func benchmarkDeflateChurn(b *testing.B, pooled bool) {
+	d := NewDeflate(flate.DefaultCompression, pooled)
+	msg := bytes.Repeat([]byte(`{"type":"update","value":42}`), 16)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s := d.newSession()
+		compressed, err := s.compress(msg)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := s.decompress(compressed); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDeflate_PooledContexts(b *testing.B) {
+	benchmarkDeflateChurn(b, true)
+}
+
+func BenchmarkDeflate_PerConnectionContexts(b *testing.B) {
+	benchmarkDeflateChurn(b, false)
+}
+
+// echoHandler：以文本消息回复收到的消息
+type echoHandler struct {
+	messageRecorder
+}
+
+func (h *echoHandler) OnMessage(c *connection.Connection, msg []byte) (ws.MessageType, []byte) {
+	h.messages <- string(msg)
+	return ws.MessageText, msg
+}
+
+func TestDeflate_GorillaClient(t *testing.T) {
+	d := NewDeflate(flate.BestSpeed, false)
+	h := &echoHandler{messageRecorder{messages: make(chan string, 1)}}
+	addr := startServer(t, New(&ws.Upgrader{ExtensionCustom: d.Negotiate}), h)
+
+	dialer := gorilla.Dialer{EnableCompression: true, HandshakeTimeout: time.Second}
+	conn, resp, err := dialer.Dial("ws://"+addr+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); !strings.Contains(ext, "permessage-deflate") {
+		t.Fatalf("expect permessage-deflate to be negotiated, but got %q", ext)
+	}
+
+	msg := strings.Repeat(`{"name":"fastnet","compressed":true}`, 100)
+	for i := 0; i < 2; i++ {
+		if err := conn.WriteMessage(gorilla.TextMessage, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		// Protocol 在 UnPacket 中解压，OnMessage 收到原始数据
+		if got := <-h.messages; got != msg {
+			t.Fatalf("expect inflated message, but got %d bytes", len(got))
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		typ, echo, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ != gorilla.TextMessage || string(echo) != msg {
+			t.Fatalf("expect compressed echo to round trip, but got %d bytes", len(echo))
+		}
+	}
+}
+
+func TestDeflate_Fallback(t *testing.T) {
+	d := NewDeflate(flate.BestSpeed, true)
+	h := &echoHandler{messageRecorder{messages: make(chan string, 1)}}
+	addr := startServer(t, New(&ws.Upgrader{ExtensionCustom: d.Negotiate}), h)
+
+	conn, resp, err := gorilla.DefaultDialer.Dial("ws://"+addr+"/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); ext != "" {
+		t.Fatalf("expect no extension without an offer, but got %q", ext)
+	}
+	if err := conn.WriteMessage(gorilla.TextMessage, []byte("plain")); err != nil {
+		t.Fatal(err)
+	}
+	<-h.messages
+	if _, echo, err := conn.ReadMessage(); err != nil || string(echo) != "plain" {
+		t.Fatalf("expect uncompressed echo, but got %q, %v", echo, err)
+	}
+}
@@ -1,17 +1,41 @@
 package websocket
 
 import (
-	"github.com/Dongxiem/gfaio/connection"
-	"github.com/Dongxiem/gfaio/log"
-	"github.com/Dongxiem/gfaio/plugins/websocket/ws"
-	"github.com/Dongxiem/gfaio/tool/ringbuffer"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/plugins/websocket/ws"
+	"github.com/Dongxiem/fastnet/plugins/websocket/ws/util"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
 )
 
-const upgradedKey = "gev_ws_upgraded"
+const (
+	upgradedKey      = "gev_ws_upgraded"
+	closeReceivedKey = "fastnet_ws_close_received"
+	fragmentsKey     = "fastnet_ws_fragments"
+)
+
+// 导致连接以 close 帧关闭的协议错误，作为连接的 CloseReason
+var (
+	ErrUnexpectedContinuation = ws.ProtocolError("unexpected continuation frame")
+	ErrInvalidControlFrame    = ws.ProtocolError("invalid control frame")
+	ErrMessageTooBig          = ws.ProtocolError("message exceeds max message size")
+)
 
-// Protocol websocket
+// Protocol websocket，控制帧在拆包时自动处理，不会交给 OnMessage：
+// 收到 ping 时回复负载相同的 pong，收到 pong 时刷新连接的空闲时间，收到 close 时回复 close 帧后关闭连接。
+// 分片的消息在收到最后一个分片后拼接为完整的消息，以第一个分片的 opcode 交给 OnMessage。
+// 非法的帧及超长的消息回复相应状态码的 close 帧，连接写出 close 帧后以对应的错误关闭
 type Protocol struct {
-	upgrade *ws.Upgrader
+	upgrade        *ws.Upgrader
+	maxMessageSize int
+
+	// OnPing：收到 ping 帧时调用，返回 true 表示已自行处理，不再自动回复 pong
+	OnPing func(c *connection.Connection, payload []byte) bool
+	// OnPong：收到 pong 帧时调用，返回 true 表示已自行处理，不再刷新空闲时间
+	OnPong func(c *connection.Connection, payload []byte) bool
+	// OnCloseFrame：收到 close 帧时调用，没有状态码时 code 为 StatusNoStatusRcvd，
+	// 返回 true 表示已自行处理，不再自动回复 close 帧并关闭连接
+	OnCloseFrame func(c *connection.Connection, code ws.StatusCode, reason string) bool
 }
 
 // New：创建 websocket Protocol
@@ -19,45 +43,235 @@ func New(u *ws.Upgrader) *Protocol {
 	return &Protocol{upgrade: u}
 }
 
-// UnPacket：解析 websocket 协议，返回 header ，payload
-func (p *Protocol) UnPacket(c *connection.Connection, buffer *ringbuffer.RingBuffer) (ctx interface{}, out []byte) {
+// SetMaxMessageSize：设置消息的最大长度，分片的消息按拼接后的总长度计算，超过时以状态码 1009 关闭连接，
+// 0 表示不限制（默认）
+func (p *Protocol) SetMaxMessageSize(n int) {
+	p.maxMessageSize = n
+}
+
+// fragments：连接上尚未收到最后一个分片的消息
+type fragments struct {
+	header  ws.Header
+	payload []byte
+}
+
+var _ connection.ErrorProtocol = &Protocol{}
+var _ connection.ConnIndependentPacket = &Protocol{}
+
+// UnPacket：解析 websocket 协议，协议错误时写出 close 帧后关闭连接。连接通过 UnPacketErr 拆包，只在直接调用时使用
+func (p *Protocol) UnPacket(c *connection.Connection, buffer *ringbuffer.RingBuffer) (interface{}, []byte) {
+	ctx, out, err := p.UnPacketErr(c, buffer)
+	if err != nil {
+		log.Error("[websocket]", err)
+		if err := c.WriteClose(nil); err != nil {
+			log.Error(err)
+		}
+	}
+	return ctx, out
+}
+
+// UnPacketErr：解析 websocket 协议，返回完整消息的 header ，payload，控制帧及非最后一个分片处理后继续解析下一帧。
+// 协议错误时 close 帧已通过 WriteBack 写入，返回的错误使连接在写出 close 帧后关闭
+func (p *Protocol) UnPacketErr(c *connection.Connection, buffer *ringbuffer.RingBuffer) (ctx interface{}, out []byte, err error) {
 	_, ok := c.Get(upgradedKey)
 	if !ok {
-		var err error
-		out, _, err = p.upgrade.Upgrade(c, buffer)
-		if err != nil {
-			log.Error("Websocket Upgrade :", err)
+		var upErr error
+		out, _, upErr = p.upgrade.Upgrade(c, buffer)
+		if upErr != nil {
+			log.Error("Websocket Upgrade :", upErr)
 			return
 		}
 		c.Set(upgradedKey, true)
-	} else {
-		header, err := ws.VirtualReadHeader(buffer)
+		return
+	}
+	// 已经收到 close 帧，之后的数据直接忽略
+	if _, closing := c.Get(closeReceivedKey); closing {
+		return
+	}
+	for {
+		header, payload, ok := readFrame(buffer)
+		if !ok {
+			return
+		}
+		if header.OpCode.IsControl() {
+			next, err := p.handleControl(c, header, payload)
+			if !next || err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		h, msg, err := p.assemble(c, header, payload)
 		if err != nil {
-			if err != ws.ErrHeaderNotReady {
-				log.Error(err)
+			return nil, nil, err
+		}
+		if h == nil {
+			continue
+		}
+		// 压缩过的消息解压后交给 OnMessage
+		if h.Rsv1() {
+			if h, msg, err = p.inflate(c, h, msg); err != nil {
+				return nil, nil, err
 			}
-			return
 		}
-		if buffer.VirtualLength() >= int(header.Length) {
-			buffer.VirtualFlush()
+		return h, msg, nil
+	}
+}
+
+// readFrame：从 buffer 中读取一个完整的帧并去掉掩码，数据不完整时返回 false
+func readFrame(buffer *ringbuffer.RingBuffer) (*ws.Header, []byte, bool) {
+	header, err := ws.VirtualReadHeader(buffer)
+	if err != nil {
+		if err != ws.ErrHeaderNotReady {
+			log.Error(err)
+		}
+		return nil, nil, false
+	}
+	if buffer.VirtualLength() < int(header.Length) {
+		buffer.VirtualRevert()
+		return nil, nil, false
+	}
+	buffer.VirtualFlush()
 
-			payload := make([]byte, int(header.Length))
-			_, _ = buffer.Read(payload)
+	payload := make([]byte, int(header.Length))
+	_, _ = buffer.Read(payload)
 
-			if header.Masked {
-				ws.Cipher(payload, header.Mask, 0)
-			}
+	if header.Masked {
+		ws.Cipher(payload, header.Mask, 0)
+	}
+	return &header, payload, true
+}
 
-			ctx = &header
-			out = payload
-		} else {
-			buffer.VirtualRevert()
+// assemble：处理数据帧，消息完整时返回其 header 及数据，尚未收到最后一个分片时返回 nil，
+// 返回错误时连接即将关闭，不再解析之后的帧
+func (p *Protocol) assemble(c *connection.Connection, h *ws.Header, payload []byte) (*ws.Header, []byte, error) {
+	var pending *fragments
+	if v, ok := c.Get(fragmentsKey); ok {
+		pending = v.(*fragments)
+	}
+	// 后续分片必须跟在未完成的消息之后，未完成的消息之间也不能插入新的数据消息
+	if (h.OpCode == ws.OpContinuation) != (pending != nil) {
+		return nil, nil, p.closeWith(c, ws.StatusProtocolError, ErrUnexpectedContinuation)
+	}
+	if pending == nil {
+		if p.maxMessageSize > 0 && len(payload) > p.maxMessageSize {
+			return nil, nil, p.closeWith(c, ws.StatusMessageTooBig, ErrMessageTooBig)
+		}
+		if h.Fin {
+			return h, payload, nil
+		}
+		c.Set(fragmentsKey, &fragments{header: *h, payload: payload})
+		return nil, nil, nil
+	}
+
+	if p.maxMessageSize > 0 && len(pending.payload)+len(payload) > p.maxMessageSize {
+		c.Delete(fragmentsKey)
+		return nil, nil, p.closeWith(c, ws.StatusMessageTooBig, ErrMessageTooBig)
+	}
+	pending.payload = append(pending.payload, payload...)
+	if !h.Fin {
+		return nil, nil, nil
+	}
+	c.Delete(fragmentsKey)
+	header := pending.header
+	header.Fin = true
+	header.Length = int64(len(pending.payload))
+	return &header, pending.payload, nil
+}
+
+// inflate：解压设置了 RSV1 的消息，返回的 header 去掉 RSV1，未协商 permessage-deflate 或解压失败时返回错误
+func (p *Protocol) inflate(c *connection.Connection, h *ws.Header, msg []byte) (*ws.Header, []byte, error) {
+	d := deflateSessionOf(c)
+	if d == nil {
+		return nil, nil, p.closeWith(c, ws.StatusProtocolError, ws.ErrProtocolNonZeroRsv)
+	}
+	plain, err := d.decompress(msg)
+	if err != nil {
+		code := ws.StatusInvalidFramePayloadData
+		if err == ErrMessageTooLarge {
+			code = ws.StatusMessageTooBig
+		}
+		return nil, nil, p.closeWith(c, code, err)
+	}
+	header := *h
+	header.Rsv &^= rsv1
+	header.Length = int64(len(plain))
+	return &header, plain, nil
+}
+
+// handleControl：处理控制帧，返回 false 时连接即将关闭，不再解析之后的帧，非法的控制帧同时返回错误。
+// 控制帧可以出现在分片消息的各个分片之间，这里不会改变分片消息的状态
+func (p *Protocol) handleControl(c *connection.Connection, h *ws.Header, payload []byte) (bool, error) {
+	// 控制帧不能分片，负载不超过 125 字节
+	if !h.Fin || h.Length > ws.MaxControlFramePayloadSize || h.OpCode.IsReserved() {
+		return false, p.closeWith(c, ws.StatusProtocolError, ErrInvalidControlFrame)
+	}
+	switch h.OpCode {
+	case ws.OpPing:
+		if p.OnPing != nil && p.OnPing(c, payload) {
+			return true, nil
+		}
+		pong, err := ws.FrameToBytes(ws.NewPongFrame(payload))
+		if err != nil {
+			log.Error(err)
+			return true, nil
+		}
+		c.WriteBack(pong)
+	case ws.OpPong:
+		if p.OnPong != nil && p.OnPong(c, payload) {
+			return true, nil
+		}
+		c.ResetIdle()
+	case ws.OpClose:
+		code, reason := ws.StatusNoStatusRcvd, ""
+		if len(payload) > 0 {
+			code, reason = ws.ParseCloseFrameData(payload)
+		}
+		if p.OnCloseFrame != nil && p.OnCloseFrame(c, code, reason) {
+			return true, nil
 		}
+		reply, err := util.HandleClose(h, payload)
+		if err != nil {
+			log.Error(err)
+		}
+		closeAfter(c, reply)
+		return false, nil
+	}
+	return true, nil
+}
+
+// closeWith：写入状态码为 code、原因为 cause 的 close 帧，不再解析之后的帧，返回 cause 交给连接关闭
+func (p *Protocol) closeWith(c *connection.Connection, code ws.StatusCode, cause error) error {
+	frame, err := ws.FrameToBytes(ws.NewCloseFrame(ws.NewCloseFrameBody(code, cause.Error())))
+	if err != nil {
+		log.Error(err)
+	}
+	c.Set(closeReceivedKey, true)
+	c.WriteBack(frame)
+	return cause
+}
+
+// closeAfter：不再解析之后的帧，frame 写出后关闭连接
+func closeAfter(c *connection.Connection, frame []byte) {
+	c.Set(closeReceivedKey, true)
+	if err := c.WriteClose(frame); err != nil {
+		log.Error(err)
 	}
-	return
+}
+
+// ReconnectFrame：重连指令为状态码 1012（Service Restart）的 close 帧，原因为 target，
+// 客户端收到后应连接 target 指向的节点，target 超过 close 帧的长度限制时被截断
+func (p *Protocol) ReconnectFrame(c *connection.Connection, target string) []byte {
+	frame, err := ws.FrameToBytes(ws.NewCloseFrame(ws.NewCloseFrameBody(ws.StatusServiceRestart, target)))
+	if err != nil {
+		log.Error(err)
+	}
+	return frame
 }
 
 // Packet：直接返回
 func (p *Protocol) Packet(c *connection.Connection, data []byte) []byte {
 	return data
 }
+
+// ConnIndependentPacket：数据帧由调用者编码，Packet 不做处理，广播的帧可以在连接间共享
+func (p *Protocol) ConnIndependentPacket() {}
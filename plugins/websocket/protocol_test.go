@@ -0,0 +1,235 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/plugins/websocket/ws"
+)
+
+var _ connection.ReconnectProtocol = &Protocol{}
+
+func TestProtocol_ReconnectFrame(t *testing.T) {
+	frame := New(&ws.Upgrader{}).ReconnectFrame(nil, "ws://10.0.0.2/chat")
+	// FIN | close，服务端发送的帧不带掩码
+	if frame[0] != 0x88 || int(frame[1]) != len(frame)-2 {
+		t.Fatalf("unexpected close frame % x", frame)
+	}
+	code, reason := ws.ParseCloseFrameData(frame[2:])
+	if code != ws.StatusServiceRestart || reason != "ws://10.0.0.2/chat" {
+		t.Fatalf("unexpected close frame data %d %q", code, reason)
+	}
+}
+
+// messageRecorder：记录收到的消息，不回复
+type messageRecorder struct {
+	messages chan string
+	closed   chan error // 不为 nil 时记录连接关闭的原因
+}
+
+func (h *messageRecorder) OnConnect(c *connection.Connection) {}
+
+func (h *messageRecorder) OnMessage(c *connection.Connection, msg []byte) (ws.MessageType, []byte) {
+	h.messages <- string(msg)
+	return ws.MessageText, nil
+}
+
+func (h *messageRecorder) OnClose(c *connection.Connection) {
+	if h.closed != nil {
+		h.closed <- c.CloseReason()
+	}
+}
+
+// startServer：启动使用 p 的 websocket Server，返回监听地址
+func startServer(t *testing.T, p *Protocol, h WSHandler) string {
+	s, err := fastnet.NewServer(NewHandlerWrap(p.upgrade, h), fastnet.Address("127.0.0.1:0"), fastnet.NumLoops(1), fastnet.Protocol(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	t.Cleanup(s.Stop)
+	return s.Addr()
+}
+
+// dialServer：建立连接并完成握手
+func dialServer(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	_ = conn.SetDeadline(time.Now().Add(3 * time.Second))
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("unexpected handshake status %d", resp.StatusCode)
+	}
+	return conn, r
+}
+
+// writeFrame：发送客户端的帧，客户端发送的帧必须带掩码
+func writeFrame(t *testing.T, conn net.Conn, fin bool, op ws.OpCode, payload []byte) {
+	h := ws.Header{Fin: fin, OpCode: op, Masked: true, Mask: [4]byte{1, 2, 3, 4}, Length: int64(len(payload))}
+	frame, err := ws.WriteHeader(&h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	masked := append([]byte(nil), payload...)
+	ws.Cipher(masked, h.Mask, 0)
+	if _, err := conn.Write(append(frame, masked...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readFrameFrom：读取一个服务端发送的帧
+func readFrameFrom(t *testing.T, r *bufio.Reader) (ws.Header, []byte) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:2]); err != nil {
+		t.Fatal(err)
+	}
+	h := ws.Header{Fin: b[0]&0x80 != 0, Rsv: b[0] >> 4 & 0x7, OpCode: ws.OpCode(b[0] & 0xf), Length: int64(b[1] & 0x7f)}
+	switch h.Length {
+	case 126:
+		if _, err := io.ReadFull(r, b[:2]); err != nil {
+			t.Fatal(err)
+		}
+		h.Length = int64(binary.BigEndian.Uint16(b[:2]))
+	case 127:
+		if _, err := io.ReadFull(r, b[:8]); err != nil {
+			t.Fatal(err)
+		}
+		h.Length = int64(binary.BigEndian.Uint64(b[:8]))
+	}
+	payload := make([]byte, h.Length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatal(err)
+	}
+	return h, payload
+}
+
+func TestProtocol_ControlFrames(t *testing.T) {
+	p := New(&ws.Upgrader{})
+	pongs := make(chan string, 1)
+	p.OnPong = func(c *connection.Connection, payload []byte) bool {
+		pongs <- string(payload)
+		return false
+	}
+	h := &messageRecorder{messages: make(chan string, 4)}
+	conn, r := dialServer(t, startServer(t, p, h))
+
+	writeFrame(t, conn, true, ws.OpPing, []byte("are you there"))
+	if h, payload := readFrameFrom(t, r); h.OpCode != ws.OpPong || string(payload) != "are you there" {
+		t.Fatalf("expect pong with the ping payload, but got %v %q", h.OpCode, payload)
+	}
+
+	writeFrame(t, conn, true, ws.OpPong, []byte("beat"))
+	if got := <-pongs; got != "beat" {
+		t.Fatalf("expect OnPong with the pong payload, but got %q", got)
+	}
+
+	// 控制帧不会交给 OnMessage
+	writeFrame(t, conn, true, ws.OpText, []byte("hello"))
+	if got := <-h.messages; got != "hello" {
+		t.Fatalf("expect data message, but got %q", got)
+	}
+
+	writeFrame(t, conn, true, ws.OpClose, ws.NewCloseFrameBody(ws.StatusGoingAway, "bye"))
+	hd, payload := readFrameFrom(t, r)
+	if code, reason := ws.ParseCloseFrameData(payload); hd.OpCode != ws.OpClose || code != ws.StatusGoingAway || reason != "bye" {
+		t.Fatalf("expect close reply, but got %v %d %q", hd.OpCode, code, reason)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Fatalf("expect connection to be closed after close reply, but got %v", err)
+	}
+}
+
+func TestProtocol_OnCloseFrame(t *testing.T) {
+	p := New(&ws.Upgrader{})
+	codes := make(chan ws.StatusCode, 1)
+	p.OnCloseFrame = func(c *connection.Connection, code ws.StatusCode, reason string) bool {
+		codes <- code
+		return true
+	}
+	p.OnPing = func(c *connection.Connection, payload []byte) bool {
+		return true
+	}
+	h := &messageRecorder{messages: make(chan string, 4)}
+	conn, r := dialServer(t, startServer(t, p, h))
+
+	// 处理了 close 帧及 ping 帧的 hook 接管后，连接保持打开，也不回复 pong
+	writeFrame(t, conn, true, ws.OpClose, nil)
+	if code := <-codes; code != ws.StatusNoStatusRcvd {
+		t.Fatalf("expect StatusNoStatusRcvd for empty close frame, but got %d", code)
+	}
+	writeFrame(t, conn, true, ws.OpPing, nil)
+	writeFrame(t, conn, true, ws.OpBinary, []byte("still open"))
+	if got := <-h.messages; got != "still open" {
+		t.Fatalf("expect data message, but got %q", got)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	if _, err := r.ReadByte(); err == nil {
+		t.Fatal("expect no reply when the hooks handle control frames")
+	}
+}
+
+func TestProtocol_Fragments(t *testing.T) {
+	h := &messageRecorder{messages: make(chan string, 4)}
+	conn, r := dialServer(t, startServer(t, New(&ws.Upgrader{}), h))
+
+	// 三个分片组成的文本消息，分片之间插入 ping
+	writeFrame(t, conn, false, ws.OpText, []byte("frag"))
+	writeFrame(t, conn, false, ws.OpContinuation, []byte("mented "))
+	writeFrame(t, conn, true, ws.OpPing, []byte("ping"))
+	writeFrame(t, conn, true, ws.OpContinuation, []byte("message"))
+	if hd, payload := readFrameFrom(t, r); hd.OpCode != ws.OpPong || string(payload) != "ping" {
+		t.Fatalf("expect pong between fragments, but got %v %q", hd.OpCode, payload)
+	}
+	if got := <-h.messages; got != "fragmented message" {
+		t.Fatalf("expect reassembled message, but got %q", got)
+	}
+
+	writeFrame(t, conn, true, ws.OpText, []byte("single"))
+	if got := <-h.messages; got != "single" {
+		t.Fatalf("expect unfragmented message, but got %q", got)
+	}
+}
+
+func TestProtocol_MaxMessageSize(t *testing.T) {
+	p := New(&ws.Upgrader{})
+	p.SetMaxMessageSize(8)
+	h := &messageRecorder{messages: make(chan string, 4), closed: make(chan error, 1)}
+	conn, r := dialServer(t, startServer(t, p, h))
+
+	writeFrame(t, conn, false, ws.OpBinary, []byte("12345"))
+	writeFrame(t, conn, true, ws.OpContinuation, []byte("6789"))
+	hd, payload := readFrameFrom(t, r)
+	if code, _ := ws.ParseCloseFrameData(payload); hd.OpCode != ws.OpClose || code != ws.StatusMessageTooBig {
+		t.Fatalf("expect close frame with status 1009, but got %v %d", hd.OpCode, code)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Fatalf("expect connection to be closed, but got %v", err)
+	}
+	if reason := <-h.closed; reason != ErrMessageTooBig {
+		t.Fatalf("expect ErrMessageTooBig as close reason, but got %v", reason)
+	}
+	select {
+	case msg := <-h.messages:
+		t.Fatalf("expect oversized message to be dropped, but got %q", msg)
+	default:
+	}
+}
@@ -1,10 +1,9 @@
 package websocket
 
 import (
-	"github.com/Dongxiem/gfaio/connection"
-	"github.com/Dongxiem/gfaio/log"
-	"github.com/Dongxiem/gfaio/plugins/websocket/ws"
-	"github.com/Dongxiem/gfaio/plugins/websocket/ws/util"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/plugins/websocket/ws"
 )
 
 // WSHandler WebSocket Server 注册接口
@@ -35,50 +34,18 @@ func (s *HandlerWrap) OnConnect(c *connection.Connection) {
 
 // OnMessage wrap
 func (s *HandlerWrap) OnMessage(c *connection.Connection, ctx interface{}, payload []byte) []byte {
-	header, ok := ctx.(*ws.Header)
+	_, ok := ctx.(*ws.Header)
 	// 升级协议 握手
 	if !ok && len(payload) != 0 {
 		return payload
 	}
 
+	// 控制帧已经由 Protocol 处理，压缩过的消息也已经解压，这里只会收到数据帧
 	if ok {
-		if header.OpCode.IsControl() {
-			var (
-				out []byte
-				err error
-			)
-			switch header.OpCode {
-			case ws.OpClose:
-				out, err = util.HandleClose(header, payload)
-				if err != nil {
-					log.Error(err)
-				}
-				_ = c.ShutdownWrite()
-			case ws.OpPing:
-				out, err = util.HandlePing(payload)
-				if err != nil {
-					log.Error(err)
-				}
-			case ws.OpPong:
-				out, err = util.HandlePong(payload)
-				if err != nil {
-					log.Error(err)
-				}
-			}
-			return out
-		}
-
 		messageType, out := s.wsHandler.OnMessage(c, payload)
 		if len(out) > 0 {
-			var frame *ws.Frame
-			switch messageType {
-			case ws.MessageBinary:
-				frame = ws.NewBinaryFrame(out)
-			case ws.MessageText:
-				frame = ws.NewTextFrame(out)
-			}
 			var err error
-			out, err = ws.FrameToBytes(frame)
+			out, err = packFrame(c, messageType, out)
 			if err != nil {
 				log.Error(err)
 			}
@@ -19,6 +19,8 @@ var (
 	ErrProtocolStatusCodeNoMeaning        = ProtocolError("status code has no meaning yet")
 	ErrProtocolStatusCodeUnknown          = ProtocolError("status code is not defined in spec")
 	ErrProtocolInvalidUTF8                = ProtocolError("invalid utf8 sequence in close reason")
+	ErrProtocolNonZeroRsv                 = ProtocolError("non-zero rsv bits with no extension negotiated")
+	ErrProtocolOpCodeReserved             = ProtocolError("use of reserved op code")
 )
 
 // Errors used by both client and server when preparing WebSocket handshake.
@@ -88,6 +88,8 @@ const (
 	StatusMessageTooBig           StatusCode = 1009
 	StatusMandatoryExt            StatusCode = 1010
 	StatusInternalServerError     StatusCode = 1011
+	StatusServiceRestart          StatusCode = 1012
+	StatusTryAgainLater           StatusCode = 1013
 	StatusTLSHandshake            StatusCode = 1015
 
 	// StatusAbnormalClosure is a special code designated for use in
@@ -143,6 +145,8 @@ func (s StatusCode) IsProtocolDefined() bool {
 		StatusMessageTooBig,
 		StatusMandatoryExt,
 		StatusInternalServerError,
+		StatusServiceRestart,
+		StatusTryAgainLater,
 		StatusNoStatusRcvd,
 		StatusAbnormalClosure,
 		StatusTLSHandshake:
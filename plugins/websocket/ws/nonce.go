@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"crypto/sha1"
 	"encoding/base64"
-	"github.com/Allenxuxu/toolkit/convert"
 )
 
 const (
@@ -45,5 +44,5 @@ func writeAccept(bw *bufio.Writer, nonce []byte) (int, error) {
 	// WriteString() copy given string into its inner buffer, unlike Write()
 	// which may write p directly to the underlying io.Writer – which in turn
 	// will lead to p escape.
-	return bw.WriteString(convert.BytesToString(accept))
+	return bw.WriteString(btsToString(accept))
 }
@@ -4,8 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 
-	"github.com/Allenxuxu/ringbuffer"
-	"github.com/Allenxuxu/toolkit/convert"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
 	"github.com/gobwas/pool/pbytes"
 )
 
@@ -99,6 +98,6 @@ func ParseCloseFrameData(payload []byte) (code StatusCode, reason string) {
 		return
 	}
 	code = StatusCode(binary.BigEndian.Uint16(payload))
-	reason = convert.BytesToString(payload[2:])
+	reason = btsToString(payload[2:])
 	return
 }
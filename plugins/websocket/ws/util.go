@@ -3,6 +3,7 @@ package ws
 import (
 	"bytes"
 	"fmt"
+	"unsafe"
 )
 
 // asciiToInt converts bytes to int.
@@ -91,3 +92,8 @@ func min(a, b int) int {
 	}
 	return b
 }
+
+// btsToString converts bytes to string without copying.
+func btsToString(b []byte) string {
+	return *(*string)(unsafe.Pointer(&b))
+}
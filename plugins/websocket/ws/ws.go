@@ -6,9 +6,8 @@ import (
 	"io"
 	"net/http"
 
-	"github.com/Allenxuxu/gev/connection"
-	"github.com/Allenxuxu/ringbuffer"
-	"github.com/Allenxuxu/toolkit/convert"
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
 	"github.com/gobwas/httphead"
 )
 
@@ -217,7 +216,7 @@ func (u *Upgrader) Upgrade(c *connection.Connection, in *ringbuffer.RingBuffer)
 		// Abort processing the whole request because we do not even know how
 		// to actually parse it.
 		err = ErrHandshakeBadProtocol
-	case convert.BytesToString(req.method) != http.MethodGet:
+	case btsToString(req.method) != http.MethodGet:
 		err = ErrHandshakeBadMethod
 	default:
 		if onRequest := u.OnRequest; onRequest != nil {
@@ -243,7 +242,7 @@ func (u *Upgrader) Upgrade(c *connection.Connection, in *ringbuffer.RingBuffer)
 			break
 		}
 
-		switch convert.BytesToString(k) {
+		switch btsToString(k) {
 		case headerHostCanonical:
 			headerSeen |= headerSeenHost
 			if onHost := u.OnHost; onHost != nil {
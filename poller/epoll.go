@@ -3,6 +3,8 @@
 package poller
 
 import (
+	"runtime"
+
 	"github.com/Dongxiem/fastnet/log"
 	"github.com/Dongxiem/fastnet/tool/sync/atomic"
 	"golang.org/x/sys/unix"
@@ -19,6 +21,9 @@ type Poller struct {
 	eventFd  int           // 事件句柄
 	running  atomic.Bool   // 判断 Poller 是否在执行当中
 	waitDone chan struct{} // 通过空结构体 chan 进行 goroutine 同步
+	spin     int           // 阻塞等待前非阻塞轮询的次数
+	batchDone func()       // 每批就绪事件回调完成后调用
+	wakeBuf  []byte        // 读取 eventFd 的缓冲区，每个 Poller 单独使用，避免多个事件循环并发写同一块内存
 }
 
 // Create：创建一个 Poller
@@ -66,12 +71,13 @@ func (ep *Poller) Wake() error {
 	return err
 }
 
-var buf = make([]byte, 8)
-
 // wakeHandlerRead: 唤醒读取处理
 func (ep *Poller) wakeHandlerRead() {
 	// 通过 unix.Read 系统调用，对 ep.eventFd 对应的文件进行读取
-	n, err := unix.Read(ep.eventFd, buf)
+	if ep.wakeBuf == nil {
+		ep.wakeBuf = make([]byte, 8)
+	}
+	n, err := unix.Read(ep.eventFd, ep.wakeBuf)
 	// 只是读了，但是并没有对数据进行啥处理
 	if err != nil || n != 8 {
 		log.Error("wakeHandlerRead", err, n)
@@ -139,11 +145,41 @@ func (ep *Poller) EnableWrite(fd int) error {
 	return ep.mod(fd, writeEvent)
 }
 
+// DisableReadWrite：取消 fd 的可读可写事件，fd 仍保留在 epoll 中，出错及挂起事件照常通知
+func (ep *Poller) DisableReadWrite(fd int) error {
+	return ep.mod(fd, 0)
+}
+
 // EnableRead：使能 fd 注册事件为可读事件
 func (ep *Poller) EnableRead(fd int) error {
 	return ep.mod(fd, readEvent)
 }
 
+// SetBatchDone：设置每次 epoll_wait 返回的一批就绪事件都回调给 handler 之后调用的函数，需要在 Poll 之前调用
+func (ep *Poller) SetBatchDone(f func()) {
+	ep.batchDone = f
+}
+
+// SetSpinBeforeBlock：设置每次阻塞在 epoll_wait 之前以非阻塞方式轮询的次数，需要在 Poll 之前调用。
+// 轮询期间有事件到来时省去了线程被唤醒的开销，可以降低延迟（尤其是尾延迟），
+// 代价是空闲时每次等待都会额外占用 CPU 进行 n 次 epoll_wait 系统调用，n 为 0 时直接阻塞
+func (ep *Poller) SetSpinBeforeBlock(n int) {
+	ep.spin = n
+}
+
+// wait：等待事件，先非阻塞轮询 spin 次，没有事件时再阻塞等待
+func (ep *Poller) wait(events []unix.EpollEvent) (int, error) {
+	for i := 0; i < ep.spin; i++ {
+		n, err := unix.EpollWait(ep.fd, events, 0)
+		if n != 0 || (err != nil && err != unix.EINTR) {
+			return n, err
+		}
+		// 让出处理器，避免饿死同一 P 上的其他 goroutine
+		runtime.Gosched()
+	}
+	return unix.EpollWait(ep.fd, events, -1)
+}
+
 // Poll：启动 epoll 进行事件读写等待循环，handler 为事件到来时的处理函数
 func (ep *Poller) Poll(handler func(fd int, event Event)) {
 	// 延迟关闭
@@ -165,7 +201,7 @@ func (ep *Poller) Poll(handler func(fd int, event Event)) {
 		// msec 设置为 -1，也即 timeout 设置为 -1，会无限期阻塞
 		// 所谓 Reactor 『非阻塞 I/O』的核心思想是指避免阻塞在 read() 或者 write() 或者其他的 I/O 系统调用上，这样可以最大限度的复用 event-loop 线程，让一个线程能服务于多个 sockets。
 		// 在 Reactor 模式中，I/O 线程只能阻塞在 I/O multiplexing 函数上（select/poll/epoll_wait）。
-		n, err := unix.EpollWait(ep.fd, events, -1)
+		n, err := ep.wait(events)
 		if err != nil && err != unix.EINTR {
 			log.Error("EpollWait: ", err)
 			continue
@@ -176,34 +212,27 @@ func (ep *Poller) Poll(handler func(fd int, event Event)) {
 			fd := int(events[i].Fd)
 			// 如果该 fd 不是我们当前 Poller 的 eventFd，需要进行 event 事件的获取，了解是什么事件发生了，然后再对应处理
 			if fd != ep.eventFd {
-				var rEvents Event
-				if ((events[i].Events & unix.POLLHUP) != 0) && ((events[i].Events & unix.POLLIN) == 0) {
-					// 错误事件
-					rEvents |= EventErr
-				}
-				if (events[i].Events&unix.EPOLLERR != 0) || (events[i].Events&unix.EPOLLOUT != 0) {
-					// 写事件
-					rEvents |= EventWrite
-				}
-				if events[i].Events&(unix.EPOLLIN|unix.EPOLLPRI|unix.EPOLLRDHUP) != 0 {
-					// 读事件
-					rEvents |= EventRead
-				}
 				// 当 epoll 检测到有就绪的 fd 时，会逐个调用上面的回调函数，主要逻辑也在这里。
-				handler(fd, rEvents)
+				handler(fd, toEvent(events[i].Events))
 			} else {
 				ep.wakeHandlerRead()
 				wake = true
 			}
 		}
+		if ep.batchDone != nil && n > 0 {
+			ep.batchDone()
+		}
 		// 如果 wake 置为 True，意思即被唤醒
 		if wake {
+			// 先记下运行状态再处理剩余事件：Close 之前投递的任务都会在这次 handler 中执行，
+			// 否则在 handler 之后才观察到关闭会丢失其间投递的任务（例如停止时关闭连接的回调）
+			running := ep.running.Get()
 			// 使用 handler 去查看并处理剩余事件，进行完美退出
 			handler(-1, 0)
 			// 再将 wake 置为 false
 			wake = false
 			// 进行退出，退出时候会延迟调用 close(ep.waitDone)
-			if !ep.running.Get() {
+			if !running {
 				return
 			}
 		}
@@ -214,3 +243,49 @@ func (ep *Poller) Poll(handler func(fd int, event Event)) {
 		}
 	}
 }
+
+// toEvent：将 epoll 返回的事件转换为 Event
+func toEvent(events uint32) Event {
+	var rEvents Event
+	if ((events & unix.POLLHUP) != 0) && ((events & unix.POLLIN) == 0) {
+		// 错误事件
+		rEvents |= EventErr
+	}
+	if (events&unix.EPOLLERR != 0) || (events&unix.EPOLLOUT != 0) {
+		// 写事件
+		rEvents |= EventWrite
+	}
+	if events&(unix.EPOLLIN|unix.EPOLLPRI|unix.EPOLLRDHUP) != 0 {
+		// 读事件
+		rEvents |= EventRead
+	}
+	return rEvents
+}
+
+// PollNow：非阻塞地检查一次就绪事件并逐个回调给 handler，唤醒事件只被消费而不回调，
+// 供不通过 Poll 运行、由调用者自行驱动的事件循环（如测试用的确定性调度器）使用
+func (ep *Poller) PollNow(handler func(fd int, event Event)) error {
+	events := make([]unix.EpollEvent, waitEventsBegin)
+	n, err := unix.EpollWait(ep.fd, events, 0)
+	if err != nil && err != unix.EINTR {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		fd := int(events[i].Fd)
+		if fd == ep.eventFd {
+			ep.wakeHandlerRead()
+			continue
+		}
+		handler(fd, toEvent(events[i].Events))
+	}
+	return nil
+}
+
+// Release：关闭从未通过 Poll 运行的 Poller，释放 epoll 与 eventfd
+func (ep *Poller) Release() error {
+	if ep.running.Get() {
+		return ErrRunning
+	}
+	_ = unix.Close(ep.eventFd)
+	return unix.Close(ep.fd)
+}
@@ -5,6 +5,9 @@ import "errors"
 // ErrClosed 错误： 重复 close poller 错误
 var ErrClosed = errors.New("poller instance is not running")
 
+// ErrRunning 错误：Poller 正在通过 Poll 运行，应使用 Close 关闭
+var ErrRunning = errors.New("poller instance is running")
+
 // waitEventsBegin：开始进行事件等待的阈值
 const waitEventsBegin = 1024
 
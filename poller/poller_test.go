@@ -1,8 +1,12 @@
 package poller
 
 import (
+	"runtime"
+	"sort"
 	"testing"
 	"time"
+
+	"golang.org/x/sys/unix"
 )
 
 func TestPoller_Poll(t *testing.T) {
@@ -32,3 +36,73 @@ func TestPoller_Close(t *testing.T) {
 		t.Fatal("poller should be closed")
 	}
 }
+
+// benchmarkLatency：轻负载下逐个发送请求，测量事件从写入到被 Poll 处理并响应的往返延迟
+func benchmarkLatency(b *testing.B, spin int) {
+	p, err := Create()
+	if err != nil {
+		b.Fatal(err)
+	}
+	p.SetSpinBeforeBlock(spin)
+
+	var req, resp [2]int
+	if err := unix.Pipe2(req[:], unix.O_NONBLOCK); err != nil {
+		b.Fatal(err)
+	}
+	if err := unix.Pipe(resp[:]); err != nil {
+		b.Fatal(err)
+	}
+	defer func() {
+		for _, fd := range []int{req[0], req[1], resp[0], resp[1]} {
+			_ = unix.Close(fd)
+		}
+	}()
+	if err := p.AddRead(req[0]); err != nil {
+		b.Fatal(err)
+	}
+
+	go p.Poll(func(fd int, event Event) {
+		if fd != req[0] {
+			return
+		}
+		buf := make([]byte, 1)
+		if n, _ := unix.Read(fd, buf); n == 1 {
+			_, _ = unix.Write(resp[1], buf)
+		}
+	})
+	defer p.Close()
+
+	one := []byte{1}
+	buf := make([]byte, 1)
+	latencies := make([]time.Duration, b.N)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		start := time.Now()
+		if _, err := unix.Write(req[1], one); err != nil {
+			b.Fatal(err)
+		}
+		if _, err := unix.Read(resp[0], buf); err != nil {
+			b.Fatal(err)
+		}
+		latencies[i] = time.Since(start)
+		// 模拟轻负载，请求之间留出空闲时间让 poller 进入等待
+		time.Sleep(50 * time.Microsecond)
+	}
+	b.StopTimer()
+
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(latencies[len(latencies)/2].Nanoseconds()), "p50-ns")
+	b.ReportMetric(float64(latencies[len(latencies)*99/100].Nanoseconds()), "p99-ns")
+}
+
+func BenchmarkPoller_Latency(b *testing.B) {
+	benchmarkLatency(b, 0)
+}
+
+func BenchmarkPoller_LatencySpin(b *testing.B) {
+	// 轮询需要独占一个 CPU，单核时只会与发送方争抢 CPU
+	if runtime.NumCPU() < 2 {
+		b.Skip("spin before block requires at least 2 CPUs")
+	}
+	benchmarkLatency(b, 10000)
+}
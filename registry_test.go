@@ -0,0 +1,66 @@
+package fastnet
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+)
+
+// idExample：记录建立的连接 ID
+type idExample struct {
+	example
+	ids chan int64
+}
+
+func (h *idExample) OnConnect(c *connection.Connection) {
+	h.ids <- c.ID()
+	h.example.OnConnect(c)
+}
+
+func TestServer_Connection(t *testing.T) {
+	handler := &idExample{ids: make(chan int64, 64)}
+	s, err := NewServer(handler, Address("127.0.0.1:0"), NumLoops(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	const n = 50
+	conns := make([]net.Conn, n)
+	for i := range conns {
+		conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+	}
+	waitConnections(t, &handler.example, n)
+	if got := s.registry.Len(); got != n {
+		t.Fatalf("expect %d registered connections, but got %d", n, got)
+	}
+
+	// 通过 ID 定向推送
+	id := <-handler.ids
+	c, ok := s.Connection(id)
+	if !ok || c.ID() != id {
+		t.Fatalf("expect connection %d to be registered", id)
+	}
+	if err := c.Send([]byte("push")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, conn := range conns {
+		_ = conn.Close()
+	}
+	waitConnections(t, &handler.example, 0)
+	if got := s.registry.Len(); got != 0 {
+		t.Fatalf("expect an empty registry after all connections closed, but got %d", got)
+	}
+	if _, ok := s.Connection(id); ok {
+		t.Fatal("expect closed connections to be deregistered")
+	}
+}
@@ -0,0 +1,81 @@
+package fastnet
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/eventloop"
+	"github.com/Dongxiem/fastnet/listener"
+	"golang.org/x/sys/unix"
+)
+
+// errReusePortNetwork：ListenerPerLoop 只支持 TCP 网络
+var errReusePortNetwork = errors.New("reuseport listener per loop requires a tcp network")
+
+// listenPerLoop：为每个 work 事件循环创建设置了 SO_REUSEPORT 的 listener，新连接留在 Accept 它的事件循环中。
+// 监听 ":0" 时其余 listener 使用第一个 listener 得到的端口，任一 listener 创建失败时关闭已创建的全部 listener
+func (s *Server) listenPerLoop() (err error) {
+	if !strings.HasPrefix(s.opts.Network, "tcp") {
+		return errReusePortNetwork
+	}
+	listeners := make([]*listener.Listener, 0, len(s.workLoops))
+	defer func() {
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Release()
+			}
+		}
+	}()
+	addr := s.opts.Address
+	for _, loop := range s.workLoops {
+		var l *listener.Listener
+		if l, err = listener.New(s.opts.Network, addr, true, loop, s.handleLoopConnection(loop)); err != nil {
+			return err
+		}
+		listeners = append(listeners, l)
+		if len(listeners) == 1 {
+			var sa unix.Sockaddr
+			if sa, err = unix.Getsockname(l.Fd()); err != nil {
+				return err
+			}
+			addr = connection.SockAddrToString(sa)
+		}
+	}
+	// 全部创建成功后再加入事件循环
+	for i, l := range listeners {
+		loop := s.workLoops[i]
+		if s.opts.AcceptBatch > 1 {
+			handle := s.handleLoopConnection(loop)
+			l.SetBatch(s.opts.AcceptBatch, func(conns []listener.Accepted) {
+				for _, a := range conns {
+					handle(a.Fd, a.Sa)
+				}
+			})
+		}
+		if err = loop.AddSocketAndEnableRead(l.Fd(), l); err != nil {
+			// 事件循环尚未运行，直接移除已加入的 listener
+			for j := 0; j < i; j++ {
+				s.workLoops[j].DeleteFdInLoop(listeners[j].Fd())
+			}
+			return err
+		}
+	}
+	s.listenFd = listeners[0].Fd()
+	s.loopListeners = listeners
+	return nil
+}
+
+// handleLoopConnection：处理 loop 的 listener Accept 的新连接，后台连接交给后台事件循环，其余留在 loop 中
+func (s *Server) handleLoopConnection(loop *eventloop.EventLoop) listener.HandleConnFunc {
+	return func(fd int, sa unix.Sockaddr) {
+		if !s.admit(fd, sa) {
+			return
+		}
+		if bg := s.backgroundFor(fd, sa); bg != nil {
+			s.serveConnection(bg, fd, sa)
+			return
+		}
+		s.serveConnection(loop, fd, sa)
+	}
+}
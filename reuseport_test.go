@@ -0,0 +1,99 @@
+package fastnet
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServer_ListenerPerLoop(t *testing.T) {
+	handler := new(example)
+	s, err := NewServer(handler, Address("127.0.0.1:0"), NumLoops(4), ListenerPerLoop(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	if len(s.loopListeners) != 4 || s.listener != nil {
+		t.Fatalf("expect one listener per loop, but got %d", len(s.loopListeners))
+	}
+
+	// 内核按四元组哈希分配连接，64 个连接全部落在少于 4 个 listener 上的概率可以忽略
+	const n = 64
+	for i := 0; i < n; i++ {
+		conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		if i%16 == 0 {
+			expectEcho(t, conn, "hello")
+		}
+	}
+	waitConnections(t, handler, n)
+
+	stats := s.Stats()
+	if stats.Connections != n || stats.Accepted != n {
+		t.Fatalf("expect %d connections and accepts, but got %+v", n, stats)
+	}
+	for i, c := range stats.LoopConnections {
+		if c == 0 {
+			t.Fatalf("expect every loop to accept connections, but loop %d got none: %v", i, stats.LoopConnections)
+		}
+	}
+}
+
+func TestServer_ListenerPerLoopFallback(t *testing.T) {
+	handler := new(example)
+	addr := filepath.Join(t.TempDir(), "fallback.sock")
+	s, err := NewServer(handler, Network("unix"), Address(addr), NumLoops(2), ListenerPerLoop(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	if s.listener == nil || len(s.loopListeners) != 0 {
+		t.Fatal("expect a single listener for networks without SO_REUSEPORT support")
+	}
+	conn, err := net.DialTimeout("unix", addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	expectEcho(t, conn, "hello")
+}
+
+// BenchmarkServer_Accept：8 个事件循环下，每个事件循环各自监听与主事件循环单独 Accept 的建立连接吞吐量对比
+func BenchmarkServer_Accept(b *testing.B) {
+	b.Run("single", func(b *testing.B) { benchmarkAccept(b, false) })
+	b.Run("reuseport", func(b *testing.B) { benchmarkAccept(b, true) })
+}
+
+func benchmarkAccept(b *testing.B, reusePort bool) {
+	handler := new(example)
+	s, err := NewServer(handler, Address("127.0.0.1:0"), NumLoops(8), ListenerPerLoop(reusePort))
+	if err != nil {
+		b.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+	addr := s.Addr()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			conn, err := net.DialTimeout("tcp", addr, time.Second)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			// 以 RST 关闭，避免大量 TIME_WAIT 耗尽本地端口
+			_ = conn.(*net.TCPConn).SetLinger(0)
+			_ = conn.Close()
+		}
+	})
+	b.StopTimer()
+}
@@ -0,0 +1,67 @@
+package fastnet
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// sctpSupported：内核是否支持 SCTP
+func sctpSupported() bool {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_SEQPACKET, unix.IPPROTO_SCTP)
+	if err != nil {
+		return false
+	}
+	_ = unix.Close(fd)
+	return true
+}
+
+func TestServer_SCTP(t *testing.T) {
+	if !sctpSupported() {
+		t.Skip("sctp is not supported by the kernel")
+	}
+	s, err := NewServer(new(example),
+		Network("sctp"),
+		Address("127.0.0.1:0"),
+		NumLoops(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	addr, err := net.ResolveTCPAddr("tcp", s.Addr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sa := &unix.SockaddrInet4{Port: addr.Port}
+	copy(sa.Addr[:], addr.IP.To4())
+
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_SEQPACKET, unix.IPPROTO_SCTP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unix.Close(fd)
+	if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &unix.Timeval{Sec: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 每条消息单独回显，消息边界保持不变
+	msgs := []string{"hello", "sctp", "message boundaries"}
+	for _, msg := range msgs {
+		if err := unix.Sendto(fd, []byte(msg), 0, sa); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf := make([]byte, 1024)
+	for _, msg := range msgs {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != msg {
+			t.Fatalf("expect %q, but got %q", msg, buf[:n])
+		}
+	}
+}
@@ -2,14 +2,20 @@ package fastnet
 
 import (
 	"errors"
+	"net"
+	"net/http"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/Dongxiem/fastnet/connection"
 	"github.com/Dongxiem/fastnet/eventloop"
 	"github.com/Dongxiem/fastnet/listener"
 	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/poller"
+	"github.com/Dongxiem/fastnet/tool/ringbuffer/pool"
 	"github.com/Dongxiem/fastnet/tool/sync"
+	"github.com/Dongxiem/fastnet/tool/sync/atomic"
 	"github.com/RussellLuo/timingwheel"
 	"golang.org/x/sys/unix"
 )
@@ -17,30 +23,135 @@ import (
 // Handler：Server 注册接口
 type Handler interface {
 	connection.CallBack
-	OnConnect(c *connection.Connection)
 }
 
 // Server：fastnet Server
 type Server struct {
 	loop          *eventloop.EventLoop 		// 主事件循环，负责监听客户端连接
 	workLoops     []*eventloop.EventLoop 	// 其他负责处理已连接客户端的读写事件
-	nextLoopIndex int 						// 下一个循环索引
+	balancer      LoadBalancer				// 为新连接选择 work 事件循环
+	backgroundLoops     []*eventloop.EventLoop	// 后台事件循环，处理 BackgroundLoops 分类出的连接
+	nextBackgroundIndex atomic.Int64			// 下一个后台循环索引，ListenerPerLoop 时由多个事件循环并发更新
 	callback      Handler 					// 回调处理
 
 	timingWheel *timingwheel.TimingWheel	// 定时器
 	opts        *Options 					// 配置选项
+
+	connPool *connection.Pool				// 连接池，设置了 MaxConnections 时使用
+	connOpts []connection.Option			// 创建连接时的选项
+	audit    *auditor					// 审计事件分发，设置了 AuditSink 时使用
+	budget   *connection.BufferBudget	// 全局缓冲区预算，设置了 MaxTotalBufferBytes 时使用
+	flap     *flapGuard				// 抖动来源检测，设置了 FlapGuard 时使用
+	loopStats map[*eventloop.EventLoop]*connection.Stats	// 每个处理连接的事件循环的计数器，由 Stats 汇总
+	registry  *connection.Registry	// 按 ID 索引尚未关闭的连接
+	accepted  atomic.Int64			// listener 接受的连接数
+	auditClosed atomic.Bool
+	stopping         StoppingHandler	// 原始 handler 实现了 StoppingHandler 时使用
+	stoppingNotified atomic.Bool		// 是否已经回调过 OnServerStopping
+	listenFd    int						// 监听的 socket，UDP 模式下为数据报 socket
+	listener    *listener.Listener		// 流式网络的 listener，UDP 及 SCTP 模式下为 nil
+	loopListeners []*listener.Listener	// ListenerPerLoop 时每个 work 事件循环各自的 listener
+	paused      atomic.Bool				// 是否通过 Pause 暂停了读取
+	draining    atomic.Bool				// 是否通过 Drain 进入了排空状态
+	healthLn    net.Listener			// 健康检查单独监听时的 listener
+	healthSrv   *http.Server
 }
 
+// ErrPreallocateWithoutLimit：开启 Preallocate 但未设置 MaxConnections
+var ErrPreallocateWithoutLimit = errors.New("preallocate requires MaxConnections")
+
+// ErrGoroutineWithPreallocate：GoroutinePerConnection 与 Preallocate 同时开启，
+// 连接 goroutine 在 OnClose 之后仍可能访问连接，预分配的连接会被复用
+var ErrGoroutineWithPreallocate = errors.New("goroutine per connection cannot be used with preallocate")
+
+// ErrConcurrencyWithoutGoroutine：设置了 MaxConcurrentMessages 但未开启 GoroutinePerConnection，
+// 默认模式下 OnMessage 在事件循环中执行，并发数已经不超过事件循环的个数
+var ErrConcurrencyWithoutGoroutine = errors.New("max concurrent messages requires goroutine per connection")
+
 // NewServer：创建 Server
 func NewServer(handler Handler, opts ...Option) (server *Server, err error) {
 	if handler == nil {
 		return nil, errors.New("handler is nil")
 	}
 	options := newOptions(opts...)
+	if options.Preallocate && options.MaxConnections <= 0 {
+		return nil, ErrPreallocateWithoutLimit
+	}
+	if options.GoroutinePerConnection && options.Preallocate {
+		return nil, ErrGoroutineWithPreallocate
+	}
+	if options.MaxConcurrentMessages > 0 && !options.GoroutinePerConnection {
+		return nil, ErrConcurrencyWithoutGoroutine
+	}
 	// server 创建及配置
 	server = new(Server)
-	server.callback = handler
+	server.callback = chain(handler, options.Middlewares)
+	server.stopping, _ = handler.(StoppingHandler)
+	if options.GoroutinePerConnection {
+		h := &perConnHandler{Handler: server.callback}
+		if options.MaxConcurrentMessages > 0 {
+			h.slots = make(chan struct{}, options.MaxConcurrentMessages)
+		}
+		server.callback = h
+	}
 	server.opts = options
+	server.balancer = options.LoadBalancer
+	if server.balancer == nil {
+		server.balancer = new(RoundRobin)
+	}
+	server.connOpts = []connection.Option{
+		connection.AllowHalfClose(options.AllowHalfClose),
+		connection.MaxReadBufferSize(options.MaxReadBufferSize),
+		connection.MaxPendingResponses(options.MaxPendingResponses),
+		connection.MaxWriteBufferSize(options.MaxWriteBufferSize, options.BufferFullPolicy),
+		connection.WriteBatching(options.WriteBatching),
+	}
+	server.registry = connection.NewRegistry()
+	server.connOpts = append(server.connOpts, connection.WithRegistry(server.registry))
+	var closeHooks []func(c *connection.Connection)
+	if options.AuditSink != nil {
+		server.audit = newAuditor(options.AuditSink)
+		closeHooks = append(closeHooks, func(c *connection.Connection) {
+			server.audit.connEvent(AuditClose, c)
+		})
+	}
+	if options.FlapThreshold > 0 {
+		server.flap = newFlapGuard(options.FlapThreshold, options.FlapWindow, options.FlapCooldown, options.Clock)
+		closeHooks = append(closeHooks, server.flap.closed)
+	}
+	switch len(closeHooks) {
+	case 0:
+	case 1:
+		server.connOpts = append(server.connOpts, connection.CloseHook(closeHooks[0]))
+	default:
+		server.connOpts = append(server.connOpts, connection.CloseHook(func(c *connection.Connection) {
+			for _, h := range closeHooks {
+				h(c)
+			}
+		}))
+	}
+	if options.Deduplicator != nil {
+		server.connOpts = append(server.connOpts, connection.WithDeduplicator(options.Deduplicator))
+	}
+	if options.BufferPool != nil {
+		server.connOpts = append(server.connOpts, connection.WithBufferPool(options.BufferPool))
+	}
+	if options.ReceiveTimestamps {
+		server.connOpts = append(server.connOpts, connection.ReceiveTimestamps(options.HardwareTimestamps))
+	}
+	if options.Clock != nil {
+		server.connOpts = append(server.connOpts, connection.WithClock(options.Clock))
+	}
+	if options.ProtocolErrorLimit > 0 {
+		server.connOpts = append(server.connOpts, connection.ProtocolErrorLimit(options.ProtocolErrorLimit, options.ProtocolErrorWindow))
+	}
+	if options.MaxTotalBufferBytes > 0 {
+		server.budget = connection.NewBufferBudget(options.MaxTotalBufferBytes)
+		server.connOpts = append(server.connOpts, connection.WithBufferBudget(server.budget))
+	}
+	if options.MaxConnections > 0 {
+		server.connPool = connection.NewPool(options.MaxConnections, options.Preallocate)
+	}
 	server.timingWheel = timingwheel.NewTimingWheel(server.opts.tick, server.opts.wheelSize)
 	server.loop, err = eventloop.New()
 	if err != nil {
@@ -48,14 +159,26 @@ func NewServer(handler Handler, opts ...Option) (server *Server, err error) {
 		return nil, err
 	}
 
-	// 生成新的监听者 listener
-	l, err := listener.New(server.opts.Network, server.opts.Address, options.ReusePort, server.loop, server.handleNewConnection)
-	if err != nil {
-		return nil, err
-	}
-	// 将该 listener 添加到服务器监听循环，监听可读事件
-	if err = server.loop.AddSocketAndEnableRead(l.Fd(), l); err != nil {
-		return nil, err
+	if isDatagram(server.opts.Network) {
+		// UDP 及 SCTP 模式下没有 accept，数据报（消息）直接由主事件循环读取并分发
+		var fd int
+		if listener.IsSCTP(server.opts.Network) {
+			fd, err = listener.NewSCTP(server.opts.Network, server.opts.Address)
+		} else {
+			fd, err = listener.NewUDP(server.opts.Network, server.opts.Address, options.ReusePort)
+		}
+		if err != nil {
+			return nil, err
+		}
+		server.listenFd = fd
+		u := connection.NewUDPSocket(fd, server.loop, server.opts.Protocol, server.callback, server.opts.UDPBatchSize)
+		if err = server.loop.AddSocketAndEnableRead(fd, u); err != nil {
+			return nil, err
+		}
+	} else if !options.ListenerPerLoop {
+		if err = server.listenStream(); err != nil {
+			return nil, err
+		}
 	}
 
 	// 如果 server.opts.NumLoops 小于等于0，则设置为现机器 CPU 的个数
@@ -64,20 +187,93 @@ func NewServer(handler Handler, opts ...Option) (server *Server, err error) {
 	}
 
 	// 根据 server.opts.NumLoops 创建对应数量的 goroutine（work 协程）负责处理已连接客户端的读写事件
-	wloops := make([]*eventloop.EventLoop, server.opts.NumLoops)
-	for i := 0; i < server.opts.NumLoops; i++ {
+	if server.workLoops, err = newLoops(server.opts.NumLoops, func(l *eventloop.EventLoop) {
+		l.SetSpinBeforeBlock(server.opts.SpinBeforeBlock)
+		if server.opts.Priorities {
+			l.EnablePriorities()
+		}
+		if server.opts.MaxLoopQueue > 0 {
+			l.SetMaxQueue(server.opts.MaxLoopQueue, server.opts.LoopQueueBlocking)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	// 后台事件循环不做忙轮询，避免与 work 事件循环争抢 CPU
+	if server.opts.BackgroundLoops > 0 {
+		if server.backgroundLoops, err = newLoops(server.opts.BackgroundLoops, func(l *eventloop.EventLoop) {
+			if server.opts.MaxLoopQueue > 0 {
+				l.SetMaxQueue(server.opts.MaxLoopQueue, server.opts.LoopQueueBlocking)
+			}
+		}); err != nil {
+			for _, l := range server.workLoops {
+				_ = l.Stop()
+			}
+			return nil, err
+		}
+	}
+
+	// 每个 work 事件循环各自监听，失败时退回到单个 listener
+	if options.ListenerPerLoop && !isDatagram(server.opts.Network) {
+		if err = server.listenPerLoop(); err != nil {
+			log.Error("[reuseport] fall back to a single listener:", err)
+			if err = server.listenStream(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	server.initStats()
+
+	if options.HealthCheckPath != "" {
+		if err = server.listenHealth(); err != nil {
+			return nil, err
+		}
+	}
+
+	return
+}
+
+// listenStream：创建由主事件循环 Accept 的流式网络 listener
+func (s *Server) listenStream() error {
+	// 生成新的监听者 listener
+	l, err := listener.New(s.opts.Network, s.opts.Address, s.opts.ReusePort, s.loop, s.handleNewConnection)
+	if err != nil {
+		return err
+	}
+	s.listenFd = l.Fd()
+	s.listener = l
+	if s.opts.AcceptBatch > 1 {
+		l.SetBatch(s.opts.AcceptBatch, s.handleNewConnections)
+	}
+	// 将该 listener 添加到服务器监听循环，监听可读事件
+	return s.loop.AddSocketAndEnableRead(l.Fd(), l)
+}
+
+// newLoops：创建 n 个事件循环并由 setup 配置，失败时停止已创建的事件循环
+func newLoops(n int, setup func(l *eventloop.EventLoop)) ([]*eventloop.EventLoop, error) {
+	loops := make([]*eventloop.EventLoop, n)
+	for i := 0; i < n; i++ {
 		l, err := eventloop.New()
 		if err != nil {
 			for j := 0; j < i; j++ {
-				_ = wloops[j].Stop()
+				_ = loops[j].Stop()
 			}
 			return nil, err
 		}
-		wloops[i] = l
+		setup(l)
+		loops[i] = l
 	}
-	server.workLoops = wloops
+	return loops, nil
+}
 
-	return
+// isUDP：判断是否为 UDP 网络
+func isUDP(network string) bool {
+	return strings.HasPrefix(network, "udp")
+}
+
+// isDatagram：判断是否为保留消息边界、按数据报处理的网络（UDP 及 SCTP）
+func isDatagram(network string) bool {
+	return isUDP(network) || listener.IsSCTP(network)
 }
 
 // RunAfter：延时任务开启
@@ -90,25 +286,109 @@ func (s *Server) RunEvery(d time.Duration, f func()) *timingwheel.Timer {
 	return s.timingWheel.ScheduleFunc(&everyScheduler{Interval: d}, f)
 }
 
-// nextLoop：下一个循环
+// nextLoop：由 LoadBalancer 选择下一个 work 事件循环
 func (s *Server) nextLoop() *eventloop.EventLoop {
-	// TODO 更多的负载方式
-	loop := s.workLoops[s.nextLoopIndex]
-	s.nextLoopIndex = (s.nextLoopIndex + 1) % len(s.workLoops)
-	return loop
+	return s.balancer.Pick(s.workLoops)
 }
 
 // handleNewConnection：进行监听事件的分发，也即 Listener 中的调用方法
 func (s *Server) handleNewConnection(fd int, sa unix.Sockaddr) {
-	// 取得下一个循环的 work 线程
-	loop := s.nextLoop()
-	// 生成新的 connection 连接
-	c := connection.New(fd, loop, sa, s.opts.Protocol, s.timingWheel, s.opts.IdleTime, s.callback)
-	// 调用回调函数中的 OnConnect 方法
-	s.callback.OnConnect(c)
+	if !s.admit(fd, sa) {
+		return
+	}
+	// 取得下一个循环的 work 线程，后台连接取得下一个后台循环
+	s.serveConnection(s.loopFor(fd, sa), fd, sa)
+}
+
+// admit：计数新连接并检查是否接受，不接受时拒绝并返回 false
+func (s *Server) admit(fd int, sa unix.Sockaddr) bool {
+	s.accepted.Add(1)
+	// 缓冲区预算已用完，不再接受新连接
+	if s.budget != nil && s.budget.Exceeded() {
+		s.reject(fd, sa, "buffer budget exceeded")
+		return false
+	}
+	// 来源 IP 频繁建立又断开连接，处于冷却期
+	if s.flap != nil && s.flap.blocked(sockaddrIP(sa)) {
+		s.reject(fd, sa, "flapping source")
+		return false
+	}
+	return true
+}
+
+// serveConnection：为新连接生成 connection，并交给 loop 处理
+func (s *Server) serveConnection(loop *eventloop.EventLoop, fd int, sa unix.Sockaddr) {
+	// 暂停期间建立的连接同样不读取数据
+	paused := s.paused.Get()
+	opts := s.connOptions(loop, paused)
+	// 生成新的 connection 连接，设置了最大连接数时从连接池获取
+	var c *connection.Connection
+	if s.connPool != nil {
+		c = s.connPool.Get(fd, loop, sa, s.opts.Protocol, s.timingWheel, s.opts.IdleTime, s.callback, opts...)
+		if c == nil {
+			// 连接数已达上限，直接关闭
+			s.reject(fd, sa, "max connections reached")
+			return
+		}
+	} else {
+		c = connection.New(fd, loop, sa, s.opts.Protocol, s.timingWheel, s.opts.IdleTime, s.callback, opts...)
+	}
+	if s.audit != nil {
+		s.audit.connEvent(AuditAccept, c)
+	}
+	// 在连接所属的事件循环中加入连接并回调 OnConnect，开启 EagerRead 时随后立即尝试读取
+	loop.QueueInLoop(func() {
+		// ListenerPerLoop 时在 work 事件循环中 Accept，读取暂停状态之后可能才调用 Pause，加入时再检查一次
+		if !paused && s.paused.Get() {
+			if s.connect(loop, fd, c, false) {
+				_ = c.PauseRead()
+			}
+			return
+		}
+		if s.connect(loop, fd, c, paused) && s.opts.EagerRead && !paused {
+			c.HandleEvent(fd, poller.EventRead)
+		}
+	})
+}
+
+// connect：将连接加入事件循环后回调 OnConnect，返回是否成功加入，只能在 loop 的 goroutine 中调用
+func (s *Server) connect(loop *eventloop.EventLoop, fd int, c *connection.Connection, paused bool) bool {
 	// 将该 socket 添加进监听循环，并且置为读监听事件
 	if err := loop.AddSocketAndEnableRead(fd, c); err != nil {
-		log.Error("[AddSocketAndEnableRead]", err)
+		c.HandlePollerError("add", err)
+		return false
+	}
+	if paused {
+		if err := loop.DisableReadWrite(fd); err != nil {
+			c.HandlePollerError("pause", err)
+			return false
+		}
+	}
+	// 调用回调函数中的 OnConnect 方法，此时尚未处理该连接的任何读事件
+	s.callback.OnConnect(c)
+	if s.audit != nil {
+		s.audit.connEvent(AuditConnect, c)
+	}
+	return true
+}
+
+// handleNewConnections：批量处理一次 Accept 得到的所有新连接
+func (s *Server) handleNewConnections(conns []listener.Accepted) {
+	for _, a := range conns {
+		s.handleNewConnection(a.Fd, a.Sa)
+	}
+}
+
+// reject：拒绝新连接，设置了 AcceptOverflow 时先发送拒绝信号，然后关闭 fd
+func (s *Server) reject(fd int, sa unix.Sockaddr, reason string) {
+	if s.opts.AcceptOverflow != nil {
+		s.opts.AcceptOverflow(fd)
+	}
+	if err := unix.Close(fd); err != nil {
+		log.Error("[close fd]", err)
+	}
+	if s.audit != nil {
+		s.audit.emit(AuditEvent{Type: AuditReject, PeerAddr: connection.SockAddrToString(sa), Reason: reason})
 	}
 }
 
@@ -119,30 +399,52 @@ func (s *Server) Start() {
 	s.timingWheel.Start()
 	// 获取循环工作线程的大小
 	length := len(s.workLoops)
-	// 然后让每个工作线程都启动
+	// 然后让每个工作线程都启动，并带上 pprof 标签以便在 profile 中区分
 	for i := 0; i < length; i++ {
-		sw.AddAndRun(s.workLoops[i].RunLoop)
+		loop, label := s.workLoops[i], workLoopLabel(i)
+		sw.AddAndRun(func() { runLabeled(label, loop.RunLoop) })
+	}
+	// 后台事件循环以较低的优先级运行
+	for i, loop := range s.backgroundLoops {
+		loop, label := loop, backgroundLoopLabel(i)
+		sw.AddAndRun(func() { runLabeled(label, func() { runBackground(loop) }) })
 	}
 	// 并开启主事件循环
-	sw.AddAndRun(s.loop.RunLoop)
+	sw.AddAndRun(func() { runLabeled("main", s.loop.RunLoop) })
+	// 健康检查单独监听时启动 HTTP 服务
+	if s.healthSrv != nil {
+		sw.AddAndRun(s.serveHealth)
+	}
 	// 在这里等待所有线程结束、退出
 	sw.Wait()
 }
 
-// Stop：关闭 Server
+// Stop：立即关闭 Server，所有连接随事件循环一起关闭，需要等待连接处理完毕时使用 Shutdown。
+// 实现了 StoppingHandler 时先回调 OnServerStopping
 func (s *Server) Stop() {
+	// 在关闭任何连接之前通知应用
+	s.notifyStopping()
 	// 先停止 timingWheel
 	s.timingWheel.Stop()
 	// 关闭主循环线程
 	if err := s.loop.Stop(); err != nil {
 		log.Error(err)
 	}
-	// 关闭其他工作线程
-	for k := range s.workLoops {
-		if err := s.workLoops[k].Stop(); err != nil {
+	// 关闭其他工作线程及后台线程
+	for _, l := range s.connLoops() {
+		if err := l.Stop(); err != nil {
 			log.Error(err)
 		}
 	}
+	// 关闭健康检查
+	if s.healthSrv != nil {
+		_ = s.healthSrv.Close()
+		_ = s.healthLn.Close()
+	}
+	// 所有事件循环退出后不会再产生审计事件，输出剩余的事件
+	if s.audit != nil && !s.auditClosed.Set(true) {
+		s.audit.close()
+	}
 }
 
 // Options：返回 options
@@ -150,3 +452,28 @@ func (s *Server) Options() Options {
 	return *s.opts
 }
 
+// Addr：实际监听的地址，通过 getsockname 获取，监听 ":0" 时可以由此得到系统分配的端口
+func (s *Server) Addr() string {
+	sa, err := unix.Getsockname(s.listenFd)
+	if err != nil {
+		log.Error("[getsockname]", err)
+		return ""
+	}
+	return connection.SockAddrToString(sa)
+}
+
+// Connection：返回 ID 为 id 且尚未关闭的连接，用于在回调之外定向推送，可以在任意 goroutine 中调用。
+// 返回的连接随时可能关闭，此时 Send 等方法返回错误
+func (s *Server) Connection(id int64) (*connection.Connection, bool) {
+	return s.registry.Get(id)
+}
+
+// BufferStats：连接读写缓冲区的分配统计，来自 BufferPool 设置的缓冲池，未设置时来自 pool.DefaultPool。
+// 扩容次数多说明缓冲区初始容量偏小，新分配次数接近获取次数说明缓冲池复用率低
+func (s *Server) BufferStats() pool.Stats {
+	if s.opts.BufferPool != nil {
+		return s.opts.BufferPool.Stats()
+	}
+	return pool.DefaultPool.Stats()
+}
+
@@ -1,6 +1,8 @@
 package fastnet
 
 import (
+	"bytes"
+	"context"
 	"github.com/Dongxiem/fastnet/tool/sync"
 	"io"
 	"net"
@@ -9,6 +11,7 @@ import (
 
 	"github.com/Dongxiem/fastnet/connection"
 	"github.com/Dongxiem/fastnet/log"
+	"github.com/Dongxiem/fastnet/tool/sync/atomic"
 )
 
 type example2 struct {
@@ -118,3 +121,208 @@ func TestIdleTime(t *testing.T) {
 
 	s.Stop()
 }
+
+type example4 struct {
+	conn chan *connection.Connection
+}
+
+func (s *example4) OnConnect(c *connection.Connection) {
+	s.conn <- c
+}
+
+func (s *example4) OnMessage(c *connection.Connection, ctx interface{}, data []byte) (out []byte) {
+	return
+}
+
+func (s *example4) OnClose(c *connection.Connection) {
+}
+
+func TestConnDone(t *testing.T) {
+	handler := &example4{conn: make(chan *connection.Connection, 1)}
+
+	s, err := NewServer(handler,
+		Network("tcp"),
+		Address(":1840"),
+		NumLoops(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Start()
+	defer s.Stop()
+
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:1840", time.Second*60)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := <-handler.conn
+	select {
+	case <-c.Done():
+		t.Fatal("Done should not be closed before disconnect")
+	default:
+	}
+
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second * 3):
+		t.Fatal("Done should be closed after disconnect")
+	}
+	if c.ConnContext().Err() != context.Canceled {
+		t.Fatal("ConnContext should be canceled after disconnect")
+	}
+}
+
+type example5 struct {
+	conn      chan *connection.Connection
+	closed    chan struct{}
+	readClose atomic.Int32
+	tail      []byte
+}
+
+func (s *example5) OnConnect(c *connection.Connection) {
+	s.conn <- c
+}
+
+func (s *example5) OnMessage(c *connection.Connection, ctx interface{}, data []byte) (out []byte) {
+	return data
+}
+
+func (s *example5) OnReadClose(c *connection.Connection) {
+	s.readClose.Add(1)
+	if err := c.Send(s.tail); err != nil {
+		panic(err)
+	}
+}
+
+func (s *example5) OnClose(c *connection.Connection) {
+	close(s.closed)
+}
+
+func TestAllowHalfClose(t *testing.T) {
+	handler := &example5{
+		conn:   make(chan *connection.Connection, 1),
+		closed: make(chan struct{}),
+		tail:   bytes.Repeat([]byte("a"), 1<<20),
+	}
+
+	s, err := NewServer(handler,
+		Network("tcp"),
+		Address(":1845"),
+		NumLoops(2),
+		AllowHalfClose(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Start()
+	defer s.Stop()
+
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:1845", time.Second*60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	c := <-handler.conn
+
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.(*net.TCPConn).CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 半关闭后服务端仍然发送完剩余的数据
+	buf := make([]byte, 5+len(handler.tail))
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:5]) != "hello" || !bytes.Equal(buf[5:], handler.tail) {
+		t.Fatal("unexpected data after half close")
+	}
+	if handler.readClose.Get() != 1 {
+		t.Fatalf("OnReadClose should be called once, but %d", handler.readClose.Get())
+	}
+	if !c.Connected() {
+		t.Fatal("connection should stay open after half close")
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-handler.closed:
+	case <-time.After(time.Second * 3):
+		t.Fatal("OnClose should be called after Close")
+	}
+}
+
+func TestPreallocate(t *testing.T) {
+	if _, err := NewServer(new(example), Address(":1846"), Preallocate(true)); err != ErrPreallocateWithoutLimit {
+		t.Fatalf("expect ErrPreallocateWithoutLimit, but got %v", err)
+	}
+
+	handler := new(example)
+	s, err := NewServer(handler,
+		Network("tcp"),
+		Address(":1846"),
+		NumLoops(2),
+		MaxConnections(2),
+		Preallocate(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go s.Start()
+	defer s.Stop()
+
+	echo := func(conn net.Conn) error {
+		if _, err := conn.Write([]byte("ping")); err != nil {
+			return err
+		}
+		buf := make([]byte, 4)
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second * 3))
+		_, err := io.ReadFull(conn, buf)
+		return err
+	}
+
+	var conns []net.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := net.DialTimeout("tcp", "127.0.0.1:1846", time.Second*60)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	for _, conn := range conns[:2] {
+		if err := echo(conn); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 超过最大连接数的连接被直接关闭
+	if err := echo(conns[2]); err == nil {
+		t.Fatal("connection over MaxConnections should be closed")
+	}
+
+	// 关闭一个连接后，回收的连接状态可以被新连接复用
+	_ = conns[0].Close()
+	for s.connPool.Active() != 1 {
+		time.Sleep(time.Millisecond * 10)
+	}
+	conn, err := net.DialTimeout("tcp", "127.0.0.1:1846", time.Second*60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := echo(conn); err != nil {
+		t.Fatal(err)
+	}
+	if err := echo(conns[1]); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -265,3 +265,35 @@ func TestServer_Stop1(t *testing.T) {
 
 	s.Stop()
 }
+
+func TestServer_Addr(t *testing.T) {
+	s, err := NewServer(new(example),
+		Address(":0"),
+		NumLoops(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	addr := s.Addr()
+	if _, port, err := net.SplitHostPort(addr); err != nil || port == "0" {
+		t.Fatalf("expect concrete bound address, but got %q", addr)
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "hello" {
+		t.Fatalf("expect hello, but got %s", buf)
+	}
+}
@@ -0,0 +1,59 @@
+package fastnet
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/eventloop"
+)
+
+// shutdownPollInterval：Shutdown 检查剩余连接数的间隔
+const shutdownPollInterval = 10 * time.Millisecond
+
+// Shutdown：平滑关闭 Server。先关闭监听 socket 不再接受新连接，并进入 Drain 的排空状态，
+// 然后等待已有连接自行关闭，全部关闭或 ctx 结束后调用 Stop 停止所有事件循环及时间轮。
+// ctx 结束时仍未关闭的连接被强制关闭（同样会回调 OnClose），返回的错误包装了 ctx.Err() 并说明强制关闭的连接数。
+// 实现了 StoppingHandler 时，OnServerStopping 在 Drain 之前回调。
+// 保留 Stop 的立即关闭语义，是为了不影响已有的调用方
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.notifyStopping()
+	s.Drain()
+	if s.listener != nil {
+		_ = s.listener.Close()
+	}
+	for _, l := range s.loopListeners {
+		_ = l.Close()
+	}
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		n := s.activeConnections()
+		if n == 0 {
+			s.Stop()
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			s.Stop()
+			return fmt.Errorf("fastnet: shutdown: %d connections closed forcibly: %w", n, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// activeConnections：work 及后台事件循环中尚未关闭的连接数
+func (s *Server) activeConnections() int {
+	n := 0
+	for _, l := range s.connLoops() {
+		l.RangeSockets(func(fd int, sock eventloop.Socket) bool {
+			if _, ok := sock.(*connection.Connection); ok {
+				n++
+			}
+			return true
+		})
+	}
+	return n
+}
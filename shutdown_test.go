@@ -0,0 +1,96 @@
+package fastnet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func startShutdownServer(t *testing.T) (*Server, *example, chan struct{}) {
+	handler := new(example)
+	s, err := NewServer(handler, Address("127.0.0.1:0"), NumLoops(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		s.Start()
+		close(done)
+	}()
+	return s, handler, done
+}
+
+func TestServer_Shutdown(t *testing.T) {
+	s, handler, done := startShutdownServer(t)
+	addr := s.Addr()
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectEcho(t, conn, "before")
+
+	result := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		result <- s.Shutdown(ctx)
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// 不再接受新连接，已有连接照常处理
+	if c, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		c.Close()
+		t.Fatal("new connections should be refused")
+	}
+	expectEcho(t, conn, "after")
+	select {
+	case err := <-result:
+		t.Fatalf("shutdown should wait for the connection, but returned %v", err)
+	default:
+	}
+
+	_ = conn.Close()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("expect nil, but got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("shutdown should return after the connection closed")
+	}
+	<-done
+	if n := handler.Count.Get(); n != 0 {
+		t.Fatalf("expect all OnClose called, but %d connections remain", n)
+	}
+}
+
+func TestServer_ShutdownTimeout(t *testing.T) {
+	s, handler, done := startShutdownServer(t)
+	conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	expectEcho(t, conn, "idle")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err = s.Shutdown(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "1 connections") {
+		t.Fatalf("expect a deadline error for 1 connection, but got %v", err)
+	}
+	<-done
+
+	// 剩余的连接被强制关闭
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("expect EOF, but got %v", err)
+	}
+	if n := handler.Count.Get(); n != 0 {
+		t.Fatalf("expect OnClose for the remaining connection, but %d remain", n)
+	}
+}
@@ -0,0 +1,64 @@
+package fastnet
+
+import (
+	"github.com/Dongxiem/fastnet/connection"
+	"github.com/Dongxiem/fastnet/eventloop"
+)
+
+// ServerStats：Server 的运行统计，由 Stats 返回
+type ServerStats struct {
+	Connections     int64    // 尚未关闭的连接数
+	LoopConnections []int64  // 每个事件循环的连接数，依次为 work 事件循环及后台事件循环
+	BytesRead       int64    // 所有连接从 socket 读到的字节数
+	BytesWritten    int64    // 所有连接写入 socket 的字节数
+	Messages        int64    // 所有连接交给 OnMessage 处理的消息数
+	LoopQueues      []int    // 每个事件循环中等待执行的任务数，顺序与 LoopConnections 相同
+	LoopLabels      []string // 每个事件循环的 LabelLoop 标签值（work-N 或 background-N），顺序与 LoopConnections 相同
+	Accepted        int64    // listener 接受的连接数，包括因连接数上限等原因被拒绝的连接
+}
+
+// Stats：返回连接数、读写字节数等统计，可以在任意 goroutine 中调用，不必在每个 Handler 中自行统计。
+// 各项计数分别原子地读取，彼此之间不是同一时刻的快照
+func (s *Server) Stats() ServerStats {
+	loops := s.connLoops()
+	stats := ServerStats{
+		LoopConnections: make([]int64, len(loops)),
+		LoopQueues:      make([]int, len(loops)),
+		LoopLabels:      make([]string, len(loops)),
+		Accepted:        s.accepted.Get(),
+	}
+	for i, l := range loops {
+		ls := s.loopStats[l]
+		n := ls.Connections.Get()
+		stats.LoopConnections[i] = n
+		stats.Connections += n
+		stats.BytesRead += ls.BytesRead.Get()
+		stats.BytesWritten += ls.BytesWritten.Get()
+		stats.Messages += ls.Messages.Get()
+		stats.LoopQueues[i] = l.QueueLength()
+		if i < len(s.workLoops) {
+			stats.LoopLabels[i] = workLoopLabel(i)
+		} else {
+			stats.LoopLabels[i] = backgroundLoopLabel(i - len(s.workLoops))
+		}
+	}
+	return stats
+}
+
+// initStats：为每个处理连接的事件循环创建计数器
+func (s *Server) initStats() {
+	loops := s.connLoops()
+	s.loopStats = make(map[*eventloop.EventLoop]*connection.Stats, len(loops))
+	for _, l := range loops {
+		s.loopStats[l] = new(connection.Stats)
+	}
+}
+
+// connOptions：创建属于 loop 的连接时使用的选项，连接计入 loop 的计数器，paused 时不读取数据
+func (s *Server) connOptions(loop *eventloop.EventLoop, paused bool) []connection.Option {
+	opts := append(s.connOpts[:len(s.connOpts):len(s.connOpts)], connection.WithStats(s.loopStats[loop]))
+	if paused {
+		opts = append(opts, connection.ReadPaused())
+	}
+	return opts
+}
@@ -0,0 +1,62 @@
+package fastnet
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServer_Stats(t *testing.T) {
+	handler := new(example)
+	s, err := NewServer(handler, Address("127.0.0.1:0"), NumLoops(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Start()
+	defer s.Stop()
+
+	const n = 3
+	conns := make([]net.Conn, n)
+	for i := range conns {
+		conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+	}
+	waitConnections(t, handler, n)
+	for _, conn := range conns {
+		expectEcho(t, conn, "hello")
+	}
+
+	stats := s.Stats()
+	if stats.Connections != n || stats.Accepted != n {
+		t.Fatalf("expect %d connections and accepts, but got %+v", n, stats)
+	}
+	if len(stats.LoopConnections) != 2 {
+		t.Fatalf("expect 2 loops, but got %v", stats.LoopConnections)
+	}
+	var sum int64
+	for _, c := range stats.LoopConnections {
+		sum += c
+	}
+	if sum != n {
+		t.Fatalf("expect per-loop connections to sum to %d, but got %v", n, stats.LoopConnections)
+	}
+	if want := int64(n * len("hello")); stats.BytesRead != want || stats.BytesWritten != want {
+		t.Fatalf("expect %d bytes read and written, but got %+v", want, stats)
+	}
+	if stats.LoopLabels[1] != "work-1" {
+		t.Fatalf("expect loop labels to match pprof labels, but got %v", stats.LoopLabels)
+	}
+	if stats.Messages != n || len(stats.LoopQueues) != 2 {
+		t.Fatalf("expect %d messages and 2 loop queues, but got %+v", n, stats)
+	}
+
+	_ = conns[0].Close()
+	waitConnections(t, handler, n-1)
+	if stats = s.Stats(); stats.Connections != n-1 || stats.Accepted != n {
+		t.Fatalf("expect %d connections and %d accepts after close, but got %+v", n-1, n, stats)
+	}
+}
@@ -0,0 +1,21 @@
+package fastnet
+
+// StoppingHandler：可选接口，Handler 实现后会在 Server 停止前收到一次 OnServerStopping 回调。
+//
+// 顺序保证：OnServerStopping 在 Stop 或 Shutdown 的最开始、同步地在调用方 goroutine 中执行，
+// 此时监听 socket 尚未关闭，事件循环仍在运行，因停止而产生的 OnClose 都发生在它返回之后。
+// Shutdown 期间客户端自行关闭的连接同样在它之后回调 OnClose。适合在连接大量关闭之前一次性地落盘状态等。
+// 多次调用 Stop、Shutdown 只回调一次。
+//
+// 与 BatchCallBack 等连接级可选接口不同，检测作用于传给 NewServer 的原始 handler，不受中间件包装的影响
+type StoppingHandler interface {
+	OnServerStopping()
+}
+
+// notifyStopping：在停止事件循环之前回调 OnServerStopping，只执行一次
+func (s *Server) notifyStopping() {
+	if s.stopping == nil || s.stoppingNotified.Set(true) {
+		return
+	}
+	s.stopping.OnServerStopping()
+}
@@ -0,0 +1,102 @@
+package fastnet
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Dongxiem/fastnet/connection"
+)
+
+type stoppingExample struct {
+	example
+	mu     sync.Mutex
+	events []string
+}
+
+func (s *stoppingExample) OnServerStopping() {
+	s.mu.Lock()
+	s.events = append(s.events, "stopping")
+	s.mu.Unlock()
+}
+
+func (s *stoppingExample) OnClose(c *connection.Connection) {
+	s.mu.Lock()
+	s.events = append(s.events, "close")
+	s.mu.Unlock()
+	s.example.OnClose(c)
+}
+
+func (s *stoppingExample) snapshot() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.events...)
+}
+
+func testServerStopping(t *testing.T, stop func(s *Server, conns []net.Conn)) {
+	handler := new(stoppingExample)
+	s, err := NewServer(handler, Address("127.0.0.1:0"), NumLoops(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		s.Start()
+		close(done)
+	}()
+
+	const n = 4
+	var conns []net.Conn
+	for i := 0; i < n; i++ {
+		conn, err := net.DialTimeout("tcp", s.Addr(), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	waitConnections(t, &handler.example, n)
+
+	stop(s, conns)
+	waitConnections(t, &handler.example, 0)
+	<-done
+
+	events := handler.snapshot()
+	if len(events) != n+1 {
+		t.Fatalf("expect %d events, but got %v", n+1, events)
+	}
+	if events[0] != "stopping" {
+		t.Fatalf("OnServerStopping must precede OnClose, got %v", events)
+	}
+	for _, e := range events[1:] {
+		if e != "close" {
+			t.Fatalf("expect only one OnServerStopping, got %v", events)
+		}
+	}
+}
+
+func TestServer_StoppingBeforeStop(t *testing.T) {
+	testServerStopping(t, func(s *Server, conns []net.Conn) { s.Stop() })
+}
+
+// Shutdown 期间由客户端关闭的连接同样在 OnServerStopping 之后回调 OnClose，
+// 且 Shutdown 内部调用 Stop 时不会重复回调
+func TestServer_StoppingBeforeShutdown(t *testing.T) {
+	testServerStopping(t, func(s *Server, conns []net.Conn) {
+		result := make(chan error, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			result <- s.Shutdown(ctx)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+		if err := <-result; err != nil {
+			t.Fatal(err)
+		}
+	})
+}
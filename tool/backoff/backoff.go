@@ -0,0 +1,129 @@
+package backoff
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Backoff：重试、重新调度的延迟策略，每次重试前调用 Next 得到需要等待的时间，成功后调用 Reset 重新开始。
+// 重连、绑定重试、限流重试等需要延迟的功能都应通过 Backoff 获取延迟，使用者也可以提供自己的实现。
+// 内置的实现都不是并发安全的，每个重试序列使用单独的实例
+type Backoff interface {
+	// Next：下一次重试前需要等待的时间
+	Next() time.Duration
+	// Reset：重置到初始状态
+	Reset()
+}
+
+// newRand：每个实例使用单独的随机数源，避免争用全局锁
+func newRand() *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
+// Constant：固定延迟
+type Constant struct {
+	delay time.Duration
+}
+
+// NewConstant：每次都等待 delay 的 Backoff
+func NewConstant(delay time.Duration) *Constant {
+	return &Constant{delay: delay}
+}
+
+// Next：返回固定的延迟
+func (b *Constant) Next() time.Duration {
+	return b.delay
+}
+
+// Reset：固定延迟没有状态
+func (b *Constant) Reset() {}
+
+// Exponential：指数退避，第 n 次的延迟为 base*2^n，不超过 max，
+// 再在 [d*(1-jitter), d*(1+jitter)] 范围内随机抖动（同样不超过 max），避免大量客户端同时重试
+type Exponential struct {
+	base   time.Duration
+	max    time.Duration
+	jitter float64
+	delay  time.Duration // 下一次未抖动的延迟，0 表示从 base 开始
+	rnd    *rand.Rand
+}
+
+// NewExponential：创建指数退避，jitter 取值 [0, 1]，为 0 时不抖动
+func NewExponential(base, max time.Duration, jitter float64) *Exponential {
+	if jitter < 0 {
+		jitter = 0
+	} else if jitter > 1 {
+		jitter = 1
+	}
+	return &Exponential{base: base, max: max, jitter: jitter, rnd: newRand()}
+}
+
+// Next：返回当前延迟并翻倍
+func (b *Exponential) Next() time.Duration {
+	if b.delay == 0 {
+		b.delay = b.base
+	}
+	d := b.delay
+	if b.delay < b.max {
+		b.delay *= 2
+		if b.delay > b.max || b.delay <= 0 {
+			b.delay = b.max
+		}
+	}
+	if d > b.max {
+		d = b.max
+	}
+	if b.jitter > 0 {
+		delta := float64(d) * b.jitter
+		d = time.Duration(float64(d) - delta + b.rnd.Float64()*2*delta)
+		if d > b.max {
+			d = b.max
+		}
+	}
+	return d
+}
+
+// Reset：从 base 重新开始
+func (b *Exponential) Reset() {
+	b.delay = 0
+}
+
+// Decorrelated：去相关抖动，每次的延迟在 [base, 上一次延迟*3] 中随机选取，不超过 max，
+// 与指数退避相比延迟增长更平缓且不同客户端之间更分散
+type Decorrelated struct {
+	base  time.Duration
+	max   time.Duration
+	delay time.Duration // 上一次的延迟，0 表示从 base 开始
+	rnd   *rand.Rand
+}
+
+// NewDecorrelated：创建去相关抖动的 Backoff
+func NewDecorrelated(base, max time.Duration) *Decorrelated {
+	return &Decorrelated{base: base, max: max, rnd: newRand()}
+}
+
+// Next：在 [base, 上一次延迟*3] 中随机选取下一次的延迟
+func (b *Decorrelated) Next() time.Duration {
+	prev := b.delay
+	if prev < b.base {
+		prev = b.base
+	}
+	upper := prev * 3
+	if upper <= 0 || upper > b.max {
+		upper = b.max
+	}
+	d := b.base
+	if upper > b.base {
+		d += time.Duration(b.rnd.Int63n(int64(upper-b.base) + 1))
+	}
+	if d > b.max {
+		d = b.max
+	}
+	b.delay = d
+	return d
+}
+
+// Reset：从 base 重新开始
+func (b *Decorrelated) Reset() {
+	b.delay = 0
+}
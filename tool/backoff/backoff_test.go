@@ -0,0 +1,104 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+var (
+	_ Backoff = &Constant{}
+	_ Backoff = &Exponential{}
+	_ Backoff = &Decorrelated{}
+)
+
+func TestConstant(t *testing.T) {
+	b := NewConstant(time.Second)
+	for i := 0; i < 5; i++ {
+		if d := b.Next(); d != time.Second {
+			t.Fatalf("expect 1s, but got %v", d)
+		}
+	}
+	b.Reset()
+	if d := b.Next(); d != time.Second {
+		t.Fatalf("expect 1s after Reset, but got %v", d)
+	}
+}
+
+func TestExponential_Sequence(t *testing.T) {
+	b := NewExponential(100*time.Millisecond, time.Second, 0)
+	expect := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		time.Second,
+		time.Second,
+	}
+	for i, want := range expect {
+		if d := b.Next(); d != want {
+			t.Fatalf("step %d: expect %v, but got %v", i, want, d)
+		}
+	}
+	b.Reset()
+	if d := b.Next(); d != 100*time.Millisecond {
+		t.Fatalf("expect base after Reset, but got %v", d)
+	}
+}
+
+func TestExponential_Jitter(t *testing.T) {
+	const jitter = 0.5
+	b := NewExponential(100*time.Millisecond, time.Second, jitter)
+	for round := 0; round < 200; round++ {
+		b.Reset()
+		base := 100 * time.Millisecond
+		for i := 0; i < 6; i++ {
+			if base > time.Second {
+				base = time.Second
+			}
+			low := time.Duration(float64(base) * (1 - jitter))
+			high := time.Duration(float64(base) * (1 + jitter))
+			if high > time.Second {
+				high = time.Second
+			}
+			if d := b.Next(); d < low || d > high {
+				t.Fatalf("step %d: expect delay in [%v, %v], but got %v", i, low, high, d)
+			}
+			base *= 2
+		}
+	}
+}
+
+func TestDecorrelated_Bounds(t *testing.T) {
+	const (
+		base = 10 * time.Millisecond
+		max  = time.Second
+	)
+	b := NewDecorrelated(base, max)
+	for round := 0; round < 200; round++ {
+		b.Reset()
+		prev := base
+		for i := 0; i < 20; i++ {
+			high := prev * 3
+			if high > max {
+				high = max
+			}
+			d := b.Next()
+			if d < base || d > high {
+				t.Fatalf("step %d: expect delay in [%v, %v], but got %v", i, base, high, d)
+			}
+			prev = d
+		}
+	}
+}
+
+func TestDecorrelated_Grows(t *testing.T) {
+	b := NewDecorrelated(10*time.Millisecond, time.Second)
+	var d time.Duration
+	for i := 0; i < 100; i++ {
+		d = b.Next()
+	}
+	// 上界每次最多扩大 3 倍，多次之后应当明显离开 base
+	if d <= 10*time.Millisecond {
+		t.Fatalf("expect delay to grow beyond base, but got %v", d)
+	}
+}
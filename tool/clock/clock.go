@@ -0,0 +1,54 @@
+package clock
+
+import (
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+)
+
+// Timer：AfterFunc 返回的定时器
+type Timer interface {
+	// Stop：取消定时器，定时器已经触发或已被取消时返回 false
+	Stop() bool
+}
+
+// Clock：时钟，连接的空闲超时、发送截止时间等依赖时间的功能都通过 Clock 获取时间及设置定时器，
+// 测试时可以注入 Fake 以推进虚拟时间代替 sleep
+type Clock interface {
+	// Now：当前时间
+	Now() time.Time
+	// AfterFunc：d 时间后在其他 goroutine 中调用 f
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// realClock：使用 time 包的时钟
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Real：返回使用 time.Now 及 time.AfterFunc 的时钟
+func Real() Clock {
+	return realClock{}
+}
+
+// wheelClock：定时器由时间轮驱动的时钟
+type wheelClock timingwheel.TimingWheel
+
+func (w *wheelClock) Now() time.Time {
+	return time.Now()
+}
+
+func (w *wheelClock) AfterFunc(d time.Duration, f func()) Timer {
+	return (*timingwheel.TimingWheel)(w).AfterFunc(d, f)
+}
+
+// Wheel：返回定时器由时间轮 tw 驱动的时钟，精度为时间轮的 tick，适合大量连接的超时管理
+func Wheel(tw *timingwheel.TimingWheel) Clock {
+	return (*wheelClock)(tw)
+}
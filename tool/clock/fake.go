@@ -0,0 +1,83 @@
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Fake：只在调用 Advance 时前进的虚拟时钟，用于确定性地测试依赖时间的功能
+type Fake struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer // 按触发时间排序
+}
+
+type fakeTimer struct {
+	fake *Fake
+	when time.Time
+	f    func()
+}
+
+// NewFake：创建当前时间为 now 的虚拟时钟
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+// Now：当前的虚拟时间
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc：虚拟时间前进 d 后，在调用 Advance 的 goroutine 中调用 f
+func (c *Fake) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{fake: c, when: c.now.Add(d), f: f}
+	// 相同触发时间的定时器按设置的顺序触发
+	i := sort.Search(len(c.timers), func(i int) bool { return c.timers[i].when.After(t.when) })
+	c.timers = append(c.timers, nil)
+	copy(c.timers[i+1:], c.timers[i:])
+	c.timers[i] = t
+	return t
+}
+
+// Advance：虚拟时间前进 d，按时间顺序依次触发到期的定时器，包括触发过程中新设置且在 d 内到期的定时器。
+// 触发定时器时虚拟时间为该定时器的触发时间，所有到期的定时器执行完后 Advance 才返回
+func (c *Fake) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for len(c.timers) > 0 && !c.timers[0].when.After(end) {
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.when
+		c.mu.Unlock()
+		t.f()
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// Pending：尚未触发的定时器个数
+func (c *Fake) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.timers)
+}
+
+// Stop：取消定时器
+func (t *fakeTimer) Stop() bool {
+	c := t.fake
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, timer := range c.timers {
+		if timer == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
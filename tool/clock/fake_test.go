@@ -0,0 +1,40 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFake_Advance(t *testing.T) {
+	start := time.Unix(1000, 0)
+	c := NewFake(start)
+
+	var fired []time.Duration
+	record := func() { fired = append(fired, c.Now().Sub(start)) }
+	c.AfterFunc(3*time.Second, record)
+	c.AfterFunc(time.Second, func() {
+		record()
+		// 触发过程中设置的定时器在本次 Advance 范围内到期时同样触发
+		c.AfterFunc(time.Second, record)
+	})
+	stopped := c.AfterFunc(2*time.Second, record)
+	if !stopped.Stop() || stopped.Stop() {
+		t.Fatal("Stop should only succeed once")
+	}
+
+	c.Advance(2500 * time.Millisecond)
+	if len(fired) != 2 || fired[0] != time.Second || fired[1] != 2*time.Second {
+		t.Fatalf("unexpected fired timers %v", fired)
+	}
+	if got := c.Now().Sub(start); got != 2500*time.Millisecond {
+		t.Fatalf("expect now advanced by 2.5s, but got %v", got)
+	}
+	if c.Pending() != 1 {
+		t.Fatalf("expect 1 pending timer, but got %d", c.Pending())
+	}
+
+	c.Advance(time.Second)
+	if len(fired) != 3 || fired[2] != 3*time.Second || c.Pending() != 0 {
+		t.Fatalf("unexpected fired timers %v", fired)
+	}
+}
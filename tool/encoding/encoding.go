@@ -0,0 +1,185 @@
+// Package encoding 提供协议实现常用的定长整数及 varint 编解码，直接操作切片或 ringbuffer.RingBuffer，不产生内存分配
+package encoding
+
+import (
+	"encoding/binary"
+
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// Order：字节序，提供切片上的 PutUint16/Uint16 等方法以及直接读写 RingBuffer 的方法。
+// 没有使用 binary.ByteOrder 接口，接口调用会使栈上的临时数组逃逸到堆上
+type Order struct {
+	little bool
+}
+
+// 字节序
+var (
+	BigEndian    = Order{}
+	LittleEndian = Order{little: true}
+)
+
+// PutUint16：将 v 写入 b
+func (o Order) PutUint16(b []byte, v uint16) {
+	if o.little {
+		binary.LittleEndian.PutUint16(b, v)
+	} else {
+		binary.BigEndian.PutUint16(b, v)
+	}
+}
+
+// PutUint32：将 v 写入 b
+func (o Order) PutUint32(b []byte, v uint32) {
+	if o.little {
+		binary.LittleEndian.PutUint32(b, v)
+	} else {
+		binary.BigEndian.PutUint32(b, v)
+	}
+}
+
+// PutUint64：将 v 写入 b
+func (o Order) PutUint64(b []byte, v uint64) {
+	if o.little {
+		binary.LittleEndian.PutUint64(b, v)
+	} else {
+		binary.BigEndian.PutUint64(b, v)
+	}
+}
+
+// Uint16：从 b 读取 uint16
+func (o Order) Uint16(b []byte) uint16 {
+	if o.little {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+// Uint32：从 b 读取 uint32
+func (o Order) Uint32(b []byte) uint32 {
+	if o.little {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+// Uint64：从 b 读取 uint64
+func (o Order) Uint64(b []byte) uint64 {
+	if o.little {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return binary.BigEndian.Uint64(b)
+}
+
+// String：字节序的名称
+func (o Order) String() string {
+	if o.little {
+		return "LittleEndian"
+	}
+	return "BigEndian"
+}
+
+// peek：将 rb 开头的 len(p) 个字节复制到 p，数据不足时返回 false
+func peek(rb *ringbuffer.RingBuffer, p []byte) bool {
+	if rb.Length() < len(p) {
+		return false
+	}
+	first, end := rb.Peek(len(p))
+	copy(p[copy(p, first):], end)
+	return true
+}
+
+// PeekUint16：读取 rb 开头的 uint16 但不移动读指针，数据不足时返回 false
+func (o Order) PeekUint16(rb *ringbuffer.RingBuffer) (uint16, bool) {
+	var b [2]byte
+	if !peek(rb, b[:]) {
+		return 0, false
+	}
+	return o.Uint16(b[:]), true
+}
+
+// PeekUint32：读取 rb 开头的 uint32 但不移动读指针，数据不足时返回 false
+func (o Order) PeekUint32(rb *ringbuffer.RingBuffer) (uint32, bool) {
+	var b [4]byte
+	if !peek(rb, b[:]) {
+		return 0, false
+	}
+	return o.Uint32(b[:]), true
+}
+
+// PeekUint64：读取 rb 开头的 uint64 但不移动读指针，数据不足时返回 false
+func (o Order) PeekUint64(rb *ringbuffer.RingBuffer) (uint64, bool) {
+	var b [8]byte
+	if !peek(rb, b[:]) {
+		return 0, false
+	}
+	return o.Uint64(b[:]), true
+}
+
+// ReadUint16：读取 rb 开头的 uint16，数据不足时返回 false 且不消耗数据
+func (o Order) ReadUint16(rb *ringbuffer.RingBuffer) (uint16, bool) {
+	v, ok := o.PeekUint16(rb)
+	if ok {
+		rb.Retrieve(2)
+	}
+	return v, ok
+}
+
+// ReadUint32：读取 rb 开头的 uint32，数据不足时返回 false 且不消耗数据
+func (o Order) ReadUint32(rb *ringbuffer.RingBuffer) (uint32, bool) {
+	v, ok := o.PeekUint32(rb)
+	if ok {
+		rb.Retrieve(4)
+	}
+	return v, ok
+}
+
+// ReadUint64：读取 rb 开头的 uint64，数据不足时返回 false 且不消耗数据
+func (o Order) ReadUint64(rb *ringbuffer.RingBuffer) (uint64, bool) {
+	v, ok := o.PeekUint64(rb)
+	if ok {
+		rb.Retrieve(8)
+	}
+	return v, ok
+}
+
+// WriteUint16：将 v 写入 rb
+func (o Order) WriteUint16(rb *ringbuffer.RingBuffer, v uint16) {
+	var b [2]byte
+	o.PutUint16(b[:], v)
+	_, _ = rb.Write(b[:])
+}
+
+// WriteUint32：将 v 写入 rb
+func (o Order) WriteUint32(rb *ringbuffer.RingBuffer, v uint32) {
+	var b [4]byte
+	o.PutUint32(b[:], v)
+	_, _ = rb.Write(b[:])
+}
+
+// WriteUint64：将 v 写入 rb
+func (o Order) WriteUint64(rb *ringbuffer.RingBuffer, v uint64) {
+	var b [8]byte
+	o.PutUint64(b[:], v)
+	_, _ = rb.Write(b[:])
+}
+
+// AppendUint16：将 v 追加到 dst
+func (o Order) AppendUint16(dst []byte, v uint16) []byte {
+	var b [2]byte
+	o.PutUint16(b[:], v)
+	return append(dst, b[:]...)
+}
+
+// AppendUint32：将 v 追加到 dst
+func (o Order) AppendUint32(dst []byte, v uint32) []byte {
+	var b [4]byte
+	o.PutUint32(b[:], v)
+	return append(dst, b[:]...)
+}
+
+// AppendUint64：将 v 追加到 dst
+func (o Order) AppendUint64(dst []byte, v uint64) []byte {
+	var b [8]byte
+	o.PutUint64(b[:], v)
+	return append(dst, b[:]...)
+}
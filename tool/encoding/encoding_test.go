@@ -0,0 +1,171 @@
+package encoding
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"testing/quick"
+
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// wrapped：返回一个读写指针已经绕回的 RingBuffer，写入的数据会跨越缓冲区末尾
+func wrapped() *ringbuffer.RingBuffer {
+	rb := ringbuffer.New(16)
+	_, _ = rb.Write(make([]byte, 13))
+	rb.Retrieve(13)
+	return rb
+}
+
+func TestOrder_Fixed(t *testing.T) {
+	for _, o := range []Order{BigEndian, LittleEndian} {
+		rb := wrapped()
+		o.WriteUint16(rb, math.MaxUint16)
+		o.WriteUint32(rb, 0x01020304)
+		o.WriteUint64(rb, math.MaxUint64-1)
+
+		if v, ok := o.PeekUint16(rb); !ok || v != math.MaxUint16 {
+			t.Fatalf("peek uint16: %v %v", v, ok)
+		}
+		if v, ok := o.ReadUint16(rb); !ok || v != math.MaxUint16 {
+			t.Fatalf("read uint16: %v %v", v, ok)
+		}
+		if v, ok := o.ReadUint32(rb); !ok || v != 0x01020304 {
+			t.Fatalf("read uint32: %#x %v", v, ok)
+		}
+		if v, ok := o.ReadUint64(rb); !ok || v != math.MaxUint64-1 {
+			t.Fatalf("read uint64: %v %v", v, ok)
+		}
+		if _, ok := o.ReadUint16(rb); ok {
+			t.Fatal("read from empty buffer should fail")
+		}
+	}
+
+	// 数据不足时不消耗数据
+	rb := ringbuffer.New(16)
+	_, _ = rb.Write([]byte{1, 2, 3})
+	if _, ok := BigEndian.ReadUint32(rb); ok || rb.Length() != 3 {
+		t.Fatalf("short read should not consume data, length %d", rb.Length())
+	}
+
+	if got := BigEndian.AppendUint32(nil, 0x01020304); !bytes.Equal(got, []byte{1, 2, 3, 4}) {
+		t.Fatalf("big endian: % x", got)
+	}
+	if got := LittleEndian.AppendUint16([]byte{9}, 0x0102); !bytes.Equal(got, []byte{9, 2, 1}) {
+		t.Fatalf("little endian: % x", got)
+	}
+}
+
+func TestUvarint_Boundary(t *testing.T) {
+	values := []uint64{0, 1, 127, 128, 255, 16383, 16384, math.MaxUint32, math.MaxInt64, math.MaxUint64}
+	for _, v := range values {
+		want := make([]byte, MaxVarintLen64)
+		want = want[:binary.PutUvarint(want, v)]
+		if got := AppendUvarint(nil, v); !bytes.Equal(got, want) {
+			t.Fatalf("%d: expect % x, but got % x", v, want, got)
+		}
+
+		rb := wrapped()
+		WriteUvarint(rb, v)
+		// 逐字节不完整时返回长度 0
+		for i := 0; i < len(want); i++ {
+			if _, n, err := Uvarint(want[:i]); n != 0 || err != nil {
+				t.Fatalf("%d: incomplete varint should return 0, got %d %v", v, n, err)
+			}
+		}
+		got, n, err := ReadUvarint(rb)
+		if err != nil || n != len(want) || got != v {
+			t.Fatalf("%d: got %d, n %d, err %v", v, got, n, err)
+		}
+		if rb.Length() != 0 {
+			t.Fatalf("%d: %d bytes left", v, rb.Length())
+		}
+	}
+
+	signed := []int64{0, -1, 1, -64, 63, -65, math.MinInt64, math.MaxInt64}
+	for _, v := range signed {
+		rb := wrapped()
+		WriteVarint(rb, v)
+		got, _, err := ReadVarint(rb)
+		if err != nil || got != v {
+			t.Fatalf("%d: got %d, err %v", v, got, err)
+		}
+		if got, _, err := Varint(AppendVarint(nil, v)); err != nil || got != v {
+			t.Fatalf("%d: got %d, err %v", v, got, err)
+		}
+	}
+}
+
+func TestUvarint_Incomplete(t *testing.T) {
+	rb := ringbuffer.New(16)
+	_, _ = rb.Write([]byte{0x80, 0x80})
+	if _, n, err := ReadUvarint(rb); n != 0 || err != nil || rb.Length() != 2 {
+		t.Fatalf("incomplete varint: n %d, err %v, length %d", n, err, rb.Length())
+	}
+}
+
+func TestUvarint_Overflow(t *testing.T) {
+	overflow := [][]byte{
+		bytes.Repeat([]byte{0xff}, 11),
+		append(bytes.Repeat([]byte{0xff}, 9), 0x02),
+	}
+	for _, b := range overflow {
+		if _, _, err := Uvarint(b); err != ErrVarintOverflow {
+			t.Fatalf("% x: expect overflow, but got %v", b, err)
+		}
+		rb := wrapped()
+		_, _ = rb.Write(b)
+		if _, _, err := PeekUvarint(rb); err != ErrVarintOverflow {
+			t.Fatalf("% x: expect overflow, but got %v", b, err)
+		}
+	}
+}
+
+func TestVarint_RoundTripQuick(t *testing.T) {
+	rb := ringbuffer.New(8)
+	unsigned := func(v uint64) bool {
+		WriteUvarint(rb, v)
+		got, n, err := ReadUvarint(rb)
+		return err == nil && n > 0 && got == v && rb.Length() == 0
+	}
+	if err := quick.Check(unsigned, &quick.Config{MaxCount: 10000}); err != nil {
+		t.Fatal(err)
+	}
+	signed := func(v int64) bool {
+		WriteVarint(rb, v)
+		got, n, err := ReadVarint(rb)
+		return err == nil && n > 0 && got == v && rb.Length() == 0
+	}
+	if err := quick.Check(signed, &quick.Config{MaxCount: 10000}); err != nil {
+		t.Fatal(err)
+	}
+	// 任意字节序列解码不会 panic，且与 encoding/binary 一致
+	decode := func(b []byte) bool {
+		v, n, err := Uvarint(b)
+		want, wn := binary.Uvarint(b)
+		if wn < 0 {
+			return err == ErrVarintOverflow
+		}
+		rb := ringbuffer.New(len(b) + 1)
+		_, _ = rb.Write(b)
+		pv, pn, perr := PeekUvarint(rb)
+		return err == nil && v == want && n == wn && perr == nil && pv == want && pn == wn
+	}
+	if err := quick.Check(decode, &quick.Config{MaxCount: 10000}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncoding_ZeroAlloc(t *testing.T) {
+	rb := ringbuffer.New(64)
+	allocs := testing.AllocsPerRun(100, func() {
+		BigEndian.WriteUint32(rb, 42)
+		WriteUvarint(rb, math.MaxUint64)
+		_, _ = BigEndian.ReadUint32(rb)
+		_, _, _ = ReadUvarint(rb)
+	})
+	if allocs != 0 {
+		t.Fatalf("expect zero allocations, but got %v", allocs)
+	}
+}
@@ -0,0 +1,119 @@
+package encoding
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// MaxVarintLen64：64 位 varint 编码的最大长度
+const MaxVarintLen64 = binary.MaxVarintLen64
+
+// ErrVarintOverflow：varint 超过 64 位
+var ErrVarintOverflow = errors.New("encoding: varint overflows a 64-bit integer")
+
+// PutUvarint：将 v 以 varint 编码写入 b，返回写入的长度，b 至少需要 MaxVarintLen64 字节
+func PutUvarint(b []byte, v uint64) int {
+	return binary.PutUvarint(b, v)
+}
+
+// AppendUvarint：将 v 以 varint 编码追加到 dst
+func AppendUvarint(dst []byte, v uint64) []byte {
+	var b [MaxVarintLen64]byte
+	return append(dst, b[:binary.PutUvarint(b[:], v)]...)
+}
+
+// Uvarint：从 b 解码 varint，返回值及读取的长度。数据不完整时长度为 0，溢出时返回 ErrVarintOverflow
+func Uvarint(b []byte) (uint64, int, error) {
+	v, n := binary.Uvarint(b)
+	if n < 0 {
+		return 0, 0, ErrVarintOverflow
+	}
+	return v, n, nil
+}
+
+// PutVarint：将有符号的 v 以 zigzag varint 编码写入 b，返回写入的长度
+func PutVarint(b []byte, v int64) int {
+	return binary.PutVarint(b, v)
+}
+
+// AppendVarint：将有符号的 v 以 zigzag varint 编码追加到 dst
+func AppendVarint(dst []byte, v int64) []byte {
+	var b [MaxVarintLen64]byte
+	return append(dst, b[:binary.PutVarint(b[:], v)]...)
+}
+
+// Varint：从 b 解码 zigzag varint，约定同 Uvarint
+func Varint(b []byte) (int64, int, error) {
+	u, n, err := Uvarint(b)
+	if err != nil || n == 0 {
+		return 0, n, err
+	}
+	return unzigzag(u), n, nil
+}
+
+// PeekUvarint：解码 rb 开头的 varint 但不移动读指针，约定同 Uvarint
+func PeekUvarint(rb *ringbuffer.RingBuffer) (uint64, int, error) {
+	// 多取一个字节，与 encoding/binary 一致：第 11 个字节仍有延续位时才判定溢出
+	first, end := rb.Peek(MaxVarintLen64 + 1)
+	var v uint64
+	var shift uint
+	for i := 0; i < len(first)+len(end); i++ {
+		if i == MaxVarintLen64 {
+			return 0, 0, ErrVarintOverflow
+		}
+		var c byte
+		if i < len(first) {
+			c = first[i]
+		} else {
+			c = end[i-len(first)]
+		}
+		if c < 0x80 {
+			// 第 10 个字节只能使用最低位
+			if i == MaxVarintLen64-1 && c > 1 {
+				return 0, 0, ErrVarintOverflow
+			}
+			return v | uint64(c)<<shift, i + 1, nil
+		}
+		v |= uint64(c&0x7f) << shift
+		shift += 7
+	}
+	return 0, 0, nil
+}
+
+// ReadUvarint：解码并消耗 rb 开头的 varint，数据不完整时长度为 0 且不消耗数据
+func ReadUvarint(rb *ringbuffer.RingBuffer) (uint64, int, error) {
+	v, n, err := PeekUvarint(rb)
+	if n > 0 {
+		rb.Retrieve(n)
+	}
+	return v, n, err
+}
+
+// ReadVarint：解码并消耗 rb 开头的 zigzag varint，约定同 ReadUvarint
+func ReadVarint(rb *ringbuffer.RingBuffer) (int64, int, error) {
+	u, n, err := ReadUvarint(rb)
+	return unzigzag(u), n, err
+}
+
+// WriteUvarint：将 v 以 varint 编码写入 rb
+func WriteUvarint(rb *ringbuffer.RingBuffer, v uint64) {
+	var b [MaxVarintLen64]byte
+	_, _ = rb.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+// WriteVarint：将有符号的 v 以 zigzag varint 编码写入 rb
+func WriteVarint(rb *ringbuffer.RingBuffer, v int64) {
+	var b [MaxVarintLen64]byte
+	_, _ = rb.Write(b[:binary.PutVarint(b[:], v)])
+}
+
+// unzigzag：zigzag 解码，与 binary.PutVarint 的编码对应
+func unzigzag(u uint64) int64 {
+	v := int64(u >> 1)
+	if u&1 != 0 {
+		v = ^v
+	}
+	return v
+}
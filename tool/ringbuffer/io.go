@@ -0,0 +1,68 @@
+package ringbuffer
+
+import "io"
+
+// minRead：ReadFrom 每次读取前保证的最小空闲空间
+const minRead = 512
+
+var (
+	_ io.WriterTo   = &RingBuffer{}
+	_ io.ReaderFrom = &RingBuffer{}
+)
+
+// WriteTo：实现 io.WriterTo，将全部数据写入 w，已写入的数据从缓冲区中移除。
+// 数据跨越缓冲区末尾时分两次写入，w 写入的字节数少于给出的数据且没有返回错误时返回 io.ErrShortWrite
+func (r *RingBuffer) WriteTo(w io.Writer) (n int64, err error) {
+	first, end := r.PeekAll()
+	for _, b := range [2][]byte{first, end} {
+		if len(b) == 0 {
+			continue
+		}
+		m, err := w.Write(b)
+		r.Retrieve(m)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+		if m < len(b) {
+			return n, io.ErrShortWrite
+		}
+	}
+	return n, nil
+}
+
+// ReadFrom：实现 io.ReaderFrom，从 rd 读取数据直到 io.EOF，空闲空间不足时自动扩容，
+// 返回读取的字节数，io.EOF 不作为错误返回
+func (r *RingBuffer) ReadFrom(rd io.Reader) (n int64, err error) {
+	for {
+		// 没有数据时从数组起点开始，得到最大的连续空闲空间
+		if r.empty() {
+			r.r, r.w, r.vr = 0, 0, 0
+		}
+		if r.free() < minRead {
+			grow := r.size
+			if grow < minRead {
+				grow = minRead
+			}
+			r.makeSpace(grow)
+		}
+		// 只读入写指针之后连续的空闲空间
+		end := r.size
+		if r.w < r.r {
+			end = r.r
+		}
+		m, e := rd.Read(r.buf[r.w:end])
+		if m > 0 {
+			r.w = (r.w + m) % r.size
+			r.isEmpty = false
+			r.vEmpty = false
+			n += int64(m)
+		}
+		if e == io.EOF {
+			return n, nil
+		}
+		if e != nil {
+			return n, e
+		}
+	}
+}
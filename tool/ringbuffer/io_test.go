@@ -0,0 +1,94 @@
+package ringbuffer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// limitedWriter：最多接受 n 字节，之后只写入部分数据
+type limitedWriter struct {
+	bytes.Buffer
+	n int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		p = p[:w.n]
+	}
+	w.n -= len(p)
+	return w.Buffer.Write(p)
+}
+
+func TestRingBuffer_WriteTo(t *testing.T) {
+	rb := New(8)
+	_, _ = rb.Write([]byte("abcdef"))
+	_, _ = rb.Read(make([]byte, 4))
+	_, _ = rb.Write([]byte("ghij"))
+	// 数据跨越缓冲区末尾
+	if rb.r != 4 || rb.w != 2 {
+		t.Fatalf("expect r=4 w=2, but got r=%d w=%d", rb.r, rb.w)
+	}
+	var out bytes.Buffer
+	if n, err := rb.WriteTo(&out); n != 6 || err != nil || out.String() != "efghij" {
+		t.Fatalf("expect efghij, but got %d %v %q", n, err, out.String())
+	}
+	if !rb.IsEmpty() {
+		t.Fatalf("expect an empty buffer, but got %d bytes", rb.Length())
+	}
+
+	// 部分写入时返回 io.ErrShortWrite，未写出的数据留在缓冲区中
+	_, _ = rb.Write([]byte("0123456789"))
+	w := &limitedWriter{n: 4}
+	if n, err := rb.WriteTo(w); n != 4 || err != io.ErrShortWrite {
+		t.Fatalf("expect a short write of 4 bytes, but got %d %v", n, err)
+	}
+	if string(rb.Bytes()) != "456789" {
+		t.Fatalf("expect 456789 to remain, but got %q", rb.Bytes())
+	}
+
+	errWrite := errors.New("write failed")
+	if n, err := rb.WriteTo(errWriter{errWrite}); n != 0 || err != errWrite {
+		t.Fatalf("expect the writer error, but got %d %v", n, err)
+	}
+}
+
+type errWriter struct {
+	err error
+}
+
+func (w errWriter) Write(p []byte) (int, error) {
+	return 0, w.err
+}
+
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+func TestRingBuffer_ReadFrom(t *testing.T) {
+	rb := New(8)
+	_, _ = rb.Write([]byte("abcdef"))
+	_, _ = rb.Read(make([]byte, 5))
+	data := strings.Repeat("0123456789", 200)
+	// 每次只读取一个字节，跨越缓冲区末尾后扩容
+	if n, err := rb.ReadFrom(iotest.OneByteReader(strings.NewReader(data))); n != int64(len(data)) || err != nil {
+		t.Fatalf("expect %d bytes, but got %d %v", len(data), n, err)
+	}
+	if string(rb.Bytes()) != "f"+data {
+		t.Fatalf("unexpected data %q", rb.Bytes())
+	}
+
+	errRead := errors.New("read failed")
+	rb = New(8)
+	n, err := rb.ReadFrom(io.MultiReader(strings.NewReader("xyz"), errReader{errRead}))
+	if n != 3 || err != errRead || string(rb.Bytes()) != "xyz" {
+		t.Fatalf("expect xyz and the reader error, but got %d %v %q", n, err, rb.Bytes())
+	}
+}
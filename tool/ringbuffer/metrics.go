@@ -0,0 +1,31 @@
+package ringbuffer
+
+import (
+	"github.com/Dongxiem/fastnet/tool/sync/atomic"
+)
+
+// 进程内所有 RingBuffer 的扩容及收缩统计
+var (
+	grows       atomic.Int64
+	growBytes   atomic.Int64
+	shrinks     atomic.Int64
+	shrinkBytes atomic.Int64
+)
+
+// Metrics：RingBuffer 扩容及收缩统计，扩容频繁说明初始容量偏小
+type Metrics struct {
+	Grows       int64 // 扩容（重新分配内存）次数
+	GrowBytes   int64 // 扩容新增的字节数
+	Shrinks     int64 // 收缩次数
+	ShrinkBytes int64 // 收缩释放的字节数
+}
+
+// ReadMetrics：返回进程内所有 RingBuffer 的扩容及收缩统计
+func ReadMetrics() Metrics {
+	return Metrics{
+		Grows:       grows.Get(),
+		GrowBytes:   growBytes.Get(),
+		Shrinks:     shrinks.Get(),
+		ShrinkBytes: shrinkBytes.Get(),
+	}
+}
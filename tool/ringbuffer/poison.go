@@ -0,0 +1,25 @@
+// +build fastnetdebug
+
+package ringbuffer
+
+// Poison：清空 RingBuffer 并将底层数组全部填充为 b，仅在 fastnetdebug 构建标签下提供，
+// 供 pool 检测归还后仍被使用的 RingBuffer
+func (r *RingBuffer) Poison(b byte) {
+	r.RetrieveAll()
+	for i := range r.buf {
+		r.buf[i] = b
+	}
+}
+
+// Poisoned：Poison 之后是否没有被读写过
+func (r *RingBuffer) Poisoned(b byte) bool {
+	if !r.isEmpty || r.r != 0 || r.w != 0 || r.vr != 0 || len(r.buf) != r.size {
+		return false
+	}
+	for _, v := range r.buf {
+		if v != b {
+			return false
+		}
+	}
+	return true
+}
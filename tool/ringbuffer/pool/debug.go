@@ -0,0 +1,34 @@
+package pool
+
+import (
+	"errors"
+	"sync"
+)
+
+// 使用 fastnetdebug 构建标签时检测到的缓冲区误用
+var (
+	ErrUseAfterPut = errors.New("ringbuffer pool: buffer modified after Put")
+	ErrDoublePut   = errors.New("ringbuffer pool: buffer Put twice")
+	ErrWrongOwner  = errors.New("ringbuffer pool: buffer used by a non-owner")
+)
+
+var (
+	corruptionMu      sync.Mutex
+	corruptionHandler = func(err error) { panic(err) }
+)
+
+// SetCorruptionHandler：设置检测到缓冲区误用时的处理函数，默认 panic。
+// 检测只在使用 fastnetdebug 构建标签（go test -tags fastnetdebug）时开启，正常构建下没有任何开销
+func SetCorruptionHandler(f func(err error)) {
+	corruptionMu.Lock()
+	corruptionHandler = f
+	corruptionMu.Unlock()
+}
+
+// reportCorruption：报告一次缓冲区误用
+func reportCorruption(err error) {
+	corruptionMu.Lock()
+	f := corruptionHandler
+	corruptionMu.Unlock()
+	f(err)
+}
@@ -0,0 +1,20 @@
+// +build !fastnetdebug
+
+package pool
+
+import "github.com/Dongxiem/fastnet/tool/ringbuffer"
+
+// Debug：是否开启了缓冲区误用检测
+const Debug = false
+
+func checkPut(r *ringbuffer.RingBuffer) bool { return true }
+
+func checkGet(r *ringbuffer.RingBuffer) {}
+
+func drop(r *ringbuffer.RingBuffer) {}
+
+// Claim：记录 r 的使用者，正常构建下为空操作
+func Claim(r *ringbuffer.RingBuffer, owner interface{}) {}
+
+// CheckOwner：检查 r 是否由 owner 使用，正常构建下为空操作
+func CheckOwner(r *ringbuffer.RingBuffer, owner interface{}) {}
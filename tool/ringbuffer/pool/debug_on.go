@@ -0,0 +1,105 @@
+// +build fastnetdebug
+
+package pool
+
+import (
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// Debug：是否开启了缓冲区误用检测
+const Debug = true
+
+// poisonByte：归还的缓冲区填充的字节
+const poisonByte = 0xDB
+
+// bufferState：缓冲区的使用状态
+type bufferState struct {
+	pooled bool        // 是否已归还到池中
+	owner  interface{} // 通过 Claim 记录的使用者
+}
+
+// states 以缓冲区的地址为键，不持有缓冲区的引用，被 sync.Pool 清理或因 MaxCapacity 丢弃的缓冲区仍可以被回收
+var (
+	statesMu sync.Mutex
+	states   = make(map[uintptr]*bufferState)
+)
+
+// state：获取 r 的状态，不存在时创建，并在 r 被回收时删除，调用方需要持有 statesMu
+func state(r *ringbuffer.RingBuffer) *bufferState {
+	key := uintptr(unsafe.Pointer(r))
+	s, ok := states[key]
+	if !ok {
+		s = &bufferState{}
+		states[key] = s
+		// finalizer 在 r 的内存被复用之前执行，地址不会被其他缓冲区误认
+		runtime.SetFinalizer(r, forget)
+	}
+	return s
+}
+
+// forget：删除 r 的状态
+func forget(r *ringbuffer.RingBuffer) {
+	statesMu.Lock()
+	delete(states, uintptr(unsafe.Pointer(r)))
+	statesMu.Unlock()
+}
+
+// drop：r 超过 MaxCapacity 被丢弃，不会再从池中取出，立即删除其状态
+func drop(r *ringbuffer.RingBuffer) {
+	forget(r)
+	runtime.SetFinalizer(r, nil)
+}
+
+// checkPut：归还前检查是否重复归还，然后填充毒化字节，返回是否可以放入池中
+func checkPut(r *ringbuffer.RingBuffer) bool {
+	statesMu.Lock()
+	s := state(r)
+	if s.pooled {
+		statesMu.Unlock()
+		reportCorruption(ErrDoublePut)
+		return false
+	}
+	s.pooled = true
+	s.owner = nil
+	statesMu.Unlock()
+	r.Poison(poisonByte)
+	return true
+}
+
+// checkGet：取出时检查缓冲区在池中是否被修改过
+func checkGet(r *ringbuffer.RingBuffer) {
+	statesMu.Lock()
+	s, ok := states[uintptr(unsafe.Pointer(r))]
+	pooled := ok && s.pooled
+	if ok {
+		s.pooled = false
+	}
+	statesMu.Unlock()
+	if pooled && !r.Poisoned(poisonByte) {
+		// 清空被写入的数据，避免影响新的使用者
+		r.RetrieveAll()
+		reportCorruption(ErrUseAfterPut)
+	}
+}
+
+// Claim：记录 r 的使用者
+func Claim(r *ringbuffer.RingBuffer, owner interface{}) {
+	statesMu.Lock()
+	state(r).owner = owner
+	statesMu.Unlock()
+}
+
+// CheckOwner：检查 r 是否由 owner 使用，r 已归还或被其他使用者 Claim 时报告 ErrWrongOwner
+func CheckOwner(r *ringbuffer.RingBuffer, owner interface{}) {
+	statesMu.Lock()
+	s, ok := states[uintptr(unsafe.Pointer(r))]
+	wrong := ok && (s.pooled || (s.owner != nil && s.owner != owner))
+	statesMu.Unlock()
+	if wrong {
+		reportCorruption(ErrWrongOwner)
+	}
+}
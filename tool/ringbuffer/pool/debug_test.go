@@ -0,0 +1,125 @@
+// +build fastnetdebug
+
+package pool
+
+import (
+	"runtime"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+)
+
+// captureCorruption：记录检测到的误用，测试结束后恢复默认的处理函数
+func captureCorruption(t *testing.T) *[]error {
+	var errs []error
+	SetCorruptionHandler(func(err error) { errs = append(errs, err) })
+	t.Cleanup(func() { SetCorruptionHandler(func(err error) { panic(err) }) })
+	return &errs
+}
+
+func TestDebug_UseAfterPut(t *testing.T) {
+	errs := captureCorruption(t)
+	p := New(16)
+	r := p.Get()
+	p.Put(r)
+	// 归还后继续写入
+	_, _ = r.Write([]byte("stale"))
+
+	// sync.Pool 不保证取回同一个缓冲区，直接检查归还的缓冲区
+	checkGet(r)
+	if len(*errs) != 1 || (*errs)[0] != ErrUseAfterPut {
+		t.Fatalf("expect ErrUseAfterPut, but got %v", *errs)
+	}
+	if r.Length() != 0 {
+		t.Fatalf("expect the corrupted buffer to be cleared, but got %d bytes", r.Length())
+	}
+}
+
+func TestDebug_UntouchedBuffer(t *testing.T) {
+	errs := captureCorruption(t)
+	p := New(16)
+	r := p.Get()
+	_, _ = r.Write([]byte("data"))
+	p.Put(r)
+	checkGet(r)
+	if len(*errs) != 0 {
+		t.Fatalf("expect no corruption, but got %v", *errs)
+	}
+}
+
+func TestDebug_DoublePut(t *testing.T) {
+	errs := captureCorruption(t)
+	p := New(16)
+	r := p.Get()
+	p.Put(r)
+	p.Put(r)
+	if len(*errs) != 1 || (*errs)[0] != ErrDoublePut {
+		t.Fatalf("expect ErrDoublePut, but got %v", *errs)
+	}
+}
+
+func TestDebug_Owner(t *testing.T) {
+	errs := captureCorruption(t)
+	p := New(16)
+	r := p.Get()
+	a, b := new(int), new(int)
+	Claim(r, a)
+	CheckOwner(r, a)
+	if len(*errs) != 0 {
+		t.Fatalf("expect no corruption, but got %v", *errs)
+	}
+	CheckOwner(r, b)
+	if len(*errs) != 1 || (*errs)[0] != ErrWrongOwner {
+		t.Fatalf("expect ErrWrongOwner, but got %v", *errs)
+	}
+
+	// 归还后原使用者继续使用
+	p.Put(r)
+	CheckOwner(r, a)
+	if len(*errs) != 2 || (*errs)[1] != ErrWrongOwner {
+		t.Fatalf("expect ErrWrongOwner after Put, but got %v", *errs)
+	}
+}
+
+// hasState：r 的状态是否仍然记录在 states 中
+func hasState(key uintptr) bool {
+	statesMu.Lock()
+	defer statesMu.Unlock()
+	_, ok := states[key]
+	return ok
+}
+
+func TestDebug_DropState(t *testing.T) {
+	captureCorruption(t)
+	p := New(16)
+	p.SetMaxCapacity(16)
+	r := p.Get()
+	_, _ = r.Write(make([]byte, 64))
+	// 扩容超过 MaxCapacity 的缓冲区被丢弃，不再记录其状态
+	p.Put(r)
+	if hasState(uintptr(unsafe.Pointer(r))) {
+		t.Fatal("expect the state of the discarded buffer to be removed")
+	}
+}
+
+func TestDebug_CollectedState(t *testing.T) {
+	captureCorruption(t)
+	r := ringbuffer.New(16)
+	Claim(r, new(int))
+	key := uintptr(unsafe.Pointer(r))
+	if !hasState(key) {
+		t.Fatal("expect the state of the claimed buffer to be recorded")
+	}
+	// states 不持有缓冲区的引用，缓冲区被回收后其状态随之删除
+	r = nil
+	deadline := time.Now().Add(time.Second * 3)
+	for hasState(key) {
+		if time.Now().After(deadline) {
+			t.Fatal("expect the state of the collected buffer to be removed")
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"github.com/Dongxiem/fastnet/tool/ringbuffer"
+	"github.com/Dongxiem/fastnet/tool/sync/atomic"
 )
 
 var DefaultPool = New(1024)
@@ -21,25 +22,75 @@ func Put(r *ringbuffer.RingBuffer) {
 // RingBufferPool：定义 RingBufferPool 结构体
 type RingBufferPool struct {
 	pool *sync.Pool
+
+	maxCapacity atomic.Int64 // 放回池中的 RingBuffer 的最大容量，0 表示不限制
+
+	gets     atomic.Int64
+	puts     atomic.Int64
+	allocs   atomic.Int64
+	discards atomic.Int64
+}
+
+// Stats：RingBufferPool 的使用统计
+type Stats struct {
+	Gets     int64 // Get 次数
+	Puts     int64 // Put 次数
+	Allocs   int64 // 池中没有可用的 RingBuffer 而新分配的次数，接近 Gets 说明复用率低
+	Discards int64 // 容量超过 SetMaxCapacity 设置的上限而没有放回池中的次数
+
+	ringbuffer.Metrics // 进程内所有 RingBuffer 的扩容统计
 }
 
 // New：根据初始化大小 initSize，初始化一个 RingBufferPool
 func New(initSize int) *RingBufferPool {
-	return &RingBufferPool{
-		pool: &sync.Pool{
-			New: func() interface{} {
-				return ringbuffer.New(initSize)
-			},
+	p := &RingBufferPool{}
+	p.pool = &sync.Pool{
+		New: func() interface{} {
+			p.allocs.Add(1)
+			return ringbuffer.New(initSize)
 		},
 	}
+	return p
 }
 
+// Get：获取元素
 func (p *RingBufferPool) Get() *ringbuffer.RingBuffer {
+	p.gets.Add(1)
 	r, _ := p.pool.Get().(*ringbuffer.RingBuffer)
+	checkGet(r)
 	return r
 }
 
-// Put：存放元素
+// SetMaxCapacity：设置放回池中的 RingBuffer 的最大容量，突发流量中扩容超过 n 的 RingBuffer 在 Put 时直接丢弃，
+// 避免池中长期持有大块内存，n <= 0 表示不限制（默认）
+func (p *RingBufferPool) SetMaxCapacity(n int) {
+	if n < 0 {
+		n = 0
+	}
+	_ = p.maxCapacity.Swap(int64(n))
+}
+
+// Put：存放元素，容量超过 SetMaxCapacity 设置的上限时丢弃
 func (p *RingBufferPool) Put(r *ringbuffer.RingBuffer) {
+	p.puts.Add(1)
+	if !checkPut(r) {
+		return
+	}
+	if max := p.maxCapacity.Get(); max > 0 && int64(r.Capacity()) > max {
+		p.discards.Add(1)
+		drop(r)
+		return
+	}
 	p.pool.Put(r)
 }
+
+// Stats：返回该 RingBufferPool 的使用统计，用于调整初始容量
+func (p *RingBufferPool) Stats() Stats {
+	return Stats{
+		Gets:     p.gets.Get(),
+		Puts:     p.puts.Get(),
+		Allocs:   p.allocs.Get(),
+		Discards: p.discards.Get(),
+		Metrics:  ringbuffer.ReadMetrics(),
+	}
+}
@@ -22,7 +22,8 @@ func TestRingBufferPool(t *testing.T) {
 	if rr.Capacity() != 1024 {
 		t.Fatal()
 	}
-	if rr.Length() != 4 {
+	// fastnetdebug 构建标签下归还的缓冲区会被清空
+	if !Debug && rr.Length() != 4 {
 		t.Fatal()
 	}
 
@@ -51,7 +52,8 @@ func TestDefaultPool(t *testing.T) {
 	if rr.Capacity() != 1024 {
 		t.Fatal()
 	}
-	if rr.Length() != 4 {
+	// fastnetdebug 构建标签下归还的缓冲区会被清空
+	if !Debug && rr.Length() != 4 {
 		t.Fatal()
 	}
 
@@ -64,3 +66,46 @@ func TestDefaultPool(t *testing.T) {
 		t.Fatal()
 	}
 }
+
+func TestRingBufferPool_Stats(t *testing.T) {
+	pool := New(16)
+
+	r := pool.Get()
+	_, _ = r.Write(make([]byte, 40))
+	pool.Put(r)
+	s := pool.Stats()
+	if s.Gets != 1 || s.Puts != 1 || s.Allocs != 1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+	if s.Grows < 1 || s.GrowBytes < 24 {
+		t.Fatalf("growth should be counted, but got %+v", s)
+	}
+
+	before := s.Allocs
+	pool.Put(pool.Get())
+	if s = pool.Stats(); s.Gets != 2 || s.Puts != 2 || s.Allocs > before+1 {
+		t.Fatalf("unexpected stats %+v", s)
+	}
+}
+
+func TestRingBufferPool_MaxCapacity(t *testing.T) {
+	pool := New(16)
+	pool.SetMaxCapacity(64)
+
+	small := pool.Get()
+	_, _ = small.Write(make([]byte, 48))
+	pool.Put(small)
+	large := ringbuffer.New(16)
+	_, _ = large.Write(make([]byte, 128))
+	pool.Put(large)
+
+	if s := pool.Stats(); s.Puts != 2 || s.Discards != 1 {
+		t.Fatalf("expect the grown buffer to be discarded, but got %+v", s)
+	}
+	// 池中只有未超过上限的缓冲区
+	for i := 0; i < 2; i++ {
+		if r := pool.Get(); r == large {
+			t.Fatal("expect a buffer beyond max capacity not to be reused")
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package ringbuffer
+
+import (
+	"bytes"
+	"errors"
+)
+
+// ErrNotEnough：缓冲区中的数据不足以完成本次读取，读位置保持不变
+var ErrNotEnough = errors.New("ring buffer: not enough data")
+
+// Reader：在 RingBuffer 之上提供类似 bufio.Reader 的读取方法，供协议拆包使用。
+// Reader 只移动自己的读位置，不会修改 RingBuffer，调用 Commit 后才真正从 RingBuffer 中移除已读的数据，
+// 调用 Rollback 可以回到上次 Commit 的位置，因此消息不完整时直接 Rollback 即可，不会消费任何数据。
+// 读取方法返回的 []byte 均为拷贝，在 Commit 之后仍然有效
+type Reader struct {
+	rb  *RingBuffer
+	pos int // 相对 RingBuffer 读指针的已读长度
+}
+
+// NewReader：返回读取 rb 的 Reader
+func NewReader(rb *RingBuffer) *Reader {
+	return &Reader{rb: rb}
+}
+
+// Reset：丢弃未 Commit 的读位置，改为读取 rb
+func (r *Reader) Reset(rb *RingBuffer) {
+	r.rb = rb
+	r.pos = 0
+}
+
+// Buffered：返回从当前读位置开始尚未读取的字节数
+func (r *Reader) Buffered() int {
+	return r.rb.Length() - r.pos
+}
+
+// Commit：从 RingBuffer 中移除已读的数据
+func (r *Reader) Commit() {
+	r.rb.Retrieve(r.pos)
+	r.pos = 0
+}
+
+// Rollback：回到上次 Commit 的位置
+func (r *Reader) Rollback() {
+	r.pos = 0
+}
+
+// ReadByte：读取一个字节
+func (r *Reader) ReadByte() (byte, error) {
+	first, end := r.unread()
+	if len(first) > 0 {
+		r.pos++
+		return first[0], nil
+	}
+	if len(end) > 0 {
+		r.pos++
+		return end[0], nil
+	}
+	return 0, ErrNotEnough
+}
+
+// ReadBytes：读取到 delim 为止的数据（包含 delim），找不到 delim 时返回 ErrNotEnough
+func (r *Reader) ReadBytes(delim byte) ([]byte, error) {
+	first, end := r.unread()
+	n := bytes.IndexByte(first, delim)
+	if n == -1 {
+		if n = bytes.IndexByte(end, delim); n == -1 {
+			return nil, ErrNotEnough
+		}
+		n += len(first)
+	}
+	return r.ReadFull(n + 1)
+}
+
+// ReadFull：读取 n 个字节，数据不足 n 个字节时返回 ErrNotEnough
+func (r *Reader) ReadFull(n int) ([]byte, error) {
+	buf, err := r.Peek(n)
+	if err != nil {
+		return nil, err
+	}
+	r.pos += n
+	return buf, nil
+}
+
+// Peek：返回接下来的 n 个字节但不移动读位置，数据不足 n 个字节时返回 ErrNotEnough
+func (r *Reader) Peek(n int) ([]byte, error) {
+	if n < 0 || n > r.Buffered() {
+		return nil, ErrNotEnough
+	}
+	first, end := r.unread()
+	buf := make([]byte, n)
+	copied := copy(buf, first)
+	copy(buf[copied:], end)
+	return buf, nil
+}
+
+// Discard：跳过 n 个字节，数据不足 n 个字节时返回 ErrNotEnough
+func (r *Reader) Discard(n int) error {
+	if n < 0 || n > r.Buffered() {
+		return ErrNotEnough
+	}
+	r.pos += n
+	return nil
+}
+
+// unread：返回从当前读位置开始尚未读取的两段数据
+func (r *Reader) unread() (first []byte, end []byte) {
+	first, end = r.rb.PeekAll()
+	if r.pos < len(first) {
+		return first[r.pos:], end
+	}
+	return end[r.pos-len(first):], nil
+}
@@ -0,0 +1,115 @@
+package ringbuffer
+
+import (
+	"io"
+	"testing"
+)
+
+func TestReader_interface(t *testing.T) {
+	var _ io.ByteReader = NewReader(New(1))
+}
+
+func TestReader_Rollback(t *testing.T) {
+	rb := New(64)
+	_, _ = rb.WriteString("*2\r\n$3\r\nGET\r\n$3\r\nke")
+	r := NewReader(rb)
+
+	// 读取一个不完整的 RESP 数组
+	line, err := r.ReadBytes('\n')
+	if err != nil || string(line) != "*2\r\n" {
+		t.Fatalf("unexpected line %q, %v", line, err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err = r.ReadBytes('\n'); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = r.ReadFull(5); err != nil {
+			break
+		}
+	}
+	if err != ErrNotEnough {
+		t.Fatalf("expect ErrNotEnough, but got %v", err)
+	}
+	r.Rollback()
+	if rb.Length() != 19 || r.Buffered() != 19 {
+		t.Fatalf("incomplete read should not consume data, length %d, buffered %d", rb.Length(), r.Buffered())
+	}
+
+	// 数据补齐后可以完整读取
+	_, _ = rb.WriteString("y\r\n")
+	if err = r.Discard(8); err != nil {
+		t.Fatal(err)
+	}
+	cmd, _ := r.ReadFull(5)
+	if _, err = r.ReadBytes('\n'); err != nil {
+		t.Fatal(err)
+	}
+	key, _ := r.ReadFull(5)
+	if string(cmd) != "GET\r\n" || string(key) != "key\r\n" {
+		t.Fatalf("unexpected command %q %q", cmd, key)
+	}
+	r.Commit()
+	if !rb.IsEmpty() || r.Buffered() != 0 {
+		t.Fatalf("buffer should be empty after commit, length %d", rb.Length())
+	}
+	if _, err = r.ReadByte(); err != ErrNotEnough {
+		t.Fatalf("expect ErrNotEnough, but got %v", err)
+	}
+}
+
+func TestReader_ReadBytesWrap(t *testing.T) {
+	rb := New(8)
+	_, _ = rb.WriteString("xxxxxx")
+	rb.Retrieve(5)
+	// 写入后数据跨越缓冲区末尾
+	_, _ = rb.WriteString("ab\ncde\n")
+	if _, end := rb.Peek(rb.Length()); len(end) == 0 {
+		t.Fatal("data should wrap around")
+	}
+
+	r := NewReader(rb)
+	b, err := r.ReadByte()
+	if err != nil || b != 'x' {
+		t.Fatalf("unexpected byte %q, %v", b, err)
+	}
+	r.Commit()
+
+	// "ab" 位于缓冲区末尾，分隔符位于缓冲区开头
+	line, err := r.ReadBytes('\n')
+	if err != nil || string(line) != "ab\n" {
+		t.Fatalf("unexpected line %q, %v", line, err)
+	}
+	line, err = r.ReadBytes('\n')
+	if err != nil || string(line) != "cde\n" {
+		t.Fatalf("unexpected line %q, %v", line, err)
+	}
+	if _, err = r.ReadBytes('\n'); err != ErrNotEnough {
+		t.Fatalf("expect ErrNotEnough, but got %v", err)
+	}
+	r.Commit()
+	if !rb.IsEmpty() {
+		t.Fatalf("buffer should be empty after commit, length %d", rb.Length())
+	}
+}
+
+func TestReader_Peek(t *testing.T) {
+	rb := New(8)
+	_, _ = rb.WriteString("abcdef")
+	rb.Retrieve(4)
+	_, _ = rb.WriteString("ghij")
+	r := NewReader(rb)
+
+	if _, err := r.Peek(7); err != ErrNotEnough {
+		t.Fatalf("expect ErrNotEnough, but got %v", err)
+	}
+	if err := r.Discard(1); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := r.Peek(5)
+	if err != nil || string(buf) != "fghij" {
+		t.Fatalf("unexpected peek %q, %v", buf, err)
+	}
+	if r.Buffered() != 5 {
+		t.Fatalf("peek should not move position, buffered %d", r.Buffered())
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"unsafe"
 )
 
 // ErrIsEmpty：缓冲区为空错误
@@ -18,6 +17,10 @@ type RingBuffer struct {
 	r       int // next position to read
 	w       int // next position to write
 	isEmpty bool
+	vEmpty  bool // isEmpty 是由虚读读完全部数据设置的，VirtualRevert 时需要还原
+
+	minSize int // 收缩的下限，即创建时的大小，0 表示不收缩（持有外部数据）
+	lowUses int // 连续多少次读取后数据量低于容量的 1/shrinkRatio
 }
 
 // New：返回一个初始大小为 size 的 RingBuffer
@@ -26,6 +29,7 @@ func New(size int) *RingBuffer {
 		buf:     make([]byte, size),
 		size:    size,
 		isEmpty: true,
+		minSize: size,
 	}
 }
 
@@ -37,19 +41,39 @@ func NewWithData(data []byte) *RingBuffer {
 	}
 }
 
+// ResetWithData：与 NewWithData 相同，让已有的 RingBuffer 改为持有 data，用于复用同一个 RingBuffer 避免每次创建，
+// data 为空时不再持有任何内存
+func (r *RingBuffer) ResetWithData(data []byte) {
+	r.buf = data
+	r.size = len(data)
+	r.r = 0
+	r.w = 0
+	r.vr = 0
+	r.isEmpty = len(data) == 0
+	r.vEmpty = false
+	r.minSize = 0
+	r.lowUses = 0
+}
+
 // VirtualFlush：刷新虚读指针
 // VirtualXXX 系列配合使用
 func (r *RingBuffer) VirtualFlush() {
 	r.r = r.vr
+	r.vEmpty = false
 	if r.r == r.w {
 		r.isEmpty = true
 	}
+	r.maybeShrink()
 }
 
 // VirtualRevert：还原虚读指针
 // VirtualXXX 系列配合使用
 func (r *RingBuffer) VirtualRevert() {
 	r.vr = r.r
+	if r.vEmpty {
+		r.isEmpty = false
+		r.vEmpty = false
+	}
 }
 
 // VirtualRead：虚读，不移动 read 指针，需要配合 VirtualFlush 和 VirtualRevert 使用
@@ -71,6 +95,7 @@ func (r *RingBuffer) VirtualRead(p []byte) (n int, err error) {
 		r.vr = (r.vr + n) % r.size
 		if r.vr == r.w {
 			r.isEmpty = true
+			r.vEmpty = true
 		}
 		return
 	}
@@ -88,6 +113,10 @@ func (r *RingBuffer) VirtualRead(p []byte) (n int, err error) {
 
 	// move vr
 	r.vr = (r.vr + n) % r.size
+	if r.vr == r.w {
+		r.isEmpty = true
+		r.vEmpty = true
+	}
 	return
 }
 
@@ -114,6 +143,8 @@ func (r *RingBuffer) RetrieveAll() {
 	r.w = 0
 	r.vr = 0
 	r.isEmpty = true
+	r.vEmpty = false
+	r.maybeShrink()
 }
 
 // Retrieve：根据 len，进行环形长度缩短
@@ -125,15 +156,19 @@ func (r *RingBuffer) Retrieve(len int) {
 	if len < r.Length() {
 		r.r = (r.r + len) % r.size
 		r.vr = r.r
+		r.vEmpty = false
 
 		if r.w == r.r {
 			r.isEmpty = true
 		}
+		r.maybeShrink()
 	} else {
 		r.RetrieveAll()
 	}
 }
 
+// Peek：得到最多 len 字节的数据，不移动读指针，数据跨越缓冲区末尾时分为 first 与 end 两段。
+// 可读数据不足 len 字节时返回全部数据，适合在拆包前检查固定长度的帧头
 func (r *RingBuffer) Peek(len int) (first []byte, end []byte) {
 	if r.isEmpty || len <= 0 {
 		return
@@ -251,6 +286,8 @@ func (r *RingBuffer) Read(p []byte) (n int, err error) {
 			r.isEmpty = true
 		}
 		r.vr = r.r
+		r.vEmpty = false
+		r.maybeShrink()
 		return
 	}
 	if n > r.size-r.r+r.w {
@@ -271,6 +308,8 @@ func (r *RingBuffer) Read(p []byte) (n int, err error) {
 		r.isEmpty = true
 	}
 	r.vr = r.r
+	r.vEmpty = false
+	r.maybeShrink()
 	return
 }
 
@@ -289,6 +328,7 @@ func (r *RingBuffer) ReadByte() (b byte, err error) {
 		r.isEmpty = true
 	}
 	r.vr = r.r
+	r.vEmpty = false
 	return
 }
 
@@ -321,6 +361,7 @@ func (r *RingBuffer) Write(p []byte) (n int, err error) {
 	}
 
 	r.isEmpty = false
+	r.vEmpty = false
 
 	return
 }
@@ -339,14 +380,15 @@ func (r *RingBuffer) WriteByte(c byte) error {
 	}
 
 	r.isEmpty = false
+	r.vEmpty = false
 
 	return nil
 }
 
-// Length：返回长度
+// Length：返回长度，不受虚读影响
 func (r *RingBuffer) Length() int {
 	if r.w == r.r {
-		if r.isEmpty {
+		if r.empty() {
 			return 0
 		}
 		return r.size
@@ -364,11 +406,33 @@ func (r *RingBuffer) Capacity() int {
 	return r.size
 }
 
-// WriteString：写入字符串
+// WriteString：写入字符串，直接从 s 拷贝，不需要先转换为 []byte
 func (r *RingBuffer) WriteString(s string) (n int, err error) {
-	x := (*[2]uintptr)(unsafe.Pointer(&s))
-	h := [3]uintptr{x[0], x[1], x[1]}
-	return r.Write(*(*[]byte)(unsafe.Pointer(&h)))
+	if len(s) == 0 {
+		return 0, nil
+	}
+	n = len(s)
+	free := r.free()
+	if free < n {
+		r.makeSpace(n - free)
+	}
+	if r.w >= r.r && r.size-r.w < n {
+		k := copy(r.buf[r.w:], s)
+		copy(r.buf, s[k:])
+		r.w = n - k
+	} else {
+		copy(r.buf[r.w:], s)
+		r.w += n
+	}
+
+	if r.w == r.size {
+		r.w = 0
+	}
+
+	r.isEmpty = false
+	r.vEmpty = false
+
+	return
 }
 
 // Bytes：返回所有可读数据，此操作不会移动读指针，仅仅是拷贝全部数据
@@ -409,6 +473,7 @@ func (r *RingBuffer) Reset() {
 	r.r = 0
 	r.w = 0
 	r.isEmpty = true
+	r.vEmpty = false
 }
 
 // String：转换为 string
@@ -418,21 +483,46 @@ func (r *RingBuffer) String() string {
 
 // makeSpace：扩容
 func (r *RingBuffer) makeSpace(len int) {
-	newSize := r.size + len
+	r.resize(r.size + len)
+
+	grows.Add(1)
+	growBytes.Add(int64(len))
+}
+
+// resize：将数据拷贝到大小为 newSize 的新数组，newSize 不能小于数据长度。
+// 保留虚读的位置，虚读过程中写入数据不会丢失虚读的进度
+func (r *RingBuffer) resize(newSize int) {
 	newBuf := make([]byte, newSize)
 	oldLen := r.Length()
-	_, _ = r.Read(newBuf)
+	virtualRead := oldLen - r.VirtualLength()
+	if oldLen > 0 {
+		if r.w > r.r {
+			copy(newBuf, r.buf[r.r:r.w])
+		} else {
+			n := copy(newBuf, r.buf[r.r:r.size])
+			copy(newBuf[n:], r.buf[0:r.w])
+		}
+	}
 
 	r.w = oldLen
 	r.r = 0
+	r.vr = virtualRead
 	r.size = newSize
 	r.buf = newBuf
+	if r.w == r.size {
+		r.w = 0
+	}
+}
+
+// empty：缓冲区中是否确实没有数据，虚读读完全部数据时 isEmpty 为 true，但数据仍然在缓冲区中
+func (r *RingBuffer) empty() bool {
+	return r.isEmpty && !r.vEmpty
 }
 
 // free：取得空闲空间
 func (r *RingBuffer) free() int {
 	if r.w == r.r {
-		if r.isEmpty {
+		if r.empty() {
 			return r.size
 		}
 		return 0
@@ -326,6 +326,38 @@ func TestRingBuffer_Peek(t *testing.T) {
 	}
 }
 
+// TestRingBuffer_PeekBeyondLength：len 超过可读数据时返回全部数据，包括跨越缓冲区末尾的情况，且不消耗数据
+func TestRingBuffer_PeekBeyondLength(t *testing.T) {
+	rb := New(8)
+	_, _ = rb.Write([]byte("abc"))
+	first, end := rb.Peek(100)
+	if string(first) != "abc" || len(end) != 0 {
+		t.Fatalf("expect abc, but got %q %q", first, end)
+	}
+
+	// 读指针移到 6，写入的数据跨越缓冲区末尾
+	_, _ = rb.Write([]byte("def"))
+	_, _ = rb.Read(make([]byte, 6))
+	_, _ = rb.Write([]byte("wxyz"))
+	if rb.r != 6 || rb.w != 2 {
+		t.Fatalf("expect r=6 w=2, but got r=%d w=%d", rb.r, rb.w)
+	}
+	first, end = rb.Peek(3)
+	if string(first) != "wx" || string(end) != "y" {
+		t.Fatalf("expect wx y, but got %q %q", first, end)
+	}
+	first, end = rb.Peek(100)
+	if string(first) != "wx" || string(end) != "yz" {
+		t.Fatalf("expect wx yz, but got %q %q", first, end)
+	}
+	if rb.Length() != 4 || string(rb.Bytes()) != "wxyz" {
+		t.Fatalf("expect peek not to consume data, but got %d %q", rb.Length(), rb.Bytes())
+	}
+	if first, end = rb.Peek(0); first != nil || end != nil {
+		t.Fatalf("expect nothing for len 0, but got %q %q", first, end)
+	}
+}
+
 func TestRingBuffer_ByteInterface(t *testing.T) {
 	rb := New(2)
 
@@ -523,6 +555,31 @@ func TestNewWithData(t *testing.T) {
 	}
 }
 
+func TestRingBuffer_ResetWithData(t *testing.T) {
+	rBuf := New(4)
+	_, _ = rBuf.Write([]byte("ab"))
+	_, _ = rBuf.VirtualRead(make([]byte, 1))
+
+	buf := []byte("test")
+	rBuf.ResetWithData(buf)
+	if !rBuf.IsFull() || rBuf.Length() != len(buf) || rBuf.VirtualLength() != len(buf) {
+		t.Fatal()
+	}
+	first, _ := rBuf.PeekAll()
+	if !bytes.Equal(first, buf) || &first[0] != &buf[0] {
+		t.Fatal("expect RingBuffer to hold data without copying")
+	}
+	rBuf.Retrieve(2)
+	if rBuf.Length() != 2 {
+		t.Fatal()
+	}
+
+	rBuf.ResetWithData(nil)
+	if !rBuf.IsEmpty() || rBuf.Length() != 0 {
+		t.Fatal()
+	}
+}
+
 func TestRingBuffer_VirtualXXX(t *testing.T) {
 	rb := New(10)
 
@@ -584,6 +641,97 @@ func TestRingBuffer_VirtualXXX(t *testing.T) {
 
 }
 
+// TestRingBuffer_VirtualRevertFull：缓冲区写满时虚读全部数据后还原，数据不能丢失
+func TestRingBuffer_VirtualRevertFull(t *testing.T) {
+	rb := New(8)
+	_, _ = rb.Write([]byte("ab"))
+	_, _ = rb.Read(make([]byte, 2))
+	// 写满并绕回
+	_, _ = rb.Write([]byte("01234567"))
+	if rb.Length() != 8 || !rb.IsFull() {
+		t.Fatalf("expect a full buffer, but got %d", rb.Length())
+	}
+
+	buf := make([]byte, 8)
+	if n, _ := rb.VirtualRead(buf); n != 8 || string(buf) != "01234567" {
+		t.Fatalf("expect 01234567, but got %q", buf[:n])
+	}
+	if rb.VirtualLength() != 0 {
+		t.Fatalf("expect virtual length 0, but got %d", rb.VirtualLength())
+	}
+	rb.VirtualRevert()
+	if rb.Length() != 8 || rb.VirtualLength() != 8 {
+		t.Fatalf("expect 8 bytes after revert, but got %d, %d", rb.Length(), rb.VirtualLength())
+	}
+
+	_, _ = rb.VirtualRead(buf)
+	rb.VirtualFlush()
+	if !rb.IsEmpty() || rb.Length() != 0 {
+		t.Fatalf("expect an empty buffer after flush, but got %d", rb.Length())
+	}
+}
+
+// TestRingBuffer_VirtualReadAfterGrow：扩容后虚读指针从新的读位置开始
+func TestRingBuffer_VirtualReadAfterGrow(t *testing.T) {
+	rb := New(4)
+	_, _ = rb.Write([]byte("abc"))
+	_, _ = rb.Write([]byte("defg"))
+	buf := make([]byte, 7)
+	if n, _ := rb.VirtualRead(buf); string(buf[:n]) != "abcdefg" {
+		t.Fatalf("expect abcdefg, but got %q", buf[:n])
+	}
+}
+
+// TestRingBuffer_WriteDuringVirtualRead：虚读过程中写入数据（包括扩容），已有的数据及虚读的进度都不能丢失
+func TestRingBuffer_WriteDuringVirtualRead(t *testing.T) {
+	rb := New(4)
+	_, _ = rb.Write([]byte("abcd"))
+	buf := make([]byte, 4)
+	_, _ = rb.VirtualRead(buf)
+	// 虚读完全部数据后缓冲区看起来为空，但仍然是满的
+	if rb.Length() != 4 || rb.free() != 0 {
+		t.Fatalf("expect a full buffer, but got length %d free %d", rb.Length(), rb.free())
+	}
+	_ = rb.WriteByte('e')
+	_, _ = rb.WriteString("fg")
+	if rb.VirtualLength() != 3 {
+		t.Fatalf("expect 3 bytes after the virtual read position, but got %d", rb.VirtualLength())
+	}
+	if n, _ := rb.VirtualRead(buf); string(buf[:n]) != "efg" {
+		t.Fatalf("expect efg, but got %q", buf[:n])
+	}
+	rb.VirtualRevert()
+	if string(rb.Bytes()) != "abcdefg" {
+		t.Fatalf("expect abcdefg, but got %q", rb.Bytes())
+	}
+}
+
+func TestRingBuffer_WriteString(t *testing.T) {
+	rb := New(8)
+	_, _ = rb.WriteString("abcdef")
+	_, _ = rb.Read(make([]byte, 4))
+	// 跨越缓冲区末尾写入
+	if n, err := rb.WriteString("ghijk"); n != 5 || err != nil {
+		t.Fatalf("expect 5 bytes written, but got %d %v", n, err)
+	}
+	if rb.r != 4 || rb.w != 3 || string(rb.Bytes()) != "efghijk" {
+		t.Fatalf("expect efghijk with r=4 w=3, but got %q r=%d w=%d", rb.Bytes(), rb.r, rb.w)
+	}
+	// 超过空闲空间时扩容
+	_, _ = rb.WriteString("lmn")
+	if rb.Capacity() != 10 || string(rb.Bytes()) != "efghijklmn" {
+		t.Fatalf("expect efghijklmn in 10 bytes, but got %q in %d", rb.Bytes(), rb.Capacity())
+	}
+	for _, expect := range []byte("efghijklmn") {
+		if b, err := rb.ReadByte(); b != expect || err != nil {
+			t.Fatalf("expect %c, but got %c %v", expect, b, err)
+		}
+	}
+	if _, err := rb.ReadByte(); err != ErrIsEmpty {
+		t.Fatalf("expect ErrIsEmpty, but got %v", err)
+	}
+}
+
 func TestRingBuffer_PeekUintXX(t *testing.T) {
 	rb := New(1024)
 	_ = rb.WriteByte(0x01)
@@ -638,3 +786,17 @@ func TestCopyBytes(t *testing.T) {
 		t.Fatal(string(out))
 	}
 }
+
+func TestReadMetrics(t *testing.T) {
+	before := ReadMetrics()
+	rb := New(4)
+	_, _ = rb.Write([]byte("12345678"))
+	_, _ = rb.Write([]byte("1"))
+	after := ReadMetrics()
+	if after.Grows-before.Grows != 2 {
+		t.Fatalf("expect 2 grows, but got %d", after.Grows-before.Grows)
+	}
+	if after.GrowBytes-before.GrowBytes < 5 {
+		t.Fatalf("expect at least 5 grown bytes, but got %d", after.GrowBytes-before.GrowBytes)
+	}
+}
@@ -0,0 +1,43 @@
+package ringbuffer
+
+// 自动收缩的条件：连续 shrinkAfter 次读取后数据量都低于容量的 1/shrinkRatio
+const (
+	shrinkRatio = 4
+	shrinkAfter = 64
+)
+
+// Shrink：将扩容后的底层数组缩小为创建时的大小与数据长度的两倍中较大的一个，容量已经不超过该值时不做任何处理。
+// 读取时会在数据量持续较低后自动收缩，也可以在突发流量结束后手动调用。
+// 持有外部数据（NewWithData、ResetWithData）的 RingBuffer 不会收缩。
+// 之前通过 Peek 等得到的切片仍然引用原来的数组，收缩后不会被覆盖
+func (r *RingBuffer) Shrink() {
+	r.lowUses = 0
+	if r.minSize <= 0 {
+		return
+	}
+	newSize := 2 * r.Length()
+	if newSize < r.minSize {
+		newSize = r.minSize
+	}
+	if newSize >= r.size {
+		return
+	}
+	shrinkBytes.Add(int64(r.size - newSize))
+	r.resize(newSize)
+	shrinks.Add(1)
+}
+
+// maybeShrink：读取数据后调用，统计数据量持续较低的次数，达到 shrinkAfter 次后收缩
+func (r *RingBuffer) maybeShrink() {
+	if r.minSize <= 0 || r.size <= r.minSize {
+		return
+	}
+	if r.Length() >= r.size/shrinkRatio {
+		r.lowUses = 0
+		return
+	}
+	r.lowUses++
+	if r.lowUses >= shrinkAfter {
+		r.Shrink()
+	}
+}
@@ -0,0 +1,70 @@
+package ringbuffer
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRingBuffer_Shrink(t *testing.T) {
+	rb := New(8)
+	_, _ = rb.Write(make([]byte, 64))
+	if rb.Capacity() != 64 {
+		t.Fatalf("expect capacity 64, but got %d", rb.Capacity())
+	}
+	// 读指针不在起点，剩余数据跨越缓冲区末尾
+	_, _ = rb.Read(make([]byte, 60))
+	_, _ = rb.Write([]byte("abcdef"))
+	before := ReadMetrics()
+	rb.Shrink()
+	if rb.Capacity() != 20 || !bytes.Equal(rb.Bytes(), []byte("\x00\x00\x00\x00abcdef")) {
+		t.Fatalf("expect 10 bytes kept in capacity 20, but got %q in %d", rb.Bytes(), rb.Capacity())
+	}
+	if m := ReadMetrics(); m.Shrinks-before.Shrinks != 1 || m.ShrinkBytes-before.ShrinkBytes != 44 {
+		t.Fatalf("expect 1 shrink of 44 bytes, but got %+v", m)
+	}
+
+	// 不会小于创建时的大小
+	rb.RetrieveAll()
+	rb.Shrink()
+	if rb.Capacity() != 8 {
+		t.Fatalf("expect capacity 8, but got %d", rb.Capacity())
+	}
+
+	// 持有外部数据时不收缩
+	data := NewWithData(make([]byte, 64))
+	data.Retrieve(60)
+	data.Shrink()
+	if data.Capacity() != 64 {
+		t.Fatalf("expect a buffer with external data not to shrink, but got %d", data.Capacity())
+	}
+}
+
+func TestRingBuffer_AutoShrink(t *testing.T) {
+	rb := New(8)
+	_, _ = rb.Write(make([]byte, 1024))
+	rb.RetrieveAll()
+	// 数据量持续低于容量的 1/shrinkRatio 时，第 shrinkAfter 次读取后自动收缩
+	for i := 0; i < shrinkAfter-2; i++ {
+		_, _ = rb.Write([]byte("ab"))
+		rb.Retrieve(2)
+	}
+	if rb.Capacity() != 1024 {
+		t.Fatalf("expect no shrink before the threshold, but got %d", rb.Capacity())
+	}
+	_, _ = rb.WriteString("xyz")
+	rb.Retrieve(2)
+	if rb.Capacity() != 8 || string(rb.Bytes()) != "z" {
+		t.Fatalf("expect z in capacity 8, but got %q in %d", rb.Bytes(), rb.Capacity())
+	}
+
+	// 数据量较高时重新计数
+	rb = New(8)
+	_, _ = rb.Write(make([]byte, 64))
+	for i := 0; i < shrinkAfter*2; i++ {
+		rb.Retrieve(20)
+		_, _ = rb.Write(make([]byte, 20))
+	}
+	if rb.Capacity() != 64 {
+		t.Fatalf("expect no shrink while the buffer is busy, but got %d", rb.Capacity())
+	}
+}
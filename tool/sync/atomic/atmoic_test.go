@@ -24,6 +24,31 @@ func TestBool(t *testing.T) {
 	}
 }
 
+// TestBool_CompareAndSwap：并发 CAS 只有一个成功
+func TestBool_CompareAndSwap(t *testing.T) {
+	var b Bool
+	if b.CompareAndSwap(true, false) {
+		t.Fatal("expect CAS on false to fail")
+	}
+	b.Set(true)
+
+	var wins Int32
+	sw := sync.WaitGroup{}
+	for i := 0; i < 100; i++ {
+		sw.Add(1)
+		go func() {
+			if b.CompareAndSwap(true, false) {
+				wins.Add(1)
+			}
+			sw.Done()
+		}()
+	}
+	sw.Wait()
+	if wins.Get() != 1 || b.Get() {
+		t.Fatal("expect exactly one winner, but got ", wins.Get())
+	}
+}
+
 // TestInt32：测试 Int32
 func TestInt32(t *testing.T) {
 	var count Int32
@@ -83,3 +108,50 @@ func TestInt64(t *testing.T) {
 		t.Fatal("expect 0 but get ", count.Get())
 	}
 }
+
+// TestUint64：测试 Uint64
+func TestUint64(t *testing.T) {
+	var count Uint64
+	sw := sync.WaitGroup{}
+	for i := 0; i < 1000; i++ {
+		sw.Add(1)
+		go func() {
+			count.Add(3)
+			sw.Done()
+		}()
+	}
+	sw.Wait()
+	if count.Get() != 3000 {
+		t.Fatal("expect 3000 but get ", count.Get())
+	}
+	if !count.CompareAndSwap(3000, 1) || count.CompareAndSwap(3000, 2) {
+		t.Fatal("unexpected CompareAndSwap result")
+	}
+	if count.Swap(7) != 1 || count.Add(^uint64(0)) != 6 {
+		t.Fatal("expect 6 but get ", count.Get())
+	}
+}
+
+// TestFloat64：并发 Add 不丢失更新
+func TestFloat64(t *testing.T) {
+	var sum Float64
+	sw := sync.WaitGroup{}
+	for i := 0; i < 1000; i++ {
+		sw.Add(1)
+		go func() {
+			sum.Add(0.5)
+			sw.Done()
+		}()
+	}
+	sw.Wait()
+	if sum.Load() != 500 {
+		t.Fatal("expect 500 but get ", sum.Load())
+	}
+	if !sum.CompareAndSwap(500, 1.25) || sum.CompareAndSwap(500, 2) {
+		t.Fatal("unexpected CompareAndSwap result")
+	}
+	sum.Store(-1)
+	if sum.Load() != -1 {
+		t.Fatal("expect -1 but get ", sum.Load())
+	}
+}
@@ -4,20 +4,29 @@ import (
 	"sync/atomic"
 )
 
+// Bool：以 int32 实现的原子布尔值，零值为 false
 type Bool struct {
 	b int32
 }
 
 // Set：将 a.b 置位为 1
 func (a *Bool) Set(b bool) bool {
-	var newV int32
-	if b {
-		newV = 1
-	}
-	return atomic.SwapInt32(&a.b, newV) == 1
+	return atomic.SwapInt32(&a.b, boolToInt32(b)) == 1
 }
 
 // Get：原子判断 a.b 是否为 1
 func (a *Bool) Get() bool {
 	return atomic.LoadInt32(&a.b) == 1
 }
+
+// CompareAndSwap：a 的值为 old 时将其设置为 new，返回是否设置成功
+func (a *Bool) CompareAndSwap(old, new bool) bool {
+	return atomic.CompareAndSwapInt32(&a.b, boolToInt32(old), boolToInt32(new))
+}
+
+func boolToInt32(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
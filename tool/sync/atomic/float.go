@@ -0,0 +1,38 @@
+package atomic
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// Float64：提供原子操作，以 math.Float64bits 的形式存储，可用于吞吐量、延迟的滑动平均等
+type Float64 struct {
+	v uint64
+}
+
+// Add：增加 delta，返回新值。通过 CAS 循环实现，并发调用时不会丢失更新
+func (a *Float64) Add(delta float64) float64 {
+	for {
+		old := atomic.LoadUint64(&a.v)
+		new := math.Float64frombits(old) + delta
+		if atomic.CompareAndSwapUint64(&a.v, old, math.Float64bits(new)) {
+			return new
+		}
+	}
+}
+
+// Store：设置值
+func (a *Float64) Store(f float64) {
+	atomic.StoreUint64(&a.v, math.Float64bits(f))
+}
+
+// Load：获取值
+func (a *Float64) Load() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&a.v))
+}
+
+// CompareAndSwap：值为 old 时将其设置为 new，返回是否设置成功。比较的是二进制表示，
+// 因此 NaN 可以与自身比较成功，而 0 与 -0 不相等
+func (a *Float64) CompareAndSwap(old, new float64) bool {
+	return atomic.CompareAndSwapUint64(&a.v, math.Float64bits(old), math.Float64bits(new))
+}
@@ -41,3 +41,33 @@ func (a *Int64) Swap(i int64) int64 {
 func (a *Int64) Get() int64 {
 	return atomic.LoadInt64(&a.v)
 }
+
+// CompareAndSwap：值为 old 时将其设置为 new，返回是否设置成功
+func (a *Int64) CompareAndSwap(old, new int64) bool {
+	return atomic.CompareAndSwapInt64(&a.v, old, new)
+}
+
+// Uint64：提供原子操作，用于只增不减的计数，如收发字节数
+type Uint64 struct {
+	v uint64
+}
+
+// Add：计数增加 i ，返回新值。减操作可以为 ：Add(^uint64(0))
+func (a *Uint64) Add(i uint64) uint64 {
+	return atomic.AddUint64(&a.v, i)
+}
+
+// Swap：交换值，并返回原来的值
+func (a *Uint64) Swap(i uint64) uint64 {
+	return atomic.SwapUint64(&a.v, i)
+}
+
+// Get：获取值
+func (a *Uint64) Get() uint64 {
+	return atomic.LoadUint64(&a.v)
+}
+
+// CompareAndSwap：值为 old 时将其设置为 new，返回是否设置成功
+func (a *Uint64) CompareAndSwap(old, new uint64) bool {
+	return atomic.CompareAndSwapUint64(&a.v, old, new)
+}